@@ -2,15 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
 	_ "github.com/lib/pq"
 
 	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/billing"
+	"github.com/amunx/backend/internal/live"
 	"github.com/amunx/backend/internal/smartinbox"
+	"github.com/amunx/backend/internal/uploads"
+	"github.com/amunx/backend/internal/worker"
 	"github.com/amunx/backend/internal/worker/audio"
 	smartinboxworker "github.com/amunx/backend/internal/worker/smartinbox"
 	"github.com/amunx/backend/pkg/logger"
@@ -55,23 +59,141 @@ func main() {
 		Queue:   deps.Queue,
 		Logger:  log.With().Str("processor", "audio").Logger(),
 		CDNBase: deps.Config.CDNBaseURL,
+
+		STTProOnly:          deps.Config.STTProOnly,
+		WaveformBuckets:     deps.Config.WaveformBuckets,
+		WaveformHiResPerSec: deps.Config.WaveformHiResPerSec,
+		Limits: map[string]audio.IngestLimits{
+			"free": {MaxBytes: deps.Config.IngestMaxBytesFree, MaxDuration: deps.Config.IngestMaxDurationFree},
+			"pro":  {MaxBytes: deps.Config.IngestMaxBytesPro, MaxDuration: deps.Config.IngestMaxDurationPro},
+		},
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := generator.Run(ctx); err != nil && err != context.Canceled {
-			log.Error().Err(err).Msg("smart inbox generator exited")
+	var (
+		transcriber worker.Transcriber
+		clipper     worker.Clipper
+		embedder    worker.Embedder
+	)
+	if deps.Config.FeatureProSTT {
+		whisper, err := worker.NewWhisperTranscriber(worker.WhisperConfig{
+			BinaryPath:   deps.Config.STTBinaryPath,
+			ModelPath:    deps.Config.STTModelPath,
+			Language:     deps.Config.STTLanguage,
+			Threads:      deps.Config.STTThreads,
+			ChunkSeconds: deps.Config.STTChunkSeconds,
+			TempDir:      deps.Config.LocalMediaPath,
+		}, deps.Storage, log.With().Str("processor", "whisper").Logger())
+		switch {
+		case err == nil:
+			transcriber = whisper
+		case deps.Config.Environment == "development":
+			log.Warn().Err(err).Msg("whisper unavailable, using mock transcriber")
+			transcriber = &worker.MockTranscriber{}
+		default:
+			log.Warn().Err(err).Msg("whisper unavailable, transcription disabled")
 		}
-	}()
+	}
+	// Clip and embedding generation only have mock implementations so far;
+	// keep them out of non-development environments.
+	if deps.Config.Environment == "development" {
+		clipper = &worker.MockClipper{}
+		embedder = &worker.MockEmbedder{}
+	}
+	pipeline := worker.NewPipeline(
+		log.With().Str("processor", "pipeline").Logger(),
+		deps.DB,
+		deps.Queue,
+		transcriber,
+		&worker.ExtractiveSummarizer{Corpus: &worker.SQLTermCorpus{DB: deps.DB}},
+		clipper,
+		embedder,
+	)
+
+	supervisor := worker.NewSupervisor(worker.SupervisorConfig{
+		PollInterval:      deps.Config.WorkerPollInterval,
+		DrainTimeout:      deps.Config.WorkerDrainTimeout,
+		HeartbeatInterval: deps.Config.WorkerHeartbeatInterval,
+		LeaseInterval:     deps.Config.QueueVisibilityTimeout / 3,
+	}, deps.Queue, deps.Redis, log.With().Str("component", "supervisor").Logger())
 
-	if err := processor.Run(ctx, deps.Config.WorkerPollInterval); err != nil && err != context.Canceled {
-		log.Error().Err(err).Msg("audio processor exited with error")
+	if err := processor.Register(supervisor, deps.Config.WorkerAudioConcurrency, deps.Config.WorkerFinalizeConcurrency); err != nil {
+		log.Fatal().Err(err).Msg("failed to register audio processor")
 	}
+	pipeline.Register(supervisor, deps.Config.WorkerPipelineConcurrency)
+	if deps.Config.FeatureAudiogramExport {
+		audiograms := worker.NewAudiogramWorker(worker.NewObjectStorage(deps.Storage), deps.Config.LocalMediaPath)
+		audiograms.Register(supervisor, deps.Config.WorkerAudiogramConcurrency)
+	}
+	supervisor.Every("smart_inbox", smartinboxworker.DefaultInterval, generator.Generate)
+
+	uploadSessions := uploads.NewSessions(deps.DB, deps.Storage, uploads.Options{
+		PartSize:   deps.Config.UploadPartSize,
+		SessionTTL: deps.Config.UploadSessionTTL,
+	})
+	supervisor.Every("upload_reaper", deps.Config.UploadReaperInterval, func(ctx context.Context) error {
+		reaped, err := uploadSessions.ReapExpired(ctx, time.Now())
+		if reaped > 0 {
+			log.Info().Int("sessions", reaped).Msg("aborted abandoned upload sessions")
+		}
+		return err
+	})
 
-	cancel()
-	wg.Wait()
+	liveTracker := live.NewTracker(deps.DB, deps.Redis, deps.Queue, live.Options{
+		HostGrace:   deps.Config.LiveHostGrace,
+		MaxDuration: deps.Config.LiveMaxDuration,
+		Recorder:    deps.LiveRecorder,
+	})
+	supervisor.Every("live_reaper", deps.Config.LiveReaperInterval, func(ctx context.Context) error {
+		ended, err := liveTracker.ReapAbandoned(ctx)
+		if ended > 0 {
+			log.Info().Int("sessions", ended).Msg("ended abandoned live sessions")
+		}
+		return err
+	})
+
+	liveSchedules := live.NewSchedules(deps.DB, deps.Push, deps.Config.LiveReminderLead)
+	supervisor.Every("live_reminders", deps.Config.LiveReminderInterval, func(ctx context.Context) error {
+		sent, err := liveSchedules.SendReminders(ctx)
+		if sent > 0 {
+			log.Info().Int("schedules", sent).Msg("sent live session reminders")
+		}
+		return err
+	})
+
+	billingService := billing.NewService(deps.DB)
+	supervisor.Every("entitlement_expiry", deps.Config.BillingExpirySweepInterval, func(ctx context.Context) error {
+		for {
+			sweep, err := billingService.ExpireEntitlements(ctx, time.Now(), billing.DefaultExpiryBatch)
+			if err != nil {
+				return err
+			}
+			if sweep.Expired > 0 {
+				log.Info().Int("entitlements", sweep.Expired).Int("downgraded", len(sweep.Downgraded)).Msg("expired billing entitlements")
+			}
+			if sweep.Expired < billing.DefaultExpiryBatch {
+				return nil
+			}
+		}
+	})
+
+	if deps.MonoPay != nil {
+		supervisor.Every("monopay_renewals", deps.Config.MonoPayRenewalInterval, func(ctx context.Context) error {
+			now := time.Now()
+			charged, chargeErr := deps.MonoPayRecurring.ChargeDue(ctx, now)
+			if charged > 0 {
+				log.Info().Int("subscriptions", charged).Msg("charged monopay renewals")
+			}
+			settled, err := deps.MonoPayRecurring.Reconcile(ctx, now)
+			if settled > 0 {
+				log.Info().Int("invoices", settled).Msg("reconciled monopay invoices")
+			}
+			return errors.Join(chargeErr, err)
+		})
+	}
+
+	if err := supervisor.Run(ctx); err != nil && err != context.Canceled {
+		log.Error().Err(err).Msg("worker supervisor exited with error")
+	}
 
 	log.Info().Msg("worker exiting")
 }
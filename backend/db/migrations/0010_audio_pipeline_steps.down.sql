@@ -0,0 +1 @@
+DROP TABLE IF EXISTS audio_pipeline_steps;
@@ -0,0 +1,11 @@
+CREATE TABLE audio_pipeline_steps (
+  audio_id UUID NOT NULL REFERENCES audio_items(id) ON DELETE CASCADE,
+  step TEXT NOT NULL CHECK (step IN ('transcribe','summarize','clips','embeddings')),
+  status TEXT NOT NULL CHECK (status IN ('running','succeeded','failed','skipped')),
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (audio_id, step)
+);
+
+CREATE INDEX audio_pipeline_steps_status_idx ON audio_pipeline_steps(status, updated_at DESC);
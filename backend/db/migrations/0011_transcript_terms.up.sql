@@ -0,0 +1,8 @@
+-- Distinct terms per transcript; document frequencies for TF-IDF keywords.
+CREATE TABLE transcript_terms (
+  audio_id UUID NOT NULL REFERENCES audio_items(id) ON DELETE CASCADE,
+  term TEXT NOT NULL,
+  PRIMARY KEY (audio_id, term)
+);
+
+CREATE INDEX transcript_terms_term_idx ON transcript_terms(term);
@@ -0,0 +1,17 @@
+-- Multipart upload sessions for large recordings; abandoned ones are reaped.
+CREATE TABLE upload_sessions (
+  id UUID PRIMARY KEY,
+  owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  storage_key TEXT NOT NULL,
+  upload_id TEXT NOT NULL,
+  content_type TEXT NOT NULL,
+  size_bytes BIGINT,
+  part_size BIGINT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active','completed','aborted')),
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX upload_sessions_owner_idx ON upload_sessions(owner_id, created_at DESC);
+CREATE INDEX upload_sessions_expiry_idx ON upload_sessions(expires_at) WHERE status = 'active';
@@ -0,0 +1,3 @@
+ALTER TABLE audio_items
+  DROP COLUMN IF EXISTS processing_error_detail,
+  DROP COLUMN IF EXISTS processing_error;
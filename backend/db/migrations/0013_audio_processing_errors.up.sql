@@ -0,0 +1,4 @@
+-- Machine-readable reason an upload was rejected or failed processing.
+ALTER TABLE audio_items
+  ADD COLUMN processing_error TEXT,
+  ADD COLUMN processing_error_detail TEXT;
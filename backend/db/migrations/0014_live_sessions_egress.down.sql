@@ -0,0 +1,3 @@
+ALTER TABLE live_sessions
+  DROP COLUMN IF EXISTS egress_ended_at,
+  DROP COLUMN IF EXISTS egress_id;
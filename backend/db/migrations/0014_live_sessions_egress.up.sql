@@ -0,0 +1,4 @@
+-- Server-side egress recording; recording_key is derived from the session.
+ALTER TABLE live_sessions
+  ADD COLUMN egress_id TEXT,
+  ADD COLUMN egress_ended_at TIMESTAMPTZ;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS audio_items_live_session_unique;
+
+ALTER TABLE audio_items
+  DROP COLUMN IF EXISTS live_session_id;
@@ -0,0 +1,7 @@
+-- Replays of live sessions are audio items; at most one per session.
+ALTER TABLE audio_items
+  ADD COLUMN live_session_id UUID REFERENCES live_sessions(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX audio_items_live_session_unique
+  ON audio_items(live_session_id)
+  WHERE live_session_id IS NOT NULL;
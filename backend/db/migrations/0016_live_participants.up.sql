@@ -0,0 +1,17 @@
+-- Per-session roster of room roles, raised hands and removals.
+CREATE TABLE live_participants(
+  session_id UUID NOT NULL REFERENCES live_sessions(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  role TEXT NOT NULL DEFAULT 'listener'
+    CHECK (role IN ('host','cohost','moderator','speaker','listener')),
+  hand_raised_at TIMESTAMPTZ,
+  muted BOOLEAN NOT NULL DEFAULT FALSE,
+  removed_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (session_id, user_id)
+);
+
+CREATE INDEX live_participants_hands_idx
+  ON live_participants(session_id, hand_raised_at)
+  WHERE hand_raised_at IS NOT NULL AND removed_at IS NULL;
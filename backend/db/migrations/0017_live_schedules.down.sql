@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS live_schedule_rsvps;
+DROP TABLE IF EXISTS live_schedules;
@@ -0,0 +1,32 @@
+-- Live sessions announced ahead of time. session_id is set once the host
+-- starts the room; the reminded_* columns record which reminders went out.
+CREATE TABLE live_schedules(
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  host_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  topic_id UUID REFERENCES topics(id) ON DELETE SET NULL,
+  title TEXT NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  scheduled_at TIMESTAMPTZ NOT NULL,
+  session_id UUID REFERENCES live_sessions(id) ON DELETE SET NULL,
+  canceled_at TIMESTAMPTZ,
+  reminded_soon_at TIMESTAMPTZ,
+  reminded_start_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX live_schedules_upcoming_idx
+  ON live_schedules(scheduled_at)
+  WHERE canceled_at IS NULL AND session_id IS NULL;
+
+CREATE INDEX live_schedules_pending_start_idx
+  ON live_schedules(scheduled_at)
+  WHERE canceled_at IS NULL AND reminded_start_at IS NULL;
+
+CREATE TABLE live_schedule_rsvps(
+  schedule_id UUID NOT NULL REFERENCES live_schedules(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (schedule_id, user_id)
+);
+
+CREATE INDEX live_schedule_rsvps_user_idx ON live_schedule_rsvps(user_id);
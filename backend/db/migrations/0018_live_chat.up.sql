@@ -0,0 +1,18 @@
+-- Live chat, questions and reactions, kept for the replay timeline.
+-- offset_ms is the time since the session started.
+CREATE TABLE live_chat_messages(
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  session_id UUID NOT NULL REFERENCES live_sessions(id) ON DELETE CASCADE,
+  user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  kind TEXT NOT NULL CHECK (kind IN ('message','question','reaction')),
+  body TEXT NOT NULL,
+  offset_ms BIGINT NOT NULL DEFAULT 0,
+  flagged BOOLEAN NOT NULL DEFAULT FALSE,
+  pinned_at TIMESTAMPTZ,
+  answered_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX live_chat_messages_session_idx
+  ON live_chat_messages(session_id, created_at)
+  WHERE NOT flagged;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS live_translation_usage;
+DROP TABLE IF EXISTS live_translations;
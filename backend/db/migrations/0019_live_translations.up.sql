@@ -0,0 +1,28 @@
+-- Live translation settings per session, controlled by the host.
+CREATE TABLE live_translations(
+  session_id UUID PRIMARY KEY REFERENCES live_sessions(id) ON DELETE CASCADE,
+  host_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  source_lang TEXT NOT NULL,
+  target_langs TEXT[] NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT TRUE,
+  disabled_reason TEXT CHECK (disabled_reason IN ('host','budget')),
+  cost_usd NUMERIC(12,4) NOT NULL DEFAULT 0,
+  audio_seconds BIGINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Usage reported by the translator agent. report_id makes retried reports
+-- idempotent.
+CREATE TABLE live_translation_usage(
+  id BIGSERIAL PRIMARY KEY,
+  session_id UUID NOT NULL REFERENCES live_translations(session_id) ON DELETE CASCADE,
+  report_id TEXT NOT NULL,
+  cost_usd NUMERIC(12,4) NOT NULL CHECK (cost_usd >= 0),
+  audio_seconds INT NOT NULL CHECK (audio_seconds >= 0),
+  reported_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (session_id, report_id)
+);
+
+CREATE INDEX live_translation_usage_window_idx
+  ON live_translation_usage(session_id, reported_at);
@@ -0,0 +1,18 @@
+-- Caption segments streamed by the live agent. start_ms/end_ms are offsets
+-- from the session start, on the same timeline as live_chat_messages.
+-- segment_id is the agent's id, so resent batches are ignored.
+CREATE TABLE live_caption_segments(
+  id BIGSERIAL PRIMARY KEY,
+  session_id UUID NOT NULL REFERENCES live_sessions(id) ON DELETE CASCADE,
+  segment_id TEXT NOT NULL,
+  speaker TEXT NOT NULL DEFAULT '',
+  lang TEXT NOT NULL,
+  start_ms BIGINT NOT NULL CHECK (start_ms >= 0),
+  end_ms BIGINT NOT NULL CHECK (end_ms >= start_ms),
+  text TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (session_id, segment_id)
+);
+
+CREATE INDEX live_caption_segments_timeline_idx
+  ON live_caption_segments(session_id, start_ms);
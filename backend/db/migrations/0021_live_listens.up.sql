@@ -0,0 +1,16 @@
+-- One row per connection of a listener to a live room, from LiveKit
+-- participant webhooks. identity is the LiveKit identity: a user id, or a
+-- throwaway id for anonymous listeners. left_at is NULL while connected.
+CREATE TABLE live_listens(
+  id BIGSERIAL PRIMARY KEY,
+  session_id UUID NOT NULL REFERENCES live_sessions(id) ON DELETE CASCADE,
+  identity TEXT NOT NULL,
+  joined_at TIMESTAMPTZ NOT NULL,
+  left_at TIMESTAMPTZ,
+  CHECK (left_at IS NULL OR left_at >= joined_at)
+);
+
+CREATE INDEX live_listens_session_idx ON live_listens(session_id, joined_at);
+CREATE UNIQUE INDEX live_listens_open_idx
+  ON live_listens(session_id, identity)
+  WHERE left_at IS NULL;
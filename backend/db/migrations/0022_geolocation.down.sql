@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS circles_local_country_idx;
+ALTER TABLE circles
+  DROP COLUMN IF EXISTS lon,
+  DROP COLUMN IF EXISTS lat;
+
+DROP INDEX IF EXISTS profiles_location_country_idx;
+ALTER TABLE profiles
+  DROP COLUMN IF EXISTS location_updated_at,
+  DROP COLUMN IF EXISTS location_source,
+  DROP COLUMN IF EXISTS location_lon,
+  DROP COLUMN IF EXISTS location_lat,
+  DROP COLUMN IF EXISTS location_continent,
+  DROP COLUMN IF EXISTS location_country,
+  DROP COLUMN IF EXISTS location_region,
+  DROP COLUMN IF EXISTS location_city;
@@ -0,0 +1,23 @@
+-- Opt-in coarse location on the profile: a city the user picked or the one
+-- their IP resolved to when they shared it. Coordinates are the city's,
+-- rounded, never the user's own position.
+ALTER TABLE profiles
+  ADD COLUMN location_city TEXT,
+  ADD COLUMN location_region TEXT,
+  ADD COLUMN location_country TEXT,
+  ADD COLUMN location_continent TEXT,
+  ADD COLUMN location_lat DOUBLE PRECISION,
+  ADD COLUMN location_lon DOUBLE PRECISION,
+  ADD COLUMN location_source TEXT CHECK (location_source IN ('ip', 'manual')),
+  ADD COLUMN location_updated_at TIMESTAMPTZ;
+
+CREATE INDEX profiles_location_country_idx
+  ON profiles(location_country)
+  WHERE location_country IS NOT NULL;
+
+-- Local circles carry their city's coordinates for "near me" discovery.
+ALTER TABLE circles
+  ADD COLUMN lat DOUBLE PRECISION,
+  ADD COLUMN lon DOUBLE PRECISION;
+
+CREATE INDEX circles_local_country_idx ON circles(country) WHERE is_local;
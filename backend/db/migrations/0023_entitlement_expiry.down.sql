@@ -0,0 +1 @@
+DROP INDEX IF EXISTS billing_entitlements_expiry_idx;
@@ -0,0 +1,4 @@
+-- Lets the expiry sweeper find active entitlements past expires_at.
+CREATE INDEX billing_entitlements_expiry_idx
+  ON billing_entitlements(expires_at)
+  WHERE status = 'active' AND expires_at IS NOT NULL;
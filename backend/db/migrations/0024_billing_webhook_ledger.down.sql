@@ -0,0 +1,2 @@
+ALTER TABLE user_subscriptions DROP COLUMN IF EXISTS last_event_at;
+DROP TABLE IF EXISTS billing_webhook_events;
@@ -0,0 +1,25 @@
+-- One row per provider webhook event, so redeliveries are recognised and
+-- failed events can be replayed. update_json is the normalized
+-- billing.SubscriptionUpdate the event produced.
+CREATE TABLE billing_webhook_events (
+  id BIGSERIAL PRIMARY KEY,
+  provider billing_provider NOT NULL,
+  event_id TEXT NOT NULL,
+  event_type TEXT NOT NULL DEFAULT '',
+  occurred_at TIMESTAMPTZ,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending','processed','skipped','failed')),
+  attempts INT NOT NULL DEFAULT 1,
+  last_error TEXT,
+  update_json JSONB NOT NULL,
+  received_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  processed_at TIMESTAMPTZ,
+  UNIQUE (provider, event_id)
+);
+
+CREATE INDEX billing_webhook_events_failed_idx
+  ON billing_webhook_events(received_at)
+  WHERE status = 'failed';
+
+-- Provider timestamp of the newest event applied to a subscription; older
+-- events arriving late are skipped.
+ALTER TABLE user_subscriptions ADD COLUMN last_event_at TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS monopay_recurring;
+DROP TABLE IF EXISTS monopay_invoices;
@@ -0,0 +1,42 @@
+-- Every MonoPay invoice we create, so invoices whose webhook never arrives
+-- can be reconciled by polling.
+CREATE TABLE monopay_invoices (
+  invoice_id TEXT PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  product_code TEXT NOT NULL,
+  kind TEXT NOT NULL CHECK (kind IN ('checkout','renewal')),
+  status TEXT NOT NULL DEFAULT 'created',
+  wallet_id TEXT NOT NULL,
+  -- Start of the period the invoice pays for; NULL for checkouts, which
+  -- start when paid.
+  period_start TIMESTAMPTZ,
+  failure_reason TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  settled_at TIMESTAMPTZ
+);
+
+CREATE INDEX monopay_invoices_open_idx
+  ON monopay_invoices(created_at)
+  WHERE settled_at IS NULL;
+
+-- One row per MonoPay subscription renewed by charging the saved card.
+CREATE TABLE monopay_recurring (
+  subscription_ref TEXT PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  product_code TEXT NOT NULL,
+  wallet_id TEXT NOT NULL,
+  card_token TEXT,
+  status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active','canceled')),
+  period_end TIMESTAMPTZ NOT NULL,
+  next_charge_at TIMESTAMPTZ,
+  failed_attempts INT NOT NULL DEFAULT 0,
+  pending_invoice_id TEXT,
+  last_error TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX monopay_recurring_due_idx
+  ON monopay_recurring(next_charge_at)
+  WHERE status = 'active' AND pending_invoice_id IS NULL;
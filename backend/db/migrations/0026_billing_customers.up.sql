@@ -0,0 +1,18 @@
+-- Provider customer ids per user, so webhooks map back to users without
+-- relying on metadata and portal sessions can be opened.
+CREATE TABLE billing_customers (
+  provider billing_provider NOT NULL,
+  external_customer_id TEXT NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (provider, external_customer_id)
+);
+
+CREATE INDEX billing_customers_user_idx ON billing_customers(user_id, provider, created_at DESC);
+
+INSERT INTO billing_customers (provider, external_customer_id, user_id, created_at)
+SELECT DISTINCT ON (provider, external_customer_id) provider, external_customer_id, user_id, created_at
+FROM user_subscriptions
+WHERE external_customer_id IS NOT NULL AND external_customer_id <> ''
+ORDER BY provider, external_customer_id, created_at
+ON CONFLICT DO NOTHING;
@@ -0,0 +1 @@
+-- Postgres cannot drop an enum value; 'internal' stays in billing_provider.
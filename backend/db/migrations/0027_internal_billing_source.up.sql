@@ -0,0 +1,4 @@
+-- First-party grants (promo codes, trials, staff grants) are entitlements
+-- with their own source. Kept apart from the tables that use it because a
+-- new enum value cannot be used in the transaction that adds it.
+ALTER TYPE billing_provider ADD VALUE IF NOT EXISTS 'internal';
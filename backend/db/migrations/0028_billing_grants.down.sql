@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS billing_grants;
+DROP TABLE IF EXISTS billing_promo_codes;
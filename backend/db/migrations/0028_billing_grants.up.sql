@@ -0,0 +1,32 @@
+CREATE TABLE billing_promo_codes (
+  code TEXT PRIMARY KEY,
+  entitlement_code TEXT NOT NULL DEFAULT 'pro',
+  duration_days INT NOT NULL CHECK (duration_days > 0),
+  -- NULL allows unlimited redemptions.
+  max_redemptions INT CHECK (max_redemptions > 0),
+  redemptions INT NOT NULL DEFAULT 0,
+  expires_at TIMESTAMPTZ,
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One row per first-party grant; the access itself is the matching
+-- billing_entitlements row with source 'internal'.
+CREATE TABLE billing_grants (
+  id BIGSERIAL PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL CHECK (kind IN ('promo','trial','staff','gift')),
+  entitlement_code TEXT NOT NULL,
+  starts_at TIMESTAMPTZ NOT NULL,
+  -- NULL grants access with no end.
+  expires_at TIMESTAMPTZ,
+  promo_code TEXT REFERENCES billing_promo_codes(code),
+  granted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  note TEXT,
+  revoked_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX billing_grants_user_idx ON billing_grants(user_id, entitlement_code) WHERE revoked_at IS NULL;
+CREATE UNIQUE INDEX billing_grants_promo_once_idx ON billing_grants(user_id, promo_code) WHERE promo_code IS NOT NULL;
+CREATE UNIQUE INDEX billing_grants_trial_once_idx ON billing_grants(user_id) WHERE kind = 'trial';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS billing_webhook_events_pending_idx;
+ALTER TABLE billing_webhook_events DROP COLUMN IF EXISTS claimed_at;
@@ -0,0 +1,9 @@
+-- When an attempt at an event started. A pending event whose attempt is
+-- older than the claim lease was abandoned (the process died mid-attempt)
+-- and may be claimed again by a redelivery or an admin replay.
+ALTER TABLE billing_webhook_events
+  ADD COLUMN claimed_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX billing_webhook_events_pending_idx
+  ON billing_webhook_events(claimed_at)
+  WHERE status = 'pending';
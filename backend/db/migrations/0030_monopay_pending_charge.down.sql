@@ -0,0 +1,3 @@
+ALTER TABLE monopay_recurring
+  DROP COLUMN IF EXISTS pending_charge_at,
+  DROP COLUMN IF EXISTS pending_charge_ref;
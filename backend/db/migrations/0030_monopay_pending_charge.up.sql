@@ -0,0 +1,6 @@
+-- The reference of a renewal charge about to be sent to MonoPay. It is
+-- stored before the charge so a worker that dies after MonoPay accepted it
+-- finds the charge by reference instead of charging the card again.
+ALTER TABLE monopay_recurring
+  ADD COLUMN pending_charge_ref TEXT,
+  ADD COLUMN pending_charge_at TIMESTAMPTZ;
@@ -0,0 +1,4 @@
+ALTER TABLE billing_entitlements
+  DROP COLUMN IF EXISTS provider_expires_at,
+  DROP COLUMN IF EXISTS provider_status,
+  DROP COLUMN IF EXISTS provider_source;
@@ -0,0 +1,7 @@
+-- While a first-party grant is stacked on paid access, the entitlement row
+-- carries the grant and these columns keep the provider's own state, so
+-- revoking the grant hands access back to the provider.
+ALTER TABLE billing_entitlements
+  ADD COLUMN provider_source billing_provider,
+  ADD COLUMN provider_status TEXT CHECK (provider_status IN ('active','revoked')),
+  ADD COLUMN provider_expires_at TIMESTAMPTZ;
@@ -5,9 +5,11 @@ INSERT INTO circles (
   description,
   is_local,
   city,
-  country
+  country,
+  lat,
+  lon
 ) VALUES (
-  $1, $2, $3, $4, $5, $6
+  $1, $2, $3, $4, $5, $6, $7, $8
 ) RETURNING *;
 
 -- name: GetCircleByID :one
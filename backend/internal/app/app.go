@@ -2,16 +2,23 @@ package app
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
 
+	"github.com/livekit/protocol/livekit"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 
 	"github.com/amunx/backend/internal/auth"
+	"github.com/amunx/backend/internal/billing"
 	"github.com/amunx/backend/internal/email"
+	"github.com/amunx/backend/internal/geo"
 	"github.com/amunx/backend/internal/integrations/monopay"
+	"github.com/amunx/backend/internal/integrations/stripe"
+	"github.com/amunx/backend/internal/live"
 	"github.com/amunx/backend/internal/push"
 	"github.com/amunx/backend/internal/queue"
 	"github.com/amunx/backend/internal/storage"
@@ -30,7 +37,20 @@ type App struct {
 	Email       email.Sender
 	Push        push.Sender
 	MonoPay     *monopay.Client
+	Stripe      *stripe.Client
 	ShutdownFns []func(context.Context) error
+
+	// LiveRecorder is nil when live recording is disabled or LiveKit is
+	// not configured.
+	LiveRecorder live.Recorder
+	// LiveRooms is nil when LiveKit server credentials are not configured.
+	LiveRooms live.RoomAdmin
+	// Geo is nil when no city database is configured; lookups then find
+	// nothing.
+	Geo *geo.CityDB
+	// MonoPayRecurring tracks MonoPay invoices and renews subscriptions; it
+	// charges and polls nothing when MonoPay is not configured.
+	MonoPayRecurring *billing.MonoPayRecurring
 }
 
 // Close releases resources gracefully.
@@ -92,25 +112,17 @@ func Build(ctx context.Context, cfg Config, log zerolog.Logger) (*App, error) {
 	}
 	jwtManager := auth.NewJWTManager(cfg.JWTAccessSecret, cfg.JWTRefreshSecret, cfg.JWTAccessTTL, cfg.JWTRefreshTTL)
 
-	store, err := storage.NewS3Client(storage.S3Config{
-		Endpoint:  cfg.StorageEndpoint,
-		Region:    cfg.StorageRegion,
-		Bucket:    cfg.StorageBucket,
-		AccessKey: cfg.StorageAccess,
-		SecretKey: cfg.StorageSecret,
-	})
+	store, err := newStorageClient(cfg)
 	if err != nil {
-		if !errors.Is(err, storage.ErrIncompleteConfig) {
-			return nil, fmt.Errorf("storage client: %w", err)
-		}
-		if cfg.Environment == "development" {
-			store = storage.NewNoopClient()
-		} else {
-			return nil, fmt.Errorf("storage client: %w", err)
-		}
+		return nil, fmt.Errorf("storage client: %w", err)
 	}
 
-	queueClient := queue.NewRedisStream(redisClient)
+	queueClient := queue.NewRedisStream(redisClient, queue.Options{
+		MaxAttempts:       cfg.QueueMaxAttempts,
+		VisibilityTimeout: cfg.QueueVisibilityTimeout,
+		BackoffBase:       cfg.QueueBackoffBase,
+		BackoffMax:        cfg.QueueBackoffMax,
+	})
 
 	emailSender := email.NewSender(email.Options{
 		Host:     cfg.SMTPHost,
@@ -135,6 +147,46 @@ func Build(ctx context.Context, cfg Config, log zerolog.Logger) (*App, error) {
 		})
 	}
 
+	var stripeClient *stripe.Client
+	if cfg.StripeAPIKey != "" {
+		stripeClient = stripe.NewClient(stripe.Config{
+			BaseURL: cfg.StripeAPIBaseURL,
+			APIKey:  cfg.StripeAPIKey,
+			Logger:  log,
+		})
+	}
+
+	var liveRecorder live.Recorder
+	if cfg.FeatureLiveRecording && cfg.LiveKitURL != "" && cfg.LiveKitAPIKey != "" && cfg.LiveKitAPISecret != "" {
+		recorder, err := live.NewEgressRecorder(live.EgressConfig{
+			URL:       cfg.LiveKitURL,
+			APIKey:    cfg.LiveKitAPIKey,
+			APISecret: cfg.LiveKitAPISecret,
+			Upload:    egressUpload(cfg, store),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("live recorder: %w", err)
+		}
+		liveRecorder = recorder
+	}
+
+	var liveRooms live.RoomAdmin
+	if cfg.LiveKitURL != "" && cfg.LiveKitAPIKey != "" && cfg.LiveKitAPISecret != "" {
+		rooms, err := live.NewLiveKitRooms(cfg.LiveKitURL, cfg.LiveKitAPIKey, cfg.LiveKitAPISecret, nil)
+		if err != nil {
+			return nil, fmt.Errorf("live rooms: %w", err)
+		}
+		liveRooms = rooms
+	}
+
+	var cityDB *geo.CityDB
+	if cfg.GeoIPCityDB != "" {
+		cityDB, err = geo.OpenCityDB(cfg.GeoIPCityDB)
+		if err != nil {
+			return nil, fmt.Errorf("geoip city database: %w", err)
+		}
+	}
+
 	return &App{
 		Config:     cfg,
 		DB:         db,
@@ -146,5 +198,80 @@ func Build(ctx context.Context, cfg Config, log zerolog.Logger) (*App, error) {
 		Email:      emailSender,
 		Push:       pushSender,
 		MonoPay:    monoClient,
+		Stripe:     stripeClient,
+
+		LiveRecorder: liveRecorder,
+		LiveRooms:    liveRooms,
+		Geo:          cityDB,
+
+		MonoPayRecurring: billing.NewMonoPayRecurring(billing.NewService(db), monoClient, billing.MonoPayOptions{
+			RenewalLead:    cfg.MonoPayRenewalLead,
+			Dunning:        cfg.MonoPayDunningSchedule,
+			ReconcileAfter: cfg.MonoPayReconcileAfter,
+			WebhookURL:     cfg.MonoPayWebhookURL,
+			RedirectURL:    cfg.MonoPayReturnURL,
+		}),
 	}, nil
 }
+
+// egressUpload points egress at the S3 bucket backing store. Local storage
+// is not reachable from the egress service, which then uses its own
+// storage configuration.
+func egressUpload(cfg Config, store storage.Client) *livekit.S3Upload {
+	if _, local := store.(*storage.FSClient); local {
+		return nil
+	}
+	return &livekit.S3Upload{
+		AccessKey:      cfg.StorageAccess,
+		Secret:         cfg.StorageSecret,
+		Region:         cfg.StorageRegion,
+		Endpoint:       cfg.StorageEndpoint,
+		Bucket:         cfg.StorageBucket,
+		ForcePathStyle: cfg.StorageEndpoint != "",
+	}
+}
+
+// newStorageClient picks the object storage backend. Without a configured
+// driver, S3 is preferred and development falls back to local disk.
+func newStorageClient(cfg Config) (storage.Client, error) {
+	fsClient := func() (storage.Client, error) {
+		secret := cfg.StorageSigningSecret
+		if secret == "" && cfg.Environment == "development" {
+			// Only the API signs object URLs, so a per-process secret works;
+			// URLs handed out before a restart simply stop verifying.
+			raw := make([]byte, 32)
+			if _, err := rand.Read(raw); err != nil {
+				return nil, err
+			}
+			secret = hex.EncodeToString(raw)
+		}
+		return storage.NewFSClient(storage.FSConfig{
+			Root:          cfg.LocalMediaPath,
+			BaseURL:       cfg.PublicAPIURL,
+			SigningSecret: secret,
+		})
+	}
+
+	switch cfg.StorageDriver {
+	case "fs":
+		return fsClient()
+	case "s3", "":
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+
+	store, err := storage.NewS3Client(storage.S3Config{
+		Endpoint:  cfg.StorageEndpoint,
+		Region:    cfg.StorageRegion,
+		Bucket:    cfg.StorageBucket,
+		AccessKey: cfg.StorageAccess,
+		SecretKey: cfg.StorageSecret,
+	})
+	if err == nil || !errors.Is(err, storage.ErrIncompleteConfig) {
+		return store, err
+	}
+	if cfg.StorageDriver == "" && cfg.Environment == "development" {
+		return fsClient()
+	}
+	return nil, err
+}
@@ -25,16 +25,55 @@ type Config struct {
 	RedisDB       int           `envconfig:"REDIS_DB" default:"0"`
 	RedisTimeout  time.Duration `envconfig:"REDIS_TIMEOUT" default:"5s"`
 
+	QueueMaxAttempts       int           `envconfig:"QUEUE_MAX_ATTEMPTS" default:"3"`
+	QueueVisibilityTimeout time.Duration `envconfig:"QUEUE_VISIBILITY_TIMEOUT" default:"5m"`
+	QueueBackoffBase       time.Duration `envconfig:"QUEUE_BACKOFF_BASE" default:"5s"`
+	QueueBackoffMax        time.Duration `envconfig:"QUEUE_BACKOFF_MAX" default:"10m"`
+
 	StorageEndpoint string `envconfig:"STORAGE_ENDPOINT" default:""`
 	StorageRegion   string `envconfig:"STORAGE_REGION" default:"auto"`
 	StorageBucket   string `envconfig:"STORAGE_BUCKET" default:"amunx"`
 	StorageAccess   string `envconfig:"STORAGE_ACCESS_KEY" default:""`
 	StorageSecret   string `envconfig:"STORAGE_SECRET_KEY" default:""`
+	// StorageDriver selects "s3" or "fs". When empty, S3 is used if it is
+	// configured and development falls back to the local filesystem.
+	StorageDriver        string `envconfig:"STORAGE_DRIVER" default:""`
+	StorageSigningSecret string `envconfig:"STORAGE_SIGNING_SECRET" default:""`
+	PublicAPIURL         string `envconfig:"PUBLIC_API_URL" default:"http://localhost:8080"`
+
+	// Uploads at least this large or long are offered as resumable
+	// multipart sessions instead of a single presigned PUT.
+	UploadMultipartMinBytes    int64         `envconfig:"UPLOAD_MULTIPART_MIN_BYTES" default:"67108864"`
+	UploadMultipartMinDuration int           `envconfig:"UPLOAD_MULTIPART_MIN_DURATION_SEC" default:"900"`
+	UploadPartSize             int64         `envconfig:"UPLOAD_PART_SIZE" default:"16777216"`
+	UploadSessionTTL           time.Duration `envconfig:"UPLOAD_SESSION_TTL" default:"24h"`
+	UploadReaperInterval       time.Duration `envconfig:"UPLOAD_REAPER_INTERVAL" default:"15m"`
 
 	CDNBaseURL         string        `envconfig:"CDN_BASE_URL" default:""`
 	LocalMediaPath     string        `envconfig:"LOCAL_MEDIA_PATH" default:"./media"`
 	WorkerPollInterval time.Duration `envconfig:"WORKER_POLL_INTERVAL" default:"2s"`
 
+	WorkerDrainTimeout         time.Duration `envconfig:"WORKER_DRAIN_TIMEOUT" default:"30s"`
+	WorkerHeartbeatInterval    time.Duration `envconfig:"WORKER_HEARTBEAT_INTERVAL" default:"10s"`
+	WorkerAudioConcurrency     int           `envconfig:"WORKER_AUDIO_CONCURRENCY" default:"2"`
+	WorkerFinalizeConcurrency  int           `envconfig:"WORKER_FINALIZE_CONCURRENCY" default:"2"`
+	WorkerPipelineConcurrency  int           `envconfig:"WORKER_PIPELINE_CONCURRENCY" default:"2"`
+	WorkerAudiogramConcurrency int           `envconfig:"WORKER_AUDIOGRAM_CONCURRENCY" default:"1"`
+
+	IngestMaxBytesFree    int64         `envconfig:"INGEST_MAX_BYTES_FREE" default:"209715200"`
+	IngestMaxBytesPro     int64         `envconfig:"INGEST_MAX_BYTES_PRO" default:"2147483648"`
+	IngestMaxDurationFree time.Duration `envconfig:"INGEST_MAX_DURATION_FREE" default:"1h"`
+	IngestMaxDurationPro  time.Duration `envconfig:"INGEST_MAX_DURATION_PRO" default:"4h"`
+
+	WaveformBuckets     int `envconfig:"WAVEFORM_BUCKETS" default:"64"`
+	WaveformHiResPerSec int `envconfig:"WAVEFORM_HIRES_PER_SEC" default:"0"`
+
+	STTBinaryPath   string `envconfig:"STT_BINARY_PATH" default:""`
+	STTModelPath    string `envconfig:"STT_MODEL_PATH" default:""`
+	STTLanguage     string `envconfig:"STT_LANGUAGE" default:"auto"`
+	STTThreads      int    `envconfig:"STT_THREADS" default:"4"`
+	STTChunkSeconds int    `envconfig:"STT_CHUNK_SECONDS" default:"600"`
+
 	JWTAccessSecret      string        `envconfig:"JWT_ACCESS_SECRET" default:""`
 	JWTRefreshSecret     string        `envconfig:"JWT_REFRESH_SECRET" default:""`
 	JWTAccessTTL         time.Duration `envconfig:"JWT_ACCESS_TTL" default:"15m"`
@@ -68,9 +107,43 @@ type Config struct {
 	LiveKitAPIKey    string `envconfig:"LIVEKIT_API_KEY" default:""`
 	LiveKitAPISecret string `envconfig:"LIVEKIT_API_SECRET" default:""`
 
+	// Sessions end once the host has been disconnected for LiveHostGrace or
+	// has been live for LiveMaxDuration.
+	LiveHostGrace      time.Duration `envconfig:"LIVE_HOST_GRACE" default:"2m"`
+	LiveMaxDuration    time.Duration `envconfig:"LIVE_MAX_DURATION" default:"12h"`
+	LiveReaperInterval time.Duration `envconfig:"LIVE_REAPER_INTERVAL" default:"30s"`
+	// Scheduled sessions remind RSVPs and followers LiveReminderLead before
+	// the start and again at the start.
+	LiveReminderLead     time.Duration `envconfig:"LIVE_REMINDER_LEAD" default:"15m"`
+	LiveReminderInterval time.Duration `envconfig:"LIVE_REMINDER_INTERVAL" default:"1m"`
+
+	// Live translation needs the host to hold LiveTranslationEntitlement and
+	// stops once a session spends LiveTranslationMaxCostPerHour within an
+	// hour. The translator agent authenticates with LiveAgentToken.
+	LiveTranslationEntitlement    string  `envconfig:"LIVE_TRANSLATION_ENTITLEMENT" default:"pro"`
+	LiveTranslationMaxCostPerHour float64 `envconfig:"BUDGET_MAX_COST_PER_HOUR_USD" default:"5.0"`
+	LiveAgentToken                string  `envconfig:"LIVE_AGENT_TOKEN" default:""`
+
+	// GeoIPCityDB is a DB-IP "IP to City Lite" CSV, optionally gzipped.
+	// Without it nobody is located by IP; opted-in profile locations still
+	// work.
+	GeoIPCityDB string `envconfig:"GEOIP_CITY_DB" default:""`
+
 	FCMServerKey string `envconfig:"FCM_SERVER_KEY" default:""`
 	FCMEndpoint  string `envconfig:"FCM_ENDPOINT" default:"https://fcm.googleapis.com/fcm/send"`
 
+	// Entitlements that pass expires_at without a closing provider event are
+	// revoked, and their users downgraded, every BillingExpirySweepInterval.
+	BillingExpirySweepInterval time.Duration `envconfig:"BILLING_EXPIRY_SWEEP_INTERVAL" default:"5m"`
+	// BillingTrialDays is the length of the card-free Pro trial; 0 turns
+	// trials off.
+	BillingTrialDays int `envconfig:"BILLING_TRIAL_DAYS" default:"7"`
+
+	// BillingRedirectOrigins lists the origins (scheme://host[:port]) a
+	// client may ask a Stripe or MonoPay payment page to send the user back
+	// to; any other success or cancel URL is rejected.
+	BillingRedirectOrigins []string `envconfig:"BILLING_REDIRECT_ORIGINS" default:"https://moweton.app"`
+
 	StripeAPIKey            string `envconfig:"STRIPE_API_KEY" default:""`
 	StripeWebhookSecret     string `envconfig:"STRIPE_WEBHOOK_SECRET" default:""`
 	StripePortalURL         string `envconfig:"STRIPE_PORTAL_URL" default:""`
@@ -82,6 +155,24 @@ type Config struct {
 	MonoPayWebhookURL       string `envconfig:"MONOPAY_WEBHOOK_URL" default:""`
 	MonoPayReturnURL        string `envconfig:"MONOPAY_RETURN_URL" default:"https://moweton.app/payments/monopay/success"`
 	MonoPayAPIBaseURL       string `envconfig:"MONOPAY_API_BASE_URL" default:"https://api.monobank.ua/api/merchant"`
+
+	// With StripeAPIKey set, checkout and portal sessions are created through
+	// the Stripe API; StripePortalURL is then only used for users without a
+	// Stripe customer.
+	StripeAPIBaseURL         string `envconfig:"STRIPE_API_BASE_URL" default:"https://api.stripe.com"`
+	StripeCheckoutSuccessURL string `envconfig:"STRIPE_CHECKOUT_SUCCESS_URL" default:"https://moweton.app/payments/stripe/success"`
+	StripeCheckoutCancelURL  string `envconfig:"STRIPE_CHECKOUT_CANCEL_URL" default:"https://moweton.app/payments/stripe/cancel"`
+	StripePortalReturnURL    string `envconfig:"STRIPE_PORTAL_RETURN_URL" default:"https://moweton.app/settings/billing"`
+
+	// MonoPay subscriptions are renewed by the worker every
+	// MonoPayRenewalInterval: the saved card is charged MonoPayRenewalLead
+	// before the period ends, and a failed charge is retried after each
+	// MonoPayDunningSchedule delay before the subscription is canceled.
+	// Invoices still open after MonoPayReconcileAfter are polled.
+	MonoPayRenewalInterval time.Duration   `envconfig:"MONOPAY_RENEWAL_INTERVAL" default:"10m"`
+	MonoPayRenewalLead     time.Duration   `envconfig:"MONOPAY_RENEWAL_LEAD" default:"24h"`
+	MonoPayDunningSchedule []time.Duration `envconfig:"MONOPAY_DUNNING_SCHEDULE" default:"6h,24h,72h"`
+	MonoPayReconcileAfter  time.Duration   `envconfig:"MONOPAY_RECONCILE_AFTER" default:"15m"`
 }
 
 // LoadConfig loads configuration using the provided environment variable prefix.
@@ -0,0 +1,52 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// SaveCustomer remembers that a provider customer belongs to userID. A
+// customer already mapped keeps its first user.
+func (s *Service) SaveCustomer(ctx context.Context, userID uuid.UUID, provider Provider, customerID string) error {
+	return saveCustomer(ctx, s.DB, userID, provider, customerID)
+}
+
+// CustomerID returns the user's most recent customer id at provider.
+func (s *Service) CustomerID(ctx context.Context, userID uuid.UUID, provider Provider) (string, error) {
+	var customerID string
+	err := s.DB.QueryRowContext(ctx, `
+SELECT external_customer_id FROM billing_customers
+WHERE user_id = $1 AND provider = $2
+ORDER BY created_at DESC
+LIMIT 1`, userID, provider).Scan(&customerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrCustomerNotFound
+	}
+	return customerID, err
+}
+
+// UserByCustomer maps a provider customer id back to its user.
+func (s *Service) UserByCustomer(ctx context.Context, provider Provider, customerID string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := s.DB.QueryRowContext(ctx, `
+SELECT user_id FROM billing_customers
+WHERE provider = $1 AND external_customer_id = $2`, provider, customerID).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrCustomerNotFound
+	}
+	return userID, err
+}
+
+func saveCustomer(ctx context.Context, db execer, userID uuid.UUID, provider Provider, customerID string) error {
+	if customerID == "" {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, `
+INSERT INTO billing_customers (provider, external_customer_id, user_id)
+VALUES ($1,$2,$3)
+ON CONFLICT (provider, external_customer_id) DO NOTHING`, provider, customerID, userID)
+	return err
+}
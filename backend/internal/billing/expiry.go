@@ -0,0 +1,113 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultExpiryBatch bounds how many entitlements one sweep revokes.
+const DefaultExpiryBatch = 500
+
+// EventEntitlementExpired is the billing_payment_events type recorded when
+// the sweeper revokes an entitlement.
+const EventEntitlementExpired = "entitlement_expired"
+
+// ExpirySweep reports what one ExpireEntitlements call changed.
+type ExpirySweep struct {
+	Expired    int
+	Downgraded []uuid.UUID
+}
+
+type expiredEntitlement struct {
+	UserID    uuid.UUID
+	Code      string
+	Source    Provider
+	ExpiresAt time.Time
+}
+
+// ExpireEntitlements revokes active entitlements whose expires_at has passed
+// without a closing provider event, recomputes the plan of their users and
+// records each expiry in billing_payment_events. It handles at most limit
+// entitlements; callers sweep again until nothing is left.
+func (s *Service) ExpireEntitlements(ctx context.Context, now time.Time, limit int) (ExpirySweep, error) {
+	if limit <= 0 {
+		limit = DefaultExpiryBatch
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ExpirySweep{}, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+WITH due AS (
+	SELECT id FROM billing_entitlements
+	WHERE status = 'active'
+	  AND expires_at <= $1
+	ORDER BY expires_at
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED
+)
+UPDATE billing_entitlements e
+SET status = 'revoked',
+	metadata = e.metadata || jsonb_build_object('revoked_reason', 'expired'),
+	updated_at = now()
+FROM due
+WHERE e.id = due.id
+RETURNING e.user_id, e.code, e.source, e.expires_at`, now, limit)
+	if err != nil {
+		return ExpirySweep{}, err
+	}
+	var expired []expiredEntitlement
+	for rows.Next() {
+		var ent expiredEntitlement
+		if err := rows.Scan(&ent.UserID, &ent.Code, &ent.Source, &ent.ExpiresAt); err != nil {
+			rows.Close()
+			return ExpirySweep{}, err
+		}
+		expired = append(expired, ent)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return ExpirySweep{}, err
+	}
+
+	sweep := ExpirySweep{Expired: len(expired)}
+	changes := make(map[uuid.UUID]planChange)
+	for _, ent := range expired {
+		change, seen := changes[ent.UserID]
+		if !seen {
+			if change, err = refreshUserPlan(ctx, tx, ent.UserID); err != nil {
+				return ExpirySweep{}, err
+			}
+			changes[ent.UserID] = change
+			if change.Changed() {
+				sweep.Downgraded = append(sweep.Downgraded, ent.UserID)
+			}
+		}
+		if err := recordExpiry(ctx, tx, ent, change); err != nil {
+			return ExpirySweep{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ExpirySweep{}, err
+	}
+	return sweep, nil
+}
+
+func recordExpiry(ctx context.Context, tx *sql.Tx, ent expiredEntitlement, change planChange) error {
+	payload := mapToJSON(map[string]any{
+		"entitlement": ent.Code,
+		"expires_at":  ent.ExpiresAt.UTC().Format(time.RFC3339),
+		"plan_before": change.Before,
+		"plan_after":  change.After,
+	})
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO billing_payment_events (user_id, provider, event_type, external_id, payload)
+VALUES ($1,$2,$3,$4,$5)`, ent.UserID, ent.Source, EventEntitlementExpired, ent.Code, payload)
+	return err
+}
@@ -0,0 +1,63 @@
+package billing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestExpireEntitlementsDowngradesOnce(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	lapsed, covered := uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE billing_entitlements e`).WithArgs(now, DefaultExpiryBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "code", "source", "expires_at"}).
+			AddRow(lapsed, "pro", "stripe", now.Add(-time.Hour)).
+			AddRow(lapsed, "live_translation", "stripe", now.Add(-time.Hour)).
+			AddRow(covered, "pro", "monopay", now.Add(-time.Minute)))
+
+	// The lapsed user has nothing left and drops to free.
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM billing_entitlements`).WithArgs(lapsed).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT plan FROM users`).WithArgs(lapsed).
+		WillReturnRows(sqlmock.NewRows([]string{"plan"}).AddRow("pro"))
+	mock.ExpectExec(`UPDATE users SET plan`).WithArgs("free", lapsed).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO billing_payment_events`).
+		WithArgs(lapsed, Provider("stripe"), EventEntitlementExpired, "pro", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO billing_payment_events`).
+		WithArgs(lapsed, Provider("stripe"), EventEntitlementExpired, "live_translation", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	// The other user still holds an active entitlement and stays pro.
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM billing_entitlements`).WithArgs(covered).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT plan FROM users`).WithArgs(covered).
+		WillReturnRows(sqlmock.NewRows([]string{"plan"}).AddRow("pro"))
+	mock.ExpectExec(`INSERT INTO billing_payment_events`).
+		WithArgs(covered, Provider("monopay"), EventEntitlementExpired, "pro", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectCommit()
+
+	sweep, err := NewService(db).ExpireEntitlements(context.Background(), now, 0)
+	if err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	if sweep.Expired != 3 || len(sweep.Downgraded) != 1 || sweep.Downgraded[0] != lapsed {
+		t.Fatalf("unexpected sweep %+v", sweep)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
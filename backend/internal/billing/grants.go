@@ -0,0 +1,431 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Grants are first-party access: promo code redemptions, free trials and
+// Pro granted or gifted by staff. Each one is a billing_grants row and
+// activates the matching billing_entitlements row with source "internal",
+// so plans, expiry and GET /billing/subscription treat it like paid access.
+// A grant stacked on paid access keeps the provider's source, status and
+// expiry in the row's provider_* columns until the provider takes over again.
+
+type GrantKind string
+
+const (
+	GrantPromo GrantKind = "promo"
+	GrantTrial GrantKind = "trial"
+	GrantStaff GrantKind = "staff"
+	GrantGift  GrantKind = "gift"
+)
+
+// DefaultGrantEntitlement is what grants unlock unless told otherwise.
+const DefaultGrantEntitlement = "pro"
+
+type Grant struct {
+	ID              int64      `json:"id"`
+	UserID          uuid.UUID  `json:"user_id"`
+	Kind            GrantKind  `json:"kind"`
+	EntitlementCode string     `json:"entitlement"`
+	StartsAt        time.Time  `json:"starts_at"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	PromoCode       string     `json:"promo_code,omitempty"`
+	GrantedBy       *uuid.UUID `json:"granted_by,omitempty"`
+	Note            string     `json:"note,omitempty"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type PromoCode struct {
+	Code            string     `json:"code"`
+	EntitlementCode string     `json:"entitlement"`
+	DurationDays    int        `json:"duration_days"`
+	MaxRedemptions  *int       `json:"max_redemptions,omitempty"`
+	Redemptions     int        `json:"redemptions"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	CreatedBy       *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// GrantRequest is a staff grant or gift. A zero Duration grants access
+// with no end.
+type GrantRequest struct {
+	UserID          uuid.UUID
+	Kind            GrantKind
+	EntitlementCode string
+	Duration        time.Duration
+	GrantedBy       uuid.UUID
+	Note            string
+}
+
+// NormalizePromoCode is the stored form of a promo code.
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (s *Service) CreatePromoCode(ctx context.Context, promo PromoCode) (*PromoCode, error) {
+	promo.Code = NormalizePromoCode(promo.Code)
+	if promo.EntitlementCode == "" {
+		promo.EntitlementCode = DefaultGrantEntitlement
+	}
+	if promo.Code == "" || promo.DurationDays <= 0 || (promo.MaxRedemptions != nil && *promo.MaxRedemptions <= 0) {
+		return nil, ErrInvalidGrant
+	}
+	err := s.DB.QueryRowContext(ctx, `
+INSERT INTO billing_promo_codes (code, entitlement_code, duration_days, max_redemptions, expires_at, created_by)
+VALUES ($1,$2,$3,$4,$5,$6)
+ON CONFLICT (code) DO NOTHING
+RETURNING created_at`, promo.Code, promo.EntitlementCode, promo.DurationDays, promo.MaxRedemptions, promo.ExpiresAt, promo.CreatedBy).Scan(&promo.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPromoExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &promo, nil
+}
+
+func (s *Service) ListPromoCodes(ctx context.Context, limit int) ([]PromoCode, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+SELECT code, entitlement_code, duration_days, max_redemptions, redemptions, expires_at, created_by, created_at
+FROM billing_promo_codes
+ORDER BY created_at DESC
+LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := []PromoCode{}
+	for rows.Next() {
+		var p PromoCode
+		if err := rows.Scan(&p.Code, &p.EntitlementCode, &p.DurationDays, &p.MaxRedemptions, &p.Redemptions, &p.ExpiresAt, &p.CreatedBy, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		codes = append(codes, p)
+	}
+	return codes, rows.Err()
+}
+
+// RedeemPromoCode grants the code's entitlement to userID. Each user can
+// redeem a code once.
+func (s *Service) RedeemPromoCode(ctx context.Context, userID uuid.UUID, code string, now time.Time) (*Grant, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var promo PromoCode
+	err = tx.QueryRowContext(ctx, `
+SELECT code, entitlement_code, duration_days, max_redemptions, redemptions, expires_at
+FROM billing_promo_codes
+WHERE code = $1
+FOR UPDATE`, NormalizePromoCode(code)).Scan(&promo.Code, &promo.EntitlementCode, &promo.DurationDays, &promo.MaxRedemptions, &promo.Redemptions, &promo.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPromoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if promo.ExpiresAt != nil && !promo.ExpiresAt.After(now) {
+		return nil, ErrPromoExpired
+	}
+	if promo.MaxRedemptions != nil && promo.Redemptions >= *promo.MaxRedemptions {
+		return nil, ErrPromoExhausted
+	}
+
+	grant := Grant{UserID: userID, Kind: GrantPromo, EntitlementCode: promo.EntitlementCode, PromoCode: promo.Code}
+	if err := s.grant(ctx, tx, &grant, time.Duration(promo.DurationDays)*24*time.Hour, now); err != nil {
+		if errors.Is(err, errDuplicateGrant) {
+			return nil, ErrPromoRedeemed
+		}
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE billing_promo_codes SET redemptions = redemptions + 1 WHERE code = $1`, promo.Code); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// StartTrial grants days of Pro without a payment method. A user gets one
+// trial, and none once they have had a paid subscription.
+func (s *Service) StartTrial(ctx context.Context, userID uuid.UUID, days int, now time.Time) (*Grant, error) {
+	if days <= 0 {
+		return nil, ErrInvalidGrant
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var subscribed bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM user_subscriptions WHERE user_id = $1)`, userID).Scan(&subscribed); err != nil {
+		return nil, err
+	}
+	if subscribed {
+		return nil, ErrTrialUsed
+	}
+
+	grant := Grant{UserID: userID, Kind: GrantTrial, EntitlementCode: DefaultGrantEntitlement}
+	if err := s.grant(ctx, tx, &grant, time.Duration(days)*24*time.Hour, now); err != nil {
+		if errors.Is(err, errDuplicateGrant) {
+			return nil, ErrTrialUsed
+		}
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// GrantAccess records a staff grant or gift.
+func (s *Service) GrantAccess(ctx context.Context, req GrantRequest, now time.Time) (*Grant, error) {
+	if (req.Kind != GrantStaff && req.Kind != GrantGift) || req.Duration < 0 {
+		return nil, ErrInvalidGrant
+	}
+	if req.EntitlementCode == "" {
+		req.EntitlementCode = DefaultGrantEntitlement
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	grantedBy := req.GrantedBy
+	grant := Grant{UserID: req.UserID, Kind: req.Kind, EntitlementCode: req.EntitlementCode, GrantedBy: &grantedBy, Note: req.Note}
+	if err := s.grant(ctx, tx, &grant, req.Duration, now); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// RevokeGrant ends a grant. The internal entitlement shrinks to whatever
+// the user's other live grants for it still cover, and goes back to the
+// provider the grant was stacked on once the provider covers as much.
+func (s *Service) RevokeGrant(ctx context.Context, id int64, now time.Time) (*Grant, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var g Grant
+	var promo, note sql.NullString
+	err = tx.QueryRowContext(ctx, `
+UPDATE billing_grants SET revoked_at = $2
+WHERE id = $1 AND revoked_at IS NULL
+RETURNING `+grantColumns, id, now).Scan(&g.ID, &g.UserID, &g.Kind, &g.EntitlementCode, &g.StartsAt, &g.ExpiresAt, &promo, &g.GrantedBy, &note, &g.RevokedAt, &g.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrGrantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.PromoCode, g.Note = promo.String, note.String
+
+	var permanent sql.NullBool
+	var until *time.Time
+	if err := tx.QueryRowContext(ctx, `
+SELECT bool_or(expires_at IS NULL), max(expires_at)
+FROM billing_grants
+WHERE user_id = $1 AND entitlement_code = $2 AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > $3)`, g.UserID, g.EntitlementCode, now).Scan(&permanent, &until); err != nil {
+		return nil, err
+	}
+	if permanent.Bool {
+		until = nil
+	}
+
+	// Access a provider took over since the grant is left alone.
+	var provider struct {
+		Source    sql.NullString
+		Status    sql.NullString
+		ExpiresAt *time.Time
+	}
+	err = tx.QueryRowContext(ctx, `
+SELECT provider_source, provider_status, provider_expires_at FROM billing_entitlements
+WHERE user_id = $1 AND code = $2 AND source = 'internal'
+FOR UPDATE`, g.UserID, g.EntitlementCode).Scan(&provider.Source, &provider.Status, &provider.ExpiresAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return nil, err
+	case provider.Source.Valid && (!permanent.Valid || providerCovers(provider.Status.String, provider.ExpiresAt, permanent.Bool, until, now)):
+		if _, err := tx.ExecContext(ctx, `
+UPDATE billing_entitlements
+SET source = provider_source, status = provider_status, expires_at = provider_expires_at,
+	provider_source = NULL, provider_status = NULL, provider_expires_at = NULL, updated_at = now()
+WHERE user_id = $1 AND code = $2 AND source = 'internal'`, g.UserID, g.EntitlementCode); err != nil {
+			return nil, err
+		}
+	default:
+		status := "active"
+		if !permanent.Valid {
+			status = "revoked"
+		}
+		if _, err := tx.ExecContext(ctx, `
+UPDATE billing_entitlements
+SET status = $3, expires_at = $4, updated_at = now()
+WHERE user_id = $1 AND code = $2 AND source = 'internal'`, g.UserID, g.EntitlementCode, status, until); err != nil {
+			return nil, err
+		}
+	}
+	change, err := refreshUserPlan(ctx, tx, g.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordGrantEvent(ctx, tx, &g, "grant_revoked", change); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// ActiveGrants lists the user's unrevoked grants that have not ended.
+func (s *Service) ActiveGrants(ctx context.Context, userID uuid.UUID, now time.Time) ([]Grant, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+SELECT `+grantColumns+`
+FROM billing_grants
+WHERE user_id = $1 AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > $2)
+ORDER BY starts_at`, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []Grant{}
+	for rows.Next() {
+		var g Grant
+		var promo, note sql.NullString
+		if err := rows.Scan(&g.ID, &g.UserID, &g.Kind, &g.EntitlementCode, &g.StartsAt, &g.ExpiresAt, &promo, &g.GrantedBy, &note, &g.RevokedAt, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		g.PromoCode, g.Note = promo.String, note.String
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// providerCovers reports whether provider access in status until
+// providerEnd lasts at least as long as the grants still live, which run
+// until grantEnd or, when permanent, with no end.
+func providerCovers(status string, providerEnd *time.Time, permanent bool, grantEnd *time.Time, now time.Time) bool {
+	if status != "active" || (providerEnd != nil && !providerEnd.After(now)) {
+		return false
+	}
+	if providerEnd == nil {
+		return true
+	}
+	return !permanent && grantEnd != nil && !providerEnd.Before(*grantEnd)
+}
+
+const grantColumns = `id, user_id, kind, entitlement_code, starts_at, expires_at, promo_code, granted_by, note, revoked_at, created_at`
+
+var errDuplicateGrant = errors.New("grant already exists")
+
+// grant records g and activates its entitlement for duration, or with no
+// end when duration is zero. Time the user already holds is kept: a timed
+// grant starts when the current access ends.
+func (s *Service) grant(ctx context.Context, tx *sql.Tx, g *Grant, duration time.Duration, now time.Time) error {
+	var current struct {
+		Status    string
+		ExpiresAt *time.Time
+	}
+	err := tx.QueryRowContext(ctx, `
+SELECT status, expires_at FROM billing_entitlements
+WHERE user_id = $1 AND code = $2
+FOR UPDATE`, g.UserID, g.EntitlementCode).Scan(&current.Status, &current.ExpiresAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	active := err == nil && current.Status == "active" && (current.ExpiresAt == nil || current.ExpiresAt.After(now))
+
+	g.StartsAt = now
+	if active && current.ExpiresAt != nil {
+		g.StartsAt = *current.ExpiresAt
+	}
+	if duration > 0 {
+		end := g.StartsAt.Add(duration)
+		g.ExpiresAt = &end
+	}
+
+	promo := sql.NullString{String: g.PromoCode, Valid: g.PromoCode != ""}
+	note := sql.NullString{String: g.Note, Valid: g.Note != ""}
+	err = tx.QueryRowContext(ctx, `
+INSERT INTO billing_grants (user_id, kind, entitlement_code, starts_at, expires_at, promo_code, granted_by, note)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+ON CONFLICT DO NOTHING
+RETURNING id, created_at`, g.UserID, g.Kind, g.EntitlementCode, g.StartsAt, g.ExpiresAt, promo, g.GrantedBy, note).Scan(&g.ID, &g.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errDuplicateGrant
+	}
+	if err != nil {
+		return err
+	}
+
+	// Access with no end already covers a timed grant.
+	if !active || current.ExpiresAt != nil || g.ExpiresAt == nil {
+		meta := mapToJSON(map[string]any{"grant_id": g.ID, "grant_kind": g.Kind})
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO billing_entitlements (user_id, code, source, status, expires_at, metadata, updated_at)
+VALUES ($1,$2,'internal','active',$3,$4, now())
+ON CONFLICT (user_id, code)
+DO UPDATE SET
+	provider_source = CASE WHEN billing_entitlements.source = 'internal' THEN billing_entitlements.provider_source ELSE billing_entitlements.source END,
+	provider_status = CASE WHEN billing_entitlements.source = 'internal' THEN billing_entitlements.provider_status ELSE billing_entitlements.status END,
+	provider_expires_at = CASE WHEN billing_entitlements.source = 'internal' THEN billing_entitlements.provider_expires_at ELSE billing_entitlements.expires_at END,
+	source = EXCLUDED.source,
+	status = EXCLUDED.status,
+	expires_at = EXCLUDED.expires_at,
+	metadata = billing_entitlements.metadata || EXCLUDED.metadata,
+	updated_at = EXCLUDED.updated_at`, g.UserID, g.EntitlementCode, g.ExpiresAt, meta); err != nil {
+			return err
+		}
+	}
+
+	change, err := refreshUserPlan(ctx, tx, g.UserID)
+	if err != nil {
+		return err
+	}
+	return recordGrantEvent(ctx, tx, g, "grant_"+string(g.Kind), change)
+}
+
+func recordGrantEvent(ctx context.Context, tx *sql.Tx, g *Grant, eventType string, change planChange) error {
+	payload := map[string]any{
+		"grant_id":    g.ID,
+		"entitlement": g.EntitlementCode,
+		"plan_before": change.Before,
+		"plan_after":  change.After,
+	}
+	if g.ExpiresAt != nil {
+		payload["expires_at"] = g.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	externalID := g.PromoCode
+	if externalID == "" {
+		externalID = g.EntitlementCode
+	}
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO billing_payment_events (user_id, provider, event_type, external_id, payload)
+VALUES ($1,$2,$3,$4,$5)`, g.UserID, ProviderInternal, eventType, externalID, mapToJSON(payload))
+	return err
+}
@@ -0,0 +1,147 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestStartTrialGrantsPro(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	end := now.AddDate(0, 0, 7)
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM user_subscriptions`).WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`SELECT status, expires_at FROM billing_entitlements`).WithArgs(userID, DefaultGrantEntitlement).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "expires_at"}))
+	mock.ExpectQuery(`INSERT INTO billing_grants`).
+		WithArgs(userID, GrantTrial, DefaultGrantEntitlement, now, &end, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+	mock.ExpectExec(`INSERT INTO billing_entitlements`).WithArgs(userID, DefaultGrantEntitlement, &end, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM billing_entitlements`).WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT plan FROM users`).WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"plan"}).AddRow("free"))
+	mock.ExpectExec(`UPDATE users SET plan`).WithArgs("pro", userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO billing_payment_events`).
+		WithArgs(userID, ProviderInternal, "grant_trial", DefaultGrantEntitlement, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	grant, err := NewService(db).StartTrial(context.Background(), userID, 7, now)
+	if err != nil {
+		t.Fatalf("start trial: %v", err)
+	}
+	if grant.ID != 1 || grant.ExpiresAt == nil || !grant.ExpiresAt.Equal(end) {
+		t.Fatalf("unexpected grant %+v", grant)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestRedeemPromoCodeRejectsExhausted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM billing_promo_codes`).WithArgs("CREATORS").
+		WillReturnRows(sqlmock.NewRows([]string{"code", "entitlement_code", "duration_days", "max_redemptions", "redemptions", "expires_at"}).
+			AddRow("CREATORS", "pro", 30, 5, 5, nil))
+	mock.ExpectRollback()
+
+	_, err = NewService(db).RedeemPromoCode(context.Background(), uuid.New(), " creators ", time.Now())
+	if !errors.Is(err, ErrPromoExhausted) {
+		t.Fatalf("expected ErrPromoExhausted, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestRevokeGrantRestoresPaidSubscription(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	paidUntil := now.AddDate(0, 0, 10)
+	grantEnd := paidUntil.AddDate(0, 0, 30)
+	userID, staffID := uuid.New(), uuid.New()
+	svc := NewService(db)
+
+	// The staff grant is stacked after the Stripe period and the Stripe
+	// state is kept beside it.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status, expires_at FROM billing_entitlements`).WithArgs(userID, DefaultGrantEntitlement).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "expires_at"}).AddRow("active", paidUntil))
+	mock.ExpectQuery(`INSERT INTO billing_grants`).
+		WithArgs(userID, GrantStaff, DefaultGrantEntitlement, paidUntil, &grantEnd, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(5, now))
+	mock.ExpectExec(`provider_source = CASE WHEN billing_entitlements.source = 'internal' THEN billing_entitlements.provider_source ELSE billing_entitlements.source END`).
+		WithArgs(userID, DefaultGrantEntitlement, &grantEnd, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM billing_entitlements`).WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT plan FROM users`).WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"plan"}).AddRow("pro"))
+	mock.ExpectExec(`INSERT INTO billing_payment_events`).
+		WithArgs(userID, ProviderInternal, "grant_staff", DefaultGrantEntitlement, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	grant, err := svc.GrantAccess(context.Background(), GrantRequest{
+		UserID: userID, Kind: GrantStaff, Duration: 30 * 24 * time.Hour, GrantedBy: staffID,
+	}, now)
+	if err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+
+	// Revoking it hands the entitlement back to Stripe, still paid until
+	// the end of its period, and the user stays on Pro.
+	revokedAt := now.Add(time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE billing_grants SET revoked_at`).WithArgs(grant.ID, revokedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "kind", "entitlement_code", "starts_at", "expires_at", "promo_code", "granted_by", "note", "revoked_at", "created_at"}).
+			AddRow(grant.ID, userID, GrantStaff, DefaultGrantEntitlement, paidUntil, grantEnd, nil, staffID, nil, revokedAt, now))
+	mock.ExpectQuery(`SELECT bool_or\(expires_at IS NULL\), max\(expires_at\)`).WithArgs(userID, DefaultGrantEntitlement, revokedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"permanent", "until"}).AddRow(nil, nil))
+	mock.ExpectQuery(`SELECT provider_source, provider_status, provider_expires_at FROM billing_entitlements`).WithArgs(userID, DefaultGrantEntitlement).
+		WillReturnRows(sqlmock.NewRows([]string{"provider_source", "provider_status", "provider_expires_at"}).AddRow("stripe", "active", paidUntil))
+	mock.ExpectExec(`SET source = provider_source, status = provider_status, expires_at = provider_expires_at`).WithArgs(userID, DefaultGrantEntitlement).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM billing_entitlements`).WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT plan FROM users`).WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"plan"}).AddRow("pro"))
+	mock.ExpectExec(`INSERT INTO billing_payment_events`).
+		WithArgs(userID, ProviderInternal, "grant_revoked", DefaultGrantEntitlement, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if _, err := svc.RevokeGrant(context.Background(), grant.ID, revokedAt); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
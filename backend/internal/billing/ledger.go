@@ -0,0 +1,184 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventStatus string
+
+const (
+	EventPending   EventStatus = "pending"
+	EventProcessed EventStatus = "processed"
+	// EventSkipped marks an event older than what its subscription already
+	// reflects.
+	EventSkipped EventStatus = "skipped"
+	EventFailed  EventStatus = "failed"
+)
+
+// eventClaimLease is how long an attempt at an event may stay pending before
+// it is considered abandoned and the event can be claimed again.
+const eventClaimLease = 5 * time.Minute
+
+// WebhookEvent identifies one provider event in the ledger. A zero
+// OccurredAt means the provider sent no event time and the update applies
+// regardless of order.
+type WebhookEvent struct {
+	Provider   Provider
+	EventID    string
+	Type       string
+	OccurredAt time.Time
+}
+
+// LedgerEntry is a webhook event as recorded in the ledger.
+type LedgerEntry struct {
+	ID          int64       `json:"id"`
+	Provider    Provider    `json:"provider"`
+	EventID     string      `json:"event_id"`
+	Type        string      `json:"event_type"`
+	OccurredAt  *time.Time  `json:"occurred_at,omitempty"`
+	Status      EventStatus `json:"status"`
+	Attempts    int         `json:"attempts"`
+	LastError   string      `json:"last_error,omitempty"`
+	UserID      uuid.UUID   `json:"user_id"`
+	ReceivedAt  time.Time   `json:"received_at"`
+	ProcessedAt *time.Time  `json:"processed_at,omitempty"`
+}
+
+// ProcessWebhookEvent records a provider event in the ledger and applies
+// its update once. Redeliveries of an event that was processed or skipped,
+// or that another attempt is still working on, return ErrDuplicateEvent; a
+// redelivery of a failed or abandoned event retries it.
+// Events older than the subscription's last applied event are skipped.
+func (s *Service) ProcessWebhookEvent(ctx context.Context, event WebhookEvent, update SubscriptionUpdate) (EventStatus, error) {
+	if !event.Provider.Valid() {
+		return "", ErrInvalidProvider
+	}
+	update.OccurredAt = nil
+	if !event.OccurredAt.IsZero() {
+		occurred := event.OccurredAt.UTC()
+		update.OccurredAt = &occurred
+	}
+	updateJSON, err := json.Marshal(update)
+	if err != nil {
+		return "", fmt.Errorf("encode update: %w", err)
+	}
+
+	var id int64
+	err = s.DB.QueryRowContext(ctx, `
+INSERT INTO billing_webhook_events (provider, event_id, event_type, occurred_at, update_json)
+VALUES ($1,$2,$3,$4,$5)
+ON CONFLICT (provider, event_id) DO NOTHING
+RETURNING id`, event.Provider, event.EventID, event.Type, update.OccurredAt, updateJSON).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		id, err = s.claimRetry(ctx, `provider = $1 AND event_id = $2`, event.Provider, event.EventID)
+		if errors.Is(err, ErrEventNotFound) {
+			return "", ErrDuplicateEvent
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.runEvent(ctx, id, update)
+}
+
+// ReplayWebhookEvent re-runs a failed or abandoned event from its stored
+// update.
+func (s *Service) ReplayWebhookEvent(ctx context.Context, id int64) (EventStatus, error) {
+	if _, err := s.claimRetry(ctx, `id = $1`, id); err != nil {
+		return "", err
+	}
+	var raw []byte
+	if err := s.DB.QueryRowContext(ctx, `SELECT update_json FROM billing_webhook_events WHERE id = $1`, id).Scan(&raw); err != nil {
+		return "", err
+	}
+	var update SubscriptionUpdate
+	if err := json.Unmarshal(raw, &update); err != nil {
+		return s.finishEvent(ctx, id, EventFailed, fmt.Errorf("decode update: %w", err))
+	}
+	return s.runEvent(ctx, id, update)
+}
+
+// ListWebhookEvents returns ledger entries with the given status, oldest
+// first, or all entries newest first when status is empty.
+func (s *Service) ListWebhookEvents(ctx context.Context, status EventStatus, limit int) ([]LedgerEntry, error) {
+	query := `
+SELECT id, provider, event_id, event_type, occurred_at, status, attempts, COALESCE(last_error, ''),
+	(update_json->>'UserID')::uuid, received_at, processed_at
+FROM billing_webhook_events`
+	args := []any{limit}
+	if status != "" {
+		query += ` WHERE status = $2 ORDER BY received_at ASC LIMIT $1`
+		args = append(args, status)
+	} else {
+		query += ` ORDER BY received_at DESC LIMIT $1`
+	}
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LedgerEntry{}
+	for rows.Next() {
+		var e LedgerEntry
+		if err := rows.Scan(&e.ID, &e.Provider, &e.EventID, &e.Type, &e.OccurredAt, &e.Status, &e.Attempts,
+			&e.LastError, &e.UserID, &e.ReceivedAt, &e.ProcessedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// claimRetry starts another attempt at an event matching where that failed,
+// or that stayed pending past eventClaimLease because its attempt was
+// abandoned, returning ErrEventNotFound when there is none.
+func (s *Service) claimRetry(ctx context.Context, where string, args ...any) (int64, error) {
+	var id int64
+	err := s.DB.QueryRowContext(ctx, fmt.Sprintf(`
+UPDATE billing_webhook_events
+SET status = 'pending', attempts = attempts + 1, claimed_at = now()
+WHERE (status = 'failed' OR (status = 'pending' AND claimed_at < now() - interval '%d seconds')) AND `,
+		int(eventClaimLease/time.Second))+where+`
+RETURNING id`, args...).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrEventNotFound
+	}
+	return id, err
+}
+
+func (s *Service) runEvent(ctx context.Context, id int64, update SubscriptionUpdate) (EventStatus, error) {
+	err := s.ApplySubscriptionUpdate(ctx, update)
+	switch {
+	case err == nil:
+		return s.finishEvent(ctx, id, EventProcessed, nil)
+	case errors.Is(err, ErrStaleEvent):
+		return s.finishEvent(ctx, id, EventSkipped, nil)
+	default:
+		return s.finishEvent(ctx, id, EventFailed, err)
+	}
+}
+
+// finishEvent stores the outcome of an attempt. A failed attempt returns
+// its cause so webhook callers answer with an error and the provider
+// retries.
+func (s *Service) finishEvent(ctx context.Context, id int64, status EventStatus, cause error) (EventStatus, error) {
+	var lastError sql.NullString
+	if cause != nil {
+		lastError = sql.NullString{String: cause.Error(), Valid: true}
+	}
+	if _, err := s.DB.ExecContext(ctx, `
+UPDATE billing_webhook_events
+SET status = $2, last_error = $3, processed_at = now()
+WHERE id = $1`, id, status, lastError); err != nil {
+		return "", err
+	}
+	return status, cause
+}
@@ -0,0 +1,138 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestProcessWebhookEventRejectsDuplicate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	event := WebhookEvent{Provider: ProviderStripe, EventID: "evt_1", Type: "customer.subscription.updated", OccurredAt: time.Now()}
+	mock.ExpectQuery(`INSERT INTO billing_webhook_events`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`UPDATE billing_webhook_events`).WithArgs(ProviderStripe, "evt_1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = NewService(db).ProcessWebhookEvent(context.Background(), event, SubscriptionUpdate{Provider: ProviderStripe})
+	if !errors.Is(err, ErrDuplicateEvent) {
+		t.Fatalf("expected ErrDuplicateEvent, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestProcessWebhookEventSkipsStale(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	occurred := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	update := SubscriptionUpdate{
+		UserID:                 uuid.New(),
+		Provider:               ProviderStripe,
+		ProductCode:            "pro_monthly",
+		ExternalSubscriptionID: "sub_1",
+		Status:                 StatusActive,
+	}
+	mock.ExpectQuery(`INSERT INTO billing_webhook_events`).
+		WithArgs(ProviderStripe, "evt_2", "customer.subscription.updated", &occurred, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM billing_products`).WithArgs("pro_monthly").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	// A newer "canceled" is already applied, so the upsert matches nothing.
+	mock.ExpectQuery(`INSERT INTO user_subscriptions`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+	mock.ExpectExec(`UPDATE billing_webhook_events`).WithArgs(int64(7), EventSkipped, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	event := WebhookEvent{Provider: ProviderStripe, EventID: "evt_2", Type: "customer.subscription.updated", OccurredAt: occurred}
+	status, err := NewService(db).ProcessWebhookEvent(context.Background(), event, update)
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if status != EventSkipped {
+		t.Fatalf("expected skipped, got %s", status)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestProcessWebhookEventReclaimsAbandonedPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	update := SubscriptionUpdate{
+		UserID:                 uuid.New(),
+		Provider:               ProviderStripe,
+		ProductCode:            "pro_monthly",
+		ExternalSubscriptionID: "sub_1",
+		Status:                 StatusActive,
+	}
+	mock.ExpectQuery(`INSERT INTO billing_webhook_events`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	// The first attempt died while pending; its lease has run out.
+	mock.ExpectQuery(`status = 'pending' AND claimed_at < now\(\) - interval '300 seconds'`).WithArgs(ProviderStripe, "evt_3").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM billing_products`).WithArgs("pro_monthly").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO user_subscriptions`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+	mock.ExpectExec(`UPDATE billing_webhook_events`).WithArgs(int64(9), EventSkipped, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	event := WebhookEvent{Provider: ProviderStripe, EventID: "evt_3", Type: "customer.subscription.updated", OccurredAt: time.Now()}
+	status, err := NewService(db).ProcessWebhookEvent(context.Background(), event, update)
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if status != EventSkipped {
+		t.Fatalf("expected the abandoned event to run again, got %s", status)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestReplayWebhookEventClaimsAbandonedPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`WHERE \(status = 'failed' OR \(status = 'pending' AND claimed_at < .*\)\) AND id = \$1`).WithArgs(int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectQuery(`SELECT update_json FROM billing_webhook_events`).WithArgs(int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"update_json"}).AddRow([]byte(`not json`)))
+	mock.ExpectExec(`UPDATE billing_webhook_events`).WithArgs(int64(4), EventFailed, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	status, err := NewService(db).ReplayWebhookEvent(context.Background(), 4)
+	if status != EventFailed || err == nil {
+		t.Fatalf("expected the replay to run and record its failure, got %s, %v", status, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
@@ -15,6 +15,9 @@ const (
 	ProviderStripe     Provider = "stripe"
 	ProviderRevenueCat Provider = "revenuecat"
 	ProviderMonoPay    Provider = "monopay"
+	// ProviderInternal is the source of first-party grants. It is not a
+	// payment provider and Valid rejects it.
+	ProviderInternal Provider = "internal"
 )
 
 type SubscriptionStatus string
@@ -56,6 +59,23 @@ var (
 	ErrProductNotFound      = errors.New("billing product not found")
 	ErrInvalidProvider      = errors.New("invalid billing provider")
 	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrStaleEvent is returned for an update older than the last event
+	// applied to its subscription.
+	ErrStaleEvent = errors.New("billing event is older than the subscription state")
+	// ErrDuplicateEvent is returned for a webhook event already in the
+	// ledger and not awaiting a retry.
+	ErrDuplicateEvent = errors.New("billing event already received")
+	ErrEventNotFound  = errors.New("billing event not found")
+	// ErrCustomerNotFound is returned when no provider customer is mapped.
+	ErrCustomerNotFound = errors.New("billing customer not found")
+	ErrPromoNotFound    = errors.New("promo code not found")
+	ErrPromoExists      = errors.New("promo code already exists")
+	ErrPromoExpired     = errors.New("promo code expired")
+	ErrPromoExhausted   = errors.New("promo code has no redemptions left")
+	ErrPromoRedeemed    = errors.New("promo code already redeemed")
+	ErrTrialUsed        = errors.New("trial already used")
+	ErrGrantNotFound    = errors.New("grant not found")
+	ErrInvalidGrant     = errors.New("invalid grant")
 )
 
 type SubscriptionUpdate struct {
@@ -78,6 +98,9 @@ type SubscriptionUpdate struct {
 	EntitlementExpires     *time.Time
 	Metadata               map[string]any
 	RawEvent               json.RawMessage
+	// OccurredAt is the provider's event time. Updates without one apply
+	// regardless of order.
+	OccurredAt *time.Time
 }
 
 type ProductStore interface {
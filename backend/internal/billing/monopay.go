@@ -0,0 +1,597 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/integrations/monopay"
+)
+
+// MonoPay has no subscriptions of its own. Checkout saves the paying card
+// into the user's wallet and MonoPayRecurring charges it before each period
+// ends, so every MonoPay invoice we create is tracked until it settles.
+
+const (
+	InvoiceCheckout = "checkout"
+	InvoiceRenewal  = "renewal"
+)
+
+// DefaultMonoPayBatch bounds how many renewals or open invoices one pass
+// handles.
+const DefaultMonoPayBatch = 100
+
+// monoPayChargeLease keeps a claimed renewal from being charged again by
+// another worker while its charge is in flight.
+const monoPayChargeLease = 15 * time.Minute
+
+var ErrInvoiceNotFound = errors.New("monopay invoice not found")
+
+type MonoPayOptions struct {
+	// RenewalLead is how long before the period ends a renewal is charged.
+	RenewalLead time.Duration
+	// Dunning holds the delay before each retry of a failed renewal. Once
+	// it is exhausted the subscription is canceled.
+	Dunning []time.Duration
+	// ReconcileAfter is how long an invoice may stay open before its status
+	// is polled instead of waiting for the webhook.
+	ReconcileAfter time.Duration
+	WebhookURL     string
+	RedirectURL    string
+	Batch          int
+}
+
+type MonoPayInvoice struct {
+	InvoiceID   string
+	UserID      uuid.UUID
+	ProductCode string
+	Kind        string
+	Status      string
+	WalletID    string
+	PeriodStart *time.Time
+}
+
+type MonoPayRecurring struct {
+	svc    *Service
+	client *monopay.Client
+	opts   MonoPayOptions
+}
+
+type monoPaySubscription struct {
+	Ref            string
+	UserID         uuid.UUID
+	ProductCode    string
+	WalletID       string
+	CardToken      string
+	Status         string
+	PeriodEnd      time.Time
+	FailedAttempts int
+	// PendingChargeRef is the reference of a charge that was about to be
+	// sent when the last attempt stopped, and PendingChargeAt when.
+	PendingChargeRef string
+	PendingChargeAt  *time.Time
+}
+
+// NewMonoPayRecurring returns the MonoPay renewal scheduler. client may be
+// nil for callers that only track and settle invoices.
+func NewMonoPayRecurring(svc *Service, client *monopay.Client, opts MonoPayOptions) *MonoPayRecurring {
+	if opts.Batch <= 0 {
+		opts.Batch = DefaultMonoPayBatch
+	}
+	return &MonoPayRecurring{svc: svc, client: client, opts: opts}
+}
+
+// MonoPayWalletID is the wallet a user's cards are saved into.
+func MonoPayWalletID(userID uuid.UUID) string {
+	return userID.String()
+}
+
+func monoPaySubscriptionRef(userID uuid.UUID, productCode string) string {
+	return "monopay:" + userID.String() + ":" + productCode
+}
+
+// TrackInvoice records an invoice so it is settled even if its webhook is
+// lost.
+func (m *MonoPayRecurring) TrackInvoice(ctx context.Context, inv MonoPayInvoice) error {
+	return trackInvoice(ctx, m.svc.DB, inv)
+}
+
+func (m *MonoPayRecurring) Invoice(ctx context.Context, invoiceID string) (*MonoPayInvoice, error) {
+	var inv MonoPayInvoice
+	err := m.svc.DB.QueryRowContext(ctx, `
+SELECT invoice_id, user_id, product_code, kind, status, wallet_id, period_start
+FROM monopay_invoices
+WHERE invoice_id = $1`, invoiceID).Scan(&inv.InvoiceID, &inv.UserID, &inv.ProductCode, &inv.Kind, &inv.Status, &inv.WalletID, &inv.PeriodStart)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// Settle applies MonoPay's status for a tracked invoice. Webhooks, wallet
+// charges and reconciliation all end here; an invoice that already settled
+// returns ErrDuplicateEvent.
+func (m *MonoPayRecurring) Settle(ctx context.Context, result monopay.InvoiceStatus) error {
+	inv, err := m.Invoice(ctx, result.InvoiceID)
+	if err != nil {
+		return err
+	}
+	if monopay.FinalStatus(inv.Status) {
+		return ErrDuplicateEvent
+	}
+	if !monopay.FinalStatus(result.Status) {
+		_, err := m.svc.DB.ExecContext(ctx, `
+UPDATE monopay_invoices SET status = $2, updated_at = now()
+WHERE invoice_id = $1 AND settled_at IS NULL`, inv.InvoiceID, result.Status)
+		return err
+	}
+
+	settledAt := time.Now().UTC()
+	if t, err := time.Parse(time.RFC3339, result.ModifiedDate); err == nil {
+		settledAt = t.UTC()
+	}
+	ref := monoPaySubscriptionRef(inv.UserID, inv.ProductCode)
+	sub, err := m.subscription(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case result.Status == monopay.StatusSuccess:
+		return m.settlePaid(ctx, inv, sub, result, settledAt)
+	case result.Status == monopay.StatusReversed:
+		if err := m.apply(ctx, inv, result, StatusCanceled, &settledAt, settledAt); err != nil {
+			return err
+		}
+		return m.finish(ctx, inv, result, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, `
+UPDATE monopay_recurring
+SET status = 'canceled', next_charge_at = NULL, pending_invoice_id = NULL, pending_charge_ref = NULL, pending_charge_at = NULL, last_error = 'payment reversed', updated_at = now()
+WHERE subscription_ref = $1`, ref)
+			return err
+		})
+	case inv.Kind == InvoiceRenewal && sub != nil:
+		return m.failRenewal(ctx, sub, inv, result, settledAt)
+	default:
+		// An unpaid checkout leaves any existing subscription alone.
+		return m.finish(ctx, inv, result, nil)
+	}
+}
+
+func (m *MonoPayRecurring) settlePaid(ctx context.Context, inv *MonoPayInvoice, sub *monoPaySubscription, result monopay.InvoiceStatus, settledAt time.Time) error {
+	product, err := m.svc.GetProductByCode(ctx, inv.ProductCode)
+	if err != nil {
+		return err
+	}
+	start := settledAt
+	switch {
+	case inv.PeriodStart != nil:
+		start = *inv.PeriodStart
+	case sub != nil && sub.Status == "active" && sub.PeriodEnd.After(settledAt):
+		// Paying again by hand extends the running period.
+		start = sub.PeriodEnd
+	}
+	end := addInterval(start, product.Interval)
+	if err := m.apply(ctx, inv, result, StatusActive, &end, settledAt); err != nil {
+		return err
+	}
+	var cardToken sql.NullString
+	if result.WalletData != nil && result.WalletData.CardToken != "" {
+		cardToken = sql.NullString{String: result.WalletData.CardToken, Valid: true}
+	}
+	return m.finish(ctx, inv, result, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+INSERT INTO monopay_recurring (subscription_ref, user_id, product_code, wallet_id, card_token, period_end, next_charge_at)
+VALUES ($1,$2,$3,$4,$5,$6,$7)
+ON CONFLICT (subscription_ref) DO UPDATE SET
+	wallet_id = EXCLUDED.wallet_id,
+	card_token = COALESCE(EXCLUDED.card_token, monopay_recurring.card_token),
+	status = 'active',
+	period_end = EXCLUDED.period_end,
+	next_charge_at = EXCLUDED.next_charge_at,
+	failed_attempts = 0,
+	pending_invoice_id = NULL,
+	pending_charge_ref = NULL,
+	pending_charge_at = NULL,
+	last_error = NULL,
+	updated_at = now()`,
+			monoPaySubscriptionRef(inv.UserID, inv.ProductCode), inv.UserID, inv.ProductCode, inv.WalletID, cardToken, end, end.Add(-m.opts.RenewalLead))
+		return err
+	})
+}
+
+// failRenewal schedules the next dunning retry, or cancels the
+// subscription once the schedule is exhausted. inv is nil when the charge
+// could not even be attempted.
+func (m *MonoPayRecurring) failRenewal(ctx context.Context, sub *monoPaySubscription, inv *MonoPayInvoice, result monopay.InvoiceStatus, at time.Time) error {
+	attempts := sub.FailedAttempts + 1
+	reason := firstNonEmpty(result.FailureReason, result.Status)
+	if attempts <= len(m.opts.Dunning) {
+		next := at.Add(m.opts.Dunning[attempts-1])
+		return m.finish(ctx, inv, result, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, `
+UPDATE monopay_recurring
+SET failed_attempts = $2, next_charge_at = $3, pending_invoice_id = NULL, pending_charge_ref = NULL, pending_charge_at = NULL, last_error = $4, updated_at = now()
+WHERE subscription_ref = $1`, sub.Ref, attempts, next, reason)
+			return err
+		})
+	}
+
+	if inv == nil {
+		inv = &MonoPayInvoice{UserID: sub.UserID, ProductCode: sub.ProductCode, Kind: InvoiceRenewal, WalletID: sub.WalletID}
+		result.InvoiceID = fmt.Sprintf("%s:%d", sub.Ref, sub.PeriodEnd.Unix())
+	}
+	periodEnd := sub.PeriodEnd
+	if err := m.apply(ctx, inv, result, StatusCanceled, &periodEnd, at); err != nil {
+		return err
+	}
+	return m.finish(ctx, inv, result, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+UPDATE monopay_recurring
+SET status = 'canceled', failed_attempts = $2, next_charge_at = NULL, pending_invoice_id = NULL, pending_charge_ref = NULL, pending_charge_at = NULL, last_error = $3, updated_at = now()
+WHERE subscription_ref = $1`, sub.Ref, attempts, reason)
+		return err
+	})
+}
+
+// apply records the subscription change in the webhook ledger, keyed by
+// invoice and status so a webhook and a poll of the same outcome apply it
+// once.
+func (m *MonoPayRecurring) apply(ctx context.Context, inv *MonoPayInvoice, result monopay.InvoiceStatus, status SubscriptionStatus, periodEnd *time.Time, at time.Time) error {
+	product, err := m.svc.GetProductByCode(ctx, inv.ProductCode)
+	if err != nil {
+		return err
+	}
+	update := SubscriptionUpdate{
+		UserID:                 inv.UserID,
+		Provider:               ProviderMonoPay,
+		ProductCode:            product.Code,
+		ProductName:            product.Name,
+		ProductDescription:     product.Description,
+		ExternalProductID:      product.ExternalID,
+		ExternalSubscriptionID: monoPaySubscriptionRef(inv.UserID, inv.ProductCode),
+		ExternalCustomerID:     inv.WalletID,
+		Currency:               product.Currency,
+		AmountCents:            product.AmountCents,
+		Interval:               product.Interval,
+		Status:                 status,
+		CurrentPeriodEnd:       periodEnd,
+		EntitlementCode:        productEntitlement(product),
+		EntitlementExpires:     periodEnd,
+		Metadata: map[string]any{
+			"invoice_id":     result.InvoiceID,
+			"invoice_kind":   inv.Kind,
+			"monopay_status": result.Status,
+		},
+	}
+	if status == StatusCanceled {
+		update.CanceledAt = &at
+	}
+	event := WebhookEvent{
+		Provider:   ProviderMonoPay,
+		EventID:    result.InvoiceID + ":" + result.Status,
+		Type:       result.Status,
+		OccurredAt: at,
+	}
+	if _, err := m.svc.ProcessWebhookEvent(ctx, event, update); err != nil && !errors.Is(err, ErrDuplicateEvent) {
+		return err
+	}
+	return nil
+}
+
+// finish marks inv settled and runs the matching change to the recurring
+// row in one transaction. A concurrent settle of the same invoice wins and
+// this one returns ErrDuplicateEvent.
+func (m *MonoPayRecurring) finish(ctx context.Context, inv *MonoPayInvoice, result monopay.InvoiceStatus, recurring func(*sql.Tx) error) error {
+	tx, err := m.svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if inv != nil && inv.InvoiceID != "" {
+		res, err := tx.ExecContext(ctx, `
+UPDATE monopay_invoices
+SET status = $2, failure_reason = NULLIF($3, ''), settled_at = now(), updated_at = now()
+WHERE invoice_id = $1 AND settled_at IS NULL`, inv.InvoiceID, result.Status, result.FailureReason)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrDuplicateEvent
+		}
+	}
+	if recurring != nil {
+		if err := recurring(tx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ChargeDue charges the saved card of every active MonoPay subscription
+// whose renewal is due at now. It returns how many charges were started.
+func (m *MonoPayRecurring) ChargeDue(ctx context.Context, now time.Time) (int, error) {
+	if m.client == nil {
+		return 0, nil
+	}
+	rows, err := m.svc.DB.QueryContext(ctx, `
+WITH due AS (
+	SELECT subscription_ref FROM monopay_recurring
+	WHERE status = 'active'
+	  AND pending_invoice_id IS NULL
+	  AND next_charge_at <= $1
+	ORDER BY next_charge_at
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED
+)
+UPDATE monopay_recurring r
+SET next_charge_at = $3, updated_at = now()
+FROM due
+WHERE r.subscription_ref = due.subscription_ref
+RETURNING r.subscription_ref, r.user_id, r.product_code, r.wallet_id, COALESCE(r.card_token, ''), r.status, r.period_end, r.failed_attempts,
+	COALESCE(r.pending_charge_ref, ''), r.pending_charge_at`,
+		now, m.opts.Batch, now.Add(monoPayChargeLease))
+	if err != nil {
+		return 0, err
+	}
+	var due []monoPaySubscription
+	for rows.Next() {
+		var sub monoPaySubscription
+		if err := rows.Scan(&sub.Ref, &sub.UserID, &sub.ProductCode, &sub.WalletID, &sub.CardToken, &sub.Status, &sub.PeriodEnd, &sub.FailedAttempts,
+			&sub.PendingChargeRef, &sub.PendingChargeAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, sub)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	charged := 0
+	var errs []error
+	for i := range due {
+		ok, err := m.charge(ctx, &due[i], now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("renew %s: %w", due[i].Ref, err))
+		}
+		if ok {
+			charged++
+		}
+	}
+	return charged, errors.Join(errs...)
+}
+
+// charge starts one renewal. Errors MonoPay answered count as a failed
+// attempt; transport errors leave the lease to expire so the charge is
+// retried. The charge reference is stored before MonoPay is called, and a
+// retry first looks for a charge already made under it, so a worker dying
+// between the charge and recording its invoice does not charge twice.
+func (m *MonoPayRecurring) charge(ctx context.Context, sub *monoPaySubscription, now time.Time) (bool, error) {
+	product, err := m.svc.GetProductByCode(ctx, sub.ProductCode)
+	if err != nil {
+		return false, err
+	}
+	token := sub.CardToken
+	var result *monopay.InvoiceStatus
+	if sub.PendingChargeRef != "" && sub.PendingChargeAt != nil {
+		if result, err = m.findCharge(ctx, sub.PendingChargeRef, *sub.PendingChargeAt); err != nil {
+			return false, err
+		}
+	}
+	if result == nil {
+		if token == "" {
+			cards, err := m.client.Wallet(ctx, sub.WalletID)
+			if err != nil && !isMonoPayRejection(err) {
+				return false, err
+			}
+			if len(cards) == 0 {
+				return false, m.failRenewal(ctx, sub, nil, monopay.InvoiceStatus{Status: monopay.StatusFailure, FailureReason: "no saved card"}, now)
+			}
+			token = cards[0].CardToken
+		}
+
+		// One reference per period and attempt; a retry of the same
+		// attempt keeps the time it first started so findCharge still
+		// covers it.
+		reference := fmt.Sprintf("%s:%d:%d", sub.Ref, sub.PeriodEnd.Unix(), sub.FailedAttempts)
+		if _, err := m.svc.DB.ExecContext(ctx, `
+UPDATE monopay_recurring
+SET pending_charge_at = CASE WHEN pending_charge_ref = $2 THEN pending_charge_at ELSE $3 END,
+	pending_charge_ref = $2, updated_at = now()
+WHERE subscription_ref = $1`, sub.Ref, reference, now); err != nil {
+			return false, err
+		}
+		result, err = m.client.ChargeWallet(ctx, monopay.WalletChargeRequest{
+			CardToken:   token,
+			AmountCents: product.AmountCents,
+			Currency:    product.Currency,
+			Reference:   reference,
+			Destination: firstNonEmpty(product.Description, product.Name),
+			RedirectURL: m.opts.RedirectURL,
+			WebhookURL:  m.opts.WebhookURL,
+		})
+		if err != nil {
+			if isMonoPayRejection(err) {
+				return false, m.failRenewal(ctx, sub, nil, monopay.InvoiceStatus{Status: monopay.StatusFailure, FailureReason: err.Error()}, now)
+			}
+			return false, err
+		}
+	}
+
+	periodStart := sub.PeriodEnd
+	inv := MonoPayInvoice{
+		InvoiceID:   result.InvoiceID,
+		UserID:      sub.UserID,
+		ProductCode: sub.ProductCode,
+		Kind:        InvoiceRenewal,
+		Status:      monopay.StatusCreated,
+		WalletID:    sub.WalletID,
+		PeriodStart: &periodStart,
+	}
+	tx, err := m.svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if err := trackInvoice(ctx, tx, inv); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+UPDATE monopay_recurring
+SET card_token = COALESCE(NULLIF($2, ''), card_token), pending_invoice_id = $3, pending_charge_ref = NULL, pending_charge_at = NULL,
+	next_charge_at = NULL, updated_at = now()
+WHERE subscription_ref = $1`, sub.Ref, token, result.InvoiceID); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	if monopay.FinalStatus(result.Status) {
+		if err := m.Settle(ctx, *result); err != nil && !errors.Is(err, ErrDuplicateEvent) {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// findCharge returns the charge MonoPay holds under reference, made since
+// the attempt that stored it started, or nil when it never reached MonoPay.
+func (m *MonoPayRecurring) findCharge(ctx context.Context, reference string, since time.Time) (*monopay.InvoiceStatus, error) {
+	// A minute of slack covers clock skew between us and MonoPay.
+	invoices, err := m.client.Statement(ctx, since.Add(-time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	for i := range invoices {
+		if invoices[i].Reference == reference {
+			return &invoices[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Reconcile polls MonoPay for invoices still open ReconcileAfter after
+// they were created and settles those that reached a final status. It
+// returns how many were settled.
+func (m *MonoPayRecurring) Reconcile(ctx context.Context, now time.Time) (int, error) {
+	if m.client == nil {
+		return 0, nil
+	}
+	rows, err := m.svc.DB.QueryContext(ctx, `
+SELECT invoice_id FROM monopay_invoices
+WHERE settled_at IS NULL AND created_at <= $1
+ORDER BY created_at
+LIMIT $2`, now.Add(-m.opts.ReconcileAfter), m.opts.Batch)
+	if err != nil {
+		return 0, err
+	}
+	var open []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		open = append(open, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	var errs []error
+	for _, id := range open {
+		status, err := m.client.InvoiceStatus(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invoice %s: %w", id, err))
+			continue
+		}
+		err = m.Settle(ctx, *status)
+		switch {
+		case errors.Is(err, ErrDuplicateEvent):
+		case err != nil:
+			errs = append(errs, fmt.Errorf("invoice %s: %w", id, err))
+		case monopay.FinalStatus(status.Status):
+			settled++
+		}
+	}
+	return settled, errors.Join(errs...)
+}
+
+func (m *MonoPayRecurring) subscription(ctx context.Context, ref string) (*monoPaySubscription, error) {
+	sub := monoPaySubscription{Ref: ref}
+	err := m.svc.DB.QueryRowContext(ctx, `
+SELECT user_id, product_code, wallet_id, COALESCE(card_token, ''), status, period_end, failed_attempts
+FROM monopay_recurring
+WHERE subscription_ref = $1`, ref).Scan(&sub.UserID, &sub.ProductCode, &sub.WalletID, &sub.CardToken, &sub.Status, &sub.PeriodEnd, &sub.FailedAttempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func trackInvoice(ctx context.Context, db execer, inv MonoPayInvoice) error {
+	status := inv.Status
+	if status == "" {
+		status = monopay.StatusCreated
+	}
+	_, err := db.ExecContext(ctx, `
+INSERT INTO monopay_invoices (invoice_id, user_id, product_code, kind, status, wallet_id, period_start)
+VALUES ($1,$2,$3,$4,$5,$6,$7)`, inv.InvoiceID, inv.UserID, inv.ProductCode, inv.Kind, status, inv.WalletID, inv.PeriodStart)
+	return err
+}
+
+// productEntitlement is the entitlement a product grants: metadata
+// "entitlement" when set, otherwise the product code.
+func productEntitlement(p *Product) string {
+	var meta struct {
+		Entitlement string `json:"entitlement"`
+	}
+	if len(p.Metadata) > 0 && json.Unmarshal(p.Metadata, &meta) == nil && meta.Entitlement != "" {
+		return meta.Entitlement
+	}
+	return p.Code
+}
+
+func addInterval(t time.Time, interval string) time.Time {
+	if interval == "year" {
+		return t.AddDate(1, 0, 0)
+	}
+	return t.AddDate(0, 1, 0)
+}
+
+func isMonoPayRejection(err error) bool {
+	var apiErr *monopay.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode < 500
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/integrations/monopay"
+)
+
+// fakeMonoPay serves the MonoPay endpoints the scheduler uses with canned
+// answers.
+func fakeMonoPay(t *testing.T, routes map[string]string) *monopay.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected monopay call %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return monopay.NewClient(monopay.Config{BaseURL: srv.URL, APIToken: "token", Merchant: "m1"})
+}
+
+func TestChargeDueStartsRenewal(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	client := fakeMonoPay(t, map[string]string{
+		"POST /wallet/payment": `{"invoiceId":"inv_renew","status":"processing"}`,
+	})
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	periodEnd := now.Add(20 * time.Hour)
+	userID := uuid.New()
+	ref := monoPaySubscriptionRef(userID, "pro_ua_monthly")
+
+	mock.ExpectQuery(`UPDATE monopay_recurring r`).WithArgs(now, DefaultMonoPayBatch, now.Add(monoPayChargeLease)).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription_ref", "user_id", "product_code", "wallet_id", "card_token", "status", "period_end", "failed_attempts", "pending_charge_ref", "pending_charge_at"}).
+			AddRow(ref, userID, "pro_ua_monthly", userID.String(), "tok_1", "active", periodEnd, 0, "", nil))
+	mock.ExpectQuery(`FROM billing_products`).WithArgs("pro_ua_monthly").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name", "description", "provider", "external_id", "currency", "amount_cents", "interval", "metadata"}).
+			AddRow(uuid.New(), "pro_ua_monthly", "Pro UA Monthly", "", "monopay", "", "UAH", 5900, "month", []byte(`{}`)))
+	mock.ExpectExec(`SET pending_charge_at`).WithArgs(ref, fmt.Sprintf("%s:%d:0", ref, periodEnd.Unix()), now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO monopay_invoices`).
+		WithArgs("inv_renew", userID, "pro_ua_monthly", InvoiceRenewal, monopay.StatusCreated, userID.String(), &periodEnd).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE monopay_recurring`).WithArgs(ref, "tok_1", "inv_renew").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	recurring := NewMonoPayRecurring(NewService(db), client, MonoPayOptions{RenewalLead: 24 * time.Hour})
+	charged, err := recurring.ChargeDue(context.Background(), now)
+	if err != nil {
+		t.Fatalf("charge due: %v", err)
+	}
+	if charged != 1 {
+		t.Fatalf("expected one renewal charged, got %d", charged)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestReconcileSchedulesDunningRetry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	client := fakeMonoPay(t, map[string]string{
+		"GET /invoice/status": `{"invoiceId":"inv_renew","status":"failure","failureReason":"insufficient funds","modifiedDate":"2026-05-01T10:00:00Z"}`,
+	})
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	failedAt := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	periodEnd := now.Add(20 * time.Hour)
+	userID := uuid.New()
+	ref := monoPaySubscriptionRef(userID, "pro_ua_monthly")
+
+	mock.ExpectQuery(`SELECT invoice_id FROM monopay_invoices`).WithArgs(now.Add(-15*time.Minute), DefaultMonoPayBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"invoice_id"}).AddRow("inv_renew"))
+	mock.ExpectQuery(`FROM monopay_invoices`).WithArgs("inv_renew").
+		WillReturnRows(sqlmock.NewRows([]string{"invoice_id", "user_id", "product_code", "kind", "status", "wallet_id", "period_start"}).
+			AddRow("inv_renew", userID, "pro_ua_monthly", InvoiceRenewal, monopay.StatusProcessing, userID.String(), periodEnd))
+	mock.ExpectQuery(`FROM monopay_recurring`).WithArgs(ref).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "product_code", "wallet_id", "card_token", "status", "period_end", "failed_attempts"}).
+			AddRow(userID, "pro_ua_monthly", userID.String(), "tok_1", "active", periodEnd, 0))
+	// The first failure keeps the subscription and retries after the
+	// first dunning delay; nothing reaches the subscription itself.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE monopay_invoices`).WithArgs("inv_renew", monopay.StatusFailure, "insufficient funds").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE monopay_recurring`).WithArgs(ref, 1, failedAt.Add(6*time.Hour), "insufficient funds").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	recurring := NewMonoPayRecurring(NewService(db), client, MonoPayOptions{
+		Dunning:        []time.Duration{6 * time.Hour, 24 * time.Hour},
+		ReconcileAfter: 15 * time.Minute,
+	})
+	settled, err := recurring.Reconcile(context.Background(), now)
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if settled != 1 {
+		t.Fatalf("expected one invoice settled, got %d", settled)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestChargeDueAdoptsChargeFromAbandonedAttempt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	periodEnd := now.Add(20 * time.Hour)
+	startedAt := now.Add(-monoPayChargeLease)
+	userID := uuid.New()
+	ref := monoPaySubscriptionRef(userID, "pro_ua_monthly")
+	pending := fmt.Sprintf("%s:%d:0", ref, periodEnd.Unix())
+
+	// The earlier attempt charged the card and died before recording the
+	// invoice; its charge shows up in the statement, so no second charge
+	// is sent.
+	client := fakeMonoPay(t, map[string]string{
+		"GET /statement": `{"list":[{"invoiceId":"inv_other","status":"success","reference":"other"},` +
+			`{"invoiceId":"inv_renew","status":"processing","reference":"` + pending + `"}]}`,
+	})
+
+	mock.ExpectQuery(`UPDATE monopay_recurring r`).WithArgs(now, DefaultMonoPayBatch, now.Add(monoPayChargeLease)).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription_ref", "user_id", "product_code", "wallet_id", "card_token", "status", "period_end", "failed_attempts", "pending_charge_ref", "pending_charge_at"}).
+			AddRow(ref, userID, "pro_ua_monthly", userID.String(), "tok_1", "active", periodEnd, 0, pending, startedAt))
+	mock.ExpectQuery(`FROM billing_products`).WithArgs("pro_ua_monthly").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name", "description", "provider", "external_id", "currency", "amount_cents", "interval", "metadata"}).
+			AddRow(uuid.New(), "pro_ua_monthly", "Pro UA Monthly", "", "monopay", "", "UAH", 5900, "month", []byte(`{}`)))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO monopay_invoices`).
+		WithArgs("inv_renew", userID, "pro_ua_monthly", InvoiceRenewal, monopay.StatusCreated, userID.String(), &periodEnd).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`pending_charge_ref = NULL`).WithArgs(ref, "tok_1", "inv_renew").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	recurring := NewMonoPayRecurring(NewService(db), client, MonoPayOptions{RenewalLead: 24 * time.Hour})
+	charged, err := recurring.ChargeDue(context.Background(), now)
+	if err != nil {
+		t.Fatalf("charge due: %v", err)
+	}
+	if charged != 1 {
+		t.Fatalf("expected the renewal to be picked up, got %d", charged)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
@@ -80,6 +80,25 @@ LIMIT 1;
 	return &snap, nil
 }
 
+// HasEntitlement reports whether the user holds an active, unexpired
+// entitlement with the given code.
+func (s *Service) HasEntitlement(ctx context.Context, userID uuid.UUID, code string) (bool, error) {
+	const query = `
+SELECT EXISTS (
+	SELECT 1 FROM billing_entitlements
+	WHERE user_id = $1
+	  AND code = $2
+	  AND status = 'active'
+	  AND (expires_at IS NULL OR expires_at > NOW())
+);
+`
+	var ok bool
+	if err := s.DB.QueryRowContext(ctx, query, userID, code).Scan(&ok); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
 func (s *Service) ApplySubscriptionUpdate(ctx context.Context, update SubscriptionUpdate) error {
 	if !update.Provider.Valid() {
 		return ErrInvalidProvider
@@ -105,6 +124,10 @@ func (s *Service) ApplySubscriptionUpdate(ctx context.Context, update Subscripti
 		return err
 	}
 
+	if err := saveCustomer(ctx, tx, update.UserID, update.Provider, update.ExternalCustomerID); err != nil {
+		return err
+	}
+
 	if update.EntitlementCode != "" {
 		if err := syncEntitlement(ctx, tx, update); err != nil {
 			return err
@@ -158,9 +181,9 @@ func upsertSubscription(ctx context.Context, tx *sql.Tx, update SubscriptionUpda
 	const query = `
 INSERT INTO user_subscriptions (
 	user_id, product_id, provider, status, started_at, current_period_end, cancel_at, canceled_at,
-	external_customer_id, external_subscription_id, metadata, updated_at
+	external_customer_id, external_subscription_id, metadata, last_event_at, updated_at
 ) VALUES (
-	$1,$2,$3,$4,COALESCE($5, now()),$6,$7,$8,$9,$10,COALESCE($11,'{}'::jsonb), now()
+	$1,$2,$3,$4,COALESCE($5, now()),$6,$7,$8,$9,$10,COALESCE($11,'{}'::jsonb),$12, now()
 ) ON CONFLICT (provider, external_subscription_id)
 DO UPDATE SET
 	product_id = EXCLUDED.product_id,
@@ -170,7 +193,11 @@ DO UPDATE SET
 	canceled_at = EXCLUDED.canceled_at,
 	external_customer_id = EXCLUDED.external_customer_id,
 	metadata = EXCLUDED.metadata,
+	last_event_at = COALESCE(EXCLUDED.last_event_at, user_subscriptions.last_event_at),
 	updated_at = EXCLUDED.updated_at
+WHERE user_subscriptions.last_event_at IS NULL
+   OR EXCLUDED.last_event_at IS NULL
+   OR user_subscriptions.last_event_at <= EXCLUDED.last_event_at
 RETURNING id;
 `
 	var startedAt *time.Time
@@ -178,7 +205,10 @@ RETURNING id;
 		now := time.Now()
 		startedAt = &now
 	}
-	_, err := tx.ExecContext(ctx, query,
+	// A newer event already applied leaves the row untouched and returns
+	// nothing, so a late "active" cannot undo a "canceled".
+	var subscriptionID uuid.UUID
+	err := tx.QueryRowContext(ctx, query,
 		update.UserID,
 		productID,
 		update.Provider,
@@ -190,7 +220,11 @@ RETURNING id;
 		update.ExternalCustomerID,
 		update.ExternalSubscriptionID,
 		metadata,
-	)
+		update.OccurredAt,
+	).Scan(&subscriptionID)
+	if err == sql.ErrNoRows {
+		return ErrStaleEvent
+	}
 	return err
 }
 
@@ -229,8 +263,18 @@ DO UPDATE SET
 	expires_at = EXCLUDED.expires_at,
 	source = EXCLUDED.source,
 	metadata = EXCLUDED.metadata,
-	updated_at = EXCLUDED.updated_at;
+	provider_source = NULL,
+	provider_status = NULL,
+	provider_expires_at = NULL,
+	updated_at = EXCLUDED.updated_at
+WHERE billing_entitlements.source <> 'internal'
+   OR billing_entitlements.status <> 'active'
+   OR billing_entitlements.expires_at <= now()
+   OR (EXCLUDED.status = 'active' AND (EXCLUDED.expires_at IS NULL OR EXCLUDED.expires_at >= billing_entitlements.expires_at));
 `
+	// A live first-party grant is only replaced by provider access that
+	// lasts at least as long. Until then the provider's state is kept
+	// beside the grant, for when the grant is revoked.
 	meta := map[string]any{
 		"subscription_status": update.Status,
 	}
@@ -241,20 +285,45 @@ DO UPDATE SET
 	}
 	metaJSON := mapToJSON(meta)
 
-	if _, err := tx.ExecContext(ctx, upsert,
+	res, err := tx.ExecContext(ctx, upsert,
 		update.UserID,
 		update.EntitlementCode,
 		update.Provider,
 		status,
 		update.EntitlementExpires,
 		metaJSON,
-	); err != nil {
+	)
+	if err != nil {
 		return err
 	}
-	return refreshUserPlan(ctx, tx, update.UserID)
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		if _, err := tx.ExecContext(ctx, `
+UPDATE billing_entitlements
+SET provider_source = $3, provider_status = $4, provider_expires_at = $5, updated_at = now()
+WHERE user_id = $1 AND code = $2`, update.UserID, update.EntitlementCode, update.Provider, status, update.EntitlementExpires); err != nil {
+			return err
+		}
+	}
+	_, err = refreshUserPlan(ctx, tx, update.UserID)
+	return err
+}
+
+// planChange is a user's plan before and after refreshUserPlan.
+type planChange struct {
+	Before string
+	After  string
 }
 
-func refreshUserPlan(ctx context.Context, tx *sql.Tx, userID uuid.UUID) error {
+func (c planChange) Changed() bool {
+	return c.Before != c.After
+}
+
+// refreshUserPlan derives users.plan from the user's live entitlements.
+// Access tokens carry the plan they were issued for, so a change makes
+// outstanding ones stale.
+func refreshUserPlan(ctx context.Context, tx *sql.Tx, userID uuid.UUID) (planChange, error) {
 	const countQuery = `
 SELECT COUNT(*) FROM billing_entitlements
 WHERE user_id = $1
@@ -263,14 +332,20 @@ WHERE user_id = $1
 `
 	var count int
 	if err := tx.QueryRowContext(ctx, countQuery, userID).Scan(&count); err != nil {
-		return err
+		return planChange{}, err
 	}
-	plan := "free"
+	change := planChange{After: "free"}
 	if count > 0 {
-		plan = "pro"
+		change.After = "pro"
 	}
-	_, err := tx.ExecContext(ctx, `UPDATE users SET plan = $1, updated_at = NOW() WHERE id = $2`, plan, userID)
-	return err
+	if err := tx.QueryRowContext(ctx, `SELECT plan FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&change.Before); err != nil {
+		return planChange{}, err
+	}
+	if !change.Changed() {
+		return change, nil
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE users SET plan = $1, updated_at = NOW() WHERE id = $2`, change.After, userID)
+	return change, err
 }
 
 func mapToJSON(src map[string]any) json.RawMessage {
@@ -0,0 +1,157 @@
+package geo
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CityDB is an in-memory IP-to-city database loaded from a DB-IP "IP to
+// City Lite" CSV export, optionally gzipped. Each row reads
+//
+//	ip_start,ip_end,continent,country,stateprov,city,latitude,longitude
+//
+// A nil *CityDB resolves nothing, so callers need not check whether a
+// database is configured.
+type CityDB struct {
+	ranges    []ipRange
+	locations []Location
+	cities    map[string]int
+}
+
+type ipRange struct {
+	start, end netip.Addr
+	location   int
+}
+
+// OpenCityDB loads the CSV at path, decompressing it when it ends in .gz.
+func OpenCityDB(path string) (*CityDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	db, err := LoadCityDB(r)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// LoadCityDB parses a city CSV. A header row, if present, is skipped.
+func LoadCityDB(r io.Reader) (*CityDB, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 8
+	reader.ReuseRecord = true
+
+	db := &CityDB{cities: make(map[string]int)}
+	seen := make(map[Location]int)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, err := netip.ParseAddr(record[0])
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		end, err := netip.ParseAddr(record[1])
+		if err != nil || end.Is4() != start.Is4() || end.Less(start) {
+			return nil, fmt.Errorf("line %d: invalid range %s-%s", line, record[0], record[1])
+		}
+		lat, latErr := strconv.ParseFloat(record[6], 64)
+		lon, lonErr := strconv.ParseFloat(record[7], 64)
+		if latErr != nil || lonErr != nil {
+			return nil, fmt.Errorf("line %d: invalid coordinates", line)
+		}
+
+		loc := Location{
+			City:      strings.TrimSpace(record[5]),
+			Region:    strings.TrimSpace(record[4]),
+			Country:   NormalizeCountry(record[3]),
+			Continent: strings.ToUpper(strings.TrimSpace(record[2])),
+			Lat:       lat,
+			Lon:       lon,
+		}
+		idx, ok := seen[loc]
+		if !ok {
+			idx = len(db.locations)
+			db.locations = append(db.locations, loc)
+			seen[loc] = idx
+			// A city split across several entries resolves to the first.
+			key := cityKey(loc.City, loc.Country)
+			if _, dup := db.cities[key]; !dup && loc.City != "" {
+				db.cities[key] = idx
+			}
+		}
+		db.ranges = append(db.ranges, ipRange{start: start, end: end, location: idx})
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return db.ranges[i].start.Less(db.ranges[j].start)
+	})
+	return db, nil
+}
+
+// Lookup returns the city an address belongs to.
+func (db *CityDB) Lookup(addr netip.Addr) (Location, bool) {
+	if db == nil || !addr.IsValid() {
+		return Location{}, false
+	}
+	addr = addr.Unmap()
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return addr.Less(db.ranges[i].start)
+	}) - 1
+	if i < 0 || db.ranges[i].end.Less(addr) {
+		return Location{}, false
+	}
+	return db.locations[db.ranges[i].location], true
+}
+
+// LookupIP is Lookup for an address in text form, as taken from a request.
+func (db *CityDB) LookupIP(ip string) (Location, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return Location{}, false
+	}
+	return db.Lookup(addr)
+}
+
+// City finds a city by name within a country, case-insensitively.
+func (db *CityDB) City(name, country string) (Location, bool) {
+	if db == nil {
+		return Location{}, false
+	}
+	idx, ok := db.cities[cityKey(name, country)]
+	if !ok {
+		return Location{}, false
+	}
+	return db.locations[idx], true
+}
+
+func cityKey(name, country string) string {
+	return NormalizeCountry(country) + "|" + strings.ToLower(strings.TrimSpace(name))
+}
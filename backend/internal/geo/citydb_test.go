@@ -0,0 +1,96 @@
+package geo
+
+import (
+	"math"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+const sampleCSV = `ip_start,ip_end,continent,country,stateprov,city,latitude,longitude
+5.58.0.0,5.58.255.255,EU,UA,Kyiv City,Kyiv,50.4501,30.5234
+5.248.0.0,5.248.127.255,EU,UA,Lviv Oblast,Lviv,49.8397,24.0297
+5.248.128.0,5.248.255.255,EU,UA,Kyiv City,Kyiv,50.4501,30.5234
+2a02:2378::,2a02:2378:ffff:ffff:ffff:ffff:ffff:ffff,EU,UA,Lviv Oblast,Lviv,49.8397,24.0297
+8.8.8.0,8.8.8.255,NA,US,California,Mountain View,37.4056,-122.0775
+`
+
+func TestCityDBLookup(t *testing.T) {
+	db, err := LoadCityDB(strings.NewReader(sampleCSV))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	cases := []struct {
+		ip   string
+		city string
+	}{
+		{"5.58.10.1", "Kyiv"},
+		{"5.248.0.0", "Lviv"},
+		{"5.248.200.7", "Kyiv"},
+		{"::ffff:5.248.1.1", "Lviv"},
+		{"2a02:2378:10::1", "Lviv"},
+		{"8.8.8.8", "Mountain View"},
+		{"5.59.0.1", ""},
+		{"1.1.1.1", ""},
+		{"not-an-ip", ""},
+	}
+	for _, tc := range cases {
+		loc, ok := db.LookupIP(tc.ip)
+		if ok != (tc.city != "") || loc.City != tc.city {
+			t.Fatalf("%s: expected %q, got %q (%v)", tc.ip, tc.city, loc.City, ok)
+		}
+	}
+
+	if loc, _ := db.LookupIP("8.8.8.8"); loc.Country != "US" || loc.Continent != ContinentNorthAmerica {
+		t.Fatalf("unexpected location %+v", loc)
+	}
+}
+
+func TestCityDBCity(t *testing.T) {
+	db, err := LoadCityDB(strings.NewReader(sampleCSV))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if loc, ok := db.City(" lviv ", "ua"); !ok || loc.Region != "Lviv Oblast" {
+		t.Fatalf("expected Lviv, got %+v (%v)", loc, ok)
+	}
+	if _, ok := db.City("Lviv", "PL"); ok {
+		t.Fatal("expected city to be scoped to its country")
+	}
+
+	var nilDB *CityDB
+	if _, ok := nilDB.City("Lviv", "UA"); ok {
+		t.Fatal("expected nil database to resolve nothing")
+	}
+	if _, ok := nilDB.Lookup(netip.MustParseAddr("5.58.0.1")); ok {
+		t.Fatal("expected nil database to resolve nothing")
+	}
+}
+
+func TestLoadCityDBRejectsBadRows(t *testing.T) {
+	for _, row := range []string{
+		"5.58.0.0,5.57.0.0,EU,UA,,Kyiv,50.45,30.52\n",
+		"5.58.0.0,2a02::,EU,UA,,Kyiv,50.45,30.52\n",
+		"5.58.0.0,5.58.0.255,EU,UA,,Kyiv,north,30.52\n",
+		"1.0.0.0,1.0.0.255,EU,UA,,Kyiv,50.45,30.52\nbogus,5.58.0.255,EU,UA,,Kyiv,50.45,30.52\n",
+	} {
+		if _, err := LoadCityDB(strings.NewReader(row)); err == nil {
+			t.Fatalf("expected %q to be rejected", row)
+		}
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	kyiv := Location{Lat: 50.4501, Lon: 30.5234}
+	lviv := Location{Lat: 49.8397, Lon: 24.0297}
+	if d := DistanceKm(kyiv, lviv); math.Abs(d-468) > 5 {
+		t.Fatalf("expected about 468 km, got %.1f", d)
+	}
+	if d := DistanceKm(kyiv, kyiv); d != 0 {
+		t.Fatalf("expected zero distance, got %f", d)
+	}
+	if c := kyiv.Coarse(); c.Lat != 50.5 || c.Lon != 30.5 {
+		t.Fatalf("unexpected coarse location %+v", c)
+	}
+}
@@ -0,0 +1,65 @@
+// Package geo resolves IP addresses and city names to coarse locations using
+// an offline city database, so no request ever leaves the service to locate
+// a listener.
+package geo
+
+import (
+	"math"
+	"strings"
+)
+
+// NearbyRadiusKm is how far from a viewer a place may be to count as nearby.
+const NearbyRadiusKm = 100
+
+const earthRadiusKm = 6371
+
+// Continent codes used by the city database.
+const (
+	ContinentEurope       = "EU"
+	ContinentNorthAmerica = "NA"
+)
+
+// Location is a city-level place. Coordinates are the city's, never a
+// listener's own position.
+type Location struct {
+	City      string  `json:"city"`
+	Region    string  `json:"region,omitempty"`
+	Country   string  `json:"country"`
+	Continent string  `json:"continent,omitempty"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+}
+
+// HasPoint reports whether the location carries coordinates.
+func (l Location) HasPoint() bool {
+	return l.Lat != 0 || l.Lon != 0
+}
+
+// Coarse rounds the coordinates to a tenth of a degree, about 11 km.
+func (l Location) Coarse() Location {
+	l.Lat = math.Round(l.Lat*10) / 10
+	l.Lon = math.Round(l.Lon*10) / 10
+	return l
+}
+
+// DistanceKm returns the great-circle distance between two locations.
+func DistanceKm(a, b Location) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat, dLon := lat2-lat1, radians(b.Lon-a.Lon)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// NormalizeCountry upper-cases an ISO 3166-1 alpha-2 code, returning "" for
+// anything else.
+func NormalizeCountry(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return ""
+	}
+	return code
+}
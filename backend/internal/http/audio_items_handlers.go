@@ -1,13 +1,19 @@
 package http
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 
 	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/httpctx"
+	"github.com/amunx/backend/internal/storage"
 )
 
 // CreateAudioItemRequest represents the request to create a new audio item
@@ -277,14 +283,61 @@ func DeleteAudioItem(w http.ResponseWriter, r *http.Request, deps *app.App) {
 		return
 	}
 
-	// TODO: Check ownership
-	// TODO: Delete using sqlc (will cascade to transcripts, summaries, clips, embeddings, etc.)
+	// The row delete cascades to transcripts, summaries, clips, embeddings,
+	// etc. It is only committed once the stored media is gone, so a storage
+	// failure leaves the item in place for a retry instead of orphaning files.
+	tx, err := deps.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "delete_failed", err.Error())
+		return
+	}
+	defer tx.Rollback()
 
-	_ = audioUUID
+	const stmt = `DELETE FROM audio_items WHERE id = $1 AND owner_id = $2 RETURNING s3_key`
+	var s3Key sql.NullString
+	if err := tx.QueryRowContext(r.Context(), stmt, audioUUID, userID).Scan(&s3Key); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteError(w, http.StatusNotFound, "not_found", "audio item not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "delete_failed", err.Error())
+		return
+	}
+
+	if err := purgeAudioObjects(r.Context(), deps.Storage, audioUUID, s3Key.String); err != nil {
+		WriteError(w, http.StatusInternalServerError, "storage_error", err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		WriteError(w, http.StatusInternalServerError, "delete_failed", err.Error())
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// purgeAudioObjects deletes the uploaded original and everything the workers
+// derived from it under episodes/<id>/.
+func purgeAudioObjects(ctx context.Context, store storage.Client, audioID uuid.UUID, s3Key string) error {
+	keys := make(map[string]struct{})
+	if s3Key != "" {
+		keys[s3Key] = struct{}{}
+	}
+	derived, err := store.ListPrefix(ctx, "episodes/"+audioID.String()+"/")
+	if err != nil {
+		return err
+	}
+	for _, obj := range derived {
+		keys[obj.Key] = struct{}{}
+	}
+	for key := range keys {
+		if err := store.DeleteObject(ctx, key); err != nil && !errors.Is(err, storage.ErrObjectNotFound) {
+			return fmt.Errorf("delete %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
 // LikeAudioItem likes an audio item (POST /audio/:id/like)
 func LikeAudioItem(w http.ResponseWriter, r *http.Request, deps *app.App) {
 	audioID := chi.URLParam(r, "id")
@@ -375,12 +428,8 @@ func UnsaveAudioItem(w http.ResponseWriter, r *http.Request, deps *app.App) {
 
 // Helper to get user ID from context (set by auth middleware)
 func getUserID(r *http.Request) uuid.UUID {
-	userIDVal := r.Context().Value("user_id")
-	if userIDVal == nil {
-		return uuid.Nil
-	}
-	if uid, ok := userIDVal.(uuid.UUID); ok {
-		return uid
+	if user, ok := httpctx.UserFromContext(r.Context()); ok {
+		return user.ID
 	}
 	return uuid.Nil
 }
@@ -3,11 +3,14 @@ package http
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 
 	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/queue"
+	"github.com/amunx/backend/internal/worker"
 )
 
 // GenerateAudiogramRequest represents the request to generate an audiogram
@@ -73,15 +76,33 @@ func GenerateAudiogramHandler(w http.ResponseWriter, r *http.Request, deps *app.
 		return
 	}
 
-	_ = audioUUID
-
 	// TODO: Check audio ownership or public access
-	// TODO: Queue job to Redis for worker
 	// TODO: Store job in database or Redis
 
 	jobID := uuid.New().String()
+	now := time.Now().UTC()
+	job, err := json.Marshal(worker.AudiogramJob{
+		JobID:        jobID,
+		AudioID:      audioUUID.String(),
+		ClipID:       req.ClipID,
+		StartSec:     req.StartSec,
+		EndSec:       req.EndSec,
+		StylePreset:  req.StylePreset,
+		SubtitleLang: req.SubtitleLang,
+		CoverText:    req.CoverText,
+		Status:       "queued",
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "audiogram_queue_failed", err.Error())
+		return
+	}
+	if err := deps.Queue.Enqueue(r.Context(), queue.TopicAudiogram, map[string]any{"job": string(job)}); err != nil {
+		WriteError(w, http.StatusServiceUnavailable, "audiogram_queue_failed", err.Error())
+		return
+	}
 
-	// Mock response
 	response := AudiogramJobResponse{
 		JobID:  jobID,
 		Status: "queued",
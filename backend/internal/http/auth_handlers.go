@@ -121,6 +121,57 @@ func registerAuthRoutes(r chi.Router, deps *app.App, logger zerolog.Logger) {
 			})
 		})
 
+		// Access tokens carry the user's plan and are refused once it
+		// changes; clients trade their refresh token for a current pair.
+		ar.Post("/refresh", func(w http.ResponseWriter, req *http.Request) {
+			var payload struct {
+				RefreshToken string `json:"refresh_token"`
+			}
+			if err := decodeJSON(req, &payload); err != nil {
+				WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
+				return
+			}
+
+			claims, err := deps.JWT.VerifyRefresh(payload.RefreshToken)
+			if err != nil {
+				WriteError(w, http.StatusUnauthorized, "invalid_token", "refresh token verification failed")
+				return
+			}
+			userID, err := uuid.Parse(claims.UserID)
+			if err != nil {
+				WriteError(w, http.StatusUnauthorized, "invalid_token", "invalid user id")
+				return
+			}
+			var plan string
+			if err := deps.DB.QueryRowContext(req.Context(), `SELECT plan FROM users WHERE id = $1`, userID).Scan(&plan); err != nil {
+				if err == sql.ErrNoRows {
+					WriteError(w, http.StatusUnauthorized, "invalid_token", "user not found")
+					return
+				}
+				WriteError(w, http.StatusInternalServerError, "internal_error", "could not load user")
+				return
+			}
+
+			accessToken, err := deps.JWT.IssueAccess(claims.UserID, plan)
+			if err != nil {
+				WriteError(w, http.StatusInternalServerError, "internal_error", "failed to issue access token")
+				return
+			}
+			refreshToken, err := deps.JWT.IssueRefresh(claims.UserID)
+			if err != nil {
+				WriteError(w, http.StatusInternalServerError, "internal_error", "failed to issue refresh token")
+				return
+			}
+
+			WriteJSON(w, http.StatusOK, map[string]any{
+				"access_token":  accessToken,
+				"refresh_token": refreshToken,
+				"expires_in":    int(deps.Config.JWTAccessTTL / time.Second),
+				"user_id":       claims.UserID,
+				"plan":          plan,
+			})
+		})
+
 		if deps.Config.Environment == "development" {
 			ar.Post("/dev-login", func(w http.ResponseWriter, req *http.Request) {
 				var payload struct {
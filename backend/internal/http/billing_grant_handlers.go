@@ -0,0 +1,216 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/billing"
+	"github.com/amunx/backend/internal/httpctx"
+)
+
+func registerBillingGrantRoutes(r chi.Router, deps *app.App) {
+	r.Post("/billing/promo/redeem", handlePromoRedeem(deps))
+	r.Post("/billing/trial", handleStartTrial(deps))
+	r.Get("/admin/billing/promo-codes", handlePromoCodeList(deps))
+	r.Post("/admin/billing/promo-codes", handlePromoCodeCreate(deps))
+	r.Post("/admin/billing/grants", handleGrantCreate(deps))
+	r.Delete("/admin/billing/grants/{id}", handleGrantRevoke(deps))
+}
+
+func handlePromoRedeem(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := httpctx.UserFromContext(r.Context())
+		if !ok {
+			WriteError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+			return
+		}
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		if strings.TrimSpace(req.Code) == "" {
+			WriteError(w, http.StatusBadRequest, "invalid_request", "code is required")
+			return
+		}
+		grant, err := billing.NewService(deps.DB).RedeemPromoCode(r.Context(), user.ID, req.Code, time.Now())
+		switch {
+		case errors.Is(err, billing.ErrPromoNotFound):
+			WriteError(w, http.StatusNotFound, "promo_not_found", "promo code not found")
+		case errors.Is(err, billing.ErrPromoExpired):
+			WriteError(w, http.StatusGone, "promo_expired", "promo code expired")
+		case errors.Is(err, billing.ErrPromoExhausted):
+			WriteError(w, http.StatusConflict, "promo_exhausted", "promo code has no redemptions left")
+		case errors.Is(err, billing.ErrPromoRedeemed):
+			WriteError(w, http.StatusConflict, "promo_redeemed", "promo code already redeemed")
+		case err != nil:
+			WriteError(w, http.StatusInternalServerError, "promo_redeem_failed", err.Error())
+		default:
+			WriteJSON(w, http.StatusCreated, map[string]any{"grant": grant})
+		}
+	}
+}
+
+func handleStartTrial(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := httpctx.UserFromContext(r.Context())
+		if !ok {
+			WriteError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+			return
+		}
+		if deps.Config.BillingTrialDays <= 0 || user.IsAnon {
+			WriteError(w, http.StatusForbidden, "trial_unavailable", "trials are not available for this account")
+			return
+		}
+		grant, err := billing.NewService(deps.DB).StartTrial(r.Context(), user.ID, deps.Config.BillingTrialDays, time.Now())
+		switch {
+		case errors.Is(err, billing.ErrTrialUsed):
+			WriteError(w, http.StatusConflict, "trial_used", "trial already used")
+		case err != nil:
+			WriteError(w, http.StatusInternalServerError, "trial_failed", err.Error())
+		default:
+			WriteJSON(w, http.StatusCreated, map[string]any{"grant": grant})
+		}
+	}
+}
+
+func handlePromoCodeList(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireModerator(w, r); !ok {
+			return
+		}
+		limit := parseLimit(r.URL.Query().Get("limit"), 50, 200)
+		codes, err := billing.NewService(deps.DB).ListPromoCodes(r.Context(), limit)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "promo_codes_failed", err.Error())
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{"items": codes})
+	}
+}
+
+func handlePromoCodeCreate(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireModerator(w, r)
+		if !ok {
+			return
+		}
+		var req struct {
+			Code           string     `json:"code"`
+			Entitlement    string     `json:"entitlement"`
+			DurationDays   int        `json:"duration_days"`
+			MaxRedemptions *int       `json:"max_redemptions"`
+			ExpiresAt      *time.Time `json:"expires_at"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		promo, err := billing.NewService(deps.DB).CreatePromoCode(r.Context(), billing.PromoCode{
+			Code:            req.Code,
+			EntitlementCode: strings.TrimSpace(req.Entitlement),
+			DurationDays:    req.DurationDays,
+			MaxRedemptions:  req.MaxRedemptions,
+			ExpiresAt:       req.ExpiresAt,
+			CreatedBy:       &user.ID,
+		})
+		switch {
+		case errors.Is(err, billing.ErrInvalidGrant):
+			WriteError(w, http.StatusBadRequest, "invalid_request", "code and a positive duration_days are required")
+		case errors.Is(err, billing.ErrPromoExists):
+			WriteError(w, http.StatusConflict, "promo_exists", "promo code already exists")
+		case err != nil:
+			WriteError(w, http.StatusInternalServerError, "promo_create_failed", err.Error())
+		default:
+			WriteJSON(w, http.StatusCreated, map[string]any{"promo_code": promo})
+		}
+	}
+}
+
+func handleGrantCreate(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireModerator(w, r)
+		if !ok {
+			return
+		}
+		var req struct {
+			UserID       string `json:"user_id"`
+			Kind         string `json:"kind"`
+			Entitlement  string `json:"entitlement"`
+			DurationDays int    `json:"duration_days"`
+			Note         string `json:"note"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		userID, err := uuid.Parse(req.UserID)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_user", "user_id must be UUID")
+			return
+		}
+		kind := billing.GrantKind(strings.ToLower(safeString(req.Kind, string(billing.GrantStaff))))
+		grant, err := billing.NewService(deps.DB).GrantAccess(r.Context(), billing.GrantRequest{
+			UserID:          userID,
+			Kind:            kind,
+			EntitlementCode: strings.TrimSpace(req.Entitlement),
+			Duration:        time.Duration(req.DurationDays) * 24 * time.Hour,
+			GrantedBy:       user.ID,
+			Note:            strings.TrimSpace(req.Note),
+		}, time.Now())
+		switch {
+		case errors.Is(err, billing.ErrInvalidGrant):
+			WriteError(w, http.StatusBadRequest, "invalid_request", "kind must be staff or gift and duration_days not negative")
+		case err != nil:
+			WriteError(w, http.StatusInternalServerError, "grant_failed", err.Error())
+		default:
+			WriteJSON(w, http.StatusCreated, map[string]any{"grant": grant})
+		}
+	}
+}
+
+func handleGrantRevoke(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireModerator(w, r); !ok {
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil || id <= 0 {
+			WriteError(w, http.StatusBadRequest, "invalid_grant_id", "grant id must be a positive integer")
+			return
+		}
+		grant, err := billing.NewService(deps.DB).RevokeGrant(r.Context(), id, time.Now())
+		switch {
+		case errors.Is(err, billing.ErrGrantNotFound):
+			WriteError(w, http.StatusNotFound, "grant_not_found", "no active grant with this id")
+		case err != nil:
+			WriteError(w, http.StatusInternalServerError, "grant_revoke_failed", err.Error())
+		default:
+			WriteJSON(w, http.StatusOK, map[string]any{"grant": grant})
+		}
+	}
+}
+
+// requireModerator writes the error response and reports false unless the
+// caller is staff.
+func requireModerator(w http.ResponseWriter, r *http.Request) (httpctx.User, bool) {
+	user, ok := httpctx.UserFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return httpctx.User{}, false
+	}
+	if !isModerator(user) {
+		WriteError(w, http.StatusForbidden, "forbidden", "moderator access required")
+		return httpctx.User{}, false
+	}
+	return user, true
+}
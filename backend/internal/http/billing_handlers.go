@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,6 +22,7 @@ import (
 	"github.com/amunx/backend/internal/billing"
 	"github.com/amunx/backend/internal/httpctx"
 	"github.com/amunx/backend/internal/integrations/monopay"
+	"github.com/amunx/backend/internal/integrations/stripe"
 )
 
 func registerBillingRoutes(r chi.Router, deps *app.App) {
@@ -27,6 +30,10 @@ func registerBillingRoutes(r chi.Router, deps *app.App) {
 	r.Get("/billing/subscription", handleBillingSubscription(deps))
 	r.Post("/billing/portal", handleBillingPortal(deps))
 	r.Post("/billing/monopay/checkout", handleMonoPayCheckout(deps))
+	r.Post("/billing/stripe/checkout", handleStripeCheckout(deps))
+	r.Get("/admin/billing/events", handleBillingEventList(deps))
+	r.Post("/admin/billing/events/{id}/replay", handleBillingEventReplay(deps))
+	registerBillingGrantRoutes(r, deps)
 }
 
 func registerBillingWebhookRoutes(r chi.Router, deps *app.App) {
@@ -67,9 +74,15 @@ func handleBillingSubscription(deps *app.App) http.HandlerFunc {
 			WriteError(w, http.StatusInternalServerError, "billing_subscription_failed", err.Error())
 			return
 		}
+		grants, err := svc.ActiveGrants(r.Context(), user.ID, time.Now())
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "billing_subscription_failed", err.Error())
+			return
+		}
 		response := map[string]any{
 			"plan":         user.Plan,
 			"subscription": snapshot,
+			"grants":       grants,
 		}
 		WriteJSON(w, http.StatusOK, response)
 	}
@@ -82,9 +95,25 @@ func handleBillingPortal(deps *app.App) http.HandlerFunc {
 			WriteError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
 			return
 		}
+		portalURL := deps.Config.StripePortalURL
+		if deps.Stripe != nil {
+			customerID, err := billing.NewService(deps.DB).CustomerID(r.Context(), user.ID, billing.ProviderStripe)
+			switch {
+			case err == nil:
+				session, err := deps.Stripe.CreatePortalSession(r.Context(), customerID, deps.Config.StripePortalReturnURL)
+				if err != nil {
+					WriteError(w, http.StatusBadGateway, "stripe_portal_failed", err.Error())
+					return
+				}
+				portalURL = session.URL
+			case !errors.Is(err, billing.ErrCustomerNotFound):
+				WriteError(w, http.StatusInternalServerError, "billing_portal_failed", err.Error())
+				return
+			}
+		}
 		response := map[string]any{
 			"user_id":                 user.ID.String(),
-			"stripe_customer_portal":  deps.Config.StripePortalURL,
+			"stripe_customer_portal":  portalURL,
 			"revenuecat_app_user_id":  user.ID.String(),
 			"monopay_checkout_notice": "MonoPay checkout handled client-side",
 		}
@@ -136,9 +165,10 @@ func handleMonoPayCheckout(deps *app.App) http.HandlerFunc {
 			return
 		}
 
-		redirect := strings.TrimSpace(req.SuccessURL)
-		if redirect == "" {
-			redirect = deps.Config.MonoPayReturnURL
+		redirect, ok := billingRedirect(deps, req.SuccessURL, deps.Config.MonoPayReturnURL)
+		if !ok {
+			WriteError(w, http.StatusBadRequest, "invalid_redirect_url", "success_url must be on an allowed origin")
+			return
 		}
 
 		reference := fmt.Sprintf("mono-%s-%d", user.ID.String(), time.Now().Unix())
@@ -156,11 +186,22 @@ func handleMonoPayCheckout(deps *app.App) http.HandlerFunc {
 			WebhookURL:   deps.Config.MonoPayWebhookURL,
 			CustomerData: customerData,
 			Validity:     time.Hour,
+			WalletID:     billing.MonoPayWalletID(user.ID),
 		})
 		if err != nil {
 			WriteError(w, http.StatusBadGateway, "monopay_invoice_failed", err.Error())
 			return
 		}
+		if err := deps.MonoPayRecurring.TrackInvoice(r.Context(), billing.MonoPayInvoice{
+			InvoiceID:   invoice.InvoiceID,
+			UserID:      user.ID,
+			ProductCode: product.Code,
+			Kind:        billing.InvoiceCheckout,
+			WalletID:    billing.MonoPayWalletID(user.ID),
+		}); err != nil {
+			WriteError(w, http.StatusInternalServerError, "monopay_invoice_failed", err.Error())
+			return
+		}
 
 		WriteJSON(w, http.StatusOK, map[string]any{
 			"invoice_id":   invoice.InvoiceID,
@@ -172,6 +213,172 @@ func handleMonoPayCheckout(deps *app.App) http.HandlerFunc {
 	}
 }
 
+func handleStripeCheckout(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := httpctx.UserFromContext(r.Context())
+		if !ok {
+			WriteError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+			return
+		}
+		if deps.Stripe == nil {
+			WriteError(w, http.StatusServiceUnavailable, "stripe_disabled", "Stripe integration not configured")
+			return
+		}
+
+		var req struct {
+			ProductCode string `json:"product_code"`
+			SuccessURL  string `json:"success_url"`
+			CancelURL   string `json:"cancel_url"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		if strings.TrimSpace(req.ProductCode) == "" {
+			WriteError(w, http.StatusBadRequest, "invalid_request", "product_code is required")
+			return
+		}
+		successURL, ok := billingRedirect(deps, req.SuccessURL, deps.Config.StripeCheckoutSuccessURL)
+		if !ok {
+			WriteError(w, http.StatusBadRequest, "invalid_redirect_url", "success_url must be on an allowed origin")
+			return
+		}
+		cancelURL, ok := billingRedirect(deps, req.CancelURL, deps.Config.StripeCheckoutCancelURL)
+		if !ok {
+			WriteError(w, http.StatusBadRequest, "invalid_redirect_url", "cancel_url must be on an allowed origin")
+			return
+		}
+
+		svc := billing.NewService(deps.DB)
+		product, err := svc.GetProductByCode(r.Context(), req.ProductCode)
+		if err != nil {
+			if err == billing.ErrProductNotFound {
+				WriteError(w, http.StatusNotFound, "product_not_found", "billing product not found")
+				return
+			}
+			WriteError(w, http.StatusInternalServerError, "product_lookup_failed", err.Error())
+			return
+		}
+		if product.Provider != billing.ProviderStripe {
+			WriteError(w, http.StatusBadRequest, "invalid_provider", "product is not a Stripe plan")
+			return
+		}
+
+		customerID, err := svc.CustomerID(r.Context(), user.ID, billing.ProviderStripe)
+		if err != nil && !errors.Is(err, billing.ErrCustomerNotFound) {
+			WriteError(w, http.StatusInternalServerError, "stripe_checkout_failed", err.Error())
+			return
+		}
+		session, err := deps.Stripe.CreateCheckoutSession(r.Context(), stripe.CheckoutRequest{
+			PriceID:           product.ExternalID,
+			ClientReferenceID: user.ID.String(),
+			CustomerID:        customerID,
+			CustomerEmail:     user.Email,
+			SuccessURL:        successURL,
+			CancelURL:         cancelURL,
+			Metadata: map[string]string{
+				"user_id":      user.ID.String(),
+				"product_code": product.Code,
+			},
+		})
+		if err != nil {
+			WriteError(w, http.StatusBadGateway, "stripe_checkout_failed", err.Error())
+			return
+		}
+
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"session_id":   session.ID,
+			"checkout_url": session.URL,
+			"amount_cents": product.AmountCents,
+			"currency":     product.Currency,
+		})
+	}
+}
+
+// billingRedirect resolves where a payment page sends the user back to:
+// fallback when the client asked for nothing, else the client's URL as long
+// as its origin is one of BillingRedirectOrigins.
+func billingRedirect(deps *app.App, requested, fallback string) (string, bool) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		return fallback, true
+	}
+	u, err := url.Parse(requested)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User != nil {
+		return "", false
+	}
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+	for _, allowed := range deps.Config.BillingRedirectOrigins {
+		if origin == strings.ToLower(strings.TrimSuffix(strings.TrimSpace(allowed), "/")) {
+			return requested, true
+		}
+	}
+	return "", false
+}
+
+func handleBillingEventList(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := httpctx.UserFromContext(r.Context())
+		if !ok {
+			WriteError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+			return
+		}
+		if !isModerator(user) {
+			WriteError(w, http.StatusForbidden, "forbidden", "moderator access required")
+			return
+		}
+		status := billing.EventStatus(strings.ToLower(strings.TrimSpace(r.URL.Query().Get("status"))))
+		switch status {
+		case "", billing.EventPending, billing.EventProcessed, billing.EventSkipped, billing.EventFailed:
+		default:
+			WriteError(w, http.StatusBadRequest, "invalid_status", "status must be pending, processed, skipped, or failed")
+			return
+		}
+		limit := parseLimit(r.URL.Query().Get("limit"), 50, 200)
+		svc := billing.NewService(deps.DB)
+		events, err := svc.ListWebhookEvents(r.Context(), status, limit)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "billing_events_failed", err.Error())
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{"items": events})
+	}
+}
+
+func handleBillingEventReplay(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := httpctx.UserFromContext(r.Context())
+		if !ok {
+			WriteError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+			return
+		}
+		if !isModerator(user) {
+			WriteError(w, http.StatusForbidden, "forbidden", "moderator access required")
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil || id <= 0 {
+			WriteError(w, http.StatusBadRequest, "invalid_event_id", "event id must be a positive integer")
+			return
+		}
+		svc := billing.NewService(deps.DB)
+		status, err := svc.ReplayWebhookEvent(r.Context(), id)
+		switch {
+		case errors.Is(err, billing.ErrEventNotFound):
+			WriteError(w, http.StatusNotFound, "billing_event_not_found", "no failed or abandoned billing event with this id")
+			return
+		case err != nil && status == "":
+			WriteError(w, http.StatusInternalServerError, "billing_replay_failed", err.Error())
+			return
+		}
+		response := map[string]any{"id": id, "status": status}
+		if err != nil {
+			response["error"] = err.Error()
+		}
+		WriteJSON(w, http.StatusOK, response)
+	}
+}
+
 func handleRevenueCatWebhook(deps *app.App) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if deps.Config.RevenueCatWebhookSecret != "" {
@@ -219,12 +426,13 @@ func handleRevenueCatWebhook(deps *app.App) http.HandlerFunc {
 			},
 			RawEvent: body,
 		}
-		svc := billing.NewService(deps.DB)
-		if err := svc.ApplySubscriptionUpdate(r.Context(), update); err != nil {
-			WriteError(w, http.StatusInternalServerError, "revenuecat_apply_failed", err.Error())
-			return
+		event := billing.WebhookEvent{
+			Provider:   billing.ProviderRevenueCat,
+			EventID:    webhookEventID(payload.Event.ID, body),
+			Type:       payload.Event.Type,
+			OccurredAt: payload.Event.OccurredAt(),
 		}
-		w.WriteHeader(http.StatusNoContent)
+		processBillingEvent(w, r, deps, event, update, "revenuecat_apply_failed")
 	}
 }
 
@@ -248,19 +456,50 @@ func handleStripeWebhook(deps *app.App) http.HandlerFunc {
 			WriteError(w, http.StatusBadRequest, "invalid_payload", err.Error())
 			return
 		}
+		svc := billing.NewService(deps.DB)
+		if event.Type == "checkout.session.completed" {
+			var session stripeCheckoutSession
+			if err := json.Unmarshal(event.Data.Object, &session); err != nil {
+				WriteError(w, http.StatusBadRequest, "invalid_payload", err.Error())
+				return
+			}
+			userID, err := uuid.Parse(session.ClientReferenceID)
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, "invalid_user", "client_reference_id must be UUID")
+				return
+			}
+			if err := svc.SaveCustomer(r.Context(), userID, billing.ProviderStripe, session.Customer); err != nil {
+				WriteError(w, http.StatusInternalServerError, "stripe_apply_failed", err.Error())
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
 		if !strings.HasPrefix(event.Type, "customer.subscription.") {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
-		sub := event.Data.Object
-		userIDStr := sub.Metadata["user_id"]
-		if userIDStr == "" {
-			WriteError(w, http.StatusBadRequest, "missing_metadata", "metadata.user_id required")
+		var sub stripeSubscription
+		if err := json.Unmarshal(event.Data.Object, &sub); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_payload", err.Error())
 			return
 		}
-		userID, err := uuid.Parse(userIDStr)
-		if err != nil {
-			WriteError(w, http.StatusBadRequest, "invalid_user", "metadata.user_id must be UUID")
+		// The customer recorded at checkout identifies the user; metadata is
+		// only a fallback for subscriptions created outside our checkout.
+		userID, err := svc.UserByCustomer(r.Context(), billing.ProviderStripe, sub.Customer)
+		if errors.Is(err, billing.ErrCustomerNotFound) {
+			userIDStr := sub.Metadata["user_id"]
+			if userIDStr == "" {
+				WriteError(w, http.StatusBadRequest, "missing_metadata", "unknown customer and metadata.user_id missing")
+				return
+			}
+			userID, err = uuid.Parse(userIDStr)
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, "invalid_user", "metadata.user_id must be UUID")
+				return
+			}
+		} else if err != nil {
+			WriteError(w, http.StatusInternalServerError, "stripe_apply_failed", err.Error())
 			return
 		}
 		productCode := sub.Metadata["product_code"]
@@ -314,12 +553,15 @@ func handleStripeWebhook(deps *app.App) http.HandlerFunc {
 			Metadata:               meta,
 			RawEvent:               body,
 		}
-		svc := billing.NewService(deps.DB)
-		if err := svc.ApplySubscriptionUpdate(r.Context(), update); err != nil {
-			WriteError(w, http.StatusInternalServerError, "stripe_apply_failed", err.Error())
-			return
+		ledgerEvent := billing.WebhookEvent{
+			Provider: billing.ProviderStripe,
+			EventID:  webhookEventID(event.ID, body),
+			Type:     event.Type,
 		}
-		w.WriteHeader(http.StatusNoContent)
+		if created := toTimePointer(event.Created); created != nil {
+			ledgerEvent.OccurredAt = *created
+		}
+		processBillingEvent(w, r, deps, ledgerEvent, update, "stripe_apply_failed")
 	}
 }
 
@@ -342,6 +584,22 @@ func handleMonoPayWebhook(deps *app.App) http.HandlerFunc {
 			WriteError(w, http.StatusBadRequest, "invalid_payload", err.Error())
 			return
 		}
+		// Invoices we track settle through the renewal scheduler, which
+		// also knows the subscription they pay for.
+		err = deps.MonoPayRecurring.Settle(r.Context(), monopay.InvoiceStatus{
+			InvoiceID:     payload.InvoiceID,
+			Status:        normalizeMonoPayStatus(payload.Status),
+			FailureReason: payload.FailureReason,
+			ModifiedDate:  payload.ModifiedDate,
+		})
+		switch {
+		case err == nil, errors.Is(err, billing.ErrDuplicateEvent):
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case !errors.Is(err, billing.ErrInvoiceNotFound):
+			WriteError(w, http.StatusInternalServerError, "monopay_apply_failed", err.Error())
+			return
+		}
 		customerData := parseMonoPayCustomerData(payload.CustomerData)
 		userID, err := resolveMonoPayUser(payload, customerData)
 		if err != nil {
@@ -383,15 +641,38 @@ func handleMonoPayWebhook(deps *app.App) http.HandlerFunc {
 			Metadata:               meta,
 			RawEvent:               body,
 		}
-		svc := billing.NewService(deps.DB)
-		if err := svc.ApplySubscriptionUpdate(r.Context(), update); err != nil {
-			WriteError(w, http.StatusInternalServerError, "monopay_apply_failed", err.Error())
-			return
+		event := billing.WebhookEvent{
+			Provider:   billing.ProviderMonoPay,
+			EventID:    webhookEventID(payload.EventID(), body),
+			Type:       payload.Status,
+			OccurredAt: payload.ModifiedAt(),
 		}
-		w.WriteHeader(http.StatusNoContent)
+		processBillingEvent(w, r, deps, event, update, "monopay_apply_failed")
 	}
 }
 
+// processBillingEvent runs a webhook event through the billing ledger.
+// Redeliveries and events older than the subscription are acknowledged
+// without effect; a failure answers 500 so the provider retries it.
+func processBillingEvent(w http.ResponseWriter, r *http.Request, deps *app.App, event billing.WebhookEvent, update billing.SubscriptionUpdate, failCode string) {
+	svc := billing.NewService(deps.DB)
+	if _, err := svc.ProcessWebhookEvent(r.Context(), event, update); err != nil && !errors.Is(err, billing.ErrDuplicateEvent) {
+		WriteError(w, http.StatusInternalServerError, failCode, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// webhookEventID returns the provider's event id, or a digest of the body
+// for providers that send none so identical redeliveries still collide.
+func webhookEventID(id string, body []byte) string {
+	if id = strings.TrimSpace(id); id != "" {
+		return id
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
 func verifyStripeSignature(body []byte, header, secret string) bool {
 	if header == "" || secret == "" {
 		return false
@@ -498,6 +779,19 @@ func mapMonoPayStatus(status string) billing.SubscriptionStatus {
 	}
 }
 
+// normalizeMonoPayStatus maps webhook statuses onto MonoPay's invoice
+// statuses.
+func normalizeMonoPayStatus(status string) string {
+	switch status = strings.ToLower(status); status {
+	case "failed":
+		return monopay.StatusFailure
+	case "canceled":
+		return monopay.StatusReversed
+	default:
+		return status
+	}
+}
+
 func firstNonEmpty(values ...string) string {
 	for _, v := range values {
 		if strings.TrimSpace(v) != "" {
@@ -551,6 +845,7 @@ func parseUserFromReference(reference string) (uuid.UUID, error) {
 }
 
 type revenueCatEvent struct {
+	ID                    string `json:"id"`
 	Type                  string `json:"type"`
 	AppUserID             string `json:"app_user_id"`
 	ProductID             string `json:"product_id"`
@@ -559,6 +854,14 @@ type revenueCatEvent struct {
 	OriginalTransactionID string `json:"original_transaction_id"`
 	Environment           string `json:"environment"`
 	ExpiresAtMilliseconds *int64 `json:"expiration_at_ms"`
+	EventTimestampMs      *int64 `json:"event_timestamp_ms"`
+}
+
+func (e revenueCatEvent) OccurredAt() time.Time {
+	if e.EventTimestampMs == nil || *e.EventTimestampMs == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(*e.EventTimestampMs)
 }
 
 func (e revenueCatEvent) ExpirationAt() *time.Time {
@@ -575,12 +878,20 @@ type revenueCatPayload struct {
 }
 
 type stripeEvent struct {
-	Type string `json:"type"`
-	Data struct {
-		Object stripeSubscription `json:"object"`
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
 	} `json:"data"`
 }
 
+type stripeCheckoutSession struct {
+	ID                string `json:"id"`
+	Customer          string `json:"customer"`
+	ClientReferenceID string `json:"client_reference_id"`
+}
+
 type stripeSubscription struct {
 	ID               string                        `json:"id"`
 	Customer         string                        `json:"customer"`
@@ -625,4 +936,23 @@ type monoPayPayload struct {
 	Currency        string `json:"currency"`
 	Details         string `json:"details"`
 	CustomerData    string `json:"customer_data"`
+	ModifiedDate    string `json:"modified_date"`
+	FailureReason   string `json:"failure_reason"`
+}
+
+// EventID identifies one status change of an invoice. MonoPay sends no
+// event id, so the invoice, status and modification time stand in for it.
+func (p monoPayPayload) EventID() string {
+	if p.InvoiceID == "" || p.ModifiedDate == "" {
+		return ""
+	}
+	return p.InvoiceID + ":" + p.Status + ":" + p.ModifiedDate
+}
+
+func (p monoPayPayload) ModifiedAt() time.Time {
+	t, err := time.Parse(time.RFC3339, p.ModifiedDate)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
 }
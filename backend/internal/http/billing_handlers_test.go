@@ -1,7 +1,16 @@
 package http
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/httpctx"
+	"github.com/amunx/backend/internal/integrations/stripe"
 )
 
 func TestMapStripeStatus(t *testing.T) {
@@ -50,3 +59,62 @@ func TestVerifyStripeSignature(t *testing.T) {
 		t.Fatal("expected signature mismatch for bad secret")
 	}
 }
+
+func TestWebhookEventID(t *testing.T) {
+	body := []byte(`{"status":"success"}`)
+	if got := webhookEventID(" evt_1 ", body); got != "evt_1" {
+		t.Fatalf("expected provider id, got %q", got)
+	}
+	digest := webhookEventID("", body)
+	if digest != webhookEventID("", body) || digest == webhookEventID("", []byte(`{}`)) {
+		t.Fatalf("expected a stable per-body digest, got %q", digest)
+	}
+	payload := monoPayPayload{InvoiceID: "inv_1", Status: "success", ModifiedDate: "2026-05-01T10:00:00Z"}
+	if got := payload.EventID(); got != "inv_1:success:2026-05-01T10:00:00Z" {
+		t.Fatalf("unexpected monopay event id %q", got)
+	}
+	if payload.ModifiedAt().IsZero() {
+		t.Fatal("expected monopay modified date to parse")
+	}
+}
+
+func TestBillingRedirectAllowsConfiguredOrigins(t *testing.T) {
+	deps := &app.App{Config: app.Config{BillingRedirectOrigins: []string{"https://moweton.app", "http://localhost:3000/"}}}
+	cases := []struct {
+		requested string
+		want      string
+		ok        bool
+	}{
+		{"", "https://moweton.app/fallback", true},
+		{"https://moweton.app/payments/done?x=1", "https://moweton.app/payments/done?x=1", true},
+		{"HTTPS://Moweton.app/payments/done", "HTTPS://Moweton.app/payments/done", true},
+		{"http://localhost:3000/billing", "http://localhost:3000/billing", true},
+		{"https://evil.example/phish", "", false},
+		{"https://moweton.app.evil.example/", "", false},
+		{"https://moweton.app@evil.example/", "", false},
+		{"//evil.example/phish", "", false},
+		{"/payments/done", "", false},
+		{"javascript:alert(1)", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := billingRedirect(deps, tc.requested, "https://moweton.app/fallback")
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("billingRedirect(%q) = %q, %v; want %q, %v", tc.requested, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestStripeCheckoutRejectsForeignRedirect(t *testing.T) {
+	deps := &app.App{
+		Config: app.Config{BillingRedirectOrigins: []string{"https://moweton.app"}},
+		Stripe: stripe.NewClient(stripe.Config{BaseURL: "http://127.0.0.1:1"}),
+	}
+	body := `{"product_code":"pro_monthly","success_url":"https://evil.example/phish"}`
+	req := httptest.NewRequest(http.MethodPost, "/billing/stripe/checkout", strings.NewReader(body))
+	req = req.WithContext(httpctx.WithUser(req.Context(), httpctx.User{ID: uuid.New()}))
+	rec := httptest.NewRecorder()
+	handleStripeCheckout(deps)(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_redirect_url") {
+		t.Fatalf("expected the foreign redirect to be rejected, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -1,13 +1,21 @@
 package http
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 
 	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/geo"
 )
 
 // CreateCircleRequest represents the request to create a circle
@@ -31,6 +39,7 @@ type CircleResponse struct {
 	Country      string        `json:"country"`
 	MemberCount  int           `json:"member_count"`
 	UserRole     string        `json:"user_role,omitempty"` // owner, moderator, member, or empty if not a member
+	DistanceKm   *float64      `json:"distance_km,omitempty"`
 	CreatedAt    string        `json:"created_at"`
 }
 
@@ -75,7 +84,25 @@ func CreateCircle(w http.ResponseWriter, r *http.Request, deps *app.App) {
 		return
 	}
 
-	// TODO: Create circle using sqlc
+	// Local circles are pinned to a known city so they can be found by
+	// proximity.
+	place := geo.Location{City: strings.TrimSpace(req.City), Country: geo.NormalizeCountry(req.Country)}
+	if req.IsLocal {
+		if place.City == "" || place.Country == "" {
+			WriteError(w, http.StatusBadRequest, "invalid_request", "local circles need a city and a two-letter country code")
+			return
+		}
+		if deps.Geo != nil {
+			found, ok := deps.Geo.City(place.City, place.Country)
+			if !ok {
+				WriteError(w, http.StatusUnprocessableEntity, "unknown_city", "city not found in that country")
+				return
+			}
+			place = found.Coarse()
+		}
+	}
+
+	// TODO: Create circle using sqlc, with the city's lat/lon from place
 	// TODO: Automatically add creator as owner in circle_members
 
 	circle := CircleResponse{
@@ -84,8 +111,8 @@ func CreateCircle(w http.ResponseWriter, r *http.Request, deps *app.App) {
 		Name:        req.Name,
 		Description: req.Description,
 		IsLocal:     req.IsLocal,
-		City:        req.City,
-		Country:     req.Country,
+		City:        place.City,
+		Country:     place.Country,
 		MemberCount: 1,
 		UserRole:    "owner",
 		CreatedAt:   "2025-01-06T12:00:00Z",
@@ -128,26 +155,136 @@ func GetCircle(w http.ResponseWriter, r *http.Request, deps *app.App) {
 	WriteJSON(w, http.StatusOK, circle)
 }
 
-// ListCircles lists all circles (GET /circles)
+// ListCircles lists all circles (GET /circles), optionally in one city or
+// country, largest first
 func ListCircles(w http.ResponseWriter, r *http.Request, deps *app.App) {
-	city := r.URL.Query().Get("city")
-	limit := getIntQueryParam(r, "limit", 20)
-	offset := getIntQueryParam(r, "offset", 0)
+	q := r.URL.Query()
+	limit := parseLimit(q.Get("limit"), 20, 50)
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
 
-	_ = city
-	_ = limit
-	_ = offset
+	query := `SELECT ` + circleColumns + ` FROM circles c WHERE TRUE`
+	args := []any{getUserID(r)}
+	if city := strings.TrimSpace(q.Get("city")); city != "" {
+		args = append(args, strings.ToLower(city))
+		query += fmt.Sprintf(" AND lower(c.city) = $%d", len(args))
+	}
+	if country := geo.NormalizeCountry(q.Get("country")); country != "" {
+		args = append(args, country)
+		query += fmt.Sprintf(" AND c.country = $%d", len(args))
+	}
+	args = append(args, limit+1, offset)
+	query += fmt.Sprintf(" ORDER BY member_count DESC, c.created_at DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
 
-	// TODO: Fetch circles from database with filters
+	circles, err := queryCircles(r.Context(), deps.DB, query, args...)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "circles_list_failed", err.Error())
+		return
+	}
+	hasMore := len(circles) > limit
+	if hasMore {
+		circles = circles[:limit]
+	}
 
 	response := map[string]interface{}{
-		"circles":  []CircleResponse{},
-		"has_more": false,
+		"circles":  circles,
+		"has_more": hasMore,
 	}
 
 	WriteJSON(w, http.StatusOK, response)
 }
 
+// NearbyCircles lists local circles near the viewer, closest first
+// (GET /circles/nearby)
+func NearbyCircles(w http.ResponseWriter, r *http.Request, deps *app.App) {
+	near, ok := viewerLocation(r, deps)
+	if !ok {
+		WriteError(w, http.StatusUnprocessableEntity, "location_unknown", "share your location to find circles near you")
+		return
+	}
+	limit := parseLimit(r.URL.Query().Get("limit"), 20, 50)
+
+	distance := distanceKmSQL("c.lat", "c.lon", 2)
+	query := fmt.Sprintf(`SELECT %s, %s AS distance_km
+  FROM circles c
+ WHERE c.is_local AND c.lat IS NOT NULL
+   AND %s <= $4
+ ORDER BY distance_km, member_count DESC
+ LIMIT $5`, circleColumns, distance, distance)
+	rows, err := deps.DB.QueryContext(r.Context(), query, getUserID(r), near.Lat, near.Lon, float64(geo.NearbyRadiusKm), limit)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "circles_list_failed", err.Error())
+		return
+	}
+	defer rows.Close()
+
+	circles := []CircleResponse{}
+	for rows.Next() {
+		var distanceKm float64
+		circle, err := scanCircle(rows, &distanceKm)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "circles_list_failed", err.Error())
+			return
+		}
+		distanceKm = math.Round(distanceKm*10) / 10
+		circle.DistanceKm = &distanceKm
+		circles = append(circles, circle)
+	}
+	if err := rows.Err(); err != nil {
+		WriteError(w, http.StatusInternalServerError, "circles_list_failed", err.Error())
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"circles": circles,
+		"near":    near,
+	})
+}
+
+// circleColumns selects a circle as scanCircle reads it, with the role of
+// the viewer bound to $1 (uuid.Nil for anonymous viewers).
+const circleColumns = `c.id, c.owner_id, c.name, COALESCE(c.description, ''), c.is_local,
+       COALESCE(c.city, ''), COALESCE(c.country, ''), c.created_at,
+       (SELECT COUNT(*) FROM circle_members m WHERE m.circle_id = c.id) AS member_count,
+       COALESCE((SELECT m.role FROM circle_members m WHERE m.circle_id = c.id AND m.user_id = $1), '')`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCircle(row rowScanner, extra ...any) (CircleResponse, error) {
+	var (
+		circle    CircleResponse
+		createdAt time.Time
+	)
+	dest := []any{&circle.ID, &circle.OwnerID, &circle.Name, &circle.Description, &circle.IsLocal,
+		&circle.City, &circle.Country, &createdAt, &circle.MemberCount, &circle.UserRole}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return CircleResponse{}, err
+	}
+	circle.CreatedAt = createdAt.Format(time.RFC3339)
+	return circle, nil
+}
+
+func queryCircles(ctx context.Context, db *sql.DB, query string, args ...any) ([]CircleResponse, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	circles := []CircleResponse{}
+	for rows.Next() {
+		circle, err := scanCircle(rows)
+		if err != nil {
+			return nil, err
+		}
+		circles = append(circles, circle)
+	}
+	return circles, rows.Err()
+}
+
 // JoinCircle joins a circle (POST /circles/:id/join)
 func JoinCircle(w http.ResponseWriter, r *http.Request, deps *app.App) {
 	circleID := chi.URLParam(r, "id")
@@ -371,6 +508,9 @@ func registerCircleRoutes(r chi.Router, deps *app.App) {
 		r.Post("/", func(w http.ResponseWriter, req *http.Request) {
 			CreateCircle(w, req, deps)
 		})
+		r.Get("/nearby", func(w http.ResponseWriter, req *http.Request) {
+			NearbyCircles(w, req, deps)
+		})
 		r.Get("/{id}", func(w http.ResponseWriter, req *http.Request) {
 			GetCircle(w, req, deps)
 		})
@@ -1,12 +1,15 @@
 package http
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/queue"
 	"github.com/amunx/backend/internal/storage"
 )
 
@@ -39,5 +42,68 @@ func registerDiagnosticsRoutes(r chi.Router, deps *app.App) {
 		}
 		WriteJSON(w, http.StatusOK, map[string]any{"status": "ok"})
 	})
+
+	r.Get("/diagnostics/queue/dead", func(w http.ResponseWriter, req *http.Request) {
+		topic, ok := queueTopic(req)
+		if !ok {
+			WriteError(w, http.StatusBadRequest, "invalid_topic", "topic must be one of the job streams")
+			return
+		}
+		limit := parseLimit(req.URL.Query().Get("limit"), 50, 500)
+		letters, err := deps.Queue.DeadLetters(req.Context(), topic, int64(limit))
+		if err != nil {
+			WriteError(w, http.StatusServiceUnavailable, "dead_letters_failed", err.Error())
+			return
+		}
+		items := make([]deadLetterResponse, 0, len(letters))
+		for _, letter := range letters {
+			items = append(items, deadLetterResponse{
+				ID:       letter.ID,
+				OriginID: letter.OriginID,
+				Attempts: letter.Attempt,
+				Error:    letter.Error,
+				FailedAt: letter.FailedAt,
+				Payload:  letter.Values,
+			})
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{"topic": topic, "items": items})
+	})
+
+	r.Post("/diagnostics/queue/dead/{id}/replay", func(w http.ResponseWriter, req *http.Request) {
+		topic, ok := queueTopic(req)
+		if !ok {
+			WriteError(w, http.StatusBadRequest, "invalid_topic", "topic must be one of the job streams")
+			return
+		}
+		err := deps.Queue.ReplayDeadLetter(req.Context(), topic, chi.URLParam(req, "id"))
+		switch {
+		case err == nil:
+			WriteJSON(w, http.StatusOK, map[string]any{"status": "replayed"})
+		case errors.Is(err, queue.ErrDeadLetterNotFound):
+			WriteError(w, http.StatusNotFound, "dead_letter_not_found", "dead letter not found")
+		default:
+			WriteError(w, http.StatusServiceUnavailable, "replay_failed", err.Error())
+		}
+	})
+}
+
+type deadLetterResponse struct {
+	ID       string         `json:"id"`
+	OriginID string         `json:"origin_id"`
+	Attempts int            `json:"attempts"`
+	Error    string         `json:"error,omitempty"`
+	FailedAt time.Time      `json:"failed_at"`
+	Payload  map[string]any `json:"payload"`
+}
+
+// queueTopic reads the ?topic= parameter, restricted to known job streams.
+func queueTopic(req *http.Request) (string, bool) {
+	topic := strings.TrimSpace(req.URL.Query().Get("topic"))
+	for _, known := range queue.Topics {
+		if topic == known {
+			return topic, true
+		}
+	}
+	return "", false
 }
 
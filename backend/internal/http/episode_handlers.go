@@ -7,9 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"hash/fnv"
-	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -22,17 +20,20 @@ import (
 	"github.com/rs/zerolog"
 
 	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/geo"
 	mw "github.com/amunx/backend/internal/http/middleware"
 	"github.com/amunx/backend/internal/httpctx"
 	"github.com/amunx/backend/internal/queue"
 	"github.com/amunx/backend/internal/storage"
+	"github.com/amunx/backend/internal/uploads"
 )
 
 type episodeResponse struct {
-	ID            string            `json:"id"`
-	Status        string            `json:"status"`
-	UploadURL     string            `json:"upload_url"`
-	UploadHeaders map[string]string `json:"upload_headers,omitempty"`
+	ID            string                   `json:"id"`
+	Status        string                   `json:"status"`
+	UploadURL     string                   `json:"upload_url"`
+	UploadHeaders map[string]string        `json:"upload_headers,omitempty"`
+	Multipart     *MultipartUploadResponse `json:"multipart,omitempty"`
 }
 
 const (
@@ -76,9 +77,20 @@ type episodeSummary struct {
 	Reactions     []reactionCount `json:"reactions,omitempty"`
 	SelfReactions []string        `json:"self_reactions,omitempty"`
 	ReactionBadge *reactionBadge  `json:"reaction_badge,omitempty"`
+	// ProcessingError is only shown to the author.
+	ProcessingError *processingError `json:"processing_error,omitempty"`
+}
+
+// processingError explains why an upload was rejected or failed processing.
+// Reason is machine-readable, e.g. "too_long" or "silent_audio".
+type processingError struct {
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
 }
 
 func registerEpisodeRoutes(r chi.Router, deps *app.App) {
+	sessions := newUploadSessions(deps)
+
 	r.Post("/episodes", func(w http.ResponseWriter, req *http.Request) {
 		currentUser, ok := httpctx.UserFromContext(req.Context())
 		if !ok {
@@ -114,6 +126,7 @@ func registerEpisodeRoutes(r chi.Router, deps *app.App) {
 			Quality     string  `json:"quality"`
 			DurationSec *int    `json:"duration_sec"`
 			ContentType string  `json:"content_type"`
+			SizeBytes   int64   `json:"size_bytes"`
 		}
 		if err := decodeJSON(req, &payload); err != nil {
 			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
@@ -144,7 +157,26 @@ func registerEpisodeRoutes(r chi.Router, deps *app.App) {
 		episodeID := uuid.New()
 		key := "episodes/" + episodeID.String() + "/original"
 
-		upload, err := deps.Storage.PresignUpload(req.Context(), key, 15*time.Minute, coalesceContentType(payload.ContentType))
+		// Long recordings upload as a resumable multipart session; the client
+		// completes it before calling finalize.
+		var (
+			upload    storage.PresignedUpload
+			multipart *MultipartUploadResponse
+			err       error
+		)
+		durationSec := 0
+		if payload.DurationSec != nil {
+			durationSec = *payload.DurationSec
+		}
+		if wantsMultipartUpload(deps.Config, payload.SizeBytes, durationSec) {
+			var session uploads.Session
+			session, err = sessions.Initiate(req.Context(), currentUser.ID, key, coalesceContentType(payload.ContentType), payload.SizeBytes)
+			if err == nil {
+				multipart = newMultipartUploadResponse(session)
+			}
+		} else {
+			upload, err = deps.Storage.PresignUpload(req.Context(), key, 15*time.Minute, coalesceContentType(payload.ContentType))
+		}
 		if err != nil {
 			if errors.Is(err, storage.ErrNotImplemented) {
 				WriteError(w, http.StatusServiceUnavailable, "storage_disabled", "object storage not configured")
@@ -184,19 +216,16 @@ func registerEpisodeRoutes(r chi.Router, deps *app.App) {
 			return
 		}
 
-		headers := map[string]string{}
-		for k, values := range upload.Headers {
-			if len(values) > 0 {
-				headers[k] = values[0]
-			}
+		resp := episodeResponse{
+			ID:        episodeID.String(),
+			Status:    "pending_upload",
+			UploadURL: upload.URL,
+			Multipart: multipart,
 		}
-
-		WriteJSON(w, http.StatusCreated, episodeResponse{
-			ID:            episodeID.String(),
-			Status:        "pending_upload",
-			UploadURL:     upload.URL,
-			UploadHeaders: headers,
-		})
+		if multipart == nil {
+			resp.UploadHeaders = flattenHeaders(upload.Headers)
+		}
+		WriteJSON(w, http.StatusCreated, resp)
 	})
 
 	r.Post("/episodes/{id}/finalize", func(w http.ResponseWriter, req *http.Request) {
@@ -444,6 +473,11 @@ func registerPublicEpisodeRoutes(r chi.Router, deps *app.App, logger zerolog.Log
 		}
 
 		filters := parseFeedFilterParams(req)
+		if filters.Region == "nearby" {
+			if loc, ok := viewerLocation(req, deps); ok {
+				filters.Near = &loc
+			}
+		}
 		items, err := listPublicEpisodes(ctx, deps.DB, listEpisodesParams{
 			Limit:    limit,
 			TopicID:  topicID,
@@ -521,7 +555,7 @@ func registerPublicEpisodeRoutes(r chi.Router, deps *app.App, logger zerolog.Log
 		WriteJSON(w, http.StatusOK, episode)
 	})
 
-	r.Get("/dev/audio/{episodeID}", handleServeDevAudio(deps))
+	withOptionalAuth.Get("/dev/audio/{episodeID}", handleServeDevAudio(deps))
 }
 
 type devEpisodeParams struct {
@@ -626,22 +660,9 @@ func handleDevEpisodeUpload(deps *app.App) http.HandlerFunc {
 		if ext == "" {
 			ext = ".m4a"
 		}
-		storageKey := filepath.Join("dev", episodeID.String()+ext)
-		absPath := filepath.Join(deps.Config.LocalMediaPath, storageKey)
-
-		if err := os.MkdirAll(filepath.Dir(absPath), 0o755); err != nil {
-			WriteError(w, http.StatusInternalServerError, "storage_error", err.Error())
-			return
-		}
-
-		dst, err := os.Create(absPath)
-		if err != nil {
-			WriteError(w, http.StatusInternalServerError, "storage_error", err.Error())
-			return
-		}
-		defer dst.Close()
-
-		if _, err := io.Copy(dst, file); err != nil {
+		storageKey := "dev/" + episodeID.String() + ext
+		contentType := coalesceContentType(header.Header.Get("Content-Type"))
+		if _, err := deps.Storage.PutObject(req.Context(), storageKey, file, map[string]string{storage.MetadataContentType: contentType}); err != nil {
 			WriteError(w, http.StatusInternalServerError, "storage_error", err.Error())
 			return
 		}
@@ -668,6 +689,9 @@ func handleDevEpisodeUpload(deps *app.App) http.HandlerFunc {
 	}
 }
 
+// devAudioURLTTL bounds how long a redirect from /dev/audio stays playable.
+const devAudioURLTTL = 15 * time.Minute
+
 func handleServeDevAudio(deps *app.App) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		episodeID, err := uuidFromParam(chi.URLParam(req, "episodeID"))
@@ -676,9 +700,13 @@ func handleServeDevAudio(deps *app.App) http.HandlerFunc {
 			return
 		}
 
-		const query = `SELECT s3_key FROM audio_items WHERE id = $1`
-		var s3Key sql.NullString
-		if err := deps.DB.QueryRowContext(req.Context(), query, episodeID).Scan(&s3Key); err != nil {
+		const query = `SELECT s3_key, owner_id, visibility FROM audio_items WHERE id = $1`
+		var (
+			s3Key      sql.NullString
+			ownerID    uuid.UUID
+			visibility string
+		)
+		if err := deps.DB.QueryRowContext(req.Context(), query, episodeID).Scan(&s3Key, &ownerID, &visibility); err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				WriteError(w, http.StatusNotFound, "not_found", "episode not found")
 				return
@@ -686,18 +714,29 @@ func handleServeDevAudio(deps *app.App) http.HandlerFunc {
 			WriteError(w, http.StatusInternalServerError, "storage_error", err.Error())
 			return
 		}
-		if !s3Key.Valid || !strings.HasPrefix(s3Key.String, "dev/") {
-			WriteError(w, http.StatusNotFound, "not_found", "dev audio not found")
+		if currentUser, ok := httpctx.UserFromContext(req.Context()); visibility != "public" && (!ok || currentUser.ID != ownerID) {
+			WriteError(w, http.StatusForbidden, "forbidden", "episode not available")
 			return
 		}
-
-		path := filepath.Join(deps.Config.LocalMediaPath, s3Key.String)
-		if _, err := os.Stat(path); err != nil {
-			WriteError(w, http.StatusNotFound, "not_found", "audio file missing")
+		if !s3Key.Valid || s3Key.String == "" {
+			WriteError(w, http.StatusNotFound, "not_found", "audio not found")
 			return
 		}
 
-		http.ServeFile(w, req, path)
+		if _, err := deps.Storage.HeadObject(req.Context(), s3Key.String); err != nil {
+			if errors.Is(err, storage.ErrObjectNotFound) {
+				WriteError(w, http.StatusNotFound, "not_found", "audio file missing")
+				return
+			}
+			WriteError(w, http.StatusInternalServerError, "storage_error", err.Error())
+			return
+		}
+		url, err := deps.Storage.PresignDownload(req.Context(), s3Key.String, devAudioURLTTL)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "storage_error", err.Error())
+			return
+		}
+		http.Redirect(w, req, url, http.StatusFound)
 	}
 }
 
@@ -706,6 +745,9 @@ type feedFilterParams struct {
 	Format string
 	Region string
 	Tags   map[string]struct{}
+	// Near is the viewer's location for the nearby region; without it the
+	// nearby feed is not narrowed.
+	Near *geo.Location
 }
 
 func parseFeedFilterParams(r *http.Request) feedFilterParams {
@@ -743,9 +785,6 @@ func applyFeedFilters(items []episodeSummary, filters feedFilterParams) []episod
 	working := make([]episodeSummary, 0, len(items))
 
 	for _, item := range items {
-		if !regionMatches(item, filters.Region) {
-			continue
-		}
 		if !formatMatches(item, filters.Format) {
 			continue
 		}
@@ -775,14 +814,6 @@ func applyFeedFilters(items []episodeSummary, filters feedFilterParams) []episod
 		if limit > 0 && limit < len(working) {
 			working = append([]episodeSummary(nil), working[:limit]...)
 		}
-	case "trending_nearby":
-		filtered := make([]episodeSummary, 0, len(working))
-		for _, item := range working {
-			if regionMatches(item, "nearby") {
-				filtered = append(filtered, item)
-			}
-		}
-		working = filtered
 	}
 
 	return working
@@ -805,24 +836,6 @@ func formatMatches(item episodeSummary, filter string) bool {
 	}
 }
 
-func regionMatches(item episodeSummary, filter string) bool {
-	if filter == "" || filter == "global" {
-		return true
-	}
-
-	region := deriveEpisodeRegion(item)
-	switch filter {
-	case "nearby":
-		return region == regionKyiv
-	case "eu", "europe":
-		return region == regionKyiv || region == regionLviv || region == regionWarsaw || region == regionBerlin
-	case "na", "north_america":
-		return region == regionNewYork
-	default:
-		return true
-	}
-}
-
 func tagsMatch(item episodeSummary, selected map[string]struct{}) bool {
 	if len(selected) == 0 {
 		return true
@@ -886,30 +899,6 @@ func classifyEpisodeFormat(item episodeSummary) string {
 	return "podcasts"
 }
 
-type episodeRegion int
-
-const (
-	regionKyiv episodeRegion = iota
-	regionLviv
-	regionWarsaw
-	regionBerlin
-	regionNewYork
-)
-
-var regionRotation = [...]episodeRegion{
-	regionKyiv,
-	regionLviv,
-	regionWarsaw,
-	regionBerlin,
-	regionNewYork,
-}
-
-func deriveEpisodeRegion(item episodeSummary) episodeRegion {
-	hash := hashString(item.ID)
-	idx := int(hash % uint32(len(regionRotation)))
-	return regionRotation[idx]
-}
-
 func hashString(value string) uint32 {
 	h := fnv.New32a()
 	_, _ = h.Write([]byte(value))
@@ -943,6 +932,7 @@ func listPublicEpisodes(ctx context.Context, db *sql.DB, params listEpisodesPara
 FROM audio_items e
 JOIN users u ON u.id = e.owner_id
 LEFT JOIN summaries s ON s.audio_id = e.id
+LEFT JOIN profiles p ON p.user_id = e.owner_id
 WHERE e.visibility = 'public'
   AND NOT (u.plan = 'free' AND COALESCE(e.duration_sec, 0) <= %d AND e.created_at < NOW() - INTERVAL '24 hours')`, shortStoryDurationThreshold)
 	var (
@@ -961,7 +951,7 @@ WHERE e.visibility = 'public'
 	}
 
 	query += buildFormatClause(params.Filters, &cursor, &args)
-	if regionClause := buildRegionClause(params.Filters.Region, &cursor, &args); regionClause != "" {
+	if regionClause := buildRegionClause(params.Filters, &cursor, &args); regionClause != "" {
 		query += " AND " + regionClause
 	}
 	if tagClause := buildTagClause(params.Filters.Tags, &cursor, &args); tagClause != "" {
@@ -1060,33 +1050,35 @@ func buildFormatClause(filters feedFilterParams, cursor *int, args *[]any) strin
 	}
 }
 
-func buildRegionClause(region string, cursor *int, args *[]any) string {
-	normalized := strings.ToLower(strings.TrimSpace(region))
-	if normalized == "" || normalized == "global" {
-		return ""
-	}
-	allowed := allowedRegions(normalized)
-	if len(allowed) == 0 {
+// buildRegionClause narrows the feed by where authors said they are:
+// a continent, a country code, a city name, or nearby the viewer.
+func buildRegionClause(filters feedFilterParams, cursor *int, args *[]any) string {
+	normalized := strings.ToLower(strings.TrimSpace(filters.Region))
+	switch normalized {
+	case "", "global":
 		return ""
+	case "eu", "europe":
+		return "p.location_continent = '" + geo.ContinentEurope + "'"
+	case "na", "north_america":
+		return "p.location_continent = '" + geo.ContinentNorthAmerica + "'"
+	case "nearby":
+		if filters.Near == nil {
+			return ""
+		}
+		index := *cursor
+		*cursor += 3
+		*args = append(*args, filters.Near.Lat, filters.Near.Lon, float64(geo.NearbyRadiusKm))
+		return fmt.Sprintf("%s <= $%d", distanceKmSQL("p.location_lat", "p.location_lon", index), index+2)
 	}
-	column := "COALESCE(NULLIF(lower(u.settings_json->>'region'), ''), 'global')"
+
 	index := *cursor
 	*cursor++
-	*args = append(*args, pq.Array(allowed))
-	return fmt.Sprintf("%s = ANY($%d)", column, index)
-}
-
-func allowedRegions(filter string) []string {
-	switch filter {
-	case "nearby":
-		return []string{"kyiv", "nearby"}
-	case "eu", "europe":
-		return []string{"kyiv", "lviv", "warsaw", "berlin", "eu", "europe"}
-	case "na", "north_america":
-		return []string{"new_york", "los_angeles", "north_america", "na"}
-	default:
-		return []string{filter}
+	if country := geo.NormalizeCountry(normalized); country != "" {
+		*args = append(*args, country)
+		return fmt.Sprintf("p.location_country = $%d", index)
 	}
+	*args = append(*args, normalized)
+	return fmt.Sprintf("lower(p.location_city) = $%d", index)
 }
 
 func buildTagClause(tags map[string]struct{}, cursor *int, args *[]any) string {
@@ -1129,10 +1121,12 @@ func buildFeedOrderClause(filters feedFilterParams) string {
 }
 
 func getEpisodeByID(ctx context.Context, db *sql.DB, id uuid.UUID) (episodeSummary, error) {
-	const query = `SELECT e.id, e.owner_id, e.title, e.visibility, e.kind, e.duration_sec, e.audio_url, e.created_at, s.tldr, s.keywords, s.mood, u.plan
+	const query = `SELECT e.id, e.owner_id, e.title, e.visibility, e.kind, e.duration_sec, e.audio_url, e.created_at, s.tldr, s.keywords, s.mood, u.plan,
+       e.processing_error, e.processing_error_detail, e.live_session_id IS NOT NULL, COALESCE(ls.mask, 'none')
 FROM audio_items e
 JOIN users u ON u.id = e.owner_id
 LEFT JOIN summaries s ON s.audio_id = e.id
+LEFT JOIN live_sessions ls ON ls.id = e.live_session_id
 WHERE e.id = $1`
 	var (
 		rec         episodeSummary
@@ -1145,6 +1139,8 @@ WHERE e.id = $1`
 		keywordsArr pq.StringArray
 		moodJSON    sql.NullString
 		authorPlan  string
+		procErr     sql.NullString
+		procDetail  sql.NullString
 	)
 	err := db.QueryRowContext(ctx, query, id).Scan(
 		&rec.ID,
@@ -1159,6 +1155,10 @@ WHERE e.id = $1`
 		&keywordsArr,
 		&moodJSON,
 		&authorPlan,
+		&procErr,
+		&procDetail,
+		&rec.IsLive,
+		&rec.Mask,
 	)
 	if err != nil {
 		return episodeSummary{}, err
@@ -1166,9 +1166,7 @@ WHERE e.id = $1`
 	rec.AuthorID = ownerUUID.String()
 	rec.AuthorPlan = authorPlan
 	rec.Status = "public" // audio_items with visibility='public' are always public
-	rec.Mask = "none"     // Default value
 	rec.Quality = "clean" // Default value
-	rec.IsLive = false    // audio_items don't have is_live
 	rec.PublishedAt = &rec.CreatedAt // Use created_at as published_at
 	if title.Valid {
 		rec.Title = &title.String
@@ -1193,6 +1191,10 @@ WHERE e.id = $1`
 			rec.Mood = mood
 		}
 	}
+	if procErr.Valid && procErr.String != "" {
+		rec.Status = "failed"
+		rec.ProcessingError = &processingError{Reason: procErr.String, Detail: procDetail.String}
+	}
 	return rec, nil
 }
 
@@ -4,14 +4,23 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
 
+	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/auth"
+	"github.com/amunx/backend/internal/geo"
 	"github.com/amunx/backend/internal/httpctx"
+	"github.com/amunx/backend/internal/storage"
 )
 
 func TestUndoEpisodeWithinWindow(t *testing.T) {
@@ -292,3 +301,91 @@ func TestIsStoryExpired(t *testing.T) {
 		t.Fatalf("long format should not expire even for free")
 	}
 }
+
+func TestBuildRegionClause(t *testing.T) {
+	cases := []struct {
+		filters feedFilterParams
+		clause  string
+		args    []any
+	}{
+		{feedFilterParams{Region: "global"}, "", nil},
+		{feedFilterParams{Region: "europe"}, "p.location_continent = 'EU'", nil},
+		{feedFilterParams{Region: "ua"}, "p.location_country = $3", []any{"UA"}},
+		{feedFilterParams{Region: "Lviv"}, "lower(p.location_city) = $3", []any{"lviv"}},
+		// Without a viewer location nearby does not narrow the feed.
+		{feedFilterParams{Region: "nearby"}, "", nil},
+		{feedFilterParams{Region: "nearby", Near: &geo.Location{Lat: 49.8, Lon: 24}}, "<= $5", []any{49.8, float64(24), float64(geo.NearbyRadiusKm)}},
+	}
+	for _, tc := range cases {
+		cursor := 3
+		var args []any
+		clause := buildRegionClause(tc.filters, &cursor, &args)
+		if !strings.HasSuffix(clause, tc.clause) || (tc.clause == "") != (clause == "") {
+			t.Fatalf("%s: expected clause ending %q, got %q", tc.filters.Region, tc.clause, clause)
+		}
+		if len(args) != len(tc.args) || cursor != 3+len(tc.args) {
+			t.Fatalf("%s: expected args %v, got %v (cursor %d)", tc.filters.Region, tc.args, args, cursor)
+		}
+		for i := range args {
+			if args[i] != tc.args[i] {
+				t.Fatalf("%s: expected args %v, got %v", tc.filters.Region, tc.args, args)
+			}
+		}
+	}
+}
+
+// signIn adds a bearer token for user to req and expects the user lookup the
+// auth middleware makes.
+func signIn(t *testing.T, deps *app.App, mock sqlmock.Sqlmock, req *http.Request, user httpctx.User) {
+	t.Helper()
+	token, err := deps.JWT.IssueAccess(user.ID.String(), user.Plan)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	mock.ExpectQuery("FROM users").
+		WithArgs(user.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "handle", "email", "display_name", "avatar", "is_anon", "plan", "shadowbanned"}).
+			AddRow(user.ID, nil, user.Email, nil, nil, false, user.Plan, false))
+}
+
+func TestServeDevAudioLetsOwnerReadPrivateEpisode(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	fs, err := storage.NewFSClient(storage.FSConfig{Root: t.TempDir(), BaseURL: "http://api.test", SigningSecret: "secret"})
+	if err != nil {
+		t.Fatalf("fs client: %v", err)
+	}
+	key := "originals/private.ogg"
+	if _, err := fs.PutObject(context.Background(), key, strings.NewReader("audio"), nil); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	deps := &app.App{
+		DB:      db,
+		JWT:     auth.NewJWTManager("access", "refresh", time.Minute, time.Hour),
+		Storage: fs,
+	}
+	r := chi.NewRouter()
+	registerPublicEpisodeRoutes(r, deps, zerolog.Nop())
+
+	owner := httpctx.User{ID: uuid.New(), Email: "owner@example.com", Plan: "free"}
+	episodeID := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/dev/audio/"+episodeID.String(), nil)
+	signIn(t, deps, mock, req, owner)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT s3_key, owner_id, visibility FROM audio_items WHERE id = $1")).
+		WithArgs(episodeID).
+		WillReturnRows(sqlmock.NewRows([]string{"s3_key", "owner_id", "visibility"}).AddRow(key, owner.ID, "private"))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected owner to be redirected to the audio, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package http
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"math"
 	"net/http"
@@ -211,6 +212,7 @@ SELECT e.id,
        COALESCE(s.keywords, ARRAY[]::text[]) AS keywords,
        COALESCE(e.audio_url, '') AS audio_url,
        COALESCE(e.title, '') AS title,
+       e.waveform,
        e.created_at,
        COALESCE(rt.likes, 0) AS likes,
        COALESCE(rt.bookmarks, 0) AS saves,
@@ -276,12 +278,13 @@ SELECT e.id,
 			keywords  pq.StringArray
 			audioURL  string
 			title     string
+			waveform  []byte
 			createdAt time.Time
 			likes     int64
 			saves     int64
 			comments  int64
 		)
-		if err := rows.Scan(&id, &authorID, &display, &avatar, &duration, &summary, &keywords, &audioURL, &title, &createdAt, &likes, &saves, &comments); err != nil {
+		if err := rows.Scan(&id, &authorID, &display, &avatar, &duration, &summary, &keywords, &audioURL, &title, &waveform, &createdAt, &likes, &saves, &comments); err != nil {
 			return nil, err
 		}
 		preview := strings.TrimSpace(summary)
@@ -302,6 +305,7 @@ SELECT e.id,
 			PreviewSentence: preview,
 			Title:           strings.TrimSpace(title),
 			Tags:            tags,
+			WaveformPeaks:   decodeWaveformPeaks(waveform),
 			AudioURL:        audioURL,
 			CreatedAt:       createdAt.Format(time.RFC3339),
 			createdAtTime:   createdAt,
@@ -317,6 +321,25 @@ SELECT e.id,
 	return cards, rows.Err()
 }
 
+// decodeWaveformPeaks reads audio_items.waveform, which holds either the
+// worker's {"peaks": [...]} object or a bare array from older rows.
+func decodeWaveformPeaks(raw []byte) []float64 {
+	if len(raw) == 0 {
+		return nil
+	}
+	var wave struct {
+		Peaks []float64 `json:"peaks"`
+	}
+	if err := json.Unmarshal(raw, &wave); err == nil {
+		return wave.Peaks
+	}
+	var peaks []float64
+	if err := json.Unmarshal(raw, &peaks); err == nil {
+		return peaks
+	}
+	return nil
+}
+
 func normalizeExploreTags(values []string) []string {
 	if len(values) == 0 {
 		return nil
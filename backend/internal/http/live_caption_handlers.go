@@ -0,0 +1,146 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/live"
+)
+
+// registerPublicLiveCaptionRoutes takes caption segments from the live
+// agent, authenticated with LIVE_AGENT_TOKEN, and serves them to listeners.
+func registerPublicLiveCaptionRoutes(r chi.Router, deps *app.App) {
+	r.Post("/live/agent/sessions/{sessionID}/captions", handleAppendLiveCaptions(deps))
+	r.Get("/live/sessions/{id}/captions", handleLiveCaptionHistory(deps))
+	r.Get("/live/sessions/{id}/captions/stream", handleLiveCaptionStream(deps))
+}
+
+func handleAppendLiveCaptions(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !authorizeLiveAgent(w, req, deps) {
+			return
+		}
+		sessionID, err := uuidFromParam(chi.URLParam(req, "sessionID"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+			return
+		}
+		var payload struct {
+			Segments []live.CaptionInput `json:"segments"`
+		}
+		if err := decodeJSON(req, &payload); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		stored, err := live.NewCaptions(deps.DB, deps.Redis).Append(req.Context(), sessionID, payload.Segments)
+		if err != nil {
+			switch {
+			case errors.Is(err, live.ErrInvalidCaptions):
+				WriteError(w, http.StatusBadRequest, "invalid_captions",
+					fmt.Sprintf("send 1-%d segments, each with segment_id, lang, text and started_at not after ended_at", live.MaxCaptionBatch))
+			case errors.Is(err, live.ErrSessionEnded):
+				WriteError(w, http.StatusNotFound, "session_not_found", "live session not found or ended")
+			default:
+				WriteError(w, http.StatusInternalServerError, "live_captions_failed", err.Error())
+			}
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"stored": len(stored),
+		})
+	}
+}
+
+func handleLiveCaptionHistory(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		sessionID, err := uuidFromParam(chi.URLParam(req, "id"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+			return
+		}
+		var after int64
+		if raw := req.URL.Query().Get("after"); raw != "" {
+			if after, err = strconv.ParseInt(raw, 10, 64); err != nil || after < 0 {
+				WriteError(w, http.StatusBadRequest, "invalid_cursor", "after must be a caption id")
+				return
+			}
+		}
+		limit := getIntQueryParam(req, "limit", live.DefaultCaptionHistory)
+		if limit <= 0 || limit > 1000 {
+			limit = live.DefaultCaptionHistory
+		}
+		segments, err := live.NewCaptions(deps.DB, deps.Redis).Since(req.Context(), sessionID, after, limit)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "live_captions_failed", err.Error())
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"segments": segments,
+			"count":    len(segments),
+		})
+	}
+}
+
+// handleLiveCaptionStream streams caption segments as server-sent events.
+// Clients reconnecting with Last-Event-ID first receive what they missed.
+func handleLiveCaptionStream(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		sessionID, err := uuidFromParam(chi.URLParam(req, "id"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+			return
+		}
+		if deps.Redis == nil {
+			WriteError(w, http.StatusServiceUnavailable, "live_captions_unavailable", "live captions are not configured")
+			return
+		}
+		if _, err := getActiveLiveSession(ctx, deps.DB, sessionID); err != nil {
+			WriteError(w, http.StatusNotFound, "session_not_found", "live session not found or ended")
+			return
+		}
+
+		captions := live.NewCaptions(deps.DB, deps.Redis)
+		sub := captions.Subscribe(ctx, sessionID)
+		defer sub.Close()
+		if _, err := sub.Receive(ctx); err != nil {
+			WriteError(w, http.StatusServiceUnavailable, "live_captions_unavailable", err.Error())
+			return
+		}
+
+		rc := openLiveEventStream(w)
+		if lastID, err := strconv.ParseInt(req.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+			missed, err := captions.Since(ctx, sessionID, lastID, 0)
+			if err != nil {
+				return
+			}
+			for _, seg := range missed {
+				if writeLiveCaptionEvent(w, seg) != nil {
+					return
+				}
+			}
+		}
+		relayLiveEvents(ctx, w, rc, sub, func(payload string) error {
+			var seg live.CaptionSegment
+			if err := json.Unmarshal([]byte(payload), &seg); err != nil {
+				return nil
+			}
+			return writeLiveCaptionEvent(w, seg)
+		})
+	}
+}
+
+func writeLiveCaptionEvent(w http.ResponseWriter, seg live.CaptionSegment) error {
+	data, err := json.Marshal(seg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: caption\ndata: %s\n\n", seg.ID, data)
+	return err
+}
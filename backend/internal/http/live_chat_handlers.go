@@ -0,0 +1,305 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/httpctx"
+	"github.com/amunx/backend/internal/live"
+)
+
+var (
+	liveChatRateLimit      int64 = 20
+	liveChatRateWindow           = 30 * time.Second
+	liveReactionRateLimit  int64 = 60
+	liveReactionRateWindow       = 30 * time.Second
+	liveChatHeartbeat            = 15 * time.Second
+)
+
+// registerLiveChatRoutes lets participants post to a room's chat and its
+// moderators pin entries and answer questions.
+func registerLiveChatRoutes(r chi.Router, deps *app.App) {
+	r.Post("/live/sessions/{id}/chat", handlePostLiveChat(deps))
+	r.Post("/live/sessions/{id}/chat/{entryID}/pin", handlePinLiveChat(deps, true))
+	r.Delete("/live/sessions/{id}/chat/{entryID}/pin", handlePinLiveChat(deps, false))
+	r.Post("/live/sessions/{id}/chat/{entryID}/answer", handleAnswerLiveChat(deps))
+}
+
+// registerPublicLiveChatRoutes serves chat history, which doubles as the
+// replay timeline, and the live event stream.
+func registerPublicLiveChatRoutes(r chi.Router, deps *app.App) {
+	r.Get("/live/sessions/{id}/chat", handleLiveChatHistory(deps))
+	r.Get("/live/sessions/{id}/chat/stream", handleLiveChatStream(deps))
+}
+
+func handlePostLiveChat(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, ok := httpctx.UserFromContext(req.Context())
+		if !ok {
+			WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+			return
+		}
+		sessionID, err := uuidFromParam(chi.URLParam(req, "id"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+			return
+		}
+		var payload struct {
+			Kind string `json:"kind"`
+			Body string `json:"body"`
+		}
+		if err := decodeJSON(req, &payload); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		kind, valid := live.ParseChatKind(payload.Kind)
+		if !valid {
+			WriteError(w, http.StatusBadRequest, "invalid_kind", "kind must be message, question or reaction")
+			return
+		}
+
+		key, limit, window := "rl:live_chat:", liveChatRateLimit, liveChatRateWindow
+		if kind == live.ChatReaction {
+			key, limit, window = "rl:live_react:", liveReactionRateLimit, liveReactionRateWindow
+		}
+		if allowed, retry := allowRate(req.Context(), deps.Redis, key+sessionID.String()+":"+user.ID.String(), limit, window); !allowed {
+			if retry > 0 {
+				w.Header().Set("Retry-After", strconv.FormatInt(int64((retry+time.Second-1)/time.Second), 10))
+			}
+			WriteError(w, http.StatusTooManyRequests, "rate_limited", "too many chat messages sent recently")
+			return
+		}
+
+		flagged := kind != live.ChatReaction && containsBannedWord(payload.Body)
+		entry, err := live.NewChat(deps.DB, deps.Redis).Post(req.Context(), sessionID, user.ID, kind, payload.Body, flagged)
+		if err != nil {
+			writeLiveChatError(w, err)
+			return
+		}
+		if flagged {
+			_ = flagContent(req.Context(), deps.DB, "live_chat/"+entry.ID.String(), "language", 1, "banned_word")
+		}
+		WriteJSON(w, http.StatusCreated, map[string]any{
+			"entry":   entry,
+			"flagged": flagged,
+		})
+	}
+}
+
+func handlePinLiveChat(deps *app.App, pinned bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, sessionID, entryID, ok := liveChatTarget(w, req)
+		if !ok {
+			return
+		}
+		entry, err := live.NewChat(deps.DB, deps.Redis).Pin(req.Context(), sessionID, user.ID, entryID, pinned)
+		if err != nil {
+			writeLiveChatError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{"entry": entry})
+	}
+}
+
+func handleAnswerLiveChat(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, sessionID, entryID, ok := liveChatTarget(w, req)
+		if !ok {
+			return
+		}
+		entry, err := live.NewChat(deps.DB, deps.Redis).Answer(req.Context(), sessionID, user.ID, entryID)
+		if err != nil {
+			writeLiveChatError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{"entry": entry})
+	}
+}
+
+func handleLiveChatHistory(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		sessionID, err := uuidFromParam(chi.URLParam(req, "id"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+			return
+		}
+		var after *uuid.UUID
+		if raw := req.URL.Query().Get("after"); raw != "" {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, "invalid_cursor", "after must be a chat entry id")
+				return
+			}
+			after = &id
+		}
+		limit := getIntQueryParam(req, "limit", live.DefaultChatHistory)
+		if limit <= 0 || limit > 500 {
+			limit = live.DefaultChatHistory
+		}
+		entries, err := live.NewChat(deps.DB, deps.Redis).History(req.Context(), sessionID, after, limit)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "live_chat_failed", err.Error())
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"entries": entries,
+			"count":   len(entries),
+		})
+	}
+}
+
+// handleLiveChatStream streams chat events as server-sent events. Clients
+// reconnecting with Last-Event-ID first receive the messages they missed.
+func handleLiveChatStream(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		sessionID, err := uuidFromParam(chi.URLParam(req, "id"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+			return
+		}
+		if deps.Redis == nil {
+			WriteError(w, http.StatusServiceUnavailable, "live_chat_unavailable", "live chat is not configured")
+			return
+		}
+		if _, err := getActiveLiveSession(ctx, deps.DB, sessionID); err != nil {
+			WriteError(w, http.StatusNotFound, "session_not_found", "live session not found or ended")
+			return
+		}
+
+		chat := live.NewChat(deps.DB, deps.Redis)
+		sub := chat.Subscribe(ctx, sessionID)
+		defer sub.Close()
+		// Wait for the subscription before backfilling so nothing posted in
+		// between is lost.
+		if _, err := sub.Receive(ctx); err != nil {
+			WriteError(w, http.StatusServiceUnavailable, "live_chat_unavailable", err.Error())
+			return
+		}
+
+		rc := openLiveEventStream(w)
+		if lastID, err := uuid.Parse(req.Header.Get("Last-Event-ID")); err == nil {
+			missed, err := chat.History(ctx, sessionID, &lastID, 0)
+			if err != nil {
+				return
+			}
+			for _, entry := range missed {
+				if writeLiveChatEvent(w, live.ChatEvent{Type: live.ChatEventMessage, Entry: entry}) != nil {
+					return
+				}
+			}
+		}
+		relayLiveEvents(ctx, w, rc, sub, func(payload string) error {
+			var event live.ChatEvent
+			if err := json.Unmarshal([]byte(payload), &event); err != nil {
+				return nil
+			}
+			return writeLiveChatEvent(w, event)
+		})
+	}
+}
+
+// openLiveEventStream starts a server-sent event response that outlives the
+// server's write timeout.
+func openLiveEventStream(w http.ResponseWriter) *http.ResponseController {
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	return rc
+}
+
+// relayLiveEvents hands every message of sub to write until the client goes
+// away, with heartbeats in between so proxies keep the stream open.
+func relayLiveEvents(ctx context.Context, w http.ResponseWriter, rc *http.ResponseController, sub *redis.PubSub, write func(payload string) error) {
+	if rc.Flush() != nil {
+		return
+	}
+	heartbeat := time.NewTicker(liveChatHeartbeat)
+	defer heartbeat.Stop()
+	events := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case msg, open := <-events:
+			if !open {
+				return
+			}
+			if write(msg.Payload) != nil {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+// writeLiveChatEvent writes one server-sent event. Only new messages carry
+// an id, so Last-Event-ID always points into posting order.
+func writeLiveChatEvent(w http.ResponseWriter, event live.ChatEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.Type == live.ChatEventMessage {
+		if _, err := fmt.Fprintf(w, "id: %s\n", event.Entry.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// liveChatTarget resolves the acting user and the session and chat entry
+// addressed by the route, writing an error response when one is missing.
+func liveChatTarget(w http.ResponseWriter, req *http.Request) (httpctx.User, uuid.UUID, uuid.UUID, bool) {
+	user, ok := httpctx.UserFromContext(req.Context())
+	if !ok {
+		WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+		return httpctx.User{}, uuid.Nil, uuid.Nil, false
+	}
+	sessionID, err := uuidFromParam(chi.URLParam(req, "id"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+		return httpctx.User{}, uuid.Nil, uuid.Nil, false
+	}
+	entryID, err := uuidFromParam(chi.URLParam(req, "entryID"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid_entry_id", err.Error())
+		return httpctx.User{}, uuid.Nil, uuid.Nil, false
+	}
+	return user, sessionID, entryID, true
+}
+
+func writeLiveChatError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, live.ErrInvalidChat):
+		WriteError(w, http.StatusBadRequest, "invalid_chat", "body is required and must be at most 500 characters, 16 for reactions")
+	case errors.Is(err, live.ErrChatNotFound):
+		WriteError(w, http.StatusNotFound, "chat_entry_not_found", err.Error())
+	case errors.Is(err, live.ErrNotParticipant):
+		WriteError(w, http.StatusForbidden, "not_participant", "join the live session before chatting")
+	case errors.Is(err, live.ErrSessionEnded), errors.Is(err, live.ErrRemoved), errors.Is(err, live.ErrForbidden):
+		writeRosterError(w, err)
+	default:
+		WriteError(w, http.StatusInternalServerError, "live_chat_failed", err.Error())
+	}
+}
@@ -17,11 +17,13 @@ import (
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 	livekitauth "github.com/livekit/protocol/auth"
+	"github.com/rs/zerolog"
 
 	"github.com/amunx/backend/internal/app"
+	mw "github.com/amunx/backend/internal/http/middleware"
 	"github.com/amunx/backend/internal/httpctx"
+	"github.com/amunx/backend/internal/live"
 	"github.com/amunx/backend/internal/push"
-	"github.com/amunx/backend/internal/queue"
 )
 
 func registerLiveRoutes(r chi.Router, deps *app.App) {
@@ -41,15 +43,33 @@ func registerLiveRoutes(r chi.Router, deps *app.App) {
 		}
 
 		var payload struct {
-			TopicID *string `json:"topic_id"`
-			Title   string  `json:"title"`
-			Mask    string  `json:"mask"`
+			TopicID    *string `json:"topic_id"`
+			Title      string  `json:"title"`
+			Mask       string  `json:"mask"`
+			ScheduleID *string `json:"schedule_id"`
 		}
 		if err := decodeJSON(req, &payload); err != nil {
 			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
 			return
 		}
 
+		// Starting a scheduled session takes its title and topic unless the
+		// request overrides them.
+		var schedule *live.Schedule
+		if payload.ScheduleID != nil && *payload.ScheduleID != "" {
+			scheduleID, err := uuid.Parse(*payload.ScheduleID)
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, "invalid_schedule_id", "schedule_id must be a valid UUID")
+				return
+			}
+			sched, err := newLiveSchedules(deps).ForHost(req.Context(), scheduleID, user.ID)
+			if err != nil {
+				writeScheduleError(w, err)
+				return
+			}
+			schedule = &sched
+		}
+
 		var topicID *uuid.UUID
 		if payload.TopicID != nil && *payload.TopicID != "" {
 			tID, err := uuid.Parse(*payload.TopicID)
@@ -72,6 +92,14 @@ func registerLiveRoutes(r chi.Router, deps *app.App) {
 			}
 			topicID = &tID
 		}
+		if schedule != nil {
+			if topicID == nil {
+				topicID = schedule.TopicID
+			}
+			if strings.TrimSpace(payload.Title) == "" {
+				payload.Title = schedule.Title
+			}
+		}
 
 		mask := normalizeMask(payload.Mask)
 		if mask != "none" && !deps.Config.FeatureLiveMaskBeta {
@@ -80,7 +108,7 @@ func registerLiveRoutes(r chi.Router, deps *app.App) {
 		}
 
 		sessionID := uuid.New()
-		roomName := fmt.Sprintf("live-%s", sessionID.String())
+		roomName := live.RoomName(sessionID)
 		now := time.Now().UTC()
 		title := strings.TrimSpace(payload.Title)
 
@@ -88,8 +116,26 @@ func registerLiveRoutes(r chi.Router, deps *app.App) {
 			WriteError(w, http.StatusInternalServerError, "live_create_failed", err.Error())
 			return
 		}
+		if schedule != nil {
+			if err := newLiveSchedules(deps).Attach(req.Context(), schedule.ID, user.ID, sessionID); err != nil {
+				// Canceled or started concurrently; drop the room we just made.
+				_, _ = deps.DB.ExecContext(req.Context(), `DELETE FROM live_sessions WHERE id = $1`, sessionID)
+				if errors.Is(err, live.ErrScheduleNotFound) {
+					WriteError(w, http.StatusConflict, "schedule_unavailable", "scheduled session was canceled or already started")
+					return
+				}
+				WriteError(w, http.StatusInternalServerError, "live_create_failed", err.Error())
+				return
+			}
+		}
+
+		host, err := newLiveRoster(deps).Join(req.Context(), sessionID, user.ID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "live_create_failed", err.Error())
+			return
+		}
 
-		token, err := generateLiveToken(deps.Config, roomName, user.ID.String(), "host", now.Add(1*time.Hour))
+		token, err := generateLiveToken(deps.Config, roomName, user.ID.String(), host, now.Add(1*time.Hour))
 		if err != nil {
 			WriteError(w, http.StatusInternalServerError, "token_generation_failed", err.Error())
 			return
@@ -109,7 +155,10 @@ func registerLiveRoutes(r chi.Router, deps *app.App) {
 			"url":   deps.Config.LiveKitURL,
 		})
 
-		go dispatchLiveStartPush(req.Context(), deps, user, sessionID, title)
+		// Scheduled sessions announce their start through the reminder job.
+		if schedule == nil {
+			go dispatchLiveStartPush(req.Context(), deps, user, sessionID, title)
+		}
 	})
 
 	r.Post("/live/sessions/{id}/end", func(w http.ResponseWriter, req *http.Request) {
@@ -149,57 +198,56 @@ func registerLiveRoutes(r chi.Router, deps *app.App) {
 				return
 			}
 		}
-
-		now := time.Now().UTC()
-		recordingKey := strings.TrimSpace(payload.RecordingKey)
-		if err := markLiveSessionEnded(req.Context(), deps.DB, sessionID, now, recordingKey, payload.DurationSec); err != nil {
-			WriteError(w, http.StatusInternalServerError, "session_end_failed", err.Error())
+		// Recordings are made server-side; finalization only trusts the
+		// key derived from the session.
+		if strings.TrimSpace(payload.RecordingKey) != "" {
+			WriteError(w, http.StatusBadRequest, "recording_key_not_allowed", "recording_key is set by the server")
 			return
 		}
 
-		job := map[string]any{
-			"session_id": sessionID.String(),
-			"attempt":    0,
-		}
-		if recordingKey != "" {
-			job["recording_key"] = recordingKey
-		}
-		if payload.DurationSec != nil {
-			job["duration_sec"] = *payload.DurationSec
-		}
-
-		if err := deps.Queue.Enqueue(req.Context(), queue.TopicFinalizeLive, job); err != nil {
-			WriteError(w, http.StatusInternalServerError, "finalize_enqueue_failed", err.Error())
+		ended, err := newLiveTracker(deps).End(req.Context(), sessionID, live.EndParams{
+			DurationSec: payload.DurationSec,
+		})
+		if err != nil {
+			if errors.Is(err, live.ErrSessionEnded) {
+				WriteError(w, http.StatusNotFound, "session_not_found", "live session not found or already ended")
+				return
+			}
+			WriteError(w, http.StatusInternalServerError, "session_end_failed", err.Error())
 			return
 		}
 
 		WriteJSON(w, http.StatusOK, map[string]any{
 			"status":    "ended",
-			"ended_at":  now.Format(time.RFC3339),
+			"ended_at":  ended.Format(time.RFC3339),
 			"sessionId": sessionID.String(),
 		})
 	})
 
-	// Enable translation (Pro only)
-	r.Post("/live/translate/enable", handleEnableTranslation(deps))
+	registerLiveRosterRoutes(r, deps)
+	registerLiveScheduleRoutes(r, deps)
+	registerLiveChatRoutes(r, deps)
+	registerLiveTranslationRoutes(r, deps)
 
-	// Disable translation
-	r.Post("/live/translate/disable/{sessionID}", handleDisableTranslation(deps))
+	r.Get("/live/sessions/{id}/stats", handleLiveSessionStats(deps))
 }
 
-func registerPublicLiveRoutes(r chi.Router, deps *app.App) {
+func registerPublicLiveRoutes(r chi.Router, deps *app.App, logger zerolog.Logger) {
+	withOptionalAuth := r.With(mw.TryAuth(deps, logger))
+
 	r.Get("/live/sessions", handleListLiveSessions(deps))
-	r.Get("/live/sessions/{id}", func(w http.ResponseWriter, req *http.Request) {
+	withOptionalAuth.Get("/live/sessions/upcoming", handleListUpcomingLiveSessions(deps))
+	withOptionalAuth.Get("/live/sessions/{id}", func(w http.ResponseWriter, req *http.Request) {
 		sessionID, err := uuidFromParam(chi.URLParam(req, "id"))
 		if err != nil {
 			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
 			return
 		}
-		role := strings.ToLower(strings.TrimSpace(req.URL.Query().Get("role")))
-		if role == "" {
-			role = "listener"
+		requested := strings.ToLower(strings.TrimSpace(req.URL.Query().Get("role")))
+		if requested == "" {
+			requested = string(live.RoleListener)
 		}
-		if role != "listener" && role != "host" {
+		if requested != string(live.RoleListener) && requested != string(live.RoleHost) {
 			WriteError(w, http.StatusBadRequest, "invalid_role", "role must be host or listener")
 			return
 		}
@@ -214,17 +262,29 @@ func registerPublicLiveRoutes(r chi.Router, deps *app.App) {
 			return
 		}
 
+		// Anonymous listeners get a throwaway identity and stay off the
+		// roster; signed-in users join it and receive their roster role.
 		userID := uuid.New().String()
+		participant := live.Participant{Role: live.RoleListener}
 		if user, ok := httpctx.UserFromContext(req.Context()); ok {
 			if user.Shadowbanned {
 				WriteError(w, http.StatusForbidden, "account_restricted", "access denied")
 				return
 			}
 			userID = user.ID.String()
+			participant, err = newLiveRoster(deps).Join(req.Context(), sessionID, user.ID)
+			if err != nil {
+				writeRosterError(w, err)
+				return
+			}
+		}
+		if requested == string(live.RoleHost) && participant.Role != live.RoleHost {
+			WriteError(w, http.StatusForbidden, "forbidden", "only the host can join as host")
+			return
 		}
 
 		expiry := time.Now().UTC().Add(2 * time.Hour)
-		token, err := generateLiveToken(deps.Config, session.Room, userID, role, expiry)
+		token, err := generateLiveToken(deps.Config, session.Room, userID, participant, expiry)
 		if err != nil {
 			WriteError(w, http.StatusInternalServerError, "token_generation_failed", err.Error())
 			return
@@ -232,13 +292,111 @@ func registerPublicLiveRoutes(r chi.Router, deps *app.App) {
 
 		WriteJSON(w, http.StatusOK, map[string]any{
 			"session": session,
+			"role":    participant.Role,
 			"token":   token,
 			"url":     deps.Config.LiveKitURL,
 		})
 	})
 
-	// Get translation status (for agent polling)
-	r.Get("/live/sessions/{sessionID}/translate/status", handleGetTranslationStatus(deps))
+	r.Get("/live/sessions/{id}/replay", handleGetLiveReplay(deps))
+	registerPublicLiveChatRoutes(r, deps)
+	registerPublicLiveTranslationRoutes(r, deps)
+	registerPublicLiveCaptionRoutes(r, deps)
+}
+
+// liveReplayChapter is a chapter of a replay, in seconds from its start.
+type liveReplayChapter struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Title string `json:"title"`
+}
+
+// handleGetLiveReplay returns the replay of an ended session. Replays that
+// are still processing or failed are only visible to the host.
+func handleGetLiveReplay(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		sessionID, err := uuidFromParam(chi.URLParam(req, "id"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+			return
+		}
+
+		var (
+			ended    bool
+			replayID uuid.NullUUID
+		)
+		err = deps.DB.QueryRowContext(ctx, `
+SELECT ls.ended_at IS NOT NULL, ai.id
+  FROM live_sessions ls
+  LEFT JOIN audio_items ai ON ai.live_session_id = ls.id
+ WHERE ls.id = $1`, sessionID).Scan(&ended, &replayID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				WriteError(w, http.StatusNotFound, "session_not_found", "live session not found")
+				return
+			}
+			WriteError(w, http.StatusInternalServerError, "replay_lookup_failed", err.Error())
+			return
+		}
+		if !ended {
+			WriteError(w, http.StatusConflict, "session_live", "live session has not ended yet")
+			return
+		}
+		if !replayID.Valid {
+			WriteError(w, http.StatusNotFound, "replay_not_found", "replay is not available yet")
+			return
+		}
+
+		replay, err := getEpisodeByID(ctx, deps.DB, replayID.UUID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "replay_lookup_failed", err.Error())
+			return
+		}
+		status := "ready"
+		switch {
+		case replay.ProcessingError != nil:
+			status = "failed"
+		case replay.AudioURL == nil:
+			status = "processing"
+		}
+		user, ok := httpctx.UserFromContext(ctx)
+		if status != "ready" && (!ok || user.ID.String() != replay.AuthorID) {
+			WriteError(w, http.StatusNotFound, "replay_not_found", "replay is not available yet")
+			return
+		}
+
+		chapters, err := loadReplayChapters(ctx, deps.DB, replayID.UUID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "replay_lookup_failed", err.Error())
+			return
+		}
+
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"session_id": sessionID.String(),
+			"status":     status,
+			"replay":     replay,
+			"chapters":   chapters,
+		})
+	}
+}
+
+func loadReplayChapters(ctx context.Context, db *sql.DB, audioID uuid.UUID) ([]liveReplayChapter, error) {
+	var raw []byte
+	err := db.QueryRowContext(ctx, `SELECT chapters FROM summaries WHERE audio_id = $1`, audioID).Scan(&raw)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	chapters := []liveReplayChapter{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &chapters); err != nil {
+			return nil, err
+		}
+	}
+	if chapters == nil {
+		chapters = []liveReplayChapter{}
+	}
+	return chapters, nil
 }
 
 func createLiveSession(ctx context.Context, db *sql.DB, id, hostID uuid.UUID, topicID *uuid.UUID, room string, title string, mask string, started time.Time) error {
@@ -315,35 +473,11 @@ WHERE id = $1;
 	return rec, nil
 }
 
-func markLiveSessionEnded(ctx context.Context, db *sql.DB, id uuid.UUID, ended time.Time, recordingKey string, durationSec *int) error {
-	const query = `
-UPDATE live_sessions
-SET ended_at = $2,
-    recording_key = COALESCE(NULLIF($3, ''), recording_key),
-    duration_sec = COALESCE($4, duration_sec)
-WHERE id = $1 AND ended_at IS NULL;
-`
-	var duration interface{}
-	if durationSec != nil {
-		duration = *durationSec
-	}
-	res, err := db.ExecContext(ctx, query, id, ended, recordingKey, duration)
-	if err != nil {
-		return err
-	}
-	n, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if n == 0 {
-		return sql.ErrNoRows
-	}
-	return nil
-}
-
-func generateLiveToken(cfg app.Config, roomName, userID, role string, expiry time.Time) (string, error) {
+// generateLiveToken mints a room token carrying the participant's roster
+// permissions. Only the host gets room admin rights in the client.
+func generateLiveToken(cfg app.Config, roomName, userID string, participant live.Participant, expiry time.Time) (string, error) {
 	if cfg.LiveKitAPIKey == "" || cfg.LiveKitAPISecret == "" {
-		payload := fmt.Sprintf("%s|%s|%s|%d", roomName, userID, role, expiry.Unix())
+		payload := fmt.Sprintf("%s|%s|%s|%d", roomName, userID, participant.Role, expiry.Unix())
 		mac := hmac.New(sha256.New, []byte("dev-secret"))
 		mac.Write([]byte(payload))
 		sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
@@ -359,71 +493,24 @@ func generateLiveToken(cfg app.Config, roomName, userID, role string, expiry tim
 		RoomJoin: true,
 		Room:     roomName,
 	}
-	videoGrant.SetCanSubscribe(true)
-	if role == "host" {
-		videoGrant.RoomAdmin = true
-		videoGrant.SetCanPublish(true)
-		videoGrant.SetCanPublishData(true)
-	} else {
-		videoGrant.SetCanPublishData(true)
+	perm := participant.Permission()
+	videoGrant.SetCanSubscribe(perm.CanSubscribe)
+	videoGrant.SetCanPublishData(perm.CanPublishData)
+	videoGrant.SetCanPublish(perm.CanPublish)
+	if perm.CanPublish {
+		videoGrant.SetCanPublishSources(perm.CanPublishSources)
 	}
+	videoGrant.RoomAdmin = participant.Role == live.RoleHost
 
 	token := livekitauth.NewAccessToken(cfg.LiveKitAPIKey, cfg.LiveKitAPISecret).
 		SetIdentity(userID).
 		SetValidFor(duration).
+		SetAttributes(participant.Attributes()).
 		AddGrant(videoGrant)
 
 	return token.ToJWT()
 }
 
-// Translation handlers
-func handleEnableTranslation(deps *app.App) http.HandlerFunc {
-	return func(w http.ResponseWriter, req *http.Request) {
-		_, ok := httpctx.UserFromContext(req.Context())
-		if !ok {
-			WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
-			return
-		}
-
-		// TODO: Check if user has Pro plan
-		// For now, allow all authenticated users
-		var payload struct {
-			SessionID   string   `json:"session_id"`
-			TargetLangs []string `json:"target_langs"`
-			SourceLang  string   `json:"source_lang"`
-		}
-		if err := decodeJSON(req, &payload); err != nil {
-			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
-			return
-		}
-
-		if len(payload.TargetLangs) > 2 {
-			WriteError(w, http.StatusBadRequest, "too_many_languages", "maximum 2 target languages allowed")
-			return
-		}
-
-		// Store in Redis (24h TTL)
-		key := fmt.Sprintf("live:translate:%s", payload.SessionID)
-		config := map[string]interface{}{
-			"source_lang":  payload.SourceLang,
-			"target_langs": payload.TargetLangs,
-			"enabled":      true,
-			"session_id":   payload.SessionID,
-		}
-
-		data, _ := json.Marshal(config)
-		if err := deps.Redis.Set(req.Context(), key, data, 24*time.Hour).Err(); err != nil {
-			WriteError(w, http.StatusInternalServerError, "redis_error", err.Error())
-			return
-		}
-
-		WriteJSON(w, http.StatusOK, map[string]interface{}{
-			"status": "enabled",
-			"config": config,
-		})
-	}
-}
-
 func handleListLiveSessions(deps *app.App) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		limit := getIntQueryParam(req, "limit", 20)
@@ -449,52 +536,6 @@ func handleListLiveSessions(deps *app.App) http.HandlerFunc {
 	}
 }
 
-func handleDisableTranslation(deps *app.App) http.HandlerFunc {
-	return func(w http.ResponseWriter, req *http.Request) {
-		sessionID := chi.URLParam(req, "sessionID")
-		if sessionID == "" {
-			WriteError(w, http.StatusBadRequest, "missing_session_id", "session_id required")
-			return
-		}
-
-		key := fmt.Sprintf("live:translate:%s", sessionID)
-		if err := deps.Redis.Del(req.Context(), key).Err(); err != nil {
-			WriteError(w, http.StatusInternalServerError, "redis_error", err.Error())
-			return
-		}
-
-		WriteJSON(w, http.StatusOK, map[string]interface{}{
-			"status": "disabled",
-		})
-	}
-}
-
-func handleGetTranslationStatus(deps *app.App) http.HandlerFunc {
-	return func(w http.ResponseWriter, req *http.Request) {
-		sessionID := chi.URLParam(req, "sessionID")
-		if sessionID == "" {
-			WriteError(w, http.StatusBadRequest, "missing_session_id", "session_id required")
-			return
-		}
-
-		key := fmt.Sprintf("live:translate:%s", sessionID)
-		val, err := deps.Redis.Get(req.Context(), key).Result()
-		if err != nil {
-			// Not enabled
-			WriteJSON(w, http.StatusOK, map[string]interface{}{
-				"enabled": false,
-			})
-			return
-		}
-
-		var config map[string]interface{}
-		json.Unmarshal([]byte(val), &config)
-		config["enabled"] = true
-
-		WriteJSON(w, http.StatusOK, config)
-	}
-}
-
 func dispatchLiveStartPush(ctx context.Context, deps *app.App, host httpctx.User, sessionID uuid.UUID, title string) {
 	if deps.Push == nil {
 		return
@@ -533,6 +574,38 @@ func dispatchLiveStartPush(ctx context.Context, deps *app.App, host httpctx.User
 	}
 }
 
+// handleLiveSessionStats reports listener analytics of a session to its
+// host, while it is live and after it ended.
+func handleLiveSessionStats(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, ok := httpctx.UserFromContext(req.Context())
+		if !ok {
+			WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+			return
+		}
+		sessionID, err := uuidFromParam(chi.URLParam(req, "id"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+			return
+		}
+		stats, err := newLiveTracker(deps).Stats(req.Context(), sessionID, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, live.ErrSessionNotFound):
+				WriteError(w, http.StatusNotFound, "session_not_found", err.Error())
+			case errors.Is(err, live.ErrForbidden):
+				WriteError(w, http.StatusForbidden, "not_host", "only the host can view session stats")
+			default:
+				WriteError(w, http.StatusInternalServerError, "live_stats_failed", err.Error())
+			}
+			return
+		}
+		WriteJSON(w, http.StatusOK, stats)
+	}
+}
+
+// listActiveLiveSessions returns live sessions, most listened to first,
+// tagged with their host's city, or "Online" for hosts without one.
 func listActiveLiveSessions(ctx context.Context, db *sql.DB, userID *uuid.UUID, limit int) ([]liveSessionListItem, error) {
 	const query = `
 SELECT ls.id,
@@ -543,11 +616,20 @@ SELECT ls.id,
        COALESCE(ls.title, '') AS title,
        COALESCE(ls.mask, 'none') AS mask,
        ls.started_at,
-       ls.topic_id
+       ls.topic_id,
+       COALESCE(NULLIF(p.location_city, ''), 'Online') AS city,
+       lst.listeners
   FROM live_sessions ls
   JOIN users u ON u.id = ls.host_id
+  LEFT JOIN profiles p ON p.user_id = ls.host_id
+  LEFT JOIN LATERAL (
+    SELECT COUNT(DISTINCT identity) FILTER (WHERE left_at IS NULL) AS listeners,
+           COUNT(DISTINCT identity) AS reach
+      FROM live_listens
+     WHERE session_id = ls.id
+  ) lst ON TRUE
  WHERE ls.ended_at IS NULL
- ORDER BY ls.started_at DESC
+ ORDER BY lst.listeners DESC, lst.reach DESC, ls.started_at DESC
  LIMIT $1;
 `
 	rows, err := db.QueryContext(ctx, query, limit)
@@ -569,8 +651,10 @@ SELECT ls.id,
 			mask       string
 			startedAt  time.Time
 			topicID    sql.NullString
+			city       string
+			listeners  int
 		)
-		if err := rows.Scan(&id, &hostID, &hostName, &hostHandle, &hostAvatar, &title, &mask, &startedAt, &topicID); err != nil {
+		if err := rows.Scan(&id, &hostID, &hostName, &hostHandle, &hostAvatar, &title, &mask, &startedAt, &topicID, &city, &listeners); err != nil {
 			return nil, err
 		}
 		var topicPtr *string
@@ -588,9 +672,9 @@ SELECT ls.id,
 			Mask:           mask,
 			StartedAt:      startedAt.Format(time.RFC3339),
 			TopicID:        topicPtr,
-			City:           "Online",
+			City:           city,
 			IsFollowedHost: false,
-			Listeners:      0,
+			Listeners:      listeners,
 			Tags:           []string{},
 		})
 		hostIDs = append(hostIDs, hostID)
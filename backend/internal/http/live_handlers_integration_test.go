@@ -11,6 +11,7 @@ import (
 	livekitauth "github.com/livekit/protocol/auth"
 
 	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/live"
 )
 
 func TestGenerateLiveTokenRoundTrip(t *testing.T) {
@@ -22,7 +23,7 @@ func TestGenerateLiveTokenRoundTrip(t *testing.T) {
 		t.Skip("LIVEKIT_API_KEY / LIVEKIT_API_SECRET not configured; skipping token verification")
 	}
 
-	token, err := generateLiveToken(cfg, "integration-room", "integration-listener", "listener", time.Now().Add(5*time.Minute))
+	token, err := generateLiveToken(cfg, "integration-room", "integration-listener", live.Participant{Role: live.RoleListener}, time.Now().Add(5*time.Minute))
 	if err != nil {
 		t.Fatalf("generateLiveToken: %v", err)
 	}
@@ -0,0 +1,270 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+
+	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/auth"
+	mw "github.com/amunx/backend/internal/http/middleware"
+	"github.com/amunx/backend/internal/httpctx"
+)
+
+var replayEpisodeColumns = []string{
+	"id", "owner_id", "title", "visibility", "kind", "duration_sec", "audio_url", "created_at",
+	"tldr", "keywords", "mood", "plan", "processing_error", "processing_error_detail", "is_live", "mask",
+}
+
+func getLiveReplay(t *testing.T, deps *app.App, sessionID uuid.UUID, user *httpctx.User) *httptest.ResponseRecorder {
+	t.Helper()
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", sessionID.String())
+	ctx := context.WithValue(context.Background(), chi.RouteCtxKey, rctx)
+	if user != nil {
+		ctx = httpctx.WithUser(ctx, *user)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/live/sessions/"+sessionID.String()+"/replay", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	handleGetLiveReplay(deps)(rec, req)
+	return rec
+}
+
+func TestGetLiveReplay(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	deps := &app.App{DB: db}
+
+	sessionID, replayID, hostID := uuid.New(), uuid.New(), uuid.New()
+	lookup := func(ended bool, replay any) {
+		mock.ExpectQuery(`SELECT ls.ended_at IS NOT NULL, ai.id`).WithArgs(sessionID).
+			WillReturnRows(sqlmock.NewRows([]string{"ended", "id"}).AddRow(ended, replay))
+	}
+	episode := func(audioURL any) {
+		mock.ExpectQuery(`FROM audio_items e`).WithArgs(replayID).
+			WillReturnRows(sqlmock.NewRows(replayEpisodeColumns).AddRow(
+				replayID, hostID, "Afterparty", "public", "podcast_episode", 240, audioURL, time.Now(),
+				nil, nil, nil, "free", nil, nil, true, "basic"))
+	}
+
+	lookup(false, nil)
+	if rec := getLiveReplay(t, deps, sessionID, nil); rec.Code != http.StatusConflict {
+		t.Fatalf("expected live session to conflict, got %d", rec.Code)
+	}
+
+	lookup(true, replayID)
+	episode(nil)
+	if rec := getLiveReplay(t, deps, sessionID, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected processing replay to be hidden, got %d", rec.Code)
+	}
+
+	lookup(true, replayID)
+	episode(nil)
+	mock.ExpectQuery(`SELECT chapters FROM summaries`).WithArgs(replayID).
+		WillReturnRows(sqlmock.NewRows([]string{"chapters"}))
+	if rec := getLiveReplay(t, deps, sessionID, &httpctx.User{ID: hostID}); rec.Code != http.StatusOK {
+		t.Fatalf("expected host to see the processing replay, got %d", rec.Code)
+	}
+
+	lookup(true, replayID)
+	episode("https://cdn.example/episodes/processed.opus")
+	mock.ExpectQuery(`SELECT chapters FROM summaries`).WithArgs(replayID).
+		WillReturnRows(sqlmock.NewRows([]string{"chapters"}).AddRow([]byte(`[{"start":0,"end":240,"title":"Afterparty"}]`)))
+	rec := getLiveReplay(t, deps, sessionID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected ready replay, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Status   string              `json:"status"`
+		Replay   episodeSummary      `json:"replay"`
+		Chapters []liveReplayChapter `json:"chapters"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Status != "ready" || !body.Replay.IsLive || body.Replay.Mask != "basic" || len(body.Chapters) != 1 {
+		t.Fatalf("unexpected replay %+v", body)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+// expectLiveJoin expects GET /live/sessions/{id} to load the session and add
+// the user to its roster with role.
+func expectLiveJoin(mock sqlmock.Sqlmock, sessionID, hostID, userID uuid.UUID, role string) {
+	mock.ExpectQuery(`FROM live_sessions`).WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "host_id", "topic_id", "sfu_room", "title", "mask", "started_at", "ended_at"}).
+			AddRow(sessionID, hostID, nil, "live_"+sessionID.String(), "Afterparty", "none", time.Now(), nil))
+	mock.ExpectQuery(`SELECT host_id, ended_at IS NOT NULL FROM live_sessions`).WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"host_id", "ended"}).AddRow(hostID, false))
+	mock.ExpectQuery(`INSERT INTO live_participants`).WithArgs(sessionID, userID, role, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"role", "hand_raised_at", "muted", "removed"}).AddRow(role, nil, false, false))
+}
+
+func TestJoinLiveSessionThroughRouterUsesSignedInUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	deps := &app.App{DB: db, JWT: auth.NewJWTManager("access", "refresh", time.Minute, time.Hour)}
+	r := chi.NewRouter()
+	registerPublicLiveRoutes(r, deps, zerolog.Nop())
+
+	sessionID := uuid.New()
+	host := httpctx.User{ID: uuid.New(), Email: "host@example.com", Plan: "free"}
+	listener := httpctx.User{ID: uuid.New(), Email: "listener@example.com", Plan: "free"}
+
+	join := func(user httpctx.User, role string) map[string]any {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/live/sessions/"+sessionID.String()+"?role="+role, nil)
+		signIn(t, deps, mock, req, user)
+		expectLiveJoin(mock, sessionID, host.ID, user.ID, role)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected %s to join, got %d: %s", role, rec.Code, rec.Body.String())
+		}
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return body
+	}
+
+	if body := join(host, "host"); body["role"] != "host" {
+		t.Fatalf("expected the host to rejoin as host, got %v", body["role"])
+	}
+	body := join(listener, "listener")
+	if body["role"] != "listener" {
+		t.Fatalf("expected a listener role, got %v", body["role"])
+	}
+	payload, _, _ := strings.Cut(body["token"].(string), ".")
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || !strings.Contains(string(decoded), "|"+listener.ID.String()+"|") {
+		t.Fatalf("expected the token identity to be the user ID, got %q", decoded)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestListLiveParticipantsRequiresParticipant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	deps := &app.App{DB: db}
+
+	sessionID, outsider := uuid.New(), uuid.New()
+	mock.ExpectQuery(`SELECT EXISTS`).WithArgs(sessionID, outsider).
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(false))
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", sessionID.String())
+	ctx := context.WithValue(context.Background(), chi.RouteCtxKey, rctx)
+	ctx = httpctx.WithUser(ctx, httpctx.User{ID: outsider})
+	req := httptest.NewRequest(http.MethodGet, "/live/sessions/"+sessionID.String()+"/participants", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	handleListLiveParticipants(deps)(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected outsiders to be refused, got %d", rec.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestListUpcomingLiveSessionsMarksViewerRSVPs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	deps := &app.App{DB: db, JWT: auth.NewJWTManager("access", "refresh", time.Minute, time.Hour)}
+	r := chi.NewRouter()
+	registerPublicLiveRoutes(r, deps, zerolog.Nop())
+
+	viewer := httpctx.User{ID: uuid.New(), Email: "fan@example.com", Plan: "free"}
+	req := httptest.NewRequest(http.MethodGet, "/live/sessions/upcoming", nil)
+	signIn(t, deps, mock, req, viewer)
+	mock.ExpectQuery(`FROM live_schedules`).
+		WithArgs(sqlmock.AnyArg(), uuid.NullUUID{UUID: viewer.ID, Valid: true}, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "host_id", "host_name", "topic_id", "title", "description", "scheduled_at", "rsvps", "rsvped"}).
+			AddRow(uuid.New(), uuid.New(), "host", nil, "Launch", "", time.Now().Add(time.Hour), 3, true))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"rsvped":true`) {
+		t.Fatalf("expected the viewer's RSVP to be reported, got %s", rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestListenerCanChatAfterJoiningThroughRouter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	// Chat fan-out is best effort, so an unreachable Redis only drops the
+	// publish.
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 50 * time.Millisecond})
+	defer rdb.Close()
+	deps := &app.App{DB: db, Redis: rdb, JWT: auth.NewJWTManager("access", "refresh", time.Minute, time.Hour)}
+	r := chi.NewRouter()
+	registerPublicLiveRoutes(r, deps, zerolog.Nop())
+	r.Group(func(protected chi.Router) {
+		protected.Use(mw.Auth(deps, zerolog.Nop()))
+		registerLiveChatRoutes(protected, deps)
+	})
+
+	sessionID, hostID := uuid.New(), uuid.New()
+	listener := httpctx.User{ID: uuid.New(), Email: "listener@example.com", Plan: "free"}
+
+	req := httptest.NewRequest(http.MethodGet, "/live/sessions/"+sessionID.String(), nil)
+	signIn(t, deps, mock, req, listener)
+	expectLiveJoin(mock, sessionID, hostID, listener.ID, "listener")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the listener to join, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/live/sessions/"+sessionID.String()+"/chat", strings.NewReader(`{"body":"hello room"}`))
+	signIn(t, deps, mock, req, listener)
+	mock.ExpectQuery(`LEFT JOIN live_participants`).WithArgs(sessionID, listener.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"joined", "removed", "ended", "started_at"}).AddRow(true, false, false, time.Now().Add(-time.Minute)))
+	mock.ExpectQuery(`INSERT INTO live_chat_messages`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "author"}).AddRow(uuid.New(), "listener"))
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected the listener's message to be posted, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
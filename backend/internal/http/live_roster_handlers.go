@@ -0,0 +1,165 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/httpctx"
+	"github.com/amunx/backend/internal/live"
+)
+
+// registerLiveRosterRoutes exposes stage requests and participant
+// management. The acting user's roster role decides what they may do.
+func registerLiveRosterRoutes(r chi.Router, deps *app.App) {
+	r.Get("/live/sessions/{id}/participants", handleListLiveParticipants(deps))
+	r.Post("/live/sessions/{id}/hand", handleLiveHand(deps, true))
+	r.Delete("/live/sessions/{id}/hand", handleLiveHand(deps, false))
+	r.Post("/live/sessions/{id}/participants/{userID}/role", handleSetLiveRole(deps))
+	r.Post("/live/sessions/{id}/participants/{userID}/mute", handleMuteLiveParticipant(deps))
+	r.Delete("/live/sessions/{id}/participants/{userID}", handleRemoveLiveParticipant(deps))
+}
+
+func newLiveRoster(deps *app.App) *live.Roster {
+	return live.NewRoster(deps.DB, deps.LiveRooms)
+}
+
+func handleListLiveParticipants(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, ok := httpctx.UserFromContext(req.Context())
+		if !ok {
+			WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+			return
+		}
+		sessionID, err := uuidFromParam(chi.URLParam(req, "id"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+			return
+		}
+		participants, err := newLiveRoster(deps).List(req.Context(), sessionID, user.ID)
+		if err != nil {
+			writeRosterError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{"participants": participants})
+	}
+}
+
+// handleLiveHand raises or lowers the caller's hand.
+func handleLiveHand(deps *app.App, raised bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, ok := httpctx.UserFromContext(req.Context())
+		if !ok {
+			WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+			return
+		}
+		sessionID, err := uuidFromParam(chi.URLParam(req, "id"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+			return
+		}
+		participant, err := newLiveRoster(deps).SetHand(req.Context(), sessionID, user.ID, raised)
+		if err != nil {
+			writeRosterError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{"participant": participant})
+	}
+}
+
+func handleSetLiveRole(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, sessionID, targetID, ok := liveRosterTarget(w, req)
+		if !ok {
+			return
+		}
+		var payload struct {
+			Role string `json:"role"`
+		}
+		if err := decodeJSON(req, &payload); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		role, valid := live.ParseRole(payload.Role)
+		if !valid || role == live.RoleHost {
+			WriteError(w, http.StatusBadRequest, "invalid_role", "role must be cohost, moderator, speaker or listener")
+			return
+		}
+		participant, err := newLiveRoster(deps).SetRole(req.Context(), sessionID, user.ID, targetID, role)
+		if err != nil {
+			writeRosterError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{"participant": participant})
+	}
+}
+
+func handleMuteLiveParticipant(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, sessionID, targetID, ok := liveRosterTarget(w, req)
+		if !ok {
+			return
+		}
+		participant, err := newLiveRoster(deps).Mute(req.Context(), sessionID, user.ID, targetID)
+		if err != nil {
+			writeRosterError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{"participant": participant})
+	}
+}
+
+func handleRemoveLiveParticipant(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, sessionID, targetID, ok := liveRosterTarget(w, req)
+		if !ok {
+			return
+		}
+		if err := newLiveRoster(deps).Remove(req.Context(), sessionID, user.ID, targetID); err != nil {
+			writeRosterError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// liveRosterTarget resolves the acting user and the session and participant
+// addressed by the route, writing an error response when one is missing.
+func liveRosterTarget(w http.ResponseWriter, req *http.Request) (httpctx.User, uuid.UUID, uuid.UUID, bool) {
+	user, ok := httpctx.UserFromContext(req.Context())
+	if !ok {
+		WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+		return httpctx.User{}, uuid.Nil, uuid.Nil, false
+	}
+	sessionID, err := uuidFromParam(chi.URLParam(req, "id"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+		return httpctx.User{}, uuid.Nil, uuid.Nil, false
+	}
+	targetID, err := uuidFromParam(chi.URLParam(req, "userID"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid_user_id", err.Error())
+		return httpctx.User{}, uuid.Nil, uuid.Nil, false
+	}
+	return user, sessionID, targetID, true
+}
+
+func writeRosterError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, live.ErrSessionEnded):
+		WriteError(w, http.StatusNotFound, "session_not_found", "live session not found or ended")
+	case errors.Is(err, live.ErrNotParticipant):
+		WriteError(w, http.StatusNotFound, "participant_not_found", err.Error())
+	case errors.Is(err, live.ErrRemoved):
+		WriteError(w, http.StatusForbidden, "participant_removed", err.Error())
+	case errors.Is(err, live.ErrForbidden):
+		WriteError(w, http.StatusForbidden, "forbidden", err.Error())
+	case errors.Is(err, live.ErrInvalidRole):
+		WriteError(w, http.StatusConflict, "invalid_role", err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, "live_roster_failed", err.Error())
+	}
+}
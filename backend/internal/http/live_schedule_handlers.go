@@ -0,0 +1,169 @@
+package http
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/httpctx"
+	"github.com/amunx/backend/internal/live"
+)
+
+// registerLiveScheduleRoutes lets hosts announce sessions ahead of time and
+// listeners RSVP to them. Hosts start a scheduled session through
+// POST /live/sessions with its schedule_id.
+func registerLiveScheduleRoutes(r chi.Router, deps *app.App) {
+	r.Post("/live/sessions/scheduled", handleCreateLiveSchedule(deps))
+	r.Delete("/live/sessions/scheduled/{id}", handleCancelLiveSchedule(deps))
+	r.Post("/live/sessions/scheduled/{id}/rsvp", handleLiveRSVP(deps, true))
+	r.Delete("/live/sessions/scheduled/{id}/rsvp", handleLiveRSVP(deps, false))
+}
+
+func newLiveSchedules(deps *app.App) *live.Schedules {
+	return live.NewSchedules(deps.DB, deps.Push, deps.Config.LiveReminderLead)
+}
+
+func handleCreateLiveSchedule(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, ok := httpctx.UserFromContext(req.Context())
+		if !ok {
+			WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+			return
+		}
+		if user.Shadowbanned {
+			WriteError(w, http.StatusForbidden, "account_restricted", "live streaming is disabled for this account")
+			return
+		}
+		if !deps.Config.FeatureLiveRecording {
+			WriteError(w, http.StatusForbidden, "feature_disabled", "live sessions are temporarily disabled")
+			return
+		}
+
+		var payload struct {
+			TopicID     *string   `json:"topic_id"`
+			Title       string    `json:"title"`
+			Description string    `json:"description"`
+			ScheduledAt time.Time `json:"scheduled_at"`
+		}
+		if err := decodeJSON(req, &payload); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+
+		var topicID *uuid.UUID
+		if payload.TopicID != nil && *payload.TopicID != "" {
+			tID, err := uuid.Parse(*payload.TopicID)
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, "invalid_topic_id", "topic_id must be a valid UUID")
+				return
+			}
+			if err := ensureTopicAccessible(req.Context(), deps.DB, tID, user.ID); err != nil {
+				switch {
+				case errors.Is(err, sql.ErrNoRows):
+					WriteError(w, http.StatusNotFound, "topic_not_found", "topic does not exist")
+				case errors.Is(err, errTopicNotAccessible):
+					WriteError(w, http.StatusForbidden, "topic_not_accessible", err.Error())
+				default:
+					WriteError(w, http.StatusInternalServerError, "topic_validation_failed", err.Error())
+				}
+				return
+			}
+			topicID = &tID
+		}
+
+		sched, err := newLiveSchedules(deps).Create(req.Context(), live.NewSchedule{
+			HostID:      user.ID,
+			TopicID:     topicID,
+			Title:       payload.Title,
+			Description: payload.Description,
+			ScheduledAt: payload.ScheduledAt,
+		})
+		if err != nil {
+			if errors.Is(err, live.ErrInvalidSchedule) {
+				WriteError(w, http.StatusBadRequest, "invalid_schedule", "title is required and scheduled_at must be in the future, at most 90 days ahead")
+				return
+			}
+			WriteError(w, http.StatusInternalServerError, "live_schedule_failed", err.Error())
+			return
+		}
+		WriteJSON(w, http.StatusCreated, map[string]any{"schedule": sched})
+	}
+}
+
+func handleCancelLiveSchedule(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, ok := httpctx.UserFromContext(req.Context())
+		if !ok {
+			WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+			return
+		}
+		scheduleID, err := uuidFromParam(chi.URLParam(req, "id"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_schedule_id", err.Error())
+			return
+		}
+		if err := newLiveSchedules(deps).Cancel(req.Context(), scheduleID, user.ID); err != nil {
+			writeScheduleError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func handleLiveRSVP(deps *app.App, going bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, ok := httpctx.UserFromContext(req.Context())
+		if !ok {
+			WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+			return
+		}
+		scheduleID, err := uuidFromParam(chi.URLParam(req, "id"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_schedule_id", err.Error())
+			return
+		}
+		if err := newLiveSchedules(deps).SetRSVP(req.Context(), scheduleID, user.ID, going); err != nil {
+			writeScheduleError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{"schedule_id": scheduleID.String(), "rsvped": going})
+	}
+}
+
+func handleListUpcomingLiveSessions(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		limit := getIntQueryParam(req, "limit", 20)
+		if limit <= 0 {
+			limit = 20
+		}
+		if limit > 100 {
+			limit = 100
+		}
+		var userID *uuid.UUID
+		if user, ok := httpctx.UserFromContext(req.Context()); ok {
+			userID = &user.ID
+		}
+		schedules, err := newLiveSchedules(deps).Upcoming(req.Context(), userID, limit)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "live_schedules_failed", err.Error())
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"sessions": schedules,
+			"count":    len(schedules),
+		})
+	}
+}
+
+func writeScheduleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, live.ErrScheduleNotFound) {
+		WriteError(w, http.StatusNotFound, "schedule_not_found", "scheduled session not found, canceled or already started")
+		return
+	}
+	WriteError(w, http.StatusInternalServerError, "live_schedule_failed", err.Error())
+}
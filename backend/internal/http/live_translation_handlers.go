@@ -0,0 +1,177 @@
+package http
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/billing"
+	"github.com/amunx/backend/internal/httpctx"
+	"github.com/amunx/backend/internal/live"
+)
+
+// registerLiveTranslationRoutes lets hosts with the translation entitlement
+// turn live dubbing on and off.
+func registerLiveTranslationRoutes(r chi.Router, deps *app.App) {
+	r.Post("/live/translate/enable", handleEnableTranslation(deps))
+	r.Post("/live/translate/disable/{sessionID}", handleDisableTranslation(deps))
+}
+
+// registerPublicLiveTranslationRoutes serves translation status to listeners
+// and the translator agent, and takes the agent's usage reports. The agent
+// authenticates with LIVE_AGENT_TOKEN rather than a user session.
+func registerPublicLiveTranslationRoutes(r chi.Router, deps *app.App) {
+	r.Get("/live/sessions/{sessionID}/translate/status", handleGetTranslationStatus(deps))
+	r.Post("/live/agent/sessions/{sessionID}/translate/usage", handleReportTranslationUsage(deps))
+}
+
+func newLiveTranslations(deps *app.App) *live.Translations {
+	return live.NewTranslations(deps.DB, deps.Config.LiveTranslationMaxCostPerHour)
+}
+
+func handleEnableTranslation(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, ok := httpctx.UserFromContext(req.Context())
+		if !ok {
+			WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+			return
+		}
+
+		var payload struct {
+			SessionID   string   `json:"session_id"`
+			TargetLangs []string `json:"target_langs"`
+			SourceLang  string   `json:"source_lang"`
+		}
+		if err := decodeJSON(req, &payload); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		sessionID, err := uuid.Parse(payload.SessionID)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", "session_id must be a valid UUID")
+			return
+		}
+
+		entitled, err := billing.NewService(deps.DB).HasEntitlement(req.Context(), user.ID, deps.Config.LiveTranslationEntitlement)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "entitlement_check_failed", err.Error())
+			return
+		}
+		if !entitled {
+			WriteError(w, http.StatusForbidden, "entitlement_required", "live translation requires an active "+deps.Config.LiveTranslationEntitlement+" subscription")
+			return
+		}
+
+		cfg, err := newLiveTranslations(deps).Enable(req.Context(), sessionID, user.ID, payload.SourceLang, payload.TargetLangs)
+		if err != nil {
+			writeTranslationError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"status": "enabled",
+			"config": cfg,
+		})
+	}
+}
+
+func handleDisableTranslation(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, ok := httpctx.UserFromContext(req.Context())
+		if !ok {
+			WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+			return
+		}
+		sessionID, err := uuidFromParam(chi.URLParam(req, "sessionID"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+			return
+		}
+		if err := newLiveTranslations(deps).Disable(req.Context(), sessionID, user.ID); err != nil {
+			writeTranslationError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"status": "disabled",
+		})
+	}
+}
+
+func handleGetTranslationStatus(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		sessionID, err := uuidFromParam(chi.URLParam(req, "sessionID"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+			return
+		}
+		cfg, err := newLiveTranslations(deps).Status(req.Context(), sessionID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "live_translation_failed", err.Error())
+			return
+		}
+		WriteJSON(w, http.StatusOK, cfg)
+	}
+}
+
+// handleReportTranslationUsage records the translator agent's spend. The
+// response tells the agent whether to keep translating; once the hourly
+// budget is spent it reports enabled=false with disabled_reason=budget.
+func handleReportTranslationUsage(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !authorizeLiveAgent(w, req, deps) {
+			return
+		}
+		sessionID, err := uuidFromParam(chi.URLParam(req, "sessionID"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_session_id", err.Error())
+			return
+		}
+		var report live.UsageReport
+		if err := decodeJSON(req, &report); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		cfg, err := newLiveTranslations(deps).ReportUsage(req.Context(), sessionID, report)
+		if err != nil {
+			writeTranslationError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, cfg)
+	}
+}
+
+// authorizeLiveAgent checks the bearer token of a live agent request,
+// writing an error response when it does not match LIVE_AGENT_TOKEN.
+func authorizeLiveAgent(w http.ResponseWriter, req *http.Request, deps *app.App) bool {
+	if deps.Config.LiveAgentToken == "" {
+		WriteError(w, http.StatusServiceUnavailable, "agent_disabled", "live agent token is not configured")
+		return false
+	}
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(deps.Config.LiveAgentToken)) != 1 {
+		WriteError(w, http.StatusUnauthorized, "invalid_agent_token", "invalid agent token")
+		return false
+	}
+	return true
+}
+
+func writeTranslationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, live.ErrInvalidLanguages):
+		WriteError(w, http.StatusBadRequest, "invalid_languages", "source_lang and 1-2 distinct target_langs must be language codes")
+	case errors.Is(err, live.ErrInvalidUsage):
+		WriteError(w, http.StatusBadRequest, "invalid_usage", "report_id is required and amounts must not be negative")
+	case errors.Is(err, live.ErrBudgetExceeded):
+		WriteError(w, http.StatusTooManyRequests, "translation_budget_exceeded", err.Error())
+	case errors.Is(err, live.ErrForbidden):
+		WriteError(w, http.StatusForbidden, "not_host", "only the host can control translation")
+	case errors.Is(err, live.ErrSessionEnded):
+		WriteError(w, http.StatusNotFound, "session_not_found", "live session not found or ended")
+	default:
+		WriteError(w, http.StatusInternalServerError, "live_translation_failed", err.Error())
+	}
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	livekitauth "github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/webhook"
+
+	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/live"
+)
+
+func registerLiveKitWebhookRoutes(r chi.Router, deps *app.App) {
+	r.Post("/live/webhooks/livekit", handleLiveKitWebhook(deps))
+}
+
+// handleLiveKitWebhook receives room, participant and egress events signed
+// with the LiveKit API secret.
+func handleLiveKitWebhook(deps *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.Config.LiveKitAPIKey == "" || deps.Config.LiveKitAPISecret == "" {
+			WriteError(w, http.StatusServiceUnavailable, "livekit_disabled", "livekit is not configured")
+			return
+		}
+		keys := livekitauth.NewSimpleKeyProvider(deps.Config.LiveKitAPIKey, deps.Config.LiveKitAPISecret)
+		event, err := webhook.ReceiveWebhookEvent(r, keys)
+		if err != nil {
+			WriteError(w, http.StatusUnauthorized, "invalid_signature", err.Error())
+			return
+		}
+		if err := newLiveTracker(deps).HandleEvent(r.Context(), event); err != nil {
+			WriteError(w, http.StatusInternalServerError, "livekit_webhook_failed", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newLiveTracker(deps *app.App) *live.Tracker {
+	return live.NewTracker(deps.DB, deps.Redis, deps.Queue, live.Options{
+		HostGrace:   deps.Config.LiveHostGrace,
+		MaxDuration: deps.Config.LiveMaxDuration,
+		Recorder:    deps.LiveRecorder,
+	})
+}
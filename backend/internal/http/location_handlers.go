@@ -0,0 +1,207 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/geo"
+	"github.com/amunx/backend/internal/httpctx"
+)
+
+const (
+	locationSourceIP     = "ip"
+	locationSourceManual = "manual"
+)
+
+// registerLocationRoutes lets users opt in to sharing the city they are in,
+// either resolved from their IP or picked by hand, and opt out again.
+func registerLocationRoutes(r chi.Router, deps *app.App) {
+	r.Get("/me/location", func(w http.ResponseWriter, req *http.Request) {
+		user, ok := httpctx.UserFromContext(req.Context())
+		if !ok {
+			WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+			return
+		}
+		loc, source, err := loadProfileLocation(req.Context(), deps.DB, user.ID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "location_lookup_failed", err.Error())
+			return
+		}
+		if source == "" {
+			WriteJSON(w, http.StatusOK, map[string]any{"location": nil})
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"location": loc,
+			"source":   source,
+		})
+	})
+
+	r.Put("/me/location", func(w http.ResponseWriter, req *http.Request) {
+		user, ok := httpctx.UserFromContext(req.Context())
+		if !ok {
+			WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+			return
+		}
+		var payload struct {
+			Source  string `json:"source"`
+			City    string `json:"city"`
+			Country string `json:"country"`
+		}
+		if err := decodeJSON(req, &payload); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+
+		var loc geo.Location
+		source := strings.ToLower(strings.TrimSpace(payload.Source))
+		switch source {
+		case locationSourceIP:
+			found, ok := deps.Geo.LookupIP(clientIP(req))
+			if !ok {
+				WriteError(w, http.StatusUnprocessableEntity, "location_unavailable", "could not determine a city from your connection; pick one instead")
+				return
+			}
+			loc = found
+		case locationSourceManual, "":
+			source = locationSourceManual
+			city := strings.TrimSpace(payload.City)
+			country := geo.NormalizeCountry(payload.Country)
+			if city == "" || len(city) > 100 || country == "" {
+				WriteError(w, http.StatusBadRequest, "invalid_location", "city and a two-letter country code are required")
+				return
+			}
+			loc = geo.Location{City: city, Country: country}
+			// Without a city database the name is kept as given and the
+			// location only matches by city and country.
+			if deps.Geo != nil {
+				found, ok := deps.Geo.City(city, country)
+				if !ok {
+					WriteError(w, http.StatusUnprocessableEntity, "unknown_city", "city not found in that country")
+					return
+				}
+				loc = found
+			}
+		default:
+			WriteError(w, http.StatusBadRequest, "invalid_source", "source must be ip or manual")
+			return
+		}
+
+		loc = loc.Coarse()
+		if err := saveProfileLocation(req.Context(), deps.DB, user.ID, loc, source); err != nil {
+			WriteError(w, http.StatusInternalServerError, "location_update_failed", err.Error())
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"location": loc,
+			"source":   source,
+		})
+	})
+
+	r.Delete("/me/location", func(w http.ResponseWriter, req *http.Request) {
+		user, ok := httpctx.UserFromContext(req.Context())
+		if !ok {
+			WriteError(w, http.StatusInternalServerError, "user_context_missing", "failed to resolve user")
+			return
+		}
+		if _, err := deps.DB.ExecContext(req.Context(), `
+UPDATE profiles
+   SET location_city = NULL, location_region = NULL, location_country = NULL,
+       location_continent = NULL, location_lat = NULL, location_lon = NULL,
+       location_source = NULL, location_updated_at = NULL, updated_at = now()
+ WHERE user_id = $1`, user.ID); err != nil {
+			WriteError(w, http.StatusInternalServerError, "location_update_failed", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// loadProfileLocation returns the location a user shared and how it was
+// set, or an empty source when they have not opted in.
+func loadProfileLocation(ctx context.Context, db *sql.DB, userID uuid.UUID) (geo.Location, string, error) {
+	var (
+		city, region, country sql.NullString
+		continent, source     sql.NullString
+		lat, lon              sql.NullFloat64
+	)
+	err := db.QueryRowContext(ctx, `
+SELECT location_city, location_region, location_country, location_continent,
+       location_lat, location_lon, location_source
+  FROM profiles
+ WHERE user_id = $1 AND location_source IS NOT NULL`, userID).
+		Scan(&city, &region, &country, &continent, &lat, &lon, &source)
+	if errors.Is(err, sql.ErrNoRows) {
+		return geo.Location{}, "", nil
+	}
+	if err != nil {
+		return geo.Location{}, "", err
+	}
+	loc := geo.Location{
+		City:      city.String,
+		Region:    region.String,
+		Country:   country.String,
+		Continent: continent.String,
+		Lat:       lat.Float64,
+		Lon:       lon.Float64,
+	}
+	return loc, source.String, nil
+}
+
+func saveProfileLocation(ctx context.Context, db *sql.DB, userID uuid.UUID, loc geo.Location, source string) error {
+	var lat, lon sql.NullFloat64
+	if loc.HasPoint() {
+		lat = sql.NullFloat64{Float64: loc.Lat, Valid: true}
+		lon = sql.NullFloat64{Float64: loc.Lon, Valid: true}
+	}
+	_, err := db.ExecContext(ctx, `
+INSERT INTO profiles (user_id, location_city, location_region, location_country, location_continent,
+                      location_lat, location_lon, location_source, location_updated_at)
+VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, NULLIF($5, ''), $6, $7, $8, now())
+ON CONFLICT (user_id) DO UPDATE
+   SET location_city = EXCLUDED.location_city,
+       location_region = EXCLUDED.location_region,
+       location_country = EXCLUDED.location_country,
+       location_continent = EXCLUDED.location_continent,
+       location_lat = EXCLUDED.location_lat,
+       location_lon = EXCLUDED.location_lon,
+       location_source = EXCLUDED.location_source,
+       location_updated_at = EXCLUDED.location_updated_at,
+       updated_at = now()
+`, userID, loc.City, loc.Region, loc.Country, loc.Continent, lat, lon, source)
+	return err
+}
+
+// viewerLocation locates whoever makes a request: by the location on their
+// profile when they are signed in and shared one with coordinates, else by
+// their IP.
+func viewerLocation(req *http.Request, deps *app.App) (geo.Location, bool) {
+	if user, ok := httpctx.UserFromContext(req.Context()); ok {
+		loc, source, err := loadProfileLocation(req.Context(), deps.DB, user.ID)
+		if err == nil && source != "" && loc.HasPoint() {
+			return loc, true
+		}
+	}
+	loc, ok := deps.Geo.LookupIP(clientIP(req))
+	if !ok || !loc.HasPoint() {
+		return geo.Location{}, false
+	}
+	return loc.Coarse(), true
+}
+
+// distanceKmSQL is geo.DistanceKm in SQL, from the latCol/lonCol point to
+// the one bound to $arg and $arg+1.
+func distanceKmSQL(latCol, lonCol string, arg int) string {
+	return fmt.Sprintf(`(2 * 6371 * asin(LEAST(1, sqrt(
+  power(sin(radians(%[1]s - $%[3]d) / 2), 2) +
+  cos(radians($%[3]d)) * cos(radians(%[1]s)) * power(sin(radians(%[2]s - $%[4]d) / 2), 2)))))`,
+		latCol, lonCol, arg, arg+1)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/rs/zerolog"
 
 	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/auth"
 	"github.com/amunx/backend/internal/httpctx"
 )
 
@@ -46,12 +47,24 @@ func Auth(deps *app.App, logger zerolog.Logger) func(http.Handler) http.Handler
 				return
 			}
 
+			if staleClaims(claims, user) {
+				respondError(w, http.StatusUnauthorized, "token_stale", "plan changed; refresh the access token")
+				return
+			}
+
 			ctx := httpctx.WithUser(r.Context(), user)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// staleClaims reports whether an access token was issued for a plan the
+// user no longer has, e.g. after an entitlement expired. Clients then
+// refresh to get a token with the current plan.
+func staleClaims(claims *auth.Claims, user httpctx.User) bool {
+	return claims.Plan != "" && claims.Plan != user.Plan
+}
+
 func extractBearer(header string) string {
 	if header == "" {
 		return ""
@@ -45,6 +45,11 @@ func TryAuth(deps *app.App, logger zerolog.Logger) func(http.Handler) http.Handl
 				return
 			}
 
+			if staleClaims(claims, user) {
+				respondError(w, http.StatusUnauthorized, "token_stale", "plan changed; refresh the access token")
+				return
+			}
+
 			ctx := httpctx.WithUser(r.Context(), user)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -13,6 +13,7 @@ import (
 
 	"github.com/amunx/backend/internal/app"
 	mw "github.com/amunx/backend/internal/http/middleware"
+	"github.com/amunx/backend/internal/storage"
 )
 
 // Server bundles the HTTP server for the API.
@@ -28,7 +29,7 @@ func NewServer(cfg app.Config, logger zerolog.Logger, deps *app.App) *Server {
 	router.Use(middleware.RequestID)
 	router.Use(middleware.RealIP)
 	router.Use(middleware.Recoverer)
-	router.Use(middleware.Timeout(30 * time.Second))
+	router.Use(requestTimeout(30 * time.Second))
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete, http.MethodOptions},
@@ -40,7 +41,7 @@ func NewServer(cfg app.Config, logger zerolog.Logger, deps *app.App) *Server {
 		router.Use(mw.NewRateLimiter(deps.Redis, cfg.RateLimitWindow, cfg.RateLimitMax).Handler())
 	}
 	router.Use(mw.Logger(logger))
-	router.Use(mw.Gzip)
+	router.Use(mw.SkipStreams(mw.Gzip))
 
 	router.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -68,17 +69,21 @@ func NewServer(cfg app.Config, logger zerolog.Logger, deps *app.App) *Server {
 		registerPublicEpisodeRoutes(r, deps, logger)
 		registerPublicTopicRoutes(r, deps)
 		registerPublicCommentRoutes(r, deps)
-		registerPublicLiveRoutes(r, deps)
+		registerPublicLiveRoutes(r, deps, logger)
 		registerExploreRoutes(r, deps)
 		registerSmartInboxRoutes(r, deps)
 		registerSearchRoutes(r, deps)
 		registerBillingWebhookRoutes(r, deps)
+		registerLiveKitWebhookRoutes(r, deps)
+		registerStorageRoutes(r, deps)
 
 		r.Group(func(protected chi.Router) {
 			protected.Use(mw.Auth(deps, logger))
 			registerUserRoutes(protected, deps)
+			registerLocationRoutes(protected, deps)
 			registerFollowRoutes(protected, deps)
 			registerEpisodeRoutes(protected, deps)
+			registerUploadRoutes(protected, deps)
 			registerTopicRoutes(protected, deps)
 			registerCommentRoutes(protected, deps)
 			registerReactionRoutes(protected, deps)
@@ -86,6 +91,7 @@ func NewServer(cfg app.Config, logger zerolog.Logger, deps *app.App) *Server {
 			registerPushRoutes(protected, deps)
 			registerReportRoutes(protected, deps)
 			registerLiveRoutes(protected, deps)
+			registerCircleRoutes(protected, deps)
 			registerModerationRoutes(protected, deps)
 			if cfg.Environment == "development" {
 				registerDiagnosticsRoutes(protected, deps)
@@ -118,6 +124,13 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	return s.httpServer.Shutdown(ctx)
 }
 
+// requestTimeout bounds ordinary requests. Event streams stay open on
+// purpose, and signed object transfers carry bodies up to
+// maxLocalUploadBytes, which do not fit in the deadline.
+func requestTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return mw.SkipStreams(mw.SkipPrefix(storage.LocalObjectsPath, middleware.Timeout(d)))
+}
+
 func itoa(v int) string {
 	return fmt.Sprintf("%d", v)
 }
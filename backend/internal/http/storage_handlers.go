@@ -0,0 +1,130 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/storage"
+)
+
+// maxLocalUploadBytes caps bodies accepted by the local upload endpoint.
+const maxLocalUploadBytes = 2 << 30
+
+// registerStorageRoutes serves presigned uploads and object reads when the
+// filesystem storage client is in use. Other clients upload straight to
+// object storage, so the routes are not mounted for them. Reads need a
+// presigned URL too: the root also holds private originals and in-progress
+// uploads.
+func registerStorageRoutes(r chi.Router, deps *app.App) {
+	fs, ok := deps.Storage.(*storage.FSClient)
+	if !ok {
+		return
+	}
+
+	r.Put("/storage/objects/*", func(w http.ResponseWriter, req *http.Request) {
+		key := chi.URLParam(req, "*")
+		if req.URL.Query().Has("upload_id") {
+			putLocalPart(w, req, fs, key)
+			return
+		}
+
+		contentType, err := fs.VerifyUpload(key, req.URL.Query())
+		if err != nil {
+			WriteError(w, http.StatusForbidden, "invalid_signature", err.Error())
+			return
+		}
+		if contentType == "" {
+			contentType = req.Header.Get("Content-Type")
+		}
+
+		liftReadDeadline(w)
+		body := http.MaxBytesReader(w, req.Body, maxLocalUploadBytes)
+		defer body.Close()
+		if _, err := fs.PutObject(req.Context(), key, body, map[string]string{storage.MetadataContentType: contentType}); err != nil {
+			writeLocalUploadError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	r.Get("/storage/objects/*", func(w http.ResponseWriter, req *http.Request) {
+		key := chi.URLParam(req, "*")
+		if err := fs.VerifyDownload(key, req.URL.Query()); err != nil {
+			WriteError(w, http.StatusForbidden, "invalid_signature", err.Error())
+			return
+		}
+		info, err := fs.HeadObject(req.Context(), key)
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrObjectNotFound), errors.Is(err, storage.ErrInvalidKey):
+				WriteError(w, http.StatusNotFound, "not_found", "object not found")
+			default:
+				WriteError(w, http.StatusInternalServerError, "storage_error", err.Error())
+			}
+			return
+		}
+		reader, err := fs.GetObject(req.Context(), key)
+		if err != nil {
+			WriteError(w, http.StatusNotFound, "not_found", "object not found")
+			return
+		}
+		defer reader.Close()
+
+		if info.ContentType != "" {
+			w.Header().Set("Content-Type", info.ContentType)
+		}
+		if file, ok := reader.(*os.File); ok {
+			http.ServeContent(w, req, "", time.Time{}, file)
+			return
+		}
+		_, _ = io.Copy(w, reader)
+	})
+}
+
+// putLocalPart stores one part of a multipart upload. Like S3, the part's
+// ETag is returned in the ETag header.
+func putLocalPart(w http.ResponseWriter, req *http.Request, fs *storage.FSClient, key string) {
+	uploadID, partNumber, err := fs.VerifyUploadPart(key, req.URL.Query())
+	if err != nil {
+		WriteError(w, http.StatusForbidden, "invalid_signature", err.Error())
+		return
+	}
+
+	liftReadDeadline(w)
+	body := http.MaxBytesReader(w, req.Body, maxLocalUploadBytes)
+	defer body.Close()
+	etag, err := fs.PutPart(req.Context(), key, uploadID, partNumber, body)
+	if err != nil {
+		writeLocalUploadError(w, err)
+		return
+	}
+	w.Header().Set("ETag", `"`+etag+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// liftReadDeadline clears the server's read timeout for a verified upload;
+// bodies up to maxLocalUploadBytes take longer than it allows. Writers that
+// cannot change deadlines are left as they are.
+func liftReadDeadline(w http.ResponseWriter) {
+	_ = http.NewResponseController(w).SetReadDeadline(time.Time{})
+}
+
+func writeLocalUploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		WriteError(w, http.StatusRequestEntityTooLarge, "upload_too_large", err.Error())
+	case errors.Is(err, storage.ErrInvalidKey), errors.Is(err, storage.ErrInvalidPart):
+		WriteError(w, http.StatusBadRequest, "invalid_upload", err.Error())
+	case errors.Is(err, storage.ErrUploadNotFound):
+		WriteError(w, http.StatusNotFound, "upload_not_found", err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, "storage_error", err.Error())
+	}
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/storage"
+)
+
+func TestStorageReadsRequireSignature(t *testing.T) {
+	fs, err := storage.NewFSClient(storage.FSConfig{Root: t.TempDir(), BaseURL: "http://api.test", SigningSecret: "secret"})
+	if err != nil {
+		t.Fatalf("fs client: %v", err)
+	}
+	key := "originals/private.ogg"
+	if _, err := fs.PutObject(context.Background(), key, strings.NewReader("audio"), nil); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Route("/v1", func(r chi.Router) {
+		registerStorageRoutes(r, &app.App{Storage: fs})
+	})
+
+	get := func(target string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec.Code
+	}
+
+	if code := get(storage.LocalObjectsPath + key); code != http.StatusForbidden {
+		t.Fatalf("expected unsigned read to be forbidden, got %d", code)
+	}
+	signed, err := fs.PresignDownload(context.Background(), key, time.Minute)
+	if err != nil {
+		t.Fatalf("presign: %v", err)
+	}
+	if code := get(strings.TrimPrefix(signed, "http://api.test")); code != http.StatusOK {
+		t.Fatalf("expected signed read to succeed, got %d", code)
+	}
+}
+
+func TestStorageObjectsSkipRequestTimeout(t *testing.T) {
+	bounded := func(target string) bool {
+		var deadline bool
+		handler := requestTimeout(time.Minute)(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+			_, deadline = req.Context().Deadline()
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, target, nil))
+		return deadline
+	}
+
+	if !bounded("/v1/episodes") {
+		t.Fatal("expected API requests to get a deadline")
+	}
+	if bounded(storage.LocalObjectsPath + "originals/long.ogg") {
+		t.Fatal("expected object uploads to skip the request deadline")
+	}
+}
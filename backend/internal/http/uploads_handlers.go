@@ -2,6 +2,7 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
@@ -11,24 +12,53 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/amunx/backend/internal/app"
+	"github.com/amunx/backend/internal/storage"
+	"github.com/amunx/backend/internal/uploads"
 )
 
 // PresignUploadRequest represents the request for presigned upload URL
 type PresignUploadRequest struct {
-	MIME     string `json:"mime"`
-	Filename string `json:"filename"`
+	MIME        string `json:"mime"`
+	Filename    string `json:"filename"`
+	SizeBytes   int64  `json:"size_bytes"`
+	DurationSec int    `json:"duration_sec"`
 }
 
-// PresignUploadResponse represents the presigned upload response
+// PresignUploadResponse represents the presigned upload response. Large
+// uploads get a multipart session instead of a single URL.
 type PresignUploadResponse struct {
-	URL       string            `json:"url"`
-	Fields    map[string]string `json:"fields"`
-	S3Key     string            `json:"s3_key"`
-	ExpiresAt string            `json:"expires_at"`
+	URL       string                   `json:"url,omitempty"`
+	Method    string                   `json:"method,omitempty"`
+	Headers   map[string]string        `json:"headers,omitempty"`
+	S3Key     string                   `json:"s3_key"`
+	ExpiresAt string                   `json:"expires_at"`
+	Multipart *MultipartUploadResponse `json:"multipart,omitempty"`
+}
+
+// MultipartUploadResponse describes a resumable upload session. Clients
+// request part URLs from /uploads/sessions/{id}/parts, upload parts of
+// part_size bytes (the last may be shorter) and then complete the session.
+type MultipartUploadResponse struct {
+	SessionID string `json:"session_id"`
+	Status    string `json:"status"`
+	PartSize  int64  `json:"part_size"`
+	PartCount int    `json:"part_count,omitempty"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// UploadPartResponse describes an uploaded part or a presigned part URL.
+type UploadPartResponse struct {
+	Number    int32             `json:"number"`
+	ETag      string            `json:"etag,omitempty"`
+	Size      int64             `json:"size,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	Method    string            `json:"method,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	ExpiresAt string            `json:"expires_at,omitempty"`
 }
 
 // RequestPresignedUpload generates a presigned URL for S3 upload (POST /uploads/presign)
-func RequestPresignedUpload(w http.ResponseWriter, r *http.Request, deps *app.App) {
+func RequestPresignedUpload(w http.ResponseWriter, r *http.Request, deps *app.App, sessions *uploads.Sessions) {
 	userID := getUserID(r)
 	if userID == uuid.Nil {
 		WriteError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
@@ -63,29 +93,200 @@ func RequestPresignedUpload(w http.ResponseWriter, r *http.Request, deps *app.Ap
 	}
 	s3Key := fmt.Sprintf("uploads/%s/%s%s", userID.String(), uuid.New().String(), ext)
 
-	// TODO: Generate presigned POST URL using AWS SDK or MinIO SDK
-	// For now, return mock response
+	if wantsMultipartUpload(deps.Config, req.SizeBytes, req.DurationSec) {
+		session, err := sessions.Initiate(r.Context(), userID, s3Key, req.MIME, req.SizeBytes)
+		if err != nil {
+			writeUploadSessionError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, PresignUploadResponse{
+			S3Key:     s3Key,
+			ExpiresAt: session.ExpiresAt.Format(time.RFC3339),
+			Multipart: newMultipartUploadResponse(session),
+		})
+		return
+	}
 
 	// Expiration: 15 minutes
 	expiresAt := time.Now().Add(15 * time.Minute)
+	upload, err := deps.Storage.PresignUpload(r.Context(), s3Key, 15*time.Minute, req.MIME)
+	if err != nil {
+		writeUploadSessionError(w, err)
+		return
+	}
 
-	response := PresignUploadResponse{
-		URL: "https://s3.example.com/amunx-bucket",
-		Fields: map[string]string{
-			"key":                         s3Key,
-			"Content-Type":                req.MIME,
-			"acl":                         "private",
-			"x-amz-algorithm":             "AWS4-HMAC-SHA256",
-			"x-amz-credential":            "...",
-			"x-amz-date":                  time.Now().Format("20060102T150405Z"),
-			"policy":                      "base64-encoded-policy",
-			"x-amz-signature":             "signature-here",
-		},
+	WriteJSON(w, http.StatusOK, PresignUploadResponse{
+		URL:       upload.URL,
+		Method:    upload.Method,
+		Headers:   flattenHeaders(upload.Headers),
 		S3Key:     s3Key,
 		ExpiresAt: expiresAt.Format(time.RFC3339),
+	})
+}
+
+// GetUploadSession reports a session and the parts uploaded so far, so an
+// interrupted client knows where to resume (GET /uploads/sessions/:id)
+func GetUploadSession(w http.ResponseWriter, r *http.Request, sessions *uploads.Sessions) {
+	sessionID, userID, ok := uploadSessionParams(w, r)
+	if !ok {
+		return
 	}
 
-	WriteJSON(w, http.StatusOK, response)
+	session, err := sessions.Get(r.Context(), sessionID, userID)
+	if err != nil {
+		writeUploadSessionError(w, err)
+		return
+	}
+	resp := map[string]any{
+		"session": newMultipartUploadResponse(session),
+		"s3_key":  session.StorageKey,
+		"parts":   []UploadPartResponse{},
+	}
+	if session.Status == uploads.StatusActive {
+		parts, err := sessions.Parts(r.Context(), sessionID, userID)
+		if err != nil && !errors.Is(err, uploads.ErrSessionClosed) {
+			writeUploadSessionError(w, err)
+			return
+		}
+		items := make([]UploadPartResponse, 0, len(parts))
+		for _, part := range parts {
+			items = append(items, UploadPartResponse{Number: part.Number, ETag: part.ETag, Size: part.Size})
+		}
+		resp["parts"] = items
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// PresignUploadParts issues part URLs (POST /uploads/sessions/:id/parts)
+func PresignUploadParts(w http.ResponseWriter, r *http.Request, sessions *uploads.Sessions) {
+	sessionID, userID, ok := uploadSessionParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		PartNumbers []int32 `json:"part_numbers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid_request", "invalid request body")
+		return
+	}
+
+	urls, err := sessions.PresignParts(r.Context(), sessionID, userID, req.PartNumbers)
+	if err != nil {
+		writeUploadSessionError(w, err)
+		return
+	}
+	items := make([]UploadPartResponse, 0, len(urls))
+	for _, u := range urls {
+		items = append(items, UploadPartResponse{
+			Number:    u.Number,
+			URL:       u.Upload.URL,
+			Method:    u.Upload.Method,
+			Headers:   flattenHeaders(u.Upload.Headers),
+			ExpiresAt: u.ExpiresAt.Format(time.RFC3339),
+		})
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"parts": items})
+}
+
+// CompleteUploadSession assembles the uploaded parts (POST /uploads/sessions/:id/complete)
+func CompleteUploadSession(w http.ResponseWriter, r *http.Request, sessions *uploads.Sessions) {
+	sessionID, userID, ok := uploadSessionParams(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := sessions.Complete(r.Context(), sessionID, userID)
+	if err != nil {
+		writeUploadSessionError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"session": newMultipartUploadResponse(session),
+		"s3_key":  session.StorageKey,
+	})
+}
+
+// AbortUploadSession discards an upload (DELETE /uploads/sessions/:id)
+func AbortUploadSession(w http.ResponseWriter, r *http.Request, sessions *uploads.Sessions) {
+	sessionID, userID, ok := uploadSessionParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := sessions.Abort(r.Context(), sessionID, userID); err != nil {
+		writeUploadSessionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func uploadSessionParams(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID := getUserID(r)
+	if userID == uuid.Nil {
+		WriteError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return uuid.Nil, uuid.Nil, false
+	}
+	sessionID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid_request", "invalid session ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return sessionID, userID, true
+}
+
+func writeUploadSessionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, uploads.ErrSessionNotFound):
+		WriteError(w, http.StatusNotFound, "upload_session_not_found", err.Error())
+	case errors.Is(err, uploads.ErrSessionClosed):
+		WriteError(w, http.StatusConflict, "upload_session_closed", err.Error())
+	case errors.Is(err, uploads.ErrIncompleteUpload):
+		WriteError(w, http.StatusConflict, "upload_incomplete", err.Error())
+	case errors.Is(err, uploads.ErrInvalidPartNumber), errors.Is(err, storage.ErrInvalidPart):
+		WriteError(w, http.StatusBadRequest, "invalid_part", err.Error())
+	case errors.Is(err, storage.ErrNotImplemented):
+		WriteError(w, http.StatusServiceUnavailable, "storage_disabled", "object storage not configured")
+	default:
+		WriteError(w, http.StatusInternalServerError, "storage_error", err.Error())
+	}
+}
+
+// wantsMultipartUpload reports whether a declared size or duration is large
+// enough that a single presigned PUT is likely to fail on mobile networks.
+func wantsMultipartUpload(cfg app.Config, sizeBytes int64, durationSec int) bool {
+	if cfg.UploadMultipartMinBytes > 0 && sizeBytes >= cfg.UploadMultipartMinBytes {
+		return true
+	}
+	return cfg.UploadMultipartMinDuration > 0 && durationSec >= cfg.UploadMultipartMinDuration
+}
+
+func newUploadSessions(deps *app.App) *uploads.Sessions {
+	return uploads.NewSessions(deps.DB, deps.Storage, uploads.Options{
+		PartSize:   deps.Config.UploadPartSize,
+		SessionTTL: deps.Config.UploadSessionTTL,
+	})
+}
+
+func newMultipartUploadResponse(session uploads.Session) *MultipartUploadResponse {
+	return &MultipartUploadResponse{
+		SessionID: session.ID.String(),
+		Status:    session.Status,
+		PartSize:  session.PartSize,
+		PartCount: session.PartCount(),
+		ExpiresAt: session.ExpiresAt.Format(time.RFC3339),
+	}
+}
+
+func flattenHeaders(headers http.Header) map[string]string {
+	flat := map[string]string{}
+	for k, values := range headers {
+		if len(values) > 0 {
+			flat[k] = values[0]
+		}
+	}
+	return flat
 }
 
 // isAudioMIME checks if the MIME type is audio
@@ -132,8 +333,25 @@ func getExtensionFromMIME(mime string) string {
 
 // registerUploadRoutes registers routes for uploads
 func registerUploadRoutes(r chi.Router, deps *app.App) {
+	sessions := newUploadSessions(deps)
+
 	r.Post("/uploads/presign", func(w http.ResponseWriter, req *http.Request) {
-		RequestPresignedUpload(w, req, deps)
+		RequestPresignedUpload(w, req, deps, sessions)
+	})
+
+	r.Route("/uploads/sessions/{id}", func(r chi.Router) {
+		r.Get("/", func(w http.ResponseWriter, req *http.Request) {
+			GetUploadSession(w, req, sessions)
+		})
+		r.Delete("/", func(w http.ResponseWriter, req *http.Request) {
+			AbortUploadSession(w, req, sessions)
+		})
+		r.Post("/parts", func(w http.ResponseWriter, req *http.Request) {
+			PresignUploadParts(w, req, sessions)
+		})
+		r.Post("/complete", func(w http.ResponseWriter, req *http.Request) {
+			CompleteUploadSession(w, req, sessions)
+		})
 	})
 }
 
@@ -5,7 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -50,6 +53,9 @@ type InvoiceRequest struct {
 	WebhookURL   string
 	CustomerData map[string]string
 	Validity     time.Duration
+	// WalletID, when set, saves the paying card into that wallet so later
+	// renewals can be charged with ChargeWallet.
+	WalletID string
 }
 
 type InvoiceResponse struct {
@@ -57,6 +63,79 @@ type InvoiceResponse struct {
 	PageURL   string `json:"pageUrl"`
 }
 
+// Invoice statuses reported by MonoPay.
+const (
+	StatusCreated    = "created"
+	StatusProcessing = "processing"
+	StatusHold       = "hold"
+	StatusSuccess    = "success"
+	StatusFailure    = "failure"
+	StatusReversed   = "reversed"
+	StatusExpired    = "expired"
+)
+
+// FinalStatus reports whether an invoice in status can no longer change.
+func FinalStatus(status string) bool {
+	switch status {
+	case StatusSuccess, StatusFailure, StatusReversed, StatusExpired:
+		return true
+	default:
+		return false
+	}
+}
+
+type WalletData struct {
+	CardToken string `json:"cardToken"`
+	WalletID  string `json:"walletId"`
+	Status    string `json:"status"`
+}
+
+// InvoiceStatus is MonoPay's view of an invoice, returned by the status
+// endpoint and by wallet charges.
+type InvoiceStatus struct {
+	InvoiceID     string      `json:"invoiceId"`
+	Status        string      `json:"status"`
+	FailureReason string      `json:"failureReason"`
+	Amount        int         `json:"amount"`
+	Ccy           int         `json:"ccy"`
+	Reference     string      `json:"reference"`
+	CreatedDate   string      `json:"createdDate"`
+	ModifiedDate  string      `json:"modifiedDate"`
+	WalletData    *WalletData `json:"walletData,omitempty"`
+	// TDSURL is set when a wallet charge needs 3-D Secure confirmation.
+	TDSURL string `json:"tdsUrl"`
+}
+
+type WalletCard struct {
+	CardToken string `json:"cardToken"`
+	MaskedPan string `json:"maskedPan"`
+	Country   string `json:"country"`
+}
+
+type WalletChargeRequest struct {
+	CardToken   string
+	AmountCents int
+	Currency    string
+	Reference   string
+	Destination string
+	RedirectURL string
+	WebhookURL  string
+}
+
+// APIError is a non-2xx answer from MonoPay.
+type APIError struct {
+	StatusCode int
+	Code       string `json:"errCode"`
+	Text       string `json:"errText"`
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("monopay request failed with %d", e.StatusCode)
+	}
+	return fmt.Sprintf("monopay request failed with %d: %s %s", e.StatusCode, e.Code, e.Text)
+}
+
 func (c *Client) CreateInvoice(ctx context.Context, req InvoiceRequest) (*InvoiceResponse, error) {
 	payload := map[string]any{
 		"amount": req.AmountCents,
@@ -76,35 +155,113 @@ func (c *Client) CreateInvoice(ctx context.Context, req InvoiceRequest) (*Invoic
 			payload["customerData"] = string(data)
 		}
 	}
+	if req.WalletID != "" {
+		payload["saveCardData"] = map[string]any{
+			"saveCard": true,
+			"walletId": req.WalletID,
+		}
+	}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
+	var invoice InvoiceResponse
+	if err := c.do(ctx, http.MethodPost, "/invoice/create", nil, payload, &invoice); err != nil {
 		return nil, err
 	}
+	return &invoice, nil
+}
 
-	url := fmt.Sprintf("%s/invoice/create", c.baseURL)
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
-	if err != nil {
+// InvoiceStatus fetches the current state of an invoice.
+func (c *Client) InvoiceStatus(ctx context.Context, invoiceID string) (*InvoiceStatus, error) {
+	var status InvoiceStatus
+	query := url.Values{"invoiceId": {invoiceID}}
+	if err := c.do(ctx, http.MethodGet, "/invoice/status", query, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Wallet lists the cards saved in a wallet.
+func (c *Client) Wallet(ctx context.Context, walletID string) ([]WalletCard, error) {
+	var resp struct {
+		Wallet []WalletCard `json:"wallet"`
+	}
+	query := url.Values{"walletId": {walletID}}
+	if err := c.do(ctx, http.MethodGet, "/wallet", query, nil, &resp); err != nil {
 		return nil, err
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
+	return resp.Wallet, nil
+}
+
+// ChargeWallet charges a saved card without the customer present. The
+// returned status is often still "processing"; the outcome arrives by
+// webhook or through InvoiceStatus.
+func (c *Client) ChargeWallet(ctx context.Context, req WalletChargeRequest) (*InvoiceStatus, error) {
+	payload := map[string]any{
+		"cardToken":      req.CardToken,
+		"amount":         req.AmountCents,
+		"ccy":            currencyCode(req.Currency),
+		"initiationKind": "merchant",
+		"paymentType":    "debit",
+		"merchantPaymInfo": map[string]any{
+			"reference":   req.Reference,
+			"destination": req.Destination,
+		},
+		"redirectUrl": req.RedirectURL,
+		"webHookUrl":  req.WebhookURL,
+	}
+	var status InvoiceStatus
+	if err := c.do(ctx, http.MethodPost, "/wallet/payment", nil, payload, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Statement lists the merchant's invoices created since from, including
+// wallet charges with the reference they were made under.
+func (c *Client) Statement(ctx context.Context, from time.Time) ([]InvoiceStatus, error) {
+	var resp struct {
+		List []InvoiceStatus `json:"list"`
+	}
+	query := url.Values{"from": {strconv.FormatInt(from.Unix(), 10)}}
+	if err := c.do(ctx, http.MethodGet, "/statement", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.List, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, payload, out any) error {
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
 	httpReq.Header.Set("X-Token", c.apiKey)
 
 	resp, err := c.http.Do(httpReq)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("monopay create invoice failed with %d", resp.StatusCode)
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		_ = json.NewDecoder(resp.Body).Decode(apiErr)
+		return apiErr
 	}
-
-	var invoice InvoiceResponse
-	if err := json.NewDecoder(resp.Body).Decode(&invoice); err != nil {
-		return nil, err
-	}
-	return &invoice, nil
+	return json.NewDecoder(resp.Body).Decode(out)
 }
 
 func currencyCode(currency string) int {
@@ -0,0 +1,63 @@
+package monopay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChargeWalletAndInvoiceStatus(t *testing.T) {
+	var charged map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/wallet/payment":
+			_ = json.NewDecoder(r.Body).Decode(&charged)
+			_, _ = w.Write([]byte(`{"invoiceId":"inv_renew","status":"processing","amount":5900,"ccy":980}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/invoice/status":
+			if r.URL.Query().Get("invoiceId") != "inv_renew" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"errCode":"NOT_FOUND","errText":"invoice not found"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"invoiceId":"inv_renew","status":"success","modifiedDate":"2026-05-01T10:00:00Z","walletData":{"cardToken":"tok_1","walletId":"w1","status":"created"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{BaseURL: srv.URL, APIToken: "token", Merchant: "m1"})
+	ctx := context.Background()
+
+	res, err := client.ChargeWallet(ctx, WalletChargeRequest{CardToken: "tok_1", AmountCents: 5900, Currency: "UAH", Reference: "ref-1"})
+	if err != nil {
+		t.Fatalf("charge: %v", err)
+	}
+	if res.InvoiceID != "inv_renew" || FinalStatus(res.Status) {
+		t.Fatalf("unexpected charge result %+v", res)
+	}
+	if charged["cardToken"] != "tok_1" || charged["initiationKind"] != "merchant" || charged["ccy"] != float64(980) {
+		t.Fatalf("unexpected charge payload %v", charged)
+	}
+
+	status, err := client.InvoiceStatus(ctx, "inv_renew")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.Status != StatusSuccess || status.WalletData == nil || status.WalletData.CardToken != "tok_1" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	_, err = client.InvoiceStatus(ctx, "missing")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "NOT_FOUND" {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package stripe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type Config struct {
+	BaseURL string
+	APIKey  string
+	Logger  zerolog.Logger
+}
+
+type Client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+	logger  zerolog.Logger
+}
+
+func NewClient(cfg Config) *Client {
+	base := strings.TrimSuffix(cfg.BaseURL, "/")
+	if base == "" {
+		base = "https://api.stripe.com"
+	}
+	return &Client{
+		baseURL: base,
+		apiKey:  cfg.APIKey,
+		http:    &http.Client{Timeout: 10 * time.Second},
+		logger:  cfg.Logger,
+	}
+}
+
+type CheckoutRequest struct {
+	PriceID string
+	// ClientReferenceID comes back on checkout.session.completed and ties
+	// the new customer to our user.
+	ClientReferenceID string
+	// CustomerID reuses an existing Stripe customer; otherwise Stripe
+	// creates one for CustomerEmail.
+	CustomerID    string
+	CustomerEmail string
+	SuccessURL    string
+	CancelURL     string
+	// Metadata is copied onto both the session and the subscription it
+	// creates.
+	Metadata map[string]string
+}
+
+type CheckoutSession struct {
+	ID                string `json:"id"`
+	URL               string `json:"url"`
+	Customer          string `json:"customer"`
+	ClientReferenceID string `json:"client_reference_id"`
+}
+
+type PortalSession struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// APIError is a non-2xx answer from Stripe.
+type APIError struct {
+	StatusCode int
+	Type       string `json:"type"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("stripe request failed with %d", e.StatusCode)
+	}
+	return fmt.Sprintf("stripe request failed with %d: %s", e.StatusCode, e.Message)
+}
+
+// CreateCheckoutSession starts a subscription checkout for one price.
+func (c *Client) CreateCheckoutSession(ctx context.Context, req CheckoutRequest) (*CheckoutSession, error) {
+	form := url.Values{
+		"mode":                    {"subscription"},
+		"line_items[0][price]":    {req.PriceID},
+		"line_items[0][quantity]": {"1"},
+		"success_url":             {req.SuccessURL},
+		"cancel_url":              {req.CancelURL},
+		"client_reference_id":     {req.ClientReferenceID},
+	}
+	switch {
+	case req.CustomerID != "":
+		form.Set("customer", req.CustomerID)
+	case req.CustomerEmail != "":
+		form.Set("customer_email", req.CustomerEmail)
+	}
+	for k, v := range req.Metadata {
+		form.Set("metadata["+k+"]", v)
+		form.Set("subscription_data[metadata]["+k+"]", v)
+	}
+
+	var session CheckoutSession
+	if err := c.post(ctx, "/v1/checkout/sessions", form, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// CreatePortalSession opens the billing portal for one customer.
+func (c *Client) CreatePortalSession(ctx context.Context, customerID, returnURL string) (*PortalSession, error) {
+	form := url.Values{"customer": {customerID}}
+	if returnURL != "" {
+		form.Set("return_url", returnURL)
+	}
+	var session PortalSession
+	if err := c.post(ctx, "/v1/billing_portal/sessions", form, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (c *Client) post(ctx context.Context, path string, form url.Values, out any) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var body struct {
+			Error APIError `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		body.Error.StatusCode = resp.StatusCode
+		return &body.Error
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
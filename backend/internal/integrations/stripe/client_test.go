@@ -0,0 +1,72 @@
+package stripe
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCreateCheckoutAndPortalSessions(t *testing.T) {
+	forms := map[string]url.Values{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"type":"invalid_request_error","message":"Invalid API Key provided"}}`))
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		forms[r.URL.Path] = r.PostForm
+		switch r.URL.Path {
+		case "/v1/checkout/sessions":
+			_, _ = w.Write([]byte(`{"id":"cs_1","url":"https://checkout.stripe.test/cs_1","client_reference_id":"user-1"}`))
+		case "/v1/billing_portal/sessions":
+			_, _ = w.Write([]byte(`{"id":"bps_1","url":"https://billing.stripe.test/bps_1"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{BaseURL: srv.URL, APIKey: "sk_test"})
+	ctx := context.Background()
+
+	session, err := client.CreateCheckoutSession(ctx, CheckoutRequest{
+		PriceID:           "price_1",
+		ClientReferenceID: "user-1",
+		CustomerEmail:     "a@example.com",
+		SuccessURL:        "https://app.test/ok",
+		CancelURL:         "https://app.test/cancel",
+		Metadata:          map[string]string{"user_id": "user-1"},
+	})
+	if err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	if session.URL != "https://checkout.stripe.test/cs_1" {
+		t.Fatalf("unexpected session %+v", session)
+	}
+	form := forms["/v1/checkout/sessions"]
+	if form.Get("mode") != "subscription" || form.Get("line_items[0][price]") != "price_1" ||
+		form.Get("client_reference_id") != "user-1" || form.Get("customer_email") != "a@example.com" ||
+		form.Get("subscription_data[metadata][user_id]") != "user-1" || form.Has("customer") {
+		t.Fatalf("unexpected checkout form %v", form)
+	}
+
+	portal, err := client.CreatePortalSession(ctx, "cus_1", "https://app.test/billing")
+	if err != nil {
+		t.Fatalf("portal: %v", err)
+	}
+	if portal.URL != "https://billing.stripe.test/bps_1" || forms["/v1/billing_portal/sessions"].Get("customer") != "cus_1" {
+		t.Fatalf("unexpected portal session %+v", portal)
+	}
+
+	_, err = NewClient(Config{BaseURL: srv.URL, APIKey: "bad"}).CreatePortalSession(ctx, "cus_1", "")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Type != "invalid_request_error" {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+}
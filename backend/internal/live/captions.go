@@ -0,0 +1,184 @@
+package live
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// MaxCaptionBatch bounds the segments accepted in one report.
+	MaxCaptionBatch = 50
+	// DefaultCaptionHistory is how many segments Since returns by default.
+	DefaultCaptionHistory = 200
+	maxCaptionRunes       = 2000
+	maxSegmentIDLen       = 128
+)
+
+// ErrInvalidCaptions is returned for empty or oversized batches and
+// malformed segments.
+var ErrInvalidCaptions = errors.New("invalid caption segments")
+
+// CaptionInput is a caption segment as the live agent reports it. Times are
+// wall-clock; they are stored as offsets from the session start.
+type CaptionInput struct {
+	SegmentID string    `json:"segment_id"`
+	Speaker   string    `json:"speaker"`
+	Lang      string    `json:"lang"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Text      string    `json:"text"`
+}
+
+// CaptionSegment is a stored caption. ID orders segments in arrival order
+// and doubles as the event id of the caption stream.
+type CaptionSegment struct {
+	ID        int64     `json:"id"`
+	SessionID uuid.UUID `json:"session_id"`
+	SegmentID string    `json:"segment_id"`
+	Speaker   string    `json:"speaker"`
+	Lang      string    `json:"lang"`
+	StartMs   int64     `json:"start_ms"`
+	EndMs     int64     `json:"end_ms"`
+	Text      string    `json:"text"`
+}
+
+// Captions stores caption segments of live sessions and fans them out over
+// Redis pub/sub. Finalization turns them into the replay's transcript.
+type Captions struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+// NewCaptions constructs a caption store.
+func NewCaptions(db *sql.DB, rdb *redis.Client) *Captions {
+	return &Captions{DB: db, Redis: rdb}
+}
+
+// CaptionChannel is the pub/sub channel of a session's captions.
+func CaptionChannel(sessionID uuid.UUID) string {
+	return fmt.Sprintf("live:captions:%s", sessionID)
+}
+
+// Append stores segments for a live session and publishes the new ones.
+// Segments already stored under the same segment id are skipped, so the
+// agent can resend a batch after a failure.
+func (c *Captions) Append(ctx context.Context, sessionID uuid.UUID, inputs []CaptionInput) ([]CaptionSegment, error) {
+	if len(inputs) == 0 || len(inputs) > MaxCaptionBatch {
+		return nil, ErrInvalidCaptions
+	}
+	for i := range inputs {
+		in := &inputs[i]
+		in.SegmentID = strings.TrimSpace(in.SegmentID)
+		in.Speaker = strings.TrimSpace(in.Speaker)
+		in.Lang = strings.TrimSpace(in.Lang)
+		in.Text = strings.TrimSpace(in.Text)
+		switch {
+		case in.SegmentID == "" || len(in.SegmentID) > maxSegmentIDLen,
+			!languageTag.MatchString(in.Lang),
+			in.Text == "" || len([]rune(in.Text)) > maxCaptionRunes,
+			in.StartedAt.IsZero() || in.EndedAt.Before(in.StartedAt):
+			return nil, ErrInvalidCaptions
+		}
+	}
+
+	var (
+		startedAt sql.NullTime
+		ended     bool
+	)
+	err := c.DB.QueryRowContext(ctx, `
+SELECT started_at, ended_at IS NOT NULL FROM live_sessions WHERE id = $1`, sessionID).Scan(&startedAt, &ended)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) || ended:
+		return nil, ErrSessionEnded
+	case err != nil:
+		return nil, fmt.Errorf("lookup session: %w", err)
+	}
+
+	stored := make([]CaptionSegment, 0, len(inputs))
+	for _, in := range inputs {
+		seg := CaptionSegment{
+			SessionID: sessionID,
+			SegmentID: in.SegmentID,
+			Speaker:   in.Speaker,
+			Lang:      in.Lang,
+			StartMs:   offsetMs(startedAt.Time, in.StartedAt),
+			EndMs:     offsetMs(startedAt.Time, in.EndedAt),
+			Text:      in.Text,
+		}
+		err := c.DB.QueryRowContext(ctx, `
+INSERT INTO live_caption_segments (session_id, segment_id, speaker, lang, start_ms, end_ms, text)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (session_id, segment_id) DO NOTHING
+RETURNING id`, sessionID, seg.SegmentID, seg.Speaker, seg.Lang, seg.StartMs, seg.EndMs, seg.Text).Scan(&seg.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return stored, fmt.Errorf("store caption: %w", err)
+		}
+		stored = append(stored, seg)
+		// Subscribers that miss the event catch up from Since.
+		_ = c.publish(ctx, seg)
+	}
+	return stored, nil
+}
+
+// Since returns a session's segments in arrival order, starting after the
+// segment with id after.
+func (c *Captions) Since(ctx context.Context, sessionID uuid.UUID, after int64, limit int) ([]CaptionSegment, error) {
+	if limit <= 0 {
+		limit = DefaultCaptionHistory
+	}
+	rows, err := c.DB.QueryContext(ctx, `
+SELECT id, session_id, segment_id, speaker, lang, start_ms, end_ms, text
+  FROM live_caption_segments
+ WHERE session_id = $1 AND id > $2
+ ORDER BY id
+ LIMIT $3`, sessionID, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("caption history: %w", err)
+	}
+	defer rows.Close()
+
+	segments := []CaptionSegment{}
+	for rows.Next() {
+		var seg CaptionSegment
+		if err := rows.Scan(&seg.ID, &seg.SessionID, &seg.SegmentID, &seg.Speaker, &seg.Lang,
+			&seg.StartMs, &seg.EndMs, &seg.Text); err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+	}
+	return segments, rows.Err()
+}
+
+// Subscribe listens to a session's captions. Callers close the
+// subscription.
+func (c *Captions) Subscribe(ctx context.Context, sessionID uuid.UUID) *redis.PubSub {
+	return c.Redis.Subscribe(ctx, CaptionChannel(sessionID))
+}
+
+func (c *Captions) publish(ctx context.Context, seg CaptionSegment) error {
+	payload, err := json.Marshal(seg)
+	if err != nil {
+		return err
+	}
+	return c.Redis.Publish(ctx, CaptionChannel(seg.SessionID), payload).Err()
+}
+
+// offsetMs is the time from start to t, floored at zero. Sessions without
+// a start time put everything at zero, like chat does.
+func offsetMs(start, t time.Time) int64 {
+	if start.IsZero() || t.Before(start) {
+		return 0
+	}
+	return t.Sub(start).Milliseconds()
+}
@@ -0,0 +1,79 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestAppendCaptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	captions := NewCaptions(db, unreachableRedis())
+	sessionID := uuid.New()
+	started := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	segment := func(id string, at time.Duration, text string) CaptionInput {
+		return CaptionInput{SegmentID: id, Speaker: "host", Lang: "uk", StartedAt: started.Add(at), EndedAt: started.Add(at + 2*time.Second), Text: text}
+	}
+
+	bad := segment("s-1", time.Second, "Привіт")
+	bad.EndedAt = bad.StartedAt.Add(-time.Second)
+	if _, err := captions.Append(context.Background(), sessionID, []CaptionInput{bad}); !errors.Is(err, ErrInvalidCaptions) {
+		t.Fatalf("expected segment ending before it starts to be rejected, got %v", err)
+	}
+
+	mock.ExpectQuery(`FROM live_sessions WHERE id`).WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"started_at", "ended"}).AddRow(started, false))
+	mock.ExpectQuery(`INSERT INTO live_caption_segments`).
+		WithArgs(sessionID, "s-1", "host", "uk", int64(1000), int64(3000), "Привіт").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO live_caption_segments`).
+		WithArgs(sessionID, "s-2", "host", "uk", int64(4000), int64(6000), "Почнемо").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(7)))
+
+	stored, err := captions.Append(context.Background(), sessionID, []CaptionInput{
+		segment("s-1", time.Second, "Привіт"),
+		segment(" s-2 ", 4*time.Second, " Почнемо "),
+	})
+	if err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if len(stored) != 1 || stored[0].ID != 7 || stored[0].SegmentID != "s-2" {
+		t.Fatalf("expected only the new segment to be stored, got %+v", stored)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestAppendCaptionsToEndedSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	captions := NewCaptions(db, unreachableRedis())
+	sessionID := uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery(`FROM live_sessions WHERE id`).WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"started_at", "ended"}).AddRow(now.Add(-time.Hour), true))
+	_, err = captions.Append(context.Background(), sessionID, []CaptionInput{
+		{SegmentID: "s-1", Lang: "uk", StartedAt: now, EndedAt: now, Text: "Бувайте"},
+	})
+	if !errors.Is(err, ErrSessionEnded) {
+		t.Fatalf("expected ended session to be refused, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
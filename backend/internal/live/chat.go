@@ -0,0 +1,310 @@
+package live
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// ChatKind is the kind of a live chat entry.
+type ChatKind string
+
+const (
+	ChatMessage  ChatKind = "message"
+	ChatQuestion ChatKind = "question"
+	ChatReaction ChatKind = "reaction"
+)
+
+// Chat event types published to subscribers.
+const (
+	ChatEventMessage  = "message"
+	ChatEventPinned   = "pinned"
+	ChatEventUnpinned = "unpinned"
+	ChatEventAnswered = "answered"
+)
+
+const (
+	maxChatRunes     = 500
+	maxReactionRunes = 16
+	// DefaultChatHistory is how many entries History returns by default.
+	DefaultChatHistory = 100
+)
+
+var (
+	// ErrInvalidChat is returned for empty or oversized entries and unknown
+	// kinds.
+	ErrInvalidChat = errors.New("invalid chat entry")
+	// ErrChatNotFound is returned when moderating an entry that does not
+	// exist in the session or cannot take the action.
+	ErrChatNotFound = errors.New("chat entry not found")
+)
+
+// ChatEntry is a message, question or reaction posted in a live room.
+// OffsetMs places it on the replay timeline.
+type ChatEntry struct {
+	ID         uuid.UUID  `json:"id"`
+	SessionID  uuid.UUID  `json:"session_id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	AuthorName string     `json:"author_name"`
+	Kind       ChatKind   `json:"kind"`
+	Body       string     `json:"body"`
+	OffsetMs   int64      `json:"offset_ms"`
+	PinnedAt   *time.Time `json:"pinned_at,omitempty"`
+	AnsweredAt *time.Time `json:"answered_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ChatEvent is what subscribers of a session's chat receive.
+type ChatEvent struct {
+	Type  string    `json:"type"`
+	Entry ChatEntry `json:"entry"`
+}
+
+// Chat persists live chat and fans it out to subscribers over Redis pub/sub,
+// so every API instance can serve every room.
+type Chat struct {
+	DB    *sql.DB
+	Redis *redis.Client
+	now   func() time.Time
+}
+
+// NewChat constructs a live chat.
+func NewChat(db *sql.DB, rdb *redis.Client) *Chat {
+	return &Chat{DB: db, Redis: rdb, now: time.Now}
+}
+
+// ChatChannel is the pub/sub channel of a session's chat.
+func ChatChannel(sessionID uuid.UUID) string {
+	return fmt.Sprintf("live:chat:%s", sessionID)
+}
+
+// ParseChatKind validates a chat kind; empty means a plain message.
+func ParseChatKind(raw string) (ChatKind, bool) {
+	switch kind := ChatKind(raw); kind {
+	case "":
+		return ChatMessage, true
+	case ChatMessage, ChatQuestion, ChatReaction:
+		return kind, true
+	}
+	return "", false
+}
+
+// Post stores an entry from a participant of a live session and publishes
+// it. Flagged entries are stored for moderators but not published.
+func (c *Chat) Post(ctx context.Context, sessionID, userID uuid.UUID, kind ChatKind, body string, flagged bool) (ChatEntry, error) {
+	body = strings.TrimSpace(body)
+	limit := maxChatRunes
+	if kind == ChatReaction {
+		limit = maxReactionRunes
+	}
+	if _, ok := ParseChatKind(string(kind)); !ok || body == "" || len([]rune(body)) > limit {
+		return ChatEntry{}, ErrInvalidChat
+	}
+
+	var (
+		joined, removed, ended bool
+		startedAt              sql.NullTime
+	)
+	err := c.DB.QueryRowContext(ctx, `
+SELECT lp.user_id IS NOT NULL, lp.removed_at IS NOT NULL, ls.ended_at IS NOT NULL, ls.started_at
+  FROM live_sessions ls
+  LEFT JOIN live_participants lp ON lp.session_id = ls.id AND lp.user_id = $2
+ WHERE ls.id = $1`, sessionID, userID).Scan(&joined, &removed, &ended, &startedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) || ended:
+		return ChatEntry{}, ErrSessionEnded
+	case err != nil:
+		return ChatEntry{}, fmt.Errorf("lookup participant: %w", err)
+	case !joined:
+		return ChatEntry{}, ErrNotParticipant
+	case removed:
+		return ChatEntry{}, ErrRemoved
+	}
+
+	now := c.now().UTC()
+	var offset int64
+	if startedAt.Valid && now.After(startedAt.Time) {
+		offset = now.Sub(startedAt.Time).Milliseconds()
+	}
+	entry := ChatEntry{SessionID: sessionID, UserID: userID, Kind: kind, Body: body, OffsetMs: offset, CreatedAt: now}
+	err = c.DB.QueryRowContext(ctx, `
+WITH inserted AS (
+  INSERT INTO live_chat_messages (session_id, user_id, kind, body, offset_ms, flagged, created_at)
+  VALUES ($1, $2, $3, $4, $5, $6, $7)
+  RETURNING id, user_id
+)
+SELECT i.id, COALESCE(NULLIF(u.display_name, ''), split_part(u.email, '@', 1))
+  FROM inserted i
+  JOIN users u ON u.id = i.user_id`,
+		sessionID, userID, string(kind), body, offset, flagged, now).Scan(&entry.ID, &entry.AuthorName)
+	if err != nil {
+		return ChatEntry{}, fmt.Errorf("store chat entry: %w", err)
+	}
+	if !flagged {
+		// Subscribers that miss the event catch up from History.
+		_ = c.publish(ctx, ChatEventMessage, entry)
+	}
+	return entry, nil
+}
+
+// Pin pins or unpins a message or question. Only moderators and above may
+// pin.
+func (c *Chat) Pin(ctx context.Context, sessionID, actorID, entryID uuid.UUID, pinned bool) (ChatEntry, error) {
+	if err := c.moderator(ctx, sessionID, actorID); err != nil {
+		return ChatEntry{}, err
+	}
+	entry, err := c.update(ctx, `
+UPDATE live_chat_messages m
+   SET pinned_at = CASE WHEN $3 THEN $4::timestamptz END
+  FROM users u
+ WHERE m.id = $1 AND m.session_id = $2 AND u.id = m.user_id
+   AND NOT m.flagged AND m.kind <> 'reaction'
+RETURNING `+chatColumns, entryID, sessionID, pinned, c.now().UTC())
+	if err != nil {
+		return ChatEntry{}, err
+	}
+	event := ChatEventPinned
+	if !pinned {
+		event = ChatEventUnpinned
+	}
+	_ = c.publish(ctx, event, entry)
+	return entry, nil
+}
+
+// Answer marks a question as answered. Only moderators and above may answer.
+func (c *Chat) Answer(ctx context.Context, sessionID, actorID, entryID uuid.UUID) (ChatEntry, error) {
+	if err := c.moderator(ctx, sessionID, actorID); err != nil {
+		return ChatEntry{}, err
+	}
+	entry, err := c.update(ctx, `
+UPDATE live_chat_messages m
+   SET answered_at = COALESCE(m.answered_at, $3)
+  FROM users u
+ WHERE m.id = $1 AND m.session_id = $2 AND u.id = m.user_id
+   AND NOT m.flagged AND m.kind = 'question'
+RETURNING `+chatColumns, entryID, sessionID, c.now().UTC())
+	if err != nil {
+		return ChatEntry{}, err
+	}
+	_ = c.publish(ctx, ChatEventAnswered, entry)
+	return entry, nil
+}
+
+// History returns published entries in posting order, starting after the
+// entry after when it is set. It serves both reconnecting subscribers and
+// the replay timeline of ended sessions.
+func (c *Chat) History(ctx context.Context, sessionID uuid.UUID, after *uuid.UUID, limit int) ([]ChatEntry, error) {
+	if limit <= 0 {
+		limit = DefaultChatHistory
+	}
+	var afterID uuid.NullUUID
+	if after != nil {
+		afterID = uuid.NullUUID{UUID: *after, Valid: true}
+	}
+	rows, err := c.DB.QueryContext(ctx, `
+SELECT `+chatColumns+`
+  FROM live_chat_messages m
+  JOIN users u ON u.id = m.user_id
+ WHERE m.session_id = $1 AND NOT m.flagged
+   AND ($2::uuid IS NULL OR (m.created_at, m.id) > (
+     SELECT created_at, id FROM live_chat_messages WHERE id = $2))
+ ORDER BY m.created_at, m.id
+ LIMIT $3`, sessionID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("chat history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []ChatEntry{}
+	for rows.Next() {
+		entry, err := scanChatEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Subscribe listens to a session's chat events. Callers close the
+// subscription.
+func (c *Chat) Subscribe(ctx context.Context, sessionID uuid.UUID) *redis.PubSub {
+	return c.Redis.Subscribe(ctx, ChatChannel(sessionID))
+}
+
+const chatColumns = `m.id, m.session_id, m.user_id,
+       COALESCE(NULLIF(u.display_name, ''), split_part(u.email, '@', 1)),
+       m.kind, m.body, m.offset_ms, m.pinned_at, m.answered_at, m.created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanChatEntry(row rowScanner) (ChatEntry, error) {
+	var (
+		entry            ChatEntry
+		pinned, answered sql.NullTime
+	)
+	if err := row.Scan(&entry.ID, &entry.SessionID, &entry.UserID, &entry.AuthorName, &entry.Kind,
+		&entry.Body, &entry.OffsetMs, &pinned, &answered, &entry.CreatedAt); err != nil {
+		return ChatEntry{}, err
+	}
+	if pinned.Valid {
+		entry.PinnedAt = &pinned.Time
+	}
+	if answered.Valid {
+		entry.AnsweredAt = &answered.Time
+	}
+	return entry, nil
+}
+
+func (c *Chat) update(ctx context.Context, query string, args ...any) (ChatEntry, error) {
+	entry, err := scanChatEntry(c.DB.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ChatEntry{}, ErrChatNotFound
+	}
+	if err != nil {
+		return ChatEntry{}, fmt.Errorf("update chat entry: %w", err)
+	}
+	return entry, nil
+}
+
+// moderator checks that actorID moderates the live session.
+func (c *Chat) moderator(ctx context.Context, sessionID, actorID uuid.UUID) error {
+	var (
+		role    Role
+		removed bool
+		ended   bool
+	)
+	err := c.DB.QueryRowContext(ctx, `
+SELECT lp.role, lp.removed_at IS NOT NULL, ls.ended_at IS NOT NULL
+  FROM live_participants lp
+  JOIN live_sessions ls ON ls.id = lp.session_id
+ WHERE lp.session_id = $1 AND lp.user_id = $2`, sessionID, actorID).Scan(&role, &removed, &ended)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrForbidden
+	case err != nil:
+		return fmt.Errorf("lookup participant: %w", err)
+	case ended:
+		return ErrSessionEnded
+	case removed || !role.Moderates():
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (c *Chat) publish(ctx context.Context, eventType string, entry ChatEntry) error {
+	payload, err := json.Marshal(ChatEvent{Type: eventType, Entry: entry})
+	if err != nil {
+		return err
+	}
+	return c.Redis.Publish(ctx, ChatChannel(entry.SessionID), payload).Err()
+}
@@ -0,0 +1,78 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestPostChat(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	chat := NewChat(db, unreachableRedis())
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	chat.now = func() time.Time { return now }
+	sessionID, userID, entryID := uuid.New(), uuid.New(), uuid.New()
+	participant := []string{"joined", "removed", "ended", "started_at"}
+
+	if _, err := chat.Post(context.Background(), sessionID, userID, ChatReaction, "this is not an emoji at all", false); !errors.Is(err, ErrInvalidChat) {
+		t.Fatalf("expected oversized reaction to be rejected, got %v", err)
+	}
+
+	mock.ExpectQuery(`LEFT JOIN live_participants`).WithArgs(sessionID, userID).
+		WillReturnRows(sqlmock.NewRows(participant).AddRow(false, false, false, now.Add(-time.Minute)))
+	if _, err := chat.Post(context.Background(), sessionID, userID, ChatMessage, "hello", false); !errors.Is(err, ErrNotParticipant) {
+		t.Fatalf("expected non-participant to be refused, got %v", err)
+	}
+
+	mock.ExpectQuery(`LEFT JOIN live_participants`).WithArgs(sessionID, userID).
+		WillReturnRows(sqlmock.NewRows(participant).AddRow(true, true, false, now.Add(-time.Minute)))
+	if _, err := chat.Post(context.Background(), sessionID, userID, ChatMessage, "hello", false); !errors.Is(err, ErrRemoved) {
+		t.Fatalf("expected removed participant to be refused, got %v", err)
+	}
+
+	mock.ExpectQuery(`LEFT JOIN live_participants`).WithArgs(sessionID, userID).
+		WillReturnRows(sqlmock.NewRows(participant).AddRow(true, false, false, now.Add(-90*time.Second)))
+	mock.ExpectQuery(`INSERT INTO live_chat_messages`).
+		WithArgs(sessionID, userID, "question", "When is the next episode?", int64(90000), false, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "author_name"}).AddRow(entryID, "Olena"))
+	entry, err := chat.Post(context.Background(), sessionID, userID, ChatQuestion, "  When is the next episode?  ", false)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	if entry.ID != entryID || entry.OffsetMs != 90000 || entry.AuthorName != "Olena" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestPinRequiresModerator(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	chat := NewChat(db, unreachableRedis())
+	sessionID, speakerID, entryID := uuid.New(), uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`FROM live_participants lp`).WithArgs(sessionID, speakerID).
+		WillReturnRows(sqlmock.NewRows([]string{"role", "removed", "ended"}).AddRow("speaker", false, false))
+
+	if _, err := chat.Pin(context.Background(), sessionID, speakerID, entryID, true); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected speakers to be refused, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
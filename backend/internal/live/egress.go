@@ -0,0 +1,109 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	livekitauth "github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/twitchtv/twirp"
+)
+
+// Recording is a server-side recording of a live session.
+type Recording struct {
+	EgressID string
+	Key      string
+}
+
+// Recorder starts and stops server-side recordings of live rooms.
+type Recorder interface {
+	Start(ctx context.Context, sessionID uuid.UUID) (Recording, error)
+	// Stop ends a recording. Stopping one that already ended is not an
+	// error.
+	Stop(ctx context.Context, egressID string) error
+}
+
+// RecordingKey is the storage key a session is recorded to. It is derived
+// from the session alone so clients cannot point finalization elsewhere.
+func RecordingKey(sessionID uuid.UUID) string {
+	return fmt.Sprintf("live/%s/recording.ogg", sessionID)
+}
+
+// EgressConfig configures LiveKit egress recording.
+type EgressConfig struct {
+	// URL is the LiveKit server URL; ws(s) schemes are accepted.
+	URL       string
+	APIKey    string
+	APISecret string
+	// Upload is where egress writes recordings. When nil, the egress
+	// service's own storage configuration is used.
+	Upload     *livekit.S3Upload
+	HTTPClient *http.Client
+}
+
+// EgressRecorder records rooms with LiveKit room-composite audio egress.
+type EgressRecorder struct {
+	client livekit.Egress
+	api    serverAPI
+	upload *livekit.S3Upload
+}
+
+// NewEgressRecorder constructs a recorder talking to the LiveKit server API.
+func NewEgressRecorder(cfg EgressConfig) (*EgressRecorder, error) {
+	if cfg.URL == "" || cfg.APIKey == "" || cfg.APISecret == "" {
+		return nil, errors.New("livekit url and api credentials are required")
+	}
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &EgressRecorder{
+		client: livekit.NewEgressProtobufClient(httpURL(cfg.URL), httpClient),
+		api:    serverAPI{apiKey: cfg.APIKey, apiSecret: cfg.APISecret},
+		upload: cfg.Upload,
+	}, nil
+}
+
+func (e *EgressRecorder) Start(ctx context.Context, sessionID uuid.UUID) (Recording, error) {
+	ctx, err := e.api.authorize(ctx, &livekitauth.VideoGrant{RoomRecord: true, Room: RoomName(sessionID)})
+	if err != nil {
+		return Recording{}, err
+	}
+	key := RecordingKey(sessionID)
+	output := &livekit.EncodedFileOutput{
+		FileType:        livekit.EncodedFileType_OGG,
+		Filepath:        key,
+		DisableManifest: true,
+	}
+	if e.upload != nil {
+		output.Output = &livekit.EncodedFileOutput_S3{S3: e.upload}
+	}
+	info, err := e.client.StartRoomCompositeEgress(ctx, &livekit.RoomCompositeEgressRequest{
+		RoomName:    RoomName(sessionID),
+		AudioOnly:   true,
+		FileOutputs: []*livekit.EncodedFileOutput{output},
+	})
+	if err != nil {
+		return Recording{}, fmt.Errorf("start egress: %w", err)
+	}
+	return Recording{EgressID: info.GetEgressId(), Key: key}, nil
+}
+
+func (e *EgressRecorder) Stop(ctx context.Context, egressID string) error {
+	ctx, err := e.api.authorize(ctx, &livekitauth.VideoGrant{RoomRecord: true})
+	if err != nil {
+		return err
+	}
+	_, err = e.client.StopEgress(ctx, &livekit.StopEgressRequest{EgressId: egressID})
+	if isTwirpCode(err, twirp.NotFound, twirp.FailedPrecondition) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stop egress: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package live
+
+import "github.com/livekit/protocol/livekit"
+
+// Role is a participant's role in a live room.
+type Role string
+
+const (
+	RoleHost      Role = "host"
+	RoleCohost    Role = "cohost"
+	RoleModerator Role = "moderator"
+	RoleSpeaker   Role = "speaker"
+	RoleListener  Role = "listener"
+)
+
+// roleRank orders roles by authority. A participant may only act on roles
+// ranked below their own.
+var roleRank = map[Role]int{
+	RoleListener:  0,
+	RoleSpeaker:   1,
+	RoleModerator: 2,
+	RoleCohost:    3,
+	RoleHost:      4,
+}
+
+// ParseRole validates a role name.
+func ParseRole(raw string) (Role, bool) {
+	role := Role(raw)
+	_, ok := roleRank[role]
+	return role, ok
+}
+
+// OnStage reports whether the role may publish audio.
+func (r Role) OnStage() bool {
+	return r == RoleHost || r == RoleCohost || r == RoleSpeaker
+}
+
+// Permission is the LiveKit participant permission granted to the role.
+func (r Role) Permission() *livekit.ParticipantPermission {
+	perm := &livekit.ParticipantPermission{
+		CanSubscribe:   true,
+		CanPublishData: true,
+	}
+	if r.OnStage() {
+		perm.CanPublish = true
+		perm.CanPublishSources = []livekit.TrackSource{livekit.TrackSource_MICROPHONE}
+	}
+	return perm
+}
+
+// Moderates reports whether the role may manage participants and chat.
+func (r Role) Moderates() bool {
+	return roleRank[r] >= roleRank[RoleModerator]
+}
+
+// CanManage reports whether actor may mute, remove or change the role of a
+// participant holding target. Only moderators and above manage others.
+func CanManage(actor, target Role) bool {
+	return actor.Moderates() && roleRank[actor] > roleRank[target]
+}
+
+// CanAssign reports whether actor may give a participant holding target the
+// role to. Nobody can make someone else host.
+func CanAssign(actor, target, to Role) bool {
+	return to != RoleHost && CanManage(actor, target) && roleRank[actor] > roleRank[to]
+}
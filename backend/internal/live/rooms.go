@@ -0,0 +1,104 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	livekitauth "github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/twitchtv/twirp"
+)
+
+// RoomAdmin applies roster changes to participants connected to a room.
+// Participants that are not connected are skipped; they pick up their role
+// with their next token.
+type RoomAdmin interface {
+	UpdateParticipant(ctx context.Context, room, identity string, perm *livekit.ParticipantPermission, attributes map[string]string) error
+	MuteParticipant(ctx context.Context, room, identity string) error
+	RemoveParticipant(ctx context.Context, room, identity string) error
+}
+
+// LiveKitRooms administers rooms through the LiveKit room service.
+type LiveKitRooms struct {
+	client livekit.RoomService
+	api    serverAPI
+}
+
+// NewLiveKitRooms constructs a room administrator for a LiveKit server.
+// url may use ws(s) schemes.
+func NewLiveKitRooms(url, apiKey, apiSecret string, httpClient *http.Client) (*LiveKitRooms, error) {
+	if url == "" || apiKey == "" || apiSecret == "" {
+		return nil, errors.New("livekit url and api credentials are required")
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &LiveKitRooms{
+		client: livekit.NewRoomServiceProtobufClient(httpURL(url), httpClient),
+		api:    serverAPI{apiKey: apiKey, apiSecret: apiSecret},
+	}, nil
+}
+
+func (l *LiveKitRooms) UpdateParticipant(ctx context.Context, room, identity string, perm *livekit.ParticipantPermission, attributes map[string]string) error {
+	ctx, err := l.admin(ctx, room)
+	if err != nil {
+		return err
+	}
+	_, err = l.client.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
+		Room:       room,
+		Identity:   identity,
+		Permission: perm,
+		Attributes: attributes,
+	})
+	return ignoreAbsent(err, "update participant")
+}
+
+func (l *LiveKitRooms) MuteParticipant(ctx context.Context, room, identity string) error {
+	ctx, err := l.admin(ctx, room)
+	if err != nil {
+		return err
+	}
+	info, err := l.client.GetParticipant(ctx, &livekit.RoomParticipantIdentity{Room: room, Identity: identity})
+	if err != nil {
+		return ignoreAbsent(err, "get participant")
+	}
+	for _, track := range info.GetTracks() {
+		if track.GetType() != livekit.TrackType_AUDIO || track.GetMuted() {
+			continue
+		}
+		if _, err := l.client.MutePublishedTrack(ctx, &livekit.MuteRoomTrackRequest{
+			Room:     room,
+			Identity: identity,
+			TrackSid: track.GetSid(),
+			Muted:    true,
+		}); err != nil {
+			return ignoreAbsent(err, "mute track")
+		}
+	}
+	return nil
+}
+
+func (l *LiveKitRooms) RemoveParticipant(ctx context.Context, room, identity string) error {
+	ctx, err := l.admin(ctx, room)
+	if err != nil {
+		return err
+	}
+	_, err = l.client.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{Room: room, Identity: identity})
+	return ignoreAbsent(err, "remove participant")
+}
+
+func (l *LiveKitRooms) admin(ctx context.Context, room string) (context.Context, error) {
+	return l.api.authorize(ctx, &livekitauth.VideoGrant{RoomAdmin: true, Room: room})
+}
+
+// ignoreAbsent drops errors for rooms or participants that are not
+// connected.
+func ignoreAbsent(err error, op string) error {
+	if err == nil || isTwirpCode(err, twirp.NotFound) {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
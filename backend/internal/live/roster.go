@@ -0,0 +1,342 @@
+package live
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/livekit/protocol/livekit"
+)
+
+var (
+	// ErrNotParticipant is returned when acting on a user who never joined
+	// the session.
+	ErrNotParticipant = errors.New("user is not a participant of this session")
+	// ErrRemoved is returned for users removed from the session.
+	ErrRemoved = errors.New("user was removed from this session")
+	// ErrForbidden is returned when the acting participant's role does not
+	// allow the change.
+	ErrForbidden = errors.New("role does not allow this action")
+	// ErrInvalidRole is returned for unknown roles and for actions that do
+	// not apply to the participant's role.
+	ErrInvalidRole = errors.New("invalid role for this action")
+)
+
+// Participant is a user's entry in a session roster.
+type Participant struct {
+	UserID       uuid.UUID  `json:"user_id"`
+	Role         Role       `json:"role"`
+	HandRaisedAt *time.Time `json:"hand_raised_at,omitempty"`
+	Muted        bool       `json:"muted"`
+}
+
+// Permission is the LiveKit permission for the participant. Muted
+// participants keep their role but lose the microphone until a moderator
+// assigns them a role again.
+func (p Participant) Permission() *livekit.ParticipantPermission {
+	perm := p.Role.Permission()
+	if p.Muted {
+		perm.CanPublish = false
+		perm.CanPublishSources = nil
+	}
+	return perm
+}
+
+// Attributes are the participant attributes other room members see.
+func (p Participant) Attributes() map[string]string {
+	return map[string]string{
+		"role":        string(p.Role),
+		"hand_raised": strconv.FormatBool(p.HandRaisedAt != nil),
+		"muted":       strconv.FormatBool(p.Muted),
+	}
+}
+
+// Roster keeps live_participants and the participants connected to the room
+// in step. Room changes are applied before the roster commits, so a failed
+// LiveKit call leaves the roster untouched.
+type Roster struct {
+	DB *sql.DB
+	// Rooms applies changes to connected participants. Nil only updates the
+	// roster; participants pick changes up with their next token.
+	Rooms RoomAdmin
+	now   func() time.Time
+}
+
+// NewRoster constructs a roster.
+func NewRoster(db *sql.DB, rooms RoomAdmin) *Roster {
+	return &Roster{DB: db, Rooms: rooms, now: time.Now}
+}
+
+// Join records userID in a live session and returns their entry. The
+// session host always joins as host; everyone else joins as listener unless
+// they were given a role earlier.
+func (r *Roster) Join(ctx context.Context, sessionID, userID uuid.UUID) (Participant, error) {
+	var (
+		hostID uuid.NullUUID
+		ended  bool
+	)
+	err := r.DB.QueryRowContext(ctx, `
+SELECT host_id, ended_at IS NOT NULL FROM live_sessions WHERE id = $1`, sessionID).Scan(&hostID, &ended)
+	if errors.Is(err, sql.ErrNoRows) || ended {
+		return Participant{}, ErrSessionEnded
+	}
+	if err != nil {
+		return Participant{}, fmt.Errorf("lookup session: %w", err)
+	}
+
+	role := RoleListener
+	if hostID.Valid && hostID.UUID == userID {
+		role = RoleHost
+	}
+	p := Participant{UserID: userID}
+	var (
+		handRaised sql.NullTime
+		removed    bool
+	)
+	err = r.DB.QueryRowContext(ctx, `
+INSERT INTO live_participants (session_id, user_id, role, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $4)
+ON CONFLICT (session_id, user_id) DO UPDATE SET updated_at = EXCLUDED.updated_at
+RETURNING role, hand_raised_at, muted, removed_at IS NOT NULL`,
+		sessionID, userID, string(role), r.now().UTC()).Scan(&p.Role, &handRaised, &p.Muted, &removed)
+	if err != nil {
+		return Participant{}, fmt.Errorf("join session: %w", err)
+	}
+	if removed {
+		return Participant{}, ErrRemoved
+	}
+	if handRaised.Valid {
+		p.HandRaisedAt = &handRaised.Time
+	}
+	return p, nil
+}
+
+// SetHand raises or lowers a listener's hand to ask for the stage.
+func (r *Roster) SetHand(ctx context.Context, sessionID, userID uuid.UUID, raised bool) (Participant, error) {
+	var out Participant
+	err := r.change(ctx, func(tx *sql.Tx) error {
+		p, err := r.member(ctx, tx, sessionID, userID)
+		if err != nil {
+			return err
+		}
+		if raised && p.Role != RoleListener {
+			return ErrInvalidRole
+		}
+		var handRaised sql.NullTime
+		err = tx.QueryRowContext(ctx, `
+UPDATE live_participants
+   SET hand_raised_at = CASE WHEN $3 THEN COALESCE(hand_raised_at, $4) END,
+       updated_at = $4
+ WHERE session_id = $1 AND user_id = $2
+RETURNING hand_raised_at`, sessionID, userID, raised, r.now().UTC()).Scan(&handRaised)
+		if err != nil {
+			return fmt.Errorf("update hand: %w", err)
+		}
+		p.HandRaisedAt = nil
+		if handRaised.Valid {
+			p.HandRaisedAt = &handRaised.Time
+		}
+		out = p
+		return r.update(ctx, sessionID, p)
+	})
+	return out, err
+}
+
+// SetRole promotes or demotes target. The actor must outrank both the
+// target's current role and the new one. A role change lowers the target's
+// hand and lifts a mute.
+func (r *Roster) SetRole(ctx context.Context, sessionID, actorID, targetID uuid.UUID, role Role) (Participant, error) {
+	if _, ok := ParseRole(string(role)); !ok || role == RoleHost {
+		return Participant{}, ErrInvalidRole
+	}
+	var out Participant
+	err := r.change(ctx, func(tx *sql.Tx) error {
+		actor, target, err := r.pair(ctx, tx, sessionID, actorID, targetID)
+		if err != nil {
+			return err
+		}
+		if !CanAssign(actor.Role, target.Role, role) {
+			return ErrForbidden
+		}
+		if _, err := tx.ExecContext(ctx, `
+UPDATE live_participants
+   SET role = $3, hand_raised_at = NULL, muted = FALSE, updated_at = $4
+ WHERE session_id = $1 AND user_id = $2`, sessionID, targetID, string(role), r.now().UTC()); err != nil {
+			return fmt.Errorf("update role: %w", err)
+		}
+		out = Participant{UserID: targetID, Role: role}
+		return r.update(ctx, sessionID, out)
+	})
+	return out, err
+}
+
+// Mute silences target's microphone and revokes publishing until they are
+// assigned a role again.
+func (r *Roster) Mute(ctx context.Context, sessionID, actorID, targetID uuid.UUID) (Participant, error) {
+	var out Participant
+	err := r.change(ctx, func(tx *sql.Tx) error {
+		actor, target, err := r.pair(ctx, tx, sessionID, actorID, targetID)
+		if err != nil {
+			return err
+		}
+		if !CanManage(actor.Role, target.Role) {
+			return ErrForbidden
+		}
+		if _, err := tx.ExecContext(ctx, `
+UPDATE live_participants SET muted = TRUE, updated_at = $3
+ WHERE session_id = $1 AND user_id = $2`, sessionID, targetID, r.now().UTC()); err != nil {
+			return fmt.Errorf("mute participant: %w", err)
+		}
+		target.Muted = true
+		out = target
+		if r.Rooms == nil {
+			return nil
+		}
+		if err := r.update(ctx, sessionID, target); err != nil {
+			return err
+		}
+		return r.Rooms.MuteParticipant(ctx, RoomName(sessionID), targetID.String())
+	})
+	return out, err
+}
+
+// Remove kicks target out of the room. Removed users cannot rejoin the
+// session.
+func (r *Roster) Remove(ctx context.Context, sessionID, actorID, targetID uuid.UUID) error {
+	return r.change(ctx, func(tx *sql.Tx) error {
+		actor, target, err := r.pair(ctx, tx, sessionID, actorID, targetID)
+		if err != nil {
+			return err
+		}
+		if !CanManage(actor.Role, target.Role) {
+			return ErrForbidden
+		}
+		if _, err := tx.ExecContext(ctx, `
+UPDATE live_participants
+   SET removed_at = $3, hand_raised_at = NULL, updated_at = $3
+ WHERE session_id = $1 AND user_id = $2`, sessionID, targetID, r.now().UTC()); err != nil {
+			return fmt.Errorf("remove participant: %w", err)
+		}
+		if r.Rooms == nil {
+			return nil
+		}
+		return r.Rooms.RemoveParticipant(ctx, RoomName(sessionID), targetID.String())
+	})
+}
+
+// List returns the session's participants, raised hands first in the order
+// they were raised. Only the host and participants who were not removed may
+// see the roster; anyone else gets ErrForbidden.
+func (r *Roster) List(ctx context.Context, sessionID, viewerID uuid.UUID) ([]Participant, error) {
+	var allowed bool
+	err := r.DB.QueryRowContext(ctx, `
+SELECT EXISTS (SELECT 1 FROM live_sessions WHERE id = $1 AND host_id = $2)
+    OR EXISTS (SELECT 1 FROM live_participants WHERE session_id = $1 AND user_id = $2 AND removed_at IS NULL)`,
+		sessionID, viewerID).Scan(&allowed)
+	if err != nil {
+		return nil, fmt.Errorf("check participant: %w", err)
+	}
+	if !allowed {
+		return nil, ErrForbidden
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT user_id, role, hand_raised_at, muted
+  FROM live_participants
+ WHERE session_id = $1 AND removed_at IS NULL
+ ORDER BY hand_raised_at NULLS LAST, created_at`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("list participants: %w", err)
+	}
+	defer rows.Close()
+
+	participants := []Participant{}
+	for rows.Next() {
+		var (
+			p          Participant
+			handRaised sql.NullTime
+		)
+		if err := rows.Scan(&p.UserID, &p.Role, &handRaised, &p.Muted); err != nil {
+			return nil, err
+		}
+		if handRaised.Valid {
+			p.HandRaisedAt = &handRaised.Time
+		}
+		participants = append(participants, p)
+	}
+	return participants, rows.Err()
+}
+
+// change runs fn in a transaction that commits only if fn succeeds.
+func (r *Roster) change(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// pair loads the acting and target participants, locking the target.
+func (r *Roster) pair(ctx context.Context, tx *sql.Tx, sessionID, actorID, targetID uuid.UUID) (Participant, Participant, error) {
+	if actorID == targetID {
+		return Participant{}, Participant{}, ErrForbidden
+	}
+	actor, err := r.member(ctx, tx, sessionID, actorID)
+	if errors.Is(err, ErrNotParticipant) || errors.Is(err, ErrRemoved) {
+		return Participant{}, Participant{}, ErrForbidden
+	}
+	if err != nil {
+		return Participant{}, Participant{}, err
+	}
+	target, err := r.member(ctx, tx, sessionID, targetID)
+	if err != nil {
+		return Participant{}, Participant{}, err
+	}
+	return actor, target, nil
+}
+
+// member loads and locks a participant of a live session.
+func (r *Roster) member(ctx context.Context, tx *sql.Tx, sessionID, userID uuid.UUID) (Participant, error) {
+	p := Participant{UserID: userID}
+	var (
+		handRaised sql.NullTime
+		removed    bool
+		ended      bool
+	)
+	err := tx.QueryRowContext(ctx, `
+SELECT lp.role, lp.hand_raised_at, lp.muted, lp.removed_at IS NOT NULL, ls.ended_at IS NOT NULL
+  FROM live_participants lp
+  JOIN live_sessions ls ON ls.id = lp.session_id
+ WHERE lp.session_id = $1 AND lp.user_id = $2
+   FOR UPDATE OF lp`, sessionID, userID).Scan(&p.Role, &handRaised, &p.Muted, &removed, &ended)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return Participant{}, ErrNotParticipant
+	case err != nil:
+		return Participant{}, fmt.Errorf("lookup participant: %w", err)
+	case ended:
+		return Participant{}, ErrSessionEnded
+	case removed:
+		return Participant{}, ErrRemoved
+	}
+	if handRaised.Valid {
+		p.HandRaisedAt = &handRaised.Time
+	}
+	return p, nil
+}
+
+// update pushes p's permission and attributes to the room.
+func (r *Roster) update(ctx context.Context, sessionID uuid.UUID, p Participant) error {
+	if r.Rooms == nil {
+		return nil
+	}
+	return r.Rooms.UpdateParticipant(ctx, RoomName(sessionID), p.UserID.String(), p.Permission(), p.Attributes())
+}
@@ -0,0 +1,181 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/livekit/protocol/livekit"
+)
+
+// fakeRooms records the room changes a roster makes.
+type fakeRooms struct {
+	updated []*livekit.ParticipantPermission
+	muted   []string
+	removed []string
+}
+
+func (f *fakeRooms) UpdateParticipant(_ context.Context, _, _ string, perm *livekit.ParticipantPermission, _ map[string]string) error {
+	f.updated = append(f.updated, perm)
+	return nil
+}
+
+func (f *fakeRooms) MuteParticipant(_ context.Context, _, identity string) error {
+	f.muted = append(f.muted, identity)
+	return nil
+}
+
+func (f *fakeRooms) RemoveParticipant(_ context.Context, _, identity string) error {
+	f.removed = append(f.removed, identity)
+	return nil
+}
+
+func TestCanAssign(t *testing.T) {
+	cases := []struct {
+		actor, target, to Role
+		want              bool
+	}{
+		{RoleHost, RoleListener, RoleSpeaker, true},
+		{RoleHost, RoleSpeaker, RoleCohost, true},
+		{RoleHost, RoleCohost, RoleListener, true},
+		{RoleHost, RoleListener, RoleHost, false},
+		{RoleCohost, RoleListener, RoleModerator, true},
+		{RoleCohost, RoleListener, RoleCohost, false},
+		{RoleCohost, RoleCohost, RoleListener, false},
+		{RoleModerator, RoleListener, RoleSpeaker, true},
+		{RoleModerator, RoleSpeaker, RoleListener, true},
+		{RoleModerator, RoleModerator, RoleListener, false},
+		{RoleSpeaker, RoleListener, RoleSpeaker, false},
+		{RoleListener, RoleListener, RoleSpeaker, false},
+	}
+	for _, tc := range cases {
+		if got := CanAssign(tc.actor, tc.target, tc.to); got != tc.want {
+			t.Errorf("%s assigning %s -> %s: expected %v, got %v", tc.actor, tc.target, tc.to, tc.want, got)
+		}
+	}
+}
+
+func TestParticipantPermission(t *testing.T) {
+	if perm := (Participant{Role: RoleListener}).Permission(); perm.CanPublish {
+		t.Fatal("listeners must not publish")
+	}
+	perm := (Participant{Role: RoleSpeaker}).Permission()
+	if !perm.CanPublish || len(perm.CanPublishSources) != 1 || perm.CanPublishSources[0] != livekit.TrackSource_MICROPHONE {
+		t.Fatalf("expected speakers to publish their microphone, got %+v", perm)
+	}
+	if perm := (Participant{Role: RoleCohost, Muted: true}).Permission(); perm.CanPublish {
+		t.Fatal("muted participants must not publish")
+	}
+}
+
+var memberColumns = []string{"role", "hand_raised_at", "muted", "removed", "ended"}
+
+func expectMember(mock sqlmock.Sqlmock, sessionID, userID uuid.UUID, role Role) {
+	mock.ExpectQuery(`FROM live_participants lp`).WithArgs(sessionID, userID).
+		WillReturnRows(sqlmock.NewRows(memberColumns).AddRow(string(role), nil, false, false, false))
+}
+
+func TestJoin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	roster := NewRoster(db, nil)
+	sessionID, hostID, kicked := uuid.New(), uuid.New(), uuid.New()
+	joinColumns := []string{"role", "hand_raised_at", "muted", "removed"}
+
+	mock.ExpectQuery(`SELECT host_id`).WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"host_id", "ended"}).AddRow(hostID, false))
+	mock.ExpectQuery(`INSERT INTO live_participants`).WithArgs(sessionID, hostID, "host", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(joinColumns).AddRow("host", nil, false, false))
+	mock.ExpectQuery(`SELECT host_id`).WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"host_id", "ended"}).AddRow(hostID, false))
+	mock.ExpectQuery(`INSERT INTO live_participants`).WithArgs(sessionID, kicked, "listener", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(joinColumns).AddRow("speaker", nil, false, true))
+	mock.ExpectQuery(`SELECT host_id`).WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"host_id", "ended"}).AddRow(hostID, true))
+
+	if p, err := roster.Join(context.Background(), sessionID, hostID); err != nil || p.Role != RoleHost {
+		t.Fatalf("expected host, got %+v %v", p, err)
+	}
+	if _, err := roster.Join(context.Background(), sessionID, kicked); !errors.Is(err, ErrRemoved) {
+		t.Fatalf("expected removed user to be refused, got %v", err)
+	}
+	if _, err := roster.Join(context.Background(), sessionID, uuid.New()); !errors.Is(err, ErrSessionEnded) {
+		t.Fatalf("expected ended session, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestSetRolePromotesOnStage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	rooms := &fakeRooms{}
+	roster := NewRoster(db, rooms)
+	sessionID, hostID, listenerID := uuid.New(), uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	expectMember(mock, sessionID, hostID, RoleHost)
+	expectMember(mock, sessionID, listenerID, RoleListener)
+	mock.ExpectExec(`UPDATE live_participants`).WithArgs(sessionID, listenerID, "speaker", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	p, err := roster.SetRole(context.Background(), sessionID, hostID, listenerID, RoleSpeaker)
+	if err != nil || p.Role != RoleSpeaker {
+		t.Fatalf("expected speaker, got %+v %v", p, err)
+	}
+	if len(rooms.updated) != 1 || !rooms.updated[0].CanPublish {
+		t.Fatalf("expected the room to let the speaker publish, got %v", rooms.updated)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestManageRequiresHigherRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	rooms := &fakeRooms{}
+	roster := NewRoster(db, rooms)
+	sessionID, moderatorID, cohostID, listenerID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	expectMember(mock, sessionID, moderatorID, RoleModerator)
+	expectMember(mock, sessionID, cohostID, RoleCohost)
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	expectMember(mock, sessionID, moderatorID, RoleModerator)
+	expectMember(mock, sessionID, listenerID, RoleListener)
+	mock.ExpectExec(`SET removed_at`).WithArgs(sessionID, listenerID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := roster.Remove(context.Background(), sessionID, moderatorID, cohostID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected moderator to be refused removing a cohost, got %v", err)
+	}
+	if err := roster.Remove(context.Background(), sessionID, moderatorID, listenerID); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if len(rooms.removed) != 1 || rooms.removed[0] != listenerID.String() {
+		t.Fatalf("expected the listener to be removed from the room, got %v", rooms.removed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
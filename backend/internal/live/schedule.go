@@ -0,0 +1,370 @@
+package live
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/push"
+)
+
+const (
+	// DefaultReminderLead is how long before the start the first reminder
+	// goes out.
+	DefaultReminderLead = 15 * time.Minute
+	// MaxScheduleAhead bounds how far ahead a session can be announced.
+	MaxScheduleAhead = 90 * 24 * time.Hour
+	// scheduleGrace keeps a schedule listed, and its start reminder
+	// deliverable, for a while after its start time so a late host can still
+	// open the room.
+	scheduleGrace = time.Hour
+	reminderBatch = 100
+	reminderSoon  = "soon"
+	reminderStart = "start"
+)
+
+var (
+	// ErrScheduleNotFound is returned for schedules that do not exist, were
+	// canceled or have already started.
+	ErrScheduleNotFound = errors.New("scheduled live session not found")
+	// ErrInvalidSchedule is returned for start times in the past or too far
+	// ahead, and for schedules without a title.
+	ErrInvalidSchedule = errors.New("invalid scheduled live session")
+)
+
+// Schedule is a live session announced ahead of time.
+type Schedule struct {
+	ID          uuid.UUID  `json:"id"`
+	HostID      uuid.UUID  `json:"host_id"`
+	HostName    string     `json:"host_name"`
+	TopicID     *uuid.UUID `json:"topic_id,omitempty"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	RSVPs       int        `json:"rsvp_count"`
+	RSVPed      bool       `json:"rsvped"`
+}
+
+// NewSchedule describes a session to announce.
+type NewSchedule struct {
+	HostID      uuid.UUID
+	TopicID     *uuid.UUID
+	Title       string
+	Description string
+	ScheduledAt time.Time
+}
+
+// Schedules stores announced sessions and their RSVPs and sends start
+// reminders to RSVPs and the host's followers.
+type Schedules struct {
+	DB   *sql.DB
+	Push push.Sender
+	// Lead is how long before the start the first reminder goes out.
+	Lead time.Duration
+	now  func() time.Time
+}
+
+// NewSchedules constructs a schedule store. A zero lead uses
+// DefaultReminderLead.
+func NewSchedules(db *sql.DB, sender push.Sender, lead time.Duration) *Schedules {
+	if lead <= 0 {
+		lead = DefaultReminderLead
+	}
+	return &Schedules{DB: db, Push: sender, Lead: lead, now: time.Now}
+}
+
+// Create announces a session.
+func (s *Schedules) Create(ctx context.Context, in NewSchedule) (Schedule, error) {
+	now := s.now().UTC()
+	title := strings.TrimSpace(in.Title)
+	if title == "" || !in.ScheduledAt.After(now) || in.ScheduledAt.After(now.Add(MaxScheduleAhead)) {
+		return Schedule{}, ErrInvalidSchedule
+	}
+	sched := Schedule{
+		ID:          uuid.New(),
+		HostID:      in.HostID,
+		TopicID:     in.TopicID,
+		Title:       title,
+		Description: strings.TrimSpace(in.Description),
+		ScheduledAt: in.ScheduledAt.UTC(),
+	}
+	var topic any
+	if in.TopicID != nil {
+		topic = *in.TopicID
+	}
+	err := s.DB.QueryRowContext(ctx, `
+WITH inserted AS (
+  INSERT INTO live_schedules (id, host_id, topic_id, title, description, scheduled_at, created_at)
+  VALUES ($1, $2, $3, $4, $5, $6, $7)
+  RETURNING host_id
+)
+SELECT COALESCE(NULLIF(u.display_name, ''), split_part(u.email, '@', 1))
+  FROM inserted JOIN users u ON u.id = inserted.host_id`,
+		sched.ID, sched.HostID, topic, sched.Title, sched.Description, sched.ScheduledAt, now).Scan(&sched.HostName)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("create schedule: %w", err)
+	}
+	return sched, nil
+}
+
+// ForHost returns an open schedule of hostID, ready to be started.
+func (s *Schedules) ForHost(ctx context.Context, id, hostID uuid.UUID) (Schedule, error) {
+	sched := Schedule{ID: id, HostID: hostID}
+	var topic uuid.NullUUID
+	err := s.DB.QueryRowContext(ctx, `
+SELECT topic_id, title, description, scheduled_at
+  FROM live_schedules
+ WHERE id = $1 AND host_id = $2 AND canceled_at IS NULL AND session_id IS NULL`,
+		id, hostID).Scan(&topic, &sched.Title, &sched.Description, &sched.ScheduledAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Schedule{}, ErrScheduleNotFound
+	}
+	if err != nil {
+		return Schedule{}, fmt.Errorf("lookup schedule: %w", err)
+	}
+	if topic.Valid {
+		sched.TopicID = &topic.UUID
+	}
+	return sched, nil
+}
+
+// Attach links a started live session to its schedule. It fails with
+// ErrScheduleNotFound when the schedule was canceled or started meanwhile.
+func (s *Schedules) Attach(ctx context.Context, id, hostID, sessionID uuid.UUID) error {
+	res, err := s.DB.ExecContext(ctx, `
+UPDATE live_schedules SET session_id = $3
+ WHERE id = $1 AND host_id = $2 AND canceled_at IS NULL AND session_id IS NULL`, id, hostID, sessionID)
+	if err != nil {
+		return fmt.Errorf("attach schedule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// Cancel withdraws an announced session that has not started.
+func (s *Schedules) Cancel(ctx context.Context, id, hostID uuid.UUID) error {
+	res, err := s.DB.ExecContext(ctx, `
+UPDATE live_schedules SET canceled_at = $3
+ WHERE id = $1 AND host_id = $2 AND canceled_at IS NULL AND session_id IS NULL`, id, hostID, s.now().UTC())
+	if err != nil {
+		return fmt.Errorf("cancel schedule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// SetRSVP adds or withdraws userID's RSVP. Both are idempotent.
+func (s *Schedules) SetRSVP(ctx context.Context, id, userID uuid.UUID, going bool) error {
+	if !going {
+		_, err := s.DB.ExecContext(ctx, `DELETE FROM live_schedule_rsvps WHERE schedule_id = $1 AND user_id = $2`, id, userID)
+		return err
+	}
+	var open bool
+	err := s.DB.QueryRowContext(ctx, `
+SELECT EXISTS(SELECT 1 FROM live_schedules WHERE id = $1 AND canceled_at IS NULL AND session_id IS NULL)`, id).Scan(&open)
+	if err != nil {
+		return fmt.Errorf("lookup schedule: %w", err)
+	}
+	if !open {
+		return ErrScheduleNotFound
+	}
+	_, err = s.DB.ExecContext(ctx, `
+INSERT INTO live_schedule_rsvps (schedule_id, user_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (schedule_id, user_id) DO NOTHING`, id, userID, s.now().UTC())
+	if err != nil {
+		return fmt.Errorf("rsvp: %w", err)
+	}
+	return nil
+}
+
+// Upcoming lists open schedules by start time, including those whose start
+// passed recently but whose host has not opened the room yet. viewer may be
+// nil for anonymous callers.
+func (s *Schedules) Upcoming(ctx context.Context, viewer *uuid.UUID, limit int) ([]Schedule, error) {
+	var viewerID uuid.NullUUID
+	if viewer != nil {
+		viewerID = uuid.NullUUID{UUID: *viewer, Valid: true}
+	}
+	rows, err := s.DB.QueryContext(ctx, `
+SELECT s.id,
+       s.host_id,
+       COALESCE(NULLIF(u.display_name, ''), split_part(u.email, '@', 1)),
+       s.topic_id,
+       s.title,
+       s.description,
+       s.scheduled_at,
+       (SELECT count(*) FROM live_schedule_rsvps r WHERE r.schedule_id = s.id),
+       EXISTS(SELECT 1 FROM live_schedule_rsvps r WHERE r.schedule_id = s.id AND r.user_id = $2)
+  FROM live_schedules s
+  JOIN users u ON u.id = s.host_id
+ WHERE s.canceled_at IS NULL AND s.session_id IS NULL AND s.scheduled_at > $1
+ ORDER BY s.scheduled_at
+ LIMIT $3`, s.now().UTC().Add(-scheduleGrace), viewerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []Schedule{}
+	for rows.Next() {
+		var (
+			sched Schedule
+			topic uuid.NullUUID
+		)
+		if err := rows.Scan(&sched.ID, &sched.HostID, &sched.HostName, &topic, &sched.Title,
+			&sched.Description, &sched.ScheduledAt, &sched.RSVPs, &sched.RSVPed); err != nil {
+			return nil, err
+		}
+		if topic.Valid {
+			sched.TopicID = &topic.UUID
+		}
+		schedules = append(schedules, sched)
+	}
+	return schedules, rows.Err()
+}
+
+// dueReminder is a schedule claimed for one reminder.
+type dueReminder struct {
+	id        uuid.UUID
+	hostID    uuid.UUID
+	hostName  string
+	title     string
+	sessionID uuid.NullUUID
+}
+
+// SendReminders pushes due reminders: one Lead before the start and one when
+// the session starts, either because the host opened the room or because
+// its start time arrived. Each reminder is claimed before it is sent, so a
+// crash may drop a reminder but never sends one twice.
+func (s *Schedules) SendReminders(ctx context.Context) (int, error) {
+	now := s.now().UTC()
+	soon, err := s.claim(ctx, `
+UPDATE live_schedules s SET reminded_soon_at = $1
+  FROM users u
+ WHERE u.id = s.host_id AND s.id IN (
+   SELECT id FROM live_schedules
+    WHERE canceled_at IS NULL AND session_id IS NULL
+      AND reminded_soon_at IS NULL AND reminded_start_at IS NULL
+      AND scheduled_at > $1 AND scheduled_at <= $2
+    ORDER BY scheduled_at
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED)
+RETURNING s.id, s.host_id, COALESCE(NULLIF(u.display_name, ''), split_part(u.email, '@', 1)), s.title, s.session_id`,
+		now, now.Add(s.Lead), reminderBatch)
+	if err != nil {
+		return 0, err
+	}
+	started, err := s.claim(ctx, `
+UPDATE live_schedules s SET reminded_start_at = $1, reminded_soon_at = COALESCE(s.reminded_soon_at, $1)
+  FROM users u
+ WHERE u.id = s.host_id AND s.id IN (
+   SELECT id FROM live_schedules
+    WHERE canceled_at IS NULL AND reminded_start_at IS NULL
+      AND (session_id IS NOT NULL OR scheduled_at <= $1)
+      AND scheduled_at > $2
+    ORDER BY scheduled_at
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED)
+RETURNING s.id, s.host_id, COALESCE(NULLIF(u.display_name, ''), split_part(u.email, '@', 1)), s.title, s.session_id`,
+		now, now.Add(-scheduleGrace), reminderBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, due := range soon {
+		if err := s.remind(ctx, due, reminderSoon); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	for _, due := range started {
+		if err := s.remind(ctx, due, reminderStart); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func (s *Schedules) claim(ctx context.Context, query string, args ...any) ([]dueReminder, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("claim reminders: %w", err)
+	}
+	defer rows.Close()
+	var due []dueReminder
+	for rows.Next() {
+		var r dueReminder
+		if err := rows.Scan(&r.id, &r.hostID, &r.hostName, &r.title, &r.sessionID); err != nil {
+			return nil, err
+		}
+		due = append(due, r)
+	}
+	return due, rows.Err()
+}
+
+// remind pushes one reminder to every device of the schedule's RSVPs and the
+// host's followers. Delivery failures of single devices are ignored.
+func (s *Schedules) remind(ctx context.Context, due dueReminder, kind string) error {
+	if s.Push == nil {
+		return nil
+	}
+	rows, err := s.DB.QueryContext(ctx, `
+SELECT DISTINCT pd.token
+  FROM push_devices pd
+ WHERE pd.token <> ''
+   AND pd.last_seen > NOW() - INTERVAL '90 days'
+   AND pd.user_id IN (
+     SELECT user_id FROM live_schedule_rsvps WHERE schedule_id = $1
+     UNION
+     SELECT follower_id FROM user_follows WHERE followee_id = $2)`, due.id, due.hostID)
+	if err != nil {
+		return fmt.Errorf("reminder recipients: %w", err)
+	}
+	var tokens []string
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			rows.Close()
+			return err
+		}
+		tokens = append(tokens, token)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	title := fmt.Sprintf("%s goes live in %d minutes", due.hostName, int(s.Lead.Minutes()))
+	switch {
+	case kind == reminderStart && due.sessionID.Valid:
+		title = fmt.Sprintf("%s is live", due.hostName)
+	case kind == reminderStart:
+		title = fmt.Sprintf("%s is starting now", due.hostName)
+	}
+	data := map[string]string{
+		"type":        "live_reminder",
+		"reminder":    kind,
+		"schedule_id": due.id.String(),
+		"host_id":     due.hostID.String(),
+		"host_name":   due.hostName,
+	}
+	if due.sessionID.Valid {
+		data["session_id"] = due.sessionID.UUID.String()
+	}
+	for _, token := range tokens {
+		_ = s.Push.Send(ctx, push.Message{Token: token, Title: title, Body: due.title, Data: data})
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/push"
+)
+
+// fakePush records sent messages.
+type fakePush struct {
+	sent []push.Message
+}
+
+func (f *fakePush) Send(_ context.Context, msg push.Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func TestCreateScheduleValidates(t *testing.T) {
+	schedules := NewSchedules(nil, nil, 0)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	schedules.now = func() time.Time { return now }
+
+	for _, in := range []NewSchedule{
+		{Title: "", ScheduledAt: now.Add(time.Hour)},
+		{Title: "Past", ScheduledAt: now.Add(-time.Minute)},
+		{Title: "Far", ScheduledAt: now.Add(MaxScheduleAhead + time.Hour)},
+	} {
+		if _, err := schedules.Create(context.Background(), in); !errors.Is(err, ErrInvalidSchedule) {
+			t.Fatalf("expected %+v to be rejected, got %v", in, err)
+		}
+	}
+}
+
+func TestSendReminders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	sender := &fakePush{}
+	schedules := NewSchedules(db, sender, 15*time.Minute)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	schedules.now = func() time.Time { return now }
+
+	soonID, startedID, hostID, sessionID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	claimed := []string{"id", "host_id", "host_name", "title", "session_id"}
+
+	mock.ExpectQuery(`SET reminded_soon_at`).WithArgs(now, now.Add(15*time.Minute), reminderBatch).
+		WillReturnRows(sqlmock.NewRows(claimed).AddRow(soonID, hostID, "Olena", "Morning news", nil))
+	mock.ExpectQuery(`SET reminded_start_at`).WithArgs(now, now.Add(-scheduleGrace), reminderBatch).
+		WillReturnRows(sqlmock.NewRows(claimed).AddRow(startedID, hostID, "Olena", "Evening show", sessionID))
+	mock.ExpectQuery(`FROM push_devices`).WithArgs(soonID, hostID).
+		WillReturnRows(sqlmock.NewRows([]string{"token"}).AddRow("rsvp-token").AddRow("follower-token"))
+	mock.ExpectQuery(`FROM push_devices`).WithArgs(startedID, hostID).
+		WillReturnRows(sqlmock.NewRows([]string{"token"}).AddRow("rsvp-token"))
+
+	sent, err := schedules.SendReminders(context.Background())
+	if err != nil {
+		t.Fatalf("send reminders: %v", err)
+	}
+	if sent != 2 || len(sender.sent) != 3 {
+		t.Fatalf("expected 2 schedules over 3 devices, got %d over %d", sent, len(sender.sent))
+	}
+	if msg := sender.sent[0]; msg.Title != "Olena goes live in 15 minutes" || msg.Data["reminder"] != "soon" {
+		t.Fatalf("unexpected soon reminder %+v", msg)
+	}
+	if msg := sender.sent[2]; msg.Title != "Olena is live" || msg.Data["session_id"] != sessionID.String() {
+		t.Fatalf("unexpected start reminder %+v", msg)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	livekitauth "github.com/livekit/protocol/auth"
+	"github.com/twitchtv/twirp"
+)
+
+// serverTokenTTL bounds the token minted for each server API call.
+const serverTokenTTL = time.Minute
+
+// serverAPI authenticates calls to the LiveKit server API.
+type serverAPI struct {
+	apiKey    string
+	apiSecret string
+}
+
+// authorize attaches a short-lived token carrying grant.
+func (a serverAPI) authorize(ctx context.Context, grant *livekitauth.VideoGrant) (context.Context, error) {
+	token, err := livekitauth.NewAccessToken(a.apiKey, a.apiSecret).
+		SetValidFor(serverTokenTTL).
+		AddGrant(grant).
+		ToJWT()
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+token)
+	return twirp.WithHTTPRequestHeaders(ctx, header)
+}
+
+// isTwirpCode reports whether err is a server API error with one of codes.
+func isTwirpCode(err error, codes ...twirp.ErrorCode) bool {
+	var twerr twirp.Error
+	if !errors.As(err, &twerr) {
+		return false
+	}
+	for _, code := range codes {
+		if twerr.Code() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// httpURL maps the websocket URL clients connect to onto the HTTP API.
+func httpURL(raw string) string {
+	switch {
+	case strings.HasPrefix(raw, "wss://"):
+		return "https://" + strings.TrimPrefix(raw, "wss://")
+	case strings.HasPrefix(raw, "ws://"):
+		return "http://" + strings.TrimPrefix(raw, "ws://")
+	}
+	return raw
+}
@@ -0,0 +1,177 @@
+package live
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxRetentionMinutes bounds the retention curve; sessions are ended after
+// DefaultMaxDuration anyway.
+const maxRetentionMinutes = 12 * 60
+
+// ErrSessionNotFound is returned for stats of a session that does not exist.
+var ErrSessionNotFound = errors.New("live session not found")
+
+// SessionStats summarizes who listened to a live session and for how long.
+type SessionStats struct {
+	SessionID       uuid.UUID `json:"session_id"`
+	Live            bool      `json:"live"`
+	DurationSec     int       `json:"duration_sec"`
+	PeakListeners   int       `json:"peak_listeners"`
+	UniqueListeners int       `json:"unique_listeners"`
+	AvgListenSec    int       `json:"avg_listen_sec"`
+	Funnel          Funnel    `json:"funnel"`
+	// Retention is the number of listeners connected during each minute
+	// since the session started.
+	Retention []int `json:"retention"`
+}
+
+// Funnel counts unique listeners by how long they stayed in total.
+type Funnel struct {
+	Joined    int `json:"joined"`
+	Stayed1m  int `json:"stayed_1m"`
+	Stayed5m  int `json:"stayed_5m"`
+	Stayed15m int `json:"stayed_15m"`
+}
+
+// listen is one connection of a listener to a room.
+type listen struct {
+	Identity string
+	JoinedAt time.Time
+	LeftAt   time.Time
+}
+
+// Stats aggregates the listens of a session for its host. Listeners still
+// connected to a live session count up to now.
+func (t *Tracker) Stats(ctx context.Context, sessionID, hostID uuid.UUID) (SessionStats, error) {
+	var (
+		host             uuid.NullUUID
+		started, endedAt sql.NullTime
+	)
+	err := t.DB.QueryRowContext(ctx, `
+SELECT host_id, started_at, ended_at FROM live_sessions WHERE id = $1`, sessionID).Scan(&host, &started, &endedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return SessionStats{}, ErrSessionNotFound
+	case err != nil:
+		return SessionStats{}, fmt.Errorf("lookup session: %w", err)
+	case !host.Valid || host.UUID != hostID:
+		return SessionStats{}, ErrForbidden
+	}
+
+	end := t.now().UTC()
+	if endedAt.Valid {
+		end = endedAt.Time
+	}
+	rows, err := t.DB.QueryContext(ctx, `
+SELECT identity, joined_at, COALESCE(left_at, GREATEST(joined_at, $2))
+  FROM live_listens
+ WHERE session_id = $1
+ ORDER BY identity, joined_at`, sessionID, end)
+	if err != nil {
+		return SessionStats{}, fmt.Errorf("load listens: %w", err)
+	}
+	defer rows.Close()
+	var listens []listen
+	for rows.Next() {
+		var l listen
+		if err := rows.Scan(&l.Identity, &l.JoinedAt, &l.LeftAt); err != nil {
+			return SessionStats{}, err
+		}
+		listens = append(listens, l)
+	}
+	if err := rows.Err(); err != nil {
+		return SessionStats{}, err
+	}
+
+	start := end
+	if started.Valid {
+		start = started.Time
+	}
+	stats := computeStats(start, end, listens)
+	stats.SessionID = sessionID
+	stats.Live = !endedAt.Valid
+	return stats, nil
+}
+
+// computeStats aggregates listens, which must be ordered by identity and
+// join time, over a session running from start to end.
+func computeStats(start, end time.Time, listens []listen) SessionStats {
+	stats := SessionStats{Retention: []int{}}
+	if end.After(start) {
+		stats.DurationSec = int(end.Sub(start) / time.Second)
+		minutes := int((end.Sub(start) + time.Minute - 1) / time.Minute)
+		stats.Retention = make([]int, min(minutes, maxRetentionMinutes))
+	}
+	minute := func(at time.Time) int {
+		if !at.After(start) {
+			return 0
+		}
+		return int(at.Sub(start) / time.Minute)
+	}
+
+	type edge struct {
+		at    time.Time
+		delta int
+	}
+	edges := make([]edge, 0, 2*len(listens))
+	var total time.Duration
+	for i := 0; i < len(listens); {
+		identity := listens[i].Identity
+		var stayed time.Duration
+		lastMinute := -1
+		for ; i < len(listens) && listens[i].Identity == identity; i++ {
+			l := listens[i]
+			stayed += l.LeftAt.Sub(l.JoinedAt)
+			edges = append(edges, edge{l.JoinedAt, 1}, edge{l.LeftAt, -1})
+
+			// Count each listener once per minute, however often they
+			// reconnected in it.
+			to := minute(l.JoinedAt)
+			if l.LeftAt.After(l.JoinedAt) {
+				to = minute(l.LeftAt.Add(-time.Nanosecond))
+			}
+			for m := max(minute(l.JoinedAt), lastMinute+1); m <= to && m < len(stats.Retention); m++ {
+				stats.Retention[m]++
+			}
+			lastMinute = max(lastMinute, to)
+		}
+
+		total += stayed
+		stats.Funnel.Joined++
+		if stayed >= time.Minute {
+			stats.Funnel.Stayed1m++
+		}
+		if stayed >= 5*time.Minute {
+			stats.Funnel.Stayed5m++
+		}
+		if stayed >= 15*time.Minute {
+			stats.Funnel.Stayed15m++
+		}
+	}
+	stats.UniqueListeners = stats.Funnel.Joined
+	if stats.UniqueListeners > 0 {
+		stats.AvgListenSec = int(total / time.Duration(stats.UniqueListeners) / time.Second)
+	}
+
+	// Leaves sort before joins at the same instant, so a reconnect does not
+	// count twice.
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].at.Equal(edges[j].at) {
+			return edges[i].delta < edges[j].delta
+		}
+		return edges[i].at.Before(edges[j].at)
+	})
+	current := 0
+	for _, e := range edges {
+		current += e.delta
+		stats.PeakListeners = max(stats.PeakListeners, current)
+	}
+	return stats
+}
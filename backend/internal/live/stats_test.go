@@ -0,0 +1,107 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/webhook"
+)
+
+func TestComputeStats(t *testing.T) {
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	stats := computeStats(start, at(4*time.Minute+30*time.Second), []listen{
+		// a reconnects within the second minute.
+		{Identity: "a", JoinedAt: at(0), LeftAt: at(90 * time.Second)},
+		{Identity: "a", JoinedAt: at(100 * time.Second), LeftAt: at(4 * time.Minute)},
+		{Identity: "b", JoinedAt: at(time.Minute), LeftAt: at(2 * time.Minute)},
+		{Identity: "c", JoinedAt: at(3*time.Minute + 10*time.Second), LeftAt: at(3*time.Minute + 20*time.Second)},
+	})
+
+	if stats.DurationSec != 270 || stats.UniqueListeners != 3 || stats.PeakListeners != 2 {
+		t.Fatalf("unexpected totals %+v", stats)
+	}
+	// a listened 90s + 140s, b 60s and c 10s.
+	if stats.AvgListenSec != 100 {
+		t.Fatalf("expected 100s average, got %d", stats.AvgListenSec)
+	}
+	if stats.Funnel != (Funnel{Joined: 3, Stayed1m: 2, Stayed5m: 0}) {
+		t.Fatalf("unexpected funnel %+v", stats.Funnel)
+	}
+	want := []int{1, 2, 1, 2, 0}
+	if len(stats.Retention) != len(want) {
+		t.Fatalf("expected %d minutes, got %v", len(want), stats.Retention)
+	}
+	for i := range want {
+		if stats.Retention[i] != want[i] {
+			t.Fatalf("expected retention %v, got %v", want, stats.Retention)
+		}
+	}
+}
+
+func TestStatsRequiresHost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	tracker := NewTracker(db, unreachableRedis(), &fakeQueue{}, Options{})
+	sessionID, hostID := uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`FROM live_sessions WHERE id`).WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"host_id", "started_at", "ended_at"}).AddRow(hostID, time.Now(), nil))
+	if _, err := tracker.Stats(context.Background(), sessionID, uuid.New()); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected non-host to be refused, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestParticipantWebhooksRecordListens(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	tracker := NewTracker(db, unreachableRedis(), &fakeQueue{}, Options{})
+	sessionID, hostID := uuid.New(), uuid.New()
+	joined := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	room := &livekit.Room{Name: RoomName(sessionID)}
+	lookup := []string{"host_id", "ended"}
+
+	mock.ExpectQuery(`SELECT host_id`).WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows(lookup).AddRow(hostID, false))
+	mock.ExpectExec(`INSERT INTO live_listens`).WithArgs(sessionID, "listener-1", joined).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`SELECT host_id`).WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows(lookup).AddRow(hostID, false))
+	mock.ExpectExec(`UPDATE live_listens`).WithArgs(sessionID, "listener-1", joined.Add(time.Minute)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	events := []*livekit.WebhookEvent{
+		{Event: webhook.EventParticipantJoined, Room: room, CreatedAt: joined.Unix(),
+			Participant: &livekit.ParticipantInfo{Identity: "listener-1"}},
+		// The translator agent is not a listener.
+		{Event: webhook.EventParticipantJoined, Room: room, CreatedAt: joined.Unix(),
+			Participant: &livekit.ParticipantInfo{Identity: "agent-1", Kind: livekit.ParticipantInfo_AGENT}},
+		{Event: webhook.EventParticipantLeft, Room: room, CreatedAt: joined.Add(time.Minute).Unix(),
+			Participant: &livekit.ParticipantInfo{Identity: "listener-1"}},
+	}
+	for _, event := range events {
+		if err := tracker.apply(context.Background(), event); err != nil {
+			t.Fatalf("apply %s: %v", event.Event, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
@@ -0,0 +1,450 @@
+package live
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/webhook"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/amunx/backend/internal/queue"
+)
+
+const (
+	// RoomPrefix prefixes the LiveKit room of every live session.
+	RoomPrefix = "live-"
+	// DefaultHostGrace is how long a session survives its host disconnecting
+	// before it is ended as abandoned.
+	DefaultHostGrace = 2 * time.Minute
+	// DefaultMaxDuration ends sessions that never reported back, e.g. when
+	// the host's app crashed before the room started.
+	DefaultMaxDuration = 12 * time.Hour
+
+	// abandonedKey is a sorted set of session IDs scored by the unix time at
+	// which they are considered abandoned.
+	abandonedKey = "live:abandoned"
+	// eventTTL bounds how long delivered webhook IDs are remembered.
+	eventTTL      = 24 * time.Hour
+	reapBatchSize = 100
+)
+
+// ErrSessionEnded is returned when ending a session that is unknown or was
+// already ended.
+var ErrSessionEnded = errors.New("live session not found or already ended")
+
+// Options tunes lifecycle tracking.
+type Options struct {
+	HostGrace   time.Duration
+	MaxDuration time.Duration
+	// Recorder records sessions while their room is up. Nil disables
+	// recording.
+	Recorder Recorder
+}
+
+// EndParams describes how a session ended. Zero values keep what is
+// already stored.
+type EndParams struct {
+	DurationSec *int
+}
+
+// Tracker keeps live_sessions and listener connections in step with the
+// SFU, records rooms while they are up and hands finished recordings to the
+// finalize worker.
+type Tracker struct {
+	DB    *sql.DB
+	Redis *redis.Client
+	Queue queue.Stream
+	opts  Options
+	now   func() time.Time
+}
+
+// NewTracker constructs a lifecycle tracker.
+func NewTracker(db *sql.DB, rdb *redis.Client, q queue.Stream, opts Options) *Tracker {
+	if opts.HostGrace <= 0 {
+		opts.HostGrace = DefaultHostGrace
+	}
+	if opts.MaxDuration <= 0 {
+		opts.MaxDuration = DefaultMaxDuration
+	}
+	return &Tracker{DB: db, Redis: rdb, Queue: q, opts: opts, now: time.Now}
+}
+
+// RoomName returns the LiveKit room for a session.
+func RoomName(sessionID uuid.UUID) string {
+	return RoomPrefix + sessionID.String()
+}
+
+// SessionIDFromRoom parses a room name created by RoomName.
+func SessionIDFromRoom(room string) (uuid.UUID, bool) {
+	raw, ok := strings.CutPrefix(room, RoomPrefix)
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(raw)
+	return id, err == nil
+}
+
+// HandleEvent applies one verified LiveKit webhook event. Redeliveries of an
+// event that was already applied are ignored; events for rooms that are not
+// live sessions are skipped.
+func (t *Tracker) HandleEvent(ctx context.Context, event *livekit.WebhookEvent) error {
+	if id := event.GetId(); id != "" {
+		key := "livekit:event:" + id
+		fresh, err := t.Redis.SetNX(ctx, key, 1, eventTTL).Result()
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return nil
+		}
+		if err := t.apply(ctx, event); err != nil {
+			// Let the retried delivery through.
+			t.Redis.Del(context.WithoutCancel(ctx), key)
+			return err
+		}
+		return nil
+	}
+	return t.apply(ctx, event)
+}
+
+func (t *Tracker) apply(ctx context.Context, event *livekit.WebhookEvent) error {
+	room := event.GetRoom().GetName()
+	if event.GetEvent() == webhook.EventEgressEnded {
+		room = event.GetEgressInfo().GetRoomName()
+	}
+	sessionID, ok := SessionIDFromRoom(room)
+	if !ok {
+		return nil
+	}
+
+	switch event.GetEvent() {
+	case webhook.EventRoomStarted:
+		return t.startRecording(ctx, sessionID)
+	case webhook.EventRoomFinished:
+		_, err := t.End(ctx, sessionID, EndParams{})
+		if errors.Is(err, ErrSessionEnded) {
+			return nil
+		}
+		return err
+	case webhook.EventParticipantJoined:
+		if !listener(event.GetParticipant()) {
+			return nil
+		}
+		return t.participantJoined(ctx, sessionID, event.GetParticipant().GetIdentity(), t.eventTime(event))
+	case webhook.EventParticipantLeft, webhook.EventParticipantConnectionAborted:
+		if !listener(event.GetParticipant()) {
+			return nil
+		}
+		return t.participantLeft(ctx, sessionID, event.GetParticipant().GetIdentity(), t.eventTime(event))
+	case webhook.EventEgressEnded:
+		return t.egressEnded(ctx, sessionID, event.GetEgressInfo())
+	}
+	return nil
+}
+
+// startRecording starts egress for a live session that is not being
+// recorded yet.
+func (t *Tracker) startRecording(ctx context.Context, sessionID uuid.UUID) error {
+	if t.opts.Recorder == nil {
+		return nil
+	}
+	var recording bool
+	err := t.DB.QueryRowContext(ctx, `SELECT egress_id IS NOT NULL FROM live_sessions WHERE id = $1 AND ended_at IS NULL`, sessionID).
+		Scan(&recording)
+	if errors.Is(err, sql.ErrNoRows) || recording {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rec, err := t.opts.Recorder.Start(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	const stmt = `
+UPDATE live_sessions
+   SET egress_id = $2,
+       recording_key = $3
+ WHERE id = $1 AND egress_id IS NULL AND ended_at IS NULL`
+	res, err := t.DB.ExecContext(ctx, stmt, sessionID, rec.EgressID, rec.Key)
+	if err == nil {
+		var n int64
+		if n, err = res.RowsAffected(); err == nil && n == 1 {
+			return nil
+		}
+	}
+	// Lost a race with another start or the end of the session.
+	if stopErr := t.opts.Recorder.Stop(context.WithoutCancel(ctx), rec.EgressID); stopErr != nil && err == nil {
+		err = stopErr
+	}
+	return err
+}
+
+// listener reports whether a participant is a person rather than a server
+// side participant such as egress or the translator agent.
+func listener(p *livekit.ParticipantInfo) bool {
+	switch p.GetKind() {
+	case livekit.ParticipantInfo_EGRESS, livekit.ParticipantInfo_AGENT:
+		return false
+	}
+	return p.GetIdentity() != ""
+}
+
+// eventTime is when LiveKit emitted an event, so redelivered and queued
+// webhooks keep listen times accurate.
+func (t *Tracker) eventTime(event *livekit.WebhookEvent) time.Time {
+	if at := event.GetCreatedAt(); at > 0 {
+		return time.Unix(at, 0).UTC()
+	}
+	return t.now().UTC()
+}
+
+func (t *Tracker) participantJoined(ctx context.Context, sessionID uuid.UUID, identity string, at time.Time) error {
+	hostID, active, err := t.lookup(ctx, sessionID)
+	if err != nil || !active {
+		return err
+	}
+	if identity == hostID.String() {
+		return t.Redis.ZRem(ctx, abandonedKey, sessionID.String()).Err()
+	}
+	const stmt = `
+INSERT INTO live_listens (session_id, identity, joined_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (session_id, identity) WHERE left_at IS NULL DO NOTHING`
+	_, err = t.DB.ExecContext(ctx, stmt, sessionID, identity, at)
+	return err
+}
+
+func (t *Tracker) participantLeft(ctx context.Context, sessionID uuid.UUID, identity string, at time.Time) error {
+	hostID, active, err := t.lookup(ctx, sessionID)
+	if err != nil || !active {
+		return err
+	}
+	if identity == hostID.String() {
+		deadline := t.now().Add(t.opts.HostGrace).Unix()
+		return t.Redis.ZAdd(ctx, abandonedKey, redis.Z{Score: float64(deadline), Member: sessionID.String()}).Err()
+	}
+	const stmt = `
+UPDATE live_listens
+   SET left_at = GREATEST(joined_at, $3)
+ WHERE session_id = $1 AND identity = $2 AND left_at IS NULL`
+	_, err = t.DB.ExecContext(ctx, stmt, sessionID, identity, at)
+	return err
+}
+
+// egressEnded records that the egress of a session finished. If the
+// session is already over, the recording is handed to the finalize worker
+// right away; otherwise End does so once it ends. Failed recordings are
+// dropped so nothing is finalized from a partial file.
+func (t *Tracker) egressEnded(ctx context.Context, sessionID uuid.UUID, info *livekit.EgressInfo) error {
+	_, durationSec, ok := egressRecording(info)
+	const stmt = `
+UPDATE live_sessions
+   SET egress_ended_at = $3,
+       recording_key = CASE WHEN $4 THEN recording_key END,
+       duration_sec = CASE WHEN $4 THEN $5 ELSE duration_sec END
+ WHERE id = $1 AND egress_id = $2 AND egress_ended_at IS NULL
+RETURNING COALESCE(recording_key, ''), ended_at IS NOT NULL`
+	var (
+		key   string
+		ended bool
+	)
+	err := t.DB.QueryRowContext(ctx, stmt, sessionID, info.GetEgressId(), t.now().UTC(), ok, durationSec).Scan(&key, &ended)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !ended || key == "" {
+		return nil
+	}
+	return t.enqueueFinalize(ctx, sessionID, key, &durationSec)
+}
+
+// egressRecording returns the file name and length of the recording an
+// egress produced.
+func egressRecording(info *livekit.EgressInfo) (string, int, bool) {
+	switch info.GetStatus() {
+	case livekit.EgressStatus_EGRESS_COMPLETE, livekit.EgressStatus_EGRESS_LIMIT_REACHED:
+	default:
+		return "", 0, false
+	}
+	for _, file := range info.GetFileResults() {
+		key := strings.TrimPrefix(file.GetFilename(), "/")
+		if key == "" || file.GetSize() == 0 {
+			continue
+		}
+		return key, int(time.Duration(file.GetDuration()).Round(time.Second) / time.Second), true
+	}
+	return "", 0, false
+}
+
+// End marks a session as ended, stops its recording and closes the listens
+// still open. When the recording has already finished,
+// queue.TopicFinalizeLive is enqueued; otherwise that happens once egress
+// reports the file. The duration falls back to the time since the session
+// started.
+func (t *Tracker) End(ctx context.Context, sessionID uuid.UUID, params EndParams) (time.Time, error) {
+	const stmt = `
+WITH closed AS (
+  UPDATE live_listens
+     SET left_at = GREATEST(joined_at, $2)
+   WHERE session_id = $1 AND left_at IS NULL
+)
+UPDATE live_sessions
+   SET ended_at = $2,
+       duration_sec = COALESCE($3, duration_sec, GREATEST(EXTRACT(EPOCH FROM ($2 - started_at))::int, 0))
+ WHERE id = $1 AND ended_at IS NULL
+RETURNING COALESCE(egress_id, ''), egress_ended_at IS NOT NULL, COALESCE(recording_key, ''), duration_sec`
+
+	ended := t.now().UTC()
+	var duration any
+	if params.DurationSec != nil {
+		duration = *params.DurationSec
+	}
+	var (
+		egressID     string
+		egressEnded  bool
+		recordingKey string
+		durationSec  sql.NullInt64
+	)
+	err := t.DB.QueryRowContext(ctx, stmt, sessionID, ended, duration).
+		Scan(&egressID, &egressEnded, &recordingKey, &durationSec)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, ErrSessionEnded
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	t.forget(ctx, sessionID)
+
+	if egressID != "" && !egressEnded {
+		// Best effort: egress also stops on its own when the room closes,
+		// and its egress_ended webhook enqueues finalization.
+		if t.opts.Recorder != nil {
+			_ = t.opts.Recorder.Stop(ctx, egressID)
+		}
+		return ended, nil
+	}
+	if recordingKey == "" {
+		return ended, nil
+	}
+	var durationPtr *int
+	if durationSec.Valid {
+		value := int(durationSec.Int64)
+		durationPtr = &value
+	}
+	return ended, t.enqueueFinalize(ctx, sessionID, recordingKey, durationPtr)
+}
+
+// ReapAbandoned ends sessions whose host left more than the grace period ago
+// and sessions older than the maximum duration, and returns how many were
+// ended.
+func (t *Tracker) ReapAbandoned(ctx context.Context) (int, error) {
+	now := t.now()
+	abandoned, err := t.Redis.ZRangeByScore(ctx, abandonedKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   fmt.Sprint(now.Unix()),
+		Count: reapBatchSize,
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+	var ids []uuid.UUID
+	for _, member := range abandoned {
+		id, err := uuid.Parse(member)
+		if err != nil {
+			t.Redis.ZRem(ctx, abandonedKey, member)
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	stale, err := t.staleSessions(ctx, now.Add(-t.opts.MaxDuration))
+	if err != nil {
+		return 0, err
+	}
+	ids = append(ids, stale...)
+
+	reaped := 0
+	for _, id := range ids {
+		_, err := t.End(ctx, id, EndParams{})
+		if errors.Is(err, ErrSessionEnded) {
+			t.forget(ctx, id)
+			continue
+		}
+		if err != nil {
+			return reaped, fmt.Errorf("end live session %s: %w", id, err)
+		}
+		reaped++
+	}
+	return reaped, nil
+}
+
+func (t *Tracker) staleSessions(ctx context.Context, startedBefore time.Time) ([]uuid.UUID, error) {
+	const query = `
+SELECT id
+  FROM live_sessions
+ WHERE ended_at IS NULL AND started_at < $1
+ ORDER BY started_at
+ LIMIT $2`
+
+	rows, err := t.DB.QueryContext(ctx, query, startedBefore, reapBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// lookup returns the host of a session and whether it is still live.
+func (t *Tracker) lookup(ctx context.Context, sessionID uuid.UUID) (uuid.UUID, bool, error) {
+	var (
+		hostID uuid.UUID
+		ended  bool
+	)
+	err := t.DB.QueryRowContext(ctx, `SELECT host_id, ended_at IS NOT NULL FROM live_sessions WHERE id = $1`, sessionID).
+		Scan(&hostID, &ended)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, false, nil
+	}
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	return hostID, !ended, nil
+}
+
+// forget drops the Redis state of an ended session. Failures are ignored:
+// the reaper skips ended sessions.
+func (t *Tracker) forget(ctx context.Context, sessionID uuid.UUID) {
+	_ = t.Redis.ZRem(ctx, abandonedKey, sessionID.String()).Err()
+}
+
+func (t *Tracker) enqueueFinalize(ctx context.Context, sessionID uuid.UUID, recordingKey string, durationSec *int) error {
+	job := map[string]any{
+		"session_id":    sessionID.String(),
+		"recording_key": recordingKey,
+		"attempt":       0,
+	}
+	if durationSec != nil {
+		job["duration_sec"] = *durationSec
+	}
+	return t.Queue.Enqueue(ctx, queue.TopicFinalizeLive, job)
+}
@@ -0,0 +1,164 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/livekit/protocol/livekit"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/amunx/backend/internal/queue"
+)
+
+// fakeQueue records enqueued jobs; other queue methods are unused.
+type fakeQueue struct {
+	queue.Stream
+	jobs []map[string]any
+}
+
+func (f *fakeQueue) Enqueue(_ context.Context, stream string, payload map[string]any) error {
+	if stream != queue.TopicFinalizeLive {
+		return errors.New("unexpected stream " + stream)
+	}
+	f.jobs = append(f.jobs, payload)
+	return nil
+}
+
+// unreachableRedis fails fast, which End tolerates when clearing state.
+func unreachableRedis() *redis.Client {
+	return redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 50 * time.Millisecond})
+}
+
+func TestSessionIDFromRoom(t *testing.T) {
+	id := uuid.New()
+	if got, ok := SessionIDFromRoom(RoomName(id)); !ok || got != id {
+		t.Fatalf("expected %s, got %s %v", id, got, ok)
+	}
+	for _, room := range []string{"", "lobby", "live-", "live-not-a-uuid", id.String()} {
+		if _, ok := SessionIDFromRoom(room); ok {
+			t.Fatalf("expected %q to be rejected", room)
+		}
+	}
+}
+
+func TestEgressRecording(t *testing.T) {
+	file := &livekit.FileInfo{Filename: "/live/abc.ogg", Size: 2048, Duration: int64(90*time.Second + 400*time.Millisecond)}
+	cases := []struct {
+		name     string
+		info     *livekit.EgressInfo
+		key      string
+		duration int
+	}{
+		{"complete", &livekit.EgressInfo{Status: livekit.EgressStatus_EGRESS_COMPLETE, FileResults: []*livekit.FileInfo{file}}, "live/abc.ogg", 90},
+		{"limit reached", &livekit.EgressInfo{Status: livekit.EgressStatus_EGRESS_LIMIT_REACHED, FileResults: []*livekit.FileInfo{file}}, "live/abc.ogg", 90},
+		{"failed", &livekit.EgressInfo{Status: livekit.EgressStatus_EGRESS_FAILED, FileResults: []*livekit.FileInfo{file}}, "", 0},
+		{"empty file", &livekit.EgressInfo{Status: livekit.EgressStatus_EGRESS_COMPLETE, FileResults: []*livekit.FileInfo{{Filename: "live/x.ogg"}}}, "", 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, duration, ok := egressRecording(tc.info)
+			if ok != (tc.key != "") || key != tc.key || duration != tc.duration {
+				t.Fatalf("expected %q %d, got %q %d %v", tc.key, tc.duration, key, duration, ok)
+			}
+		})
+	}
+}
+
+// fakeRecorder records stopped egresses.
+type fakeRecorder struct {
+	stopped []string
+}
+
+func (f *fakeRecorder) Start(_ context.Context, sessionID uuid.UUID) (Recording, error) {
+	return Recording{EgressID: "EG_1", Key: RecordingKey(sessionID)}, nil
+}
+
+func (f *fakeRecorder) Stop(_ context.Context, egressID string) error {
+	f.stopped = append(f.stopped, egressID)
+	return nil
+}
+
+var endColumns = []string{"egress_id", "egress_ended", "recording_key", "duration_sec"}
+
+func TestEndFinalizesOnlyFinishedRecordings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	jobs, recorder := &fakeQueue{}, &fakeRecorder{}
+	tracker := NewTracker(db, unreachableRedis(), jobs, Options{Recorder: recorder})
+	finished, recording, unrecorded := uuid.New(), uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`UPDATE live_sessions`).
+		WithArgs(finished, sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows(endColumns).AddRow("EG_1", true, RecordingKey(finished), 95))
+	mock.ExpectQuery(`UPDATE live_sessions`).
+		WithArgs(recording, sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows(endColumns).AddRow("EG_2", false, RecordingKey(recording), 30))
+	mock.ExpectQuery(`UPDATE live_sessions`).
+		WithArgs(unrecorded, sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows(endColumns).AddRow("", false, "", 30))
+	mock.ExpectQuery(`UPDATE live_sessions`).
+		WithArgs(finished, sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows(endColumns))
+
+	for _, id := range []uuid.UUID{finished, recording, unrecorded} {
+		if _, err := tracker.End(context.Background(), id, EndParams{}); err != nil {
+			t.Fatalf("end %s: %v", id, err)
+		}
+	}
+	if _, err := tracker.End(context.Background(), finished, EndParams{}); !errors.Is(err, ErrSessionEnded) {
+		t.Fatalf("expected ended session, got %v", err)
+	}
+
+	if len(recorder.stopped) != 1 || recorder.stopped[0] != "EG_2" {
+		t.Fatalf("expected the running egress to be stopped, got %v", recorder.stopped)
+	}
+	if len(jobs.jobs) != 1 {
+		t.Fatalf("expected one finalize job, got %v", jobs.jobs)
+	}
+	job := jobs.jobs[0]
+	if job["session_id"] != finished.String() || job["recording_key"] != RecordingKey(finished) || job["duration_sec"] != 95 {
+		t.Fatalf("unexpected finalize job %v", job)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestEgressEndedFinalizesEndedSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	jobs := &fakeQueue{}
+	tracker := NewTracker(db, unreachableRedis(), jobs, Options{})
+	id := uuid.New()
+	info := &livekit.EgressInfo{
+		EgressId:    "EG_1",
+		Status:      livekit.EgressStatus_EGRESS_COMPLETE,
+		FileResults: []*livekit.FileInfo{{Filename: RecordingKey(id), Size: 4096, Duration: int64(2 * time.Minute)}},
+	}
+
+	mock.ExpectQuery(`UPDATE live_sessions`).
+		WithArgs(id, "EG_1", sqlmock.AnyArg(), true, 120).
+		WillReturnRows(sqlmock.NewRows([]string{"recording_key", "ended"}).AddRow(RecordingKey(id), true))
+
+	if err := tracker.egressEnded(context.Background(), id, info); err != nil {
+		t.Fatalf("egress ended: %v", err)
+	}
+	if len(jobs.jobs) != 1 || jobs.jobs[0]["recording_key"] != RecordingKey(id) {
+		t.Fatalf("expected a finalize job for the derived key, got %v", jobs.jobs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
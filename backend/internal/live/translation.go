@@ -0,0 +1,287 @@
+package live
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// MaxTranslationTargets bounds the dub tracks a session can request.
+	MaxTranslationTargets = 2
+	// DefaultTranslationBudget is the default cap on translation spend per
+	// session and rolling hour.
+	DefaultTranslationBudget = 5.0
+	budgetWindow             = time.Hour
+
+	// Reasons translation is off.
+	TranslationDisabledByHost = "host"
+	TranslationOverBudget     = "budget"
+	TranslationSessionEnded   = "ended"
+)
+
+var (
+	// ErrInvalidLanguages is returned for malformed or too many languages.
+	ErrInvalidLanguages = errors.New("invalid translation languages")
+	// ErrBudgetExceeded is returned when enabling translation for a session
+	// that spent its hourly budget.
+	ErrBudgetExceeded = errors.New("translation budget exceeded for this hour")
+	// ErrInvalidUsage is returned for usage reports without an id or with
+	// negative amounts.
+	ErrInvalidUsage = errors.New("invalid usage report")
+
+	languageTag = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z]{2,4})?$`)
+)
+
+// TranslationConfig is a session's translation settings and spend so far.
+type TranslationConfig struct {
+	SessionID      uuid.UUID `json:"session_id"`
+	Enabled        bool      `json:"enabled"`
+	SourceLang     string    `json:"source_lang,omitempty"`
+	TargetLangs    []string  `json:"target_langs"`
+	DisabledReason string    `json:"disabled_reason,omitempty"`
+	CostUSD        float64   `json:"cost_usd"`
+	AudioSeconds   int64     `json:"audio_seconds"`
+}
+
+// UsageReport is spend the translator agent incurred since its last report.
+type UsageReport struct {
+	ID           string  `json:"report_id"`
+	CostUSD      float64 `json:"cost_usd"`
+	AudioSeconds int     `json:"audio_seconds"`
+}
+
+// Translations keeps live translation settings in Postgres and enforces the
+// per-session hourly budget on reported usage.
+type Translations struct {
+	DB *sql.DB
+	// MaxCostPerHour caps spend per session over a rolling hour.
+	MaxCostPerHour float64
+	now            func() time.Time
+}
+
+// NewTranslations constructs a translation store. A non-positive budget uses
+// DefaultTranslationBudget.
+func NewTranslations(db *sql.DB, maxCostPerHour float64) *Translations {
+	if maxCostPerHour <= 0 {
+		maxCostPerHour = DefaultTranslationBudget
+	}
+	return &Translations{DB: db, MaxCostPerHour: maxCostPerHour, now: time.Now}
+}
+
+// Enable turns translation on for a live session hosted by hostID.
+// Entitlement checks are the caller's.
+func (t *Translations) Enable(ctx context.Context, sessionID, hostID uuid.UUID, source string, targets []string) (TranslationConfig, error) {
+	source, targets, err := normalizeLanguages(source, targets)
+	if err != nil {
+		return TranslationConfig{}, err
+	}
+	if err := t.checkHost(ctx, sessionID, hostID); err != nil {
+		return TranslationConfig{}, err
+	}
+	spent, err := t.spentThisHour(ctx, t.DB, sessionID)
+	if err != nil {
+		return TranslationConfig{}, err
+	}
+	if spent >= t.MaxCostPerHour {
+		return TranslationConfig{}, ErrBudgetExceeded
+	}
+
+	cfg, err := scanTranslation(t.DB.QueryRowContext(ctx, `
+INSERT INTO live_translations (session_id, host_id, source_lang, target_langs, enabled, created_at, updated_at)
+VALUES ($1, $2, $3, $4, TRUE, $5, $5)
+ON CONFLICT (session_id) DO UPDATE
+   SET source_lang = EXCLUDED.source_lang,
+       target_langs = EXCLUDED.target_langs,
+       enabled = TRUE,
+       disabled_reason = NULL,
+       updated_at = EXCLUDED.updated_at
+RETURNING `+translationColumns, sessionID, hostID, source, pq.Array(targets), t.now().UTC()))
+	if err != nil {
+		return TranslationConfig{}, fmt.Errorf("enable translation: %w", err)
+	}
+	return cfg, nil
+}
+
+// Disable turns translation off for a session hosted by hostID.
+func (t *Translations) Disable(ctx context.Context, sessionID, hostID uuid.UUID) error {
+	if err := t.checkHost(ctx, sessionID, hostID); err != nil {
+		return err
+	}
+	_, err := t.DB.ExecContext(ctx, `
+UPDATE live_translations
+   SET enabled = FALSE, disabled_reason = $2, updated_at = $3
+ WHERE session_id = $1 AND enabled`, sessionID, TranslationDisabledByHost, t.now().UTC())
+	if err != nil {
+		return fmt.Errorf("disable translation: %w", err)
+	}
+	return nil
+}
+
+// Status returns a session's translation settings. Sessions that never
+// enabled translation, and ended sessions, report it disabled.
+func (t *Translations) Status(ctx context.Context, sessionID uuid.UUID) (TranslationConfig, error) {
+	cfg, err := scanTranslation(t.DB.QueryRowContext(ctx, `
+SELECT lt.session_id,
+       lt.enabled AND ls.ended_at IS NULL,
+       lt.source_lang,
+       lt.target_langs,
+       CASE WHEN ls.ended_at IS NOT NULL THEN '`+TranslationSessionEnded+`' ELSE COALESCE(lt.disabled_reason, '') END,
+       lt.cost_usd,
+       lt.audio_seconds
+  FROM live_translations lt
+  JOIN live_sessions ls ON ls.id = lt.session_id
+ WHERE lt.session_id = $1`, sessionID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return TranslationConfig{SessionID: sessionID, TargetLangs: []string{}}, nil
+	}
+	if err != nil {
+		return TranslationConfig{}, fmt.Errorf("translation status: %w", err)
+	}
+	return cfg, nil
+}
+
+// ReportUsage records agent spend and stops translation once the session
+// spent its budget for the rolling hour. Reports are idempotent by ID. The
+// returned config tells the agent whether to keep translating.
+func (t *Translations) ReportUsage(ctx context.Context, sessionID uuid.UUID, report UsageReport) (TranslationConfig, error) {
+	report.ID = strings.TrimSpace(report.ID)
+	if report.ID == "" || report.CostUSD < 0 || report.AudioSeconds < 0 {
+		return TranslationConfig{}, ErrInvalidUsage
+	}
+	tx, err := t.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return TranslationConfig{}, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	now := t.now().UTC()
+	res, err := tx.ExecContext(ctx, `
+INSERT INTO live_translation_usage (session_id, report_id, cost_usd, audio_seconds, reported_at)
+SELECT session_id, $2, $3, $4, $5 FROM live_translations WHERE session_id = $1
+ON CONFLICT (session_id, report_id) DO NOTHING`,
+		sessionID, report.ID, report.CostUSD, report.AudioSeconds, now)
+	if err != nil {
+		return TranslationConfig{}, fmt.Errorf("record usage: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		if _, err := tx.ExecContext(ctx, `
+UPDATE live_translations
+   SET cost_usd = cost_usd + $2, audio_seconds = audio_seconds + $3, updated_at = $4
+ WHERE session_id = $1`, sessionID, report.CostUSD, report.AudioSeconds, now); err != nil {
+			return TranslationConfig{}, fmt.Errorf("update usage totals: %w", err)
+		}
+	}
+
+	spent, err := t.spentThisHour(ctx, tx, sessionID)
+	if err != nil {
+		return TranslationConfig{}, err
+	}
+	query := `SELECT ` + translationColumns + ` FROM live_translations WHERE session_id = $1`
+	args := []any{sessionID}
+	if spent >= t.MaxCostPerHour {
+		query = `
+UPDATE live_translations
+   SET enabled = FALSE,
+       disabled_reason = CASE WHEN enabled THEN $2 ELSE disabled_reason END,
+       updated_at = $3
+ WHERE session_id = $1
+RETURNING ` + translationColumns
+		args = append(args, TranslationOverBudget, now)
+	}
+	cfg, err := scanTranslation(tx.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		// Translation was never enabled; tell the agent to stay idle.
+		return TranslationConfig{SessionID: sessionID, TargetLangs: []string{}}, nil
+	}
+	if err != nil {
+		return TranslationConfig{}, fmt.Errorf("load translation: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return TranslationConfig{}, err
+	}
+	return cfg, nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (t *Translations) spentThisHour(ctx context.Context, q queryRower, sessionID uuid.UUID) (float64, error) {
+	var spent float64
+	err := q.QueryRowContext(ctx, `
+SELECT COALESCE(SUM(cost_usd), 0) FROM live_translation_usage
+ WHERE session_id = $1 AND reported_at > $2`, sessionID, t.now().UTC().Add(-budgetWindow)).Scan(&spent)
+	if err != nil {
+		return 0, fmt.Errorf("translation spend: %w", err)
+	}
+	return spent, nil
+}
+
+// checkHost verifies that sessionID is live and hosted by hostID.
+func (t *Translations) checkHost(ctx context.Context, sessionID, hostID uuid.UUID) error {
+	var (
+		host  uuid.NullUUID
+		ended bool
+	)
+	err := t.DB.QueryRowContext(ctx, `
+SELECT host_id, ended_at IS NOT NULL FROM live_sessions WHERE id = $1`, sessionID).Scan(&host, &ended)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) || ended:
+		return ErrSessionEnded
+	case err != nil:
+		return fmt.Errorf("lookup session: %w", err)
+	case !host.Valid || host.UUID != hostID:
+		return ErrForbidden
+	}
+	return nil
+}
+
+const translationColumns = `session_id, enabled, source_lang, target_langs, COALESCE(disabled_reason, ''), cost_usd, audio_seconds`
+
+func scanTranslation(row rowScanner) (TranslationConfig, error) {
+	var cfg TranslationConfig
+	err := row.Scan(&cfg.SessionID, &cfg.Enabled, &cfg.SourceLang, pq.Array(&cfg.TargetLangs),
+		&cfg.DisabledReason, &cfg.CostUSD, &cfg.AudioSeconds)
+	if cfg.TargetLangs == nil {
+		cfg.TargetLangs = []string{}
+	}
+	return cfg, err
+}
+
+// normalizeLanguages validates language tags, drops duplicates and the
+// source language from targets.
+func normalizeLanguages(source string, targets []string) (string, []string, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		source = "uk"
+	}
+	if !languageTag.MatchString(source) {
+		return "", nil, ErrInvalidLanguages
+	}
+	base := func(tag string) string { return strings.SplitN(tag, "-", 2)[0] }
+	seen := map[string]bool{base(source): true}
+	out := make([]string, 0, len(targets))
+	for _, tag := range targets {
+		tag = strings.TrimSpace(tag)
+		if !languageTag.MatchString(tag) {
+			return "", nil, ErrInvalidLanguages
+		}
+		if seen[base(tag)] {
+			continue
+		}
+		seen[base(tag)] = true
+		out = append(out, tag)
+	}
+	if len(out) == 0 || len(out) > MaxTranslationTargets {
+		return "", nil, ErrInvalidLanguages
+	}
+	return source, out, nil
+}
@@ -0,0 +1,95 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestNormalizeLanguages(t *testing.T) {
+	source, targets, err := normalizeLanguages("", []string{"en", "uk-UA", "en", "pl"})
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if source != "uk" || len(targets) != 2 || targets[0] != "en" || targets[1] != "pl" {
+		t.Fatalf("unexpected languages %q %v", source, targets)
+	}
+	if _, _, err := normalizeLanguages("uk", []string{"en", "pl", "de"}); !errors.Is(err, ErrInvalidLanguages) {
+		t.Fatalf("expected three targets to be rejected, got %v", err)
+	}
+	if _, _, err := normalizeLanguages("uk", []string{"uk"}); !errors.Is(err, ErrInvalidLanguages) {
+		t.Fatalf("expected source-only targets to be rejected, got %v", err)
+	}
+}
+
+func TestEnableTranslationRequiresHost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	translations := NewTranslations(db, 5)
+	sessionID, hostID, otherID := uuid.New(), uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`FROM live_sessions WHERE id`).WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"host_id", "ended"}).AddRow(hostID, false))
+	if _, err := translations.Enable(context.Background(), sessionID, otherID, "uk", []string{"en"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected non-host to be refused, got %v", err)
+	}
+
+	mock.ExpectQuery(`FROM live_sessions WHERE id`).WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"host_id", "ended"}).AddRow(hostID, false))
+	mock.ExpectQuery(`FROM live_translation_usage`).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(5.2))
+	if _, err := translations.Enable(context.Background(), sessionID, hostID, "uk", []string{"en"}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected spent budget to block enabling, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestReportUsageDisablesOverBudget(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	translations := NewTranslations(db, 5)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	translations.now = func() time.Time { return now }
+	sessionID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO live_translation_usage`).
+		WithArgs(sessionID, "r-1", 1.5, 120, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE live_translations`).
+		WithArgs(sessionID, 1.5, 120, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`FROM live_translation_usage`).
+		WithArgs(sessionID, now.Add(-time.Hour)).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(5.5))
+	mock.ExpectQuery(`UPDATE live_translations`).
+		WithArgs(sessionID, TranslationOverBudget, now).
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "enabled", "source_lang", "target_langs", "reason", "cost_usd", "audio_seconds"}).
+			AddRow(sessionID, false, "uk", "{en}", TranslationOverBudget, 5.5, 600))
+	mock.ExpectCommit()
+
+	cfg, err := translations.ReportUsage(context.Background(), sessionID, UsageReport{ID: "r-1", CostUSD: 1.5, AudioSeconds: 120})
+	if err != nil {
+		t.Fatalf("report usage: %v", err)
+	}
+	if cfg.Enabled || cfg.DisabledReason != TranslationOverBudget || len(cfg.TargetLangs) != 1 {
+		t.Fatalf("expected translation stopped for budget, got %+v", cfg)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
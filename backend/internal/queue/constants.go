@@ -6,4 +6,13 @@ const (
 
 	// TopicFinalizeLive handles post-processing for completed live sessions.
 	TopicFinalizeLive = "jobs:finalize_live"
+
+	// TopicPipeline carries per-step AI pipeline jobs (transcribe, summarize, clips, embeddings).
+	TopicPipeline = "jobs:pipeline"
+
+	// TopicAudiogram carries audiogram video render jobs.
+	TopicAudiogram = "jobs:audiogram"
 )
+
+// Topics lists the job streams consumed by workers.
+var Topics = []string{TopicProcessAudio, TopicFinalizeLive, TopicPipeline, TopicAudiogram}
@@ -2,17 +2,59 @@ package queue
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// Reserved message fields used to track redelivery. They are stripped from
+// Message.Values on claim and exposed as Message fields instead.
+const (
+	fieldAttempt  = "_attempt"
+	fieldOriginID = "_origin_id"
+	fieldError    = "_error"
+	fieldFailedAt = "_failed_at"
+)
+
+const (
+	defaultMaxAttempts       = 3
+	defaultVisibilityTimeout = 5 * time.Minute
+	defaultBackoffBase       = 5 * time.Second
+	defaultBackoffMax        = 10 * time.Minute
+)
+
+// ErrDeadLetterNotFound is returned when replaying an unknown dead letter.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// ErrLeaseLost is returned by Extend when a message is no longer pending for
+// the consumer, e.g. because another consumer reclaimed it.
+var ErrLeaseLost = errors.New("message lease lost")
+
+var errVisibilityTimeout = errors.New("consumer did not ack before the visibility timeout")
+
 // Stream defines the minimal Redis Stream interactions used by workers.
 type Stream interface {
 	Enqueue(ctx context.Context, stream string, payload map[string]any) error
 	Claim(ctx context.Context, stream, group, consumer string, batchSize int64) ([]Message, error)
 	Ack(ctx context.Context, stream, group string, ids ...string) error
+	// Extend resets the idle time of messages the consumer is still
+	// handling so they are not reclaimed after the visibility timeout. It
+	// returns ErrLeaseLost when a message is no longer pending for consumer.
+	Extend(ctx context.Context, stream, group, consumer string, ids ...string) error
+	// Retry acks msg and schedules it for redelivery with exponential
+	// backoff. Once MaxAttempts deliveries have failed the message is moved
+	// to the dead-letter stream instead and dead is true.
+	Retry(ctx context.Context, stream, group string, msg Message, cause error) (dead bool, err error)
+	// DeadLetters lists the most recent dead letters of a stream, newest first.
+	DeadLetters(ctx context.Context, stream string, limit int64) ([]DeadLetter, error)
+	// ReplayDeadLetter moves a dead letter back onto its stream with a fresh
+	// attempt budget.
+	ReplayDeadLetter(ctx context.Context, stream, id string) error
 }
 
 // Message represents a single queue message.
@@ -20,17 +62,102 @@ type Message struct {
 	ID      string
 	Values  map[string]any
 	Pending bool
+	// Attempt counts the failed deliveries that came before this one.
+	Attempt int
+	// OriginID is the ID the message was first enqueued with; it survives
+	// retries and dead-lettering.
+	OriginID string
+	// DeadLetterCause is set when Claim reclaimed the message past its
+	// attempts and already moved it to the dead-letter stream. Such a
+	// message must not be handled again; it is only returned so the
+	// consumer can run its dead-letter hook.
+	DeadLetterCause error
+}
+
+// DeadLetter is a message that exhausted its attempts.
+type DeadLetter struct {
+	Message
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// Options tunes redelivery. Zero values fall back to the defaults.
+type Options struct {
+	// MaxAttempts is the number of deliveries a message gets before it is
+	// dead-lettered.
+	MaxAttempts int
+	// VisibilityTimeout is how long a claimed message may stay unacked
+	// before another consumer reclaims it.
+	VisibilityTimeout time.Duration
+	BackoffBase       time.Duration
+	BackoffMax        time.Duration
+}
+
+// DeadLetterStream returns the dead-letter stream for a topic.
+func DeadLetterStream(stream string) string {
+	return stream + ":dead"
+}
+
+func delayedKey(stream string) string {
+	return stream + ":delayed"
 }
 
 // NewRedisStream wraps a go-redis client to implement Stream.
-func NewRedisStream(client *redis.Client) Stream {
-	return &redisStream{client: client}
+func NewRedisStream(client *redis.Client, opts Options) Stream {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = defaultVisibilityTimeout
+	}
+	if opts.BackoffBase <= 0 {
+		opts.BackoffBase = defaultBackoffBase
+	}
+	if opts.BackoffMax <= 0 {
+		opts.BackoffMax = defaultBackoffMax
+	}
+	return &redisStream{client: client, opts: opts, now: time.Now}
 }
 
 type redisStream struct {
 	client *redis.Client
+	opts   Options
+	now    func() time.Time
 }
 
+// extendOwned resets the idle time of the given entries, skipping any that
+// are no longer pending for the consumer in ARGV[1]. It returns how many
+// entries were extended.
+var extendOwned = redis.NewScript(`
+local extended = 0
+for i = 2, #ARGV do
+  local entry = redis.call('XPENDING', KEYS[1], KEYS[2], ARGV[i], ARGV[i], 1)
+  if #entry == 1 and entry[1][2] == ARGV[1] then
+    redis.call('XCLAIM', KEYS[1], KEYS[2], ARGV[1], 0, ARGV[i], 'JUSTID')
+    extended = extended + 1
+  end
+end
+return extended
+`)
+
+// promoteDue moves delayed retries whose backoff has elapsed onto the stream.
+// Members are JSON-encoded field maps; removing and re-adding happens in one
+// script so a retry is never lost or delivered twice.
+var promoteDue = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, member in ipairs(due) do
+  redis.call('ZREM', KEYS[1], member)
+  local fields = cjson.decode(member)
+  local args = {}
+  for k, v in pairs(fields) do
+    table.insert(args, k)
+    table.insert(args, tostring(v))
+  end
+  redis.call('XADD', KEYS[2], '*', unpack(args))
+end
+return #due
+`)
+
 func (r *redisStream) Enqueue(ctx context.Context, stream string, payload map[string]any) error {
 	if stream == "" {
 		return errors.New("stream name is required")
@@ -45,6 +172,11 @@ func (r *redisStream) Enqueue(ctx context.Context, stream string, payload map[st
 	return r.client.XAdd(ctx, args).Err()
 }
 
+// Claim returns up to batchSize messages: due retries are promoted first,
+// then entries idle past the visibility timeout are reclaimed from other
+// consumers, and the remainder is filled with new entries. Reclaimed entries
+// that ran out of attempts are dead-lettered and returned with
+// DeadLetterCause set.
 func (r *redisStream) Claim(ctx context.Context, stream, group, consumer string, batchSize int64) ([]Message, error) {
 	if stream == "" || group == "" || consumer == "" {
 		return nil, errors.New("stream, group, and consumer are required")
@@ -58,33 +190,104 @@ func (r *redisStream) Claim(ctx context.Context, stream, group, consumer string,
 		batchSize = 1
 	}
 
+	now := strconv.FormatInt(r.now().UnixMilli(), 10)
+	if err := promoteDue.Run(ctx, r.client, []string{delayedKey(stream), stream}, now, batchSize).Err(); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	messages, err := r.reclaim(ctx, stream, group, consumer, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(messages)) >= batchSize {
+		return messages, nil
+	}
+
 	args := &redis.XReadGroupArgs{
 		Group:    group,
 		Consumer: consumer,
 		Streams:  []string{stream, ">"},
-		Count:    batchSize,
-		Block:    0,
+		Count:    batchSize - int64(len(messages)),
+		// Workers poll on their own ticker; never block the loop.
+		Block: -1,
 	}
 
 	streams, err := r.client.XReadGroup(ctx, args).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			return nil, nil
+			return messages, nil
 		}
 		return nil, err
 	}
 
-	var messages []Message
 	for _, st := range streams {
 		for _, msg := range st.Messages {
-			messages = append(messages, Message{
-				ID:      msg.ID,
-				Values:  msg.Values,
-				Pending: false,
+			messages = append(messages, newMessage(msg, false))
+		}
+	}
+
+	return messages, nil
+}
+
+// reclaim takes over entries another consumer claimed but never acked. Every
+// earlier delivery that timed out counts as a failed attempt, so a message
+// that keeps crashing its consumer still ends up in the dead-letter stream.
+func (r *redisStream) reclaim(ctx context.Context, stream, group, consumer string, batchSize int64) ([]Message, error) {
+	claimed, _, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		MinIdle:  r.opts.VisibilityTimeout,
+		Start:    "0-0",
+		Count:    batchSize,
+		Consumer: consumer,
+	}).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(claimed) == 0 {
+		return nil, nil
+	}
+
+	cmds := make([]*redis.XPendingExtCmd, len(claimed))
+	if _, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, msg := range claimed {
+			cmds[i] = pipe.XPendingExt(ctx, &redis.XPendingExtArgs{
+				Stream: stream,
+				Group:  group,
+				Start:  msg.ID,
+				End:    msg.ID,
+				Count:  1,
 			})
 		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	deliveries := make(map[string]int64, len(claimed))
+	for _, cmd := range cmds {
+		for _, p := range cmd.Val() {
+			deliveries[p.ID] = p.RetryCount
+		}
 	}
 
+	messages := make([]Message, 0, len(claimed))
+	for _, msg := range claimed {
+		m := newMessage(msg, true)
+		// XAUTOCLAIM bumped the delivery count; earlier deliveries crashed.
+		if crashed := int(deliveries[msg.ID]) - 1; crashed > 0 {
+			m.Attempt += crashed
+		}
+		if m.Attempt >= r.opts.MaxAttempts {
+			if err := r.deadLetter(ctx, stream, group, m, m.Attempt, errVisibilityTimeout); err != nil {
+				return nil, err
+			}
+			m.DeadLetterCause = errVisibilityTimeout
+		}
+		messages = append(messages, m)
+	}
 	return messages, nil
 }
 
@@ -95,6 +298,122 @@ func (r *redisStream) Ack(ctx context.Context, stream, group string, ids ...stri
 	return r.client.XAck(ctx, stream, group, ids...).Err()
 }
 
+// Extend re-claims ids for the same consumer with JUSTID, which resets their
+// idle time without counting another delivery. Entries that were already
+// reclaimed by another consumer or settled are left alone and reported with
+// ErrLeaseLost.
+func (r *redisStream) Extend(ctx context.Context, stream, group, consumer string, ids ...string) error {
+	if stream == "" || group == "" || consumer == "" || len(ids) == 0 {
+		return errors.New("stream, group, consumer and ids are required")
+	}
+	args := make([]any, 0, len(ids)+1)
+	args = append(args, consumer)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	extended, err := extendOwned.Run(ctx, r.client, []string{stream, group}, args...).Int()
+	if err != nil {
+		return err
+	}
+	if extended < len(ids) {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (r *redisStream) Retry(ctx context.Context, stream, group string, msg Message, cause error) (bool, error) {
+	if stream == "" || group == "" || msg.ID == "" {
+		return false, errors.New("stream, group and message id are required")
+	}
+
+	attempt := msg.Attempt + 1
+	if attempt >= r.opts.MaxAttempts {
+		return true, r.deadLetter(ctx, stream, group, msg, attempt, cause)
+	}
+
+	member, err := json.Marshal(messageFields(msg, attempt))
+	if err != nil {
+		return false, err
+	}
+	due := r.now().Add(Backoff(attempt, r.opts.BackoffBase, r.opts.BackoffMax))
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, delayedKey(stream), redis.Z{Score: float64(due.UnixMilli()), Member: string(member)})
+		pipe.XAck(ctx, stream, group, msg.ID)
+		return nil
+	})
+	return false, err
+}
+
+// deadLetter moves msg to the dead-letter stream and acks the original.
+func (r *redisStream) deadLetter(ctx context.Context, stream, group string, msg Message, attempt int, cause error) error {
+	fields := messageFields(msg, attempt)
+	if cause != nil {
+		fields[fieldError] = cause.Error()
+	}
+	fields[fieldFailedAt] = r.now().UTC().Format(time.RFC3339)
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: DeadLetterStream(stream), ID: "*", Values: fields})
+		pipe.XAck(ctx, stream, group, msg.ID)
+		return nil
+	})
+	return err
+}
+
+func (r *redisStream) DeadLetters(ctx context.Context, stream string, limit int64) ([]DeadLetter, error) {
+	if stream == "" {
+		return nil, errors.New("stream name is required")
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	entries, err := r.client.XRevRangeN(ctx, DeadLetterStream(stream), "+", "-", limit).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]DeadLetter, 0, len(entries))
+	for _, entry := range entries {
+		letter := DeadLetter{
+			Error: stringField(entry.Values, fieldError),
+		}
+		if at, err := time.Parse(time.RFC3339, stringField(entry.Values, fieldFailedAt)); err == nil {
+			letter.FailedAt = at
+		}
+		delete(entry.Values, fieldError)
+		delete(entry.Values, fieldFailedAt)
+		letter.Message = newMessage(entry, false)
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+func (r *redisStream) ReplayDeadLetter(ctx context.Context, stream, id string) error {
+	if stream == "" || id == "" {
+		return errors.New("stream and id are required")
+	}
+	deadStream := DeadLetterStream(stream)
+	entries, err := r.client.XRange(ctx, deadStream, id, id).Result()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return ErrDeadLetterNotFound
+	}
+
+	msg := newMessage(entries[0], false)
+	fields := messageFields(msg, 0)
+	delete(fields, fieldError)
+	delete(fields, fieldFailedAt)
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: stream, ID: "*", Values: fields})
+		pipe.XDel(ctx, deadStream, id)
+		return nil
+	})
+	return err
+}
+
 func (r *redisStream) ensureGroup(ctx context.Context, stream, group string) error {
 	err := r.client.XGroupCreateMkStream(ctx, stream, group, "0").Err()
 	if err != nil {
@@ -105,3 +424,66 @@ func (r *redisStream) ensureGroup(ctx context.Context, stream, group string) err
 	}
 	return nil
 }
+
+// Backoff returns the delay before the given retry attempt: base doubled for
+// every attempt after the first, capped at maxDelay.
+func Backoff(attempt int, base, maxDelay time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// newMessage lifts the retry bookkeeping out of the raw stream fields.
+func newMessage(entry redis.XMessage, pending bool) Message {
+	msg := Message{
+		ID:       entry.ID,
+		Values:   entry.Values,
+		Pending:  pending,
+		OriginID: entry.ID,
+	}
+	if msg.Values == nil {
+		msg.Values = map[string]any{}
+	}
+	if raw := stringField(msg.Values, fieldAttempt); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil {
+			msg.Attempt = n
+		}
+	}
+	if origin := stringField(msg.Values, fieldOriginID); origin != "" {
+		msg.OriginID = origin
+	}
+	delete(msg.Values, fieldAttempt)
+	delete(msg.Values, fieldOriginID)
+	return msg
+}
+
+// messageFields renders msg back into stream fields for redelivery.
+func messageFields(msg Message, attempt int) map[string]any {
+	fields := make(map[string]any, len(msg.Values)+2)
+	for k, v := range msg.Values {
+		fields[k] = fmt.Sprint(v)
+	}
+	origin := msg.OriginID
+	if origin == "" {
+		origin = msg.ID
+	}
+	fields[fieldOriginID] = origin
+	fields[fieldAttempt] = strconv.Itoa(attempt)
+	return fields
+}
+
+func stringField(values map[string]any, key string) string {
+	v, _ := values[key].(string)
+	return v
+}
@@ -0,0 +1,45 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestBackoffDoublesUpToMax(t *testing.T) {
+	base, maxDelay := 5*time.Second, time.Minute
+	cases := map[int]time.Duration{
+		0: 5 * time.Second,
+		1: 5 * time.Second,
+		2: 10 * time.Second,
+		3: 20 * time.Second,
+		4: 40 * time.Second,
+		5: time.Minute,
+		9: time.Minute,
+	}
+	for attempt, want := range cases {
+		if got := Backoff(attempt, base, maxDelay); got != want {
+			t.Fatalf("attempt %d: expected %s, got %s", attempt, want, got)
+		}
+	}
+}
+
+func TestMessageRoundTripKeepsOriginAndAttempt(t *testing.T) {
+	first := newMessage(redis.XMessage{ID: "1-0", Values: map[string]any{"episode_id": "abc"}}, false)
+	if first.OriginID != "1-0" || first.Attempt != 0 {
+		t.Fatalf("unexpected first delivery %#v", first)
+	}
+
+	fields := messageFields(first, 1)
+	retried := newMessage(redis.XMessage{ID: "7-0", Values: fields}, false)
+	if retried.OriginID != "1-0" || retried.Attempt != 1 {
+		t.Fatalf("expected origin and attempt to survive the retry, got %#v", retried)
+	}
+	if _, ok := retried.Values[fieldAttempt]; ok {
+		t.Fatalf("expected bookkeeping fields to be stripped, got %#v", retried.Values)
+	}
+	if retried.Values["episode_id"] != "abc" {
+		t.Fatalf("expected payload to survive the retry, got %#v", retried.Values)
+	}
+}
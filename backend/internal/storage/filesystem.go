@@ -0,0 +1,392 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetadataContentType is the metadata key PutObject reads the object's
+// content type from on clients that keep it separately.
+const MetadataContentType = "content-type"
+
+// LocalObjectsPath is the API path that serves and receives objects for the
+// filesystem client.
+const LocalObjectsPath = "/v1/storage/objects/"
+
+const sidecarSuffix = ".meta.json"
+
+// ErrInvalidSignature is returned for expired or tampered local object URLs.
+var ErrInvalidSignature = errors.New("invalid or expired object signature")
+
+// ErrInvalidKey is returned for keys that would escape the storage root.
+var ErrInvalidKey = errors.New("invalid object key")
+
+// FSConfig configures the filesystem-backed client.
+type FSConfig struct {
+	// Root is the directory objects are written under.
+	Root string
+	// BaseURL is the public URL of the API that serves LocalObjectsPath.
+	BaseURL string
+	// SigningSecret signs presigned upload and download URLs. It must not
+	// be shared with any other key, such as the JWT secret.
+	SigningSecret string
+}
+
+// sidecar is the JSON stored next to each object.
+type sidecar struct {
+	ContentType string            `json:"content_type,omitempty"`
+	ETag        string            `json:"etag,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// FSClient stores objects on local disk. Presigned uploads point back at the
+// API, which verifies the signature and streams the body to disk.
+type FSClient struct {
+	root    string
+	baseURL string
+	secret  []byte
+	now     func() time.Time
+}
+
+// NewFSClient creates a filesystem-backed client rooted at cfg.Root.
+func NewFSClient(cfg FSConfig) (*FSClient, error) {
+	if cfg.Root == "" {
+		return nil, fmt.Errorf("%w: root", ErrIncompleteConfig)
+	}
+	if cfg.SigningSecret == "" {
+		return nil, fmt.Errorf("%w: signing secret", ErrIncompleteConfig)
+	}
+	root, err := filepath.Abs(cfg.Root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &FSClient{
+		root:    root,
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		secret:  []byte(cfg.SigningSecret),
+		now:     time.Now,
+	}, nil
+}
+
+func (c *FSClient) PutObject(ctx context.Context, key string, body io.Reader, metadata map[string]string) (string, error) {
+	target, err := c.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", err
+	}
+
+	// Write to a temp file and rename so readers never see a partial object.
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), contextReader{ctx: ctx, r: body}); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	meta := sidecar{ETag: hex.EncodeToString(hash.Sum(nil))}
+	for k, v := range metadata {
+		if strings.EqualFold(k, MetadataContentType) {
+			meta.ContentType = v
+			continue
+		}
+		if meta.Metadata == nil {
+			meta.Metadata = make(map[string]string, len(metadata))
+		}
+		meta.Metadata[k] = v
+	}
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(target+sidecarSuffix, raw, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", err
+	}
+	return c.objectURL(key), nil
+}
+
+func (c *FSClient) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	target, err := c.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return file, err
+}
+
+func (c *FSClient) DeleteObject(ctx context.Context, key string) error {
+	target, err := c.path(key)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{target, target + sidecarSuffix} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	c.pruneEmptyDirs(filepath.Dir(target))
+	return nil
+}
+
+func (c *FSClient) HeadObject(ctx context.Context, key string) (ObjectInfo, error) {
+	target, err := c.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	fi, err := os.Stat(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return c.objectInfo(strings.TrimPrefix(key, "/"), target, fi)
+}
+
+func (c *FSClient) ListPrefix(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+	// Only walk the deepest directory the prefix names.
+	dir := c.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		target, err := c.path(prefix[:i])
+		if err != nil {
+			return nil, err
+		}
+		dir = target
+	}
+
+	var objects []ObjectInfo
+	err := filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		base := entry.Name()
+		if entry.IsDir() && name == filepath.Join(c.root, multipartDir) {
+			return filepath.SkipDir
+		}
+		if entry.IsDir() || strings.HasSuffix(base, sidecarSuffix) || strings.HasPrefix(base, ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(c.root, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		info, err := c.objectInfo(key, name, fi)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (c *FSClient) CopyObject(ctx context.Context, srcKey, dstKey string) error {
+	info, err := c.HeadObject(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	reader, err := c.GetObject(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	metadata := make(map[string]string, len(info.Metadata)+1)
+	for k, v := range info.Metadata {
+		metadata[k] = v
+	}
+	if info.ContentType != "" {
+		metadata[MetadataContentType] = info.ContentType
+	}
+	_, err = c.PutObject(ctx, dstKey, reader, metadata)
+	return err
+}
+
+// objectInfo combines file stats with the sidecar, if any.
+func (c *FSClient) objectInfo(key, target string, fi fs.FileInfo) (ObjectInfo, error) {
+	info := ObjectInfo{Key: key, Size: fi.Size(), LastModified: fi.ModTime().UTC()}
+	raw, err := os.ReadFile(target + sidecarSuffix)
+	switch {
+	case err == nil:
+		var meta sidecar
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return ObjectInfo{}, err
+		}
+		info.ContentType = meta.ContentType
+		info.ETag = meta.ETag
+		info.Metadata = meta.Metadata
+	case !errors.Is(err, os.ErrNotExist):
+		return ObjectInfo{}, err
+	}
+	if info.ETag == "" {
+		info.ETag = fmt.Sprintf("%x-%x", fi.ModTime().UnixNano(), fi.Size())
+	}
+	return info, nil
+}
+
+// pruneEmptyDirs removes now-empty parents of a deleted object up to the root.
+func (c *FSClient) pruneEmptyDirs(dir string) {
+	for dir != c.root && strings.HasPrefix(dir, c.root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func (c *FSClient) PresignUpload(ctx context.Context, key string, ttl time.Duration, contentType string) (PresignedUpload, error) {
+	if _, err := c.path(key); err != nil {
+		return PresignedUpload{}, err
+	}
+	expires := c.now().Add(ttl).Unix()
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	if contentType != "" {
+		query.Set("content_type", contentType)
+	}
+	query.Set("signature", c.sign(http.MethodPut, key, expires, uploadScope(contentType)))
+
+	headers := http.Header{}
+	if contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+	return PresignedUpload{
+		URL:     c.objectURL(key) + "?" + query.Encode(),
+		Method:  http.MethodPut,
+		Headers: headers,
+	}, nil
+}
+
+func (c *FSClient) PresignDownload(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if _, err := c.path(key); err != nil {
+		return "", err
+	}
+	expires := c.now().Add(ttl).Unix()
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", c.sign(http.MethodGet, key, expires, ""))
+	return c.objectURL(key) + "?" + query.Encode(), nil
+}
+
+// VerifyUpload checks the query string of a presigned upload URL and returns
+// the content type it was signed for.
+func (c *FSClient) VerifyUpload(key string, query url.Values) (string, error) {
+	contentType := query.Get("content_type")
+	if err := c.verify(http.MethodPut, key, uploadScope(contentType), query); err != nil {
+		return "", err
+	}
+	return contentType, nil
+}
+
+// VerifyDownload checks the query string of a presigned download URL.
+func (c *FSClient) VerifyDownload(key string, query url.Values) error {
+	return c.verify(http.MethodGet, key, "", query)
+}
+
+func (c *FSClient) verify(method, key, scope string, query url.Values) error {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || c.now().Unix() > expires {
+		return ErrInvalidSignature
+	}
+	expected := c.sign(method, key, expires, scope)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// sign binds a URL to method, key and expiry. scope carries whatever else the
+// URL is restricted to: the content type of uploads or the part of multipart
+// uploads.
+func (c *FSClient) sign(method, key string, expires int64, scope string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%s", method, key, expires, scope)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func uploadScope(contentType string) string {
+	return "content-type:" + contentType
+}
+
+func (c *FSClient) objectURL(key string) string {
+	return c.baseURL + LocalObjectsPath + strings.TrimPrefix(key, "/")
+}
+
+// path maps a key to a file under the root, rejecting keys that would escape
+// it or collide with sidecars and multipart uploads.
+func (c *FSClient) path(key string) (string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if key == "" || strings.HasSuffix(key, sidecarSuffix) || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." ||
+		key == multipartDir || strings.HasPrefix(key, multipartDir+"/") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return filepath.Join(c.root, filepath.FromSlash(key)), nil
+}
+
+// contextReader stops long copies once the request is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// multipartDir holds in-progress multipart uploads under the storage root.
+// Keys may not start with it, so uploads never collide with objects.
+const multipartDir = ".multipart"
+
+const (
+	uploadManifest = "upload.json"
+	partSuffix     = ".part"
+	etagSuffix     = ".etag"
+)
+
+type uploadManifestFile struct {
+	Key         string    `json:"key"`
+	ContentType string    `json:"content_type,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (c *FSClient) CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	if _, err := c.path(key); err != nil {
+		return "", err
+	}
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	uploadID := hex.EncodeToString(raw)
+
+	dir := filepath.Join(c.root, multipartDir, uploadID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	manifest, err := json.Marshal(uploadManifestFile{
+		Key:         strings.TrimPrefix(key, "/"),
+		ContentType: contentType,
+		CreatedAt:   c.now().UTC(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, uploadManifest), manifest, 0o644); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+func (c *FSClient) PresignUploadPart(ctx context.Context, key, uploadID string, partNumber int32, ttl time.Duration) (PresignedUpload, error) {
+	if partNumber < 1 || partNumber > MaxUploadParts {
+		return PresignedUpload{}, fmt.Errorf("%w: part number %d", ErrInvalidPart, partNumber)
+	}
+	if _, _, err := c.upload(key, uploadID); err != nil {
+		return PresignedUpload{}, err
+	}
+	expires := c.now().Add(ttl).Unix()
+
+	query := url.Values{}
+	query.Set("upload_id", uploadID)
+	query.Set("part_number", strconv.Itoa(int(partNumber)))
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", c.sign(http.MethodPut, key, expires, partScope(uploadID, partNumber)))
+	return PresignedUpload{
+		URL:     c.objectURL(key) + "?" + query.Encode(),
+		Method:  http.MethodPut,
+		Headers: http.Header{},
+	}, nil
+}
+
+// VerifyUploadPart checks the query string of a presigned part URL and returns
+// the upload and part it was signed for.
+func (c *FSClient) VerifyUploadPart(key string, query url.Values) (string, int32, error) {
+	uploadID := query.Get("upload_id")
+	partNumber, err := strconv.ParseInt(query.Get("part_number"), 10, 32)
+	if err != nil || uploadID == "" {
+		return "", 0, ErrInvalidSignature
+	}
+	if err := c.verify(http.MethodPut, key, partScope(uploadID, int32(partNumber)), query); err != nil {
+		return "", 0, err
+	}
+	return uploadID, int32(partNumber), nil
+}
+
+// PutPart stores one part of a multipart upload and returns its ETag.
+// Re-uploading a part replaces it.
+func (c *FSClient) PutPart(ctx context.Context, key, uploadID string, partNumber int32, body io.Reader) (string, error) {
+	if partNumber < 1 || partNumber > MaxUploadParts {
+		return "", fmt.Errorf("%w: part number %d", ErrInvalidPart, partNumber)
+	}
+	dir, _, err := c.upload(key, uploadID)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), contextReader{ctx: ctx, r: body}); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	etag := hex.EncodeToString(hash.Sum(nil))
+	name := filepath.Join(dir, partName(partNumber))
+	if err := os.WriteFile(name+etagSuffix, []byte(etag), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), name+partSuffix); err != nil {
+		return "", err
+	}
+	return etag, nil
+}
+
+func (c *FSClient) ListParts(ctx context.Context, key, uploadID string) ([]UploadedPart, error) {
+	dir, _, err := c.upload(key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []UploadedPart
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, partSuffix) {
+			continue
+		}
+		number, err := strconv.ParseInt(strings.TrimSuffix(name, partSuffix), 10, 32)
+		if err != nil {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		etag, err := os.ReadFile(filepath.Join(dir, strings.TrimSuffix(name, partSuffix)+etagSuffix))
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, UploadedPart{Number: int32(number), ETag: string(etag), Size: fi.Size()})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts, nil
+}
+
+func (c *FSClient) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []UploadedPart) (string, error) {
+	dir, manifest, err := c.upload(key, uploadID)
+	if err != nil {
+		return "", err
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("%w: no parts", ErrInvalidPart)
+	}
+	uploaded, err := c.ListParts(ctx, key, uploadID)
+	if err != nil {
+		return "", err
+	}
+	etags := make(map[int32]string, len(uploaded))
+	for _, part := range uploaded {
+		etags[part.Number] = part.ETag
+	}
+
+	readers := make([]io.Reader, 0, len(parts))
+	var last int32
+	for _, part := range parts {
+		if part.Number <= last {
+			return "", fmt.Errorf("%w: parts must be in ascending order", ErrInvalidPart)
+		}
+		last = part.Number
+		if etag, ok := etags[part.Number]; !ok || !strings.EqualFold(etag, strings.Trim(part.ETag, `"`)) {
+			return "", fmt.Errorf("%w: part %d", ErrInvalidPart, part.Number)
+		}
+		file, err := os.Open(filepath.Join(dir, partName(part.Number)+partSuffix))
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		readers = append(readers, file)
+	}
+
+	var metadata map[string]string
+	if manifest.ContentType != "" {
+		metadata = map[string]string{MetadataContentType: manifest.ContentType}
+	}
+	objectURL, err := c.PutObject(ctx, key, io.MultiReader(readers...), metadata)
+	if err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	return objectURL, nil
+}
+
+func (c *FSClient) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	dir, _, err := c.upload(key, uploadID)
+	if errors.Is(err, ErrUploadNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// upload resolves an in-progress upload and checks it belongs to key.
+func (c *FSClient) upload(key, uploadID string) (string, uploadManifestFile, error) {
+	if _, err := hex.DecodeString(uploadID); err != nil || uploadID == "" {
+		return "", uploadManifestFile{}, ErrUploadNotFound
+	}
+	dir := filepath.Join(c.root, multipartDir, uploadID)
+	raw, err := os.ReadFile(filepath.Join(dir, uploadManifest))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", uploadManifestFile{}, ErrUploadNotFound
+	}
+	if err != nil {
+		return "", uploadManifestFile{}, err
+	}
+	var manifest uploadManifestFile
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return "", uploadManifestFile{}, err
+	}
+	if manifest.Key != strings.TrimPrefix(key, "/") {
+		return "", uploadManifestFile{}, ErrUploadNotFound
+	}
+	return dir, manifest, nil
+}
+
+func partName(partNumber int32) string {
+	return fmt.Sprintf("%05d", partNumber)
+}
+
+func partScope(uploadID string, partNumber int32) string {
+	return "part:" + uploadID + ":" + strconv.Itoa(int(partNumber))
+}
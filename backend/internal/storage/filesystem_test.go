@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestFSClient(t *testing.T) *FSClient {
+	t.Helper()
+	client, err := NewFSClient(FSConfig{Root: t.TempDir(), BaseURL: "http://api.test/", SigningSecret: "secret"})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return client
+}
+
+func TestFSClientPutGetWithSidecar(t *testing.T) {
+	client := newTestFSClient(t)
+	ctx := context.Background()
+
+	url, err := client.PutObject(ctx, "episodes/1/original", strings.NewReader("audio"), map[string]string{
+		"Content-Type": "audio/mp4",
+		"processed":    "false",
+	})
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if url != "http://api.test/v1/storage/objects/episodes/1/original" {
+		t.Fatalf("unexpected object url %q", url)
+	}
+
+	reader, err := client.GetObject(ctx, "episodes/1/original")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	body, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(body) != "audio" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	info, err := client.HeadObject(ctx, "episodes/1/original")
+	if err != nil {
+		t.Fatalf("head: %v", err)
+	}
+	if info.ContentType != "audio/mp4" || info.Size != 5 || info.Metadata["processed"] != "false" || info.ETag != "a5ca0b5894324f8bb54bb9fffad29d1e" {
+		t.Fatalf("unexpected info %#v", info)
+	}
+
+	if _, err := client.GetObject(ctx, "episodes/2/original"); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestFSClientRejectsEscapingKeys(t *testing.T) {
+	client := newTestFSClient(t)
+	for _, key := range []string{"../etc/passwd", "a/../../b", "", "episodes/1/original.meta.json"} {
+		if _, err := client.PutObject(context.Background(), key, strings.NewReader("x"), nil); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("key %q: expected invalid key, got %v", key, err)
+		}
+	}
+}
+
+func TestFSClientPresignedUploadSignature(t *testing.T) {
+	client := newTestFSClient(t)
+	now := time.Unix(1_700_000_000, 0)
+	client.now = func() time.Time { return now }
+
+	upload, err := client.PresignUpload(context.Background(), "episodes/1/original", time.Minute, "audio/mp4")
+	if err != nil {
+		t.Fatalf("presign: %v", err)
+	}
+	parsed, err := url.Parse(upload.URL)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+	if parsed.Path != "/v1/storage/objects/episodes/1/original" || upload.Headers.Get("Content-Type") != "audio/mp4" {
+		t.Fatalf("unexpected presigned upload %#v", upload)
+	}
+
+	contentType, err := client.VerifyUpload("episodes/1/original", parsed.Query())
+	if err != nil || contentType != "audio/mp4" {
+		t.Fatalf("expected valid signature, got %q, %v", contentType, err)
+	}
+
+	if _, err := client.VerifyUpload("episodes/2/original", parsed.Query()); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected signature bound to key, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := client.VerifyUpload("episodes/1/original", parsed.Query()); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected expired signature, got %v", err)
+	}
+}
+
+func TestFSClientListCopyDelete(t *testing.T) {
+	client := newTestFSClient(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"episodes/1/original", "episodes/1/processed.opus", "episodes/10/original"} {
+		if _, err := client.PutObject(ctx, key, strings.NewReader(key), map[string]string{MetadataContentType: "audio/ogg"}); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+
+	objects, err := client.ListPrefix(ctx, "episodes/1/")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(objects) != 2 || objects[0].Key != "episodes/1/original" || objects[1].Key != "episodes/1/processed.opus" {
+		t.Fatalf("unexpected listing %#v", objects)
+	}
+
+	if err := client.CopyObject(ctx, "episodes/1/original", "archive/1/original"); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	copied, err := client.HeadObject(ctx, "archive/1/original")
+	if err != nil || copied.ContentType != "audio/ogg" || copied.ETag != objects[0].ETag {
+		t.Fatalf("unexpected copy %#v, %v", copied, err)
+	}
+
+	for _, obj := range objects {
+		if err := client.DeleteObject(ctx, obj.Key); err != nil {
+			t.Fatalf("delete %s: %v", obj.Key, err)
+		}
+	}
+	if err := client.DeleteObject(ctx, "episodes/1/original"); err != nil {
+		t.Fatalf("expected deleting a missing key to succeed, got %v", err)
+	}
+	if _, err := client.HeadObject(ctx, "episodes/1/original"); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+	remaining, err := client.ListPrefix(ctx, "episodes/")
+	if err != nil || len(remaining) != 1 || remaining[0].Key != "episodes/10/original" {
+		t.Fatalf("unexpected remaining objects %#v, %v", remaining, err)
+	}
+}
+
+func TestFSClientPresignedDownloadSignature(t *testing.T) {
+	client := newTestFSClient(t)
+	now := time.Unix(1_700_000_000, 0)
+	client.now = func() time.Time { return now }
+
+	raw, err := client.PresignDownload(context.Background(), "episodes/1/processed.opus", time.Minute)
+	if err != nil {
+		t.Fatalf("presign: %v", err)
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+	if err := client.VerifyDownload("episodes/1/processed.opus", parsed.Query()); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+	if _, err := client.VerifyUpload("episodes/1/processed.opus", parsed.Query()); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected download signature to be rejected for uploads, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := client.VerifyDownload("episodes/1/processed.opus", parsed.Query()); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected expired signature, got %v", err)
+	}
+}
+
+func TestFSClientMultipartUpload(t *testing.T) {
+	client := newTestFSClient(t)
+	ctx := context.Background()
+	key := "episodes/1/original"
+
+	uploadID, err := client.CreateMultipartUpload(ctx, key, "audio/mp4")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	upload, err := client.PresignUploadPart(ctx, key, uploadID, 2, time.Minute)
+	if err != nil {
+		t.Fatalf("presign part: %v", err)
+	}
+	parsed, _ := url.Parse(upload.URL)
+	gotID, part, err := client.VerifyUploadPart(key, parsed.Query())
+	if err != nil || gotID != uploadID || part != 2 {
+		t.Fatalf("unexpected part verification %q %d %v", gotID, part, err)
+	}
+	if _, err := client.VerifyUpload(key, parsed.Query()); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected part signature to be rejected for whole uploads, got %v", err)
+	}
+
+	// Parts may arrive out of order and be retried.
+	for _, p := range []struct {
+		number int32
+		body   string
+	}{{2, "world"}, {1, "hullo "}, {1, "hello "}} {
+		if _, err := client.PutPart(ctx, key, uploadID, p.number, strings.NewReader(p.body)); err != nil {
+			t.Fatalf("put part %d: %v", p.number, err)
+		}
+	}
+
+	parts, err := client.ListParts(ctx, key, uploadID)
+	if err != nil || len(parts) != 2 || parts[0].Number != 1 || parts[0].Size != 6 {
+		t.Fatalf("unexpected parts %#v, %v", parts, err)
+	}
+	if _, err := client.CompleteMultipartUpload(ctx, key, uploadID, []UploadedPart{{Number: 1, ETag: "bogus"}}); !errors.Is(err, ErrInvalidPart) {
+		t.Fatalf("expected etag mismatch, got %v", err)
+	}
+	if _, err := client.CompleteMultipartUpload(ctx, key, uploadID, parts); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+
+	reader, err := client.GetObject(ctx, key)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	body, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(body) != "hello world" {
+		t.Fatalf("unexpected assembled body %q", body)
+	}
+	if info, _ := client.HeadObject(ctx, key); info.ContentType != "audio/mp4" {
+		t.Fatalf("expected content type from the upload, got %q", info.ContentType)
+	}
+	if _, err := client.ListParts(ctx, key, uploadID); !errors.Is(err, ErrUploadNotFound) {
+		t.Fatalf("expected completed upload to be gone, got %v", err)
+	}
+	if err := client.AbortMultipartUpload(ctx, key, uploadID); err != nil {
+		t.Fatalf("expected aborting a finished upload to succeed, got %v", err)
+	}
+	if objects, _ := client.ListPrefix(ctx, ""); len(objects) != 1 {
+		t.Fatalf("expected multipart scratch space to stay out of listings, got %#v", objects)
+	}
+}
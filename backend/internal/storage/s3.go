@@ -2,9 +2,11 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -87,6 +89,10 @@ func (c *s3Client) PutObject(ctx context.Context, key string, body io.Reader, me
 	if len(metadata) > 0 {
 		md := make(map[string]string, len(metadata))
 		for k, v := range metadata {
+			if strings.EqualFold(k, MetadataContentType) {
+				input.ContentType = aws.String(v)
+				continue
+			}
 			md[k] = v
 		}
 		input.Metadata = md
@@ -131,7 +137,200 @@ func (c *s3Client) GetObject(ctx context.Context, key string) (io.ReadCloser, er
 		Key:    aws.String(key),
 	})
 	if err != nil {
-		return nil, err
+		return nil, mapS3Error(err)
 	}
 	return resp.Body, nil
 }
+
+func (c *s3Client) DeleteObject(ctx context.Context, key string) error {
+	_, err := c.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (c *s3Client) HeadObject(ctx context.Context, key string) (ObjectInfo, error) {
+	resp, err := c.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return ObjectInfo{}, mapS3Error(err)
+	}
+	return ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(resp.ContentLength),
+		ETag:         strings.Trim(aws.ToString(resp.ETag), `"`),
+		ContentType:  aws.ToString(resp.ContentType),
+		LastModified: aws.ToTime(resp.LastModified),
+		Metadata:     resp.Metadata,
+	}, nil
+}
+
+func (c *s3Client) ListPrefix(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	paginator := s3.NewListObjectsV2Paginator(c.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(c.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	var objects []ObjectInfo
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				ETag:         strings.Trim(aws.ToString(obj.ETag), `"`),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
+
+func (c *s3Client) CopyObject(ctx context.Context, srcKey, dstKey string) error {
+	_, err := c.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(c.bucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(url.PathEscape(c.bucket) + "/" + escapeKey(srcKey)),
+	})
+	return mapS3Error(err)
+}
+
+func (c *s3Client) PresignDownload(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	request, err := c.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = ttl
+	})
+	if err != nil {
+		return "", err
+	}
+	return request.URL, nil
+}
+
+func (c *s3Client) CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+		ACL:    types.ObjectCannedACLPrivate,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	resp, err := c.client.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(resp.UploadId), nil
+}
+
+func (c *s3Client) PresignUploadPart(ctx context.Context, key, uploadID string, partNumber int32, ttl time.Duration) (PresignedUpload, error) {
+	request, err := c.presign.PresignUploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(c.bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(partNumber),
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = ttl
+	})
+	if err != nil {
+		return PresignedUpload{}, err
+	}
+	return PresignedUpload{
+		URL:     request.URL,
+		Method:  http.MethodPut,
+		Headers: request.SignedHeader,
+	}, nil
+}
+
+func (c *s3Client) ListParts(ctx context.Context, key, uploadID string) ([]UploadedPart, error) {
+	paginator := s3.NewListPartsPaginator(c.client, &s3.ListPartsInput{
+		Bucket:   aws.String(c.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+
+	var parts []UploadedPart
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, mapS3Error(err)
+		}
+		for _, part := range page.Parts {
+			parts = append(parts, UploadedPart{
+				Number: aws.ToInt32(part.PartNumber),
+				ETag:   strings.Trim(aws.ToString(part.ETag), `"`),
+				Size:   aws.ToInt64(part.Size),
+			})
+		}
+	}
+	return parts, nil
+}
+
+func (c *s3Client) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []UploadedPart) (string, error) {
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, types.CompletedPart{
+			ETag:       aws.String(`"` + strings.Trim(part.ETag, `"`) + `"`),
+			PartNumber: aws.Int32(part.Number),
+		})
+	}
+	_, err := c.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(c.bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		var apiErr interface{ ErrorCode() string }
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "InvalidPart" || apiErr.ErrorCode() == "InvalidPartOrder") {
+			return "", fmt.Errorf("%w: %v", ErrInvalidPart, err)
+		}
+		return "", mapS3Error(err)
+	}
+	return c.objectURL(key), nil
+}
+
+func (c *s3Client) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	_, err := c.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(c.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if errors.Is(mapS3Error(err), ErrUploadNotFound) {
+		return nil
+	}
+	return err
+}
+
+// escapeKey URL-encodes each path segment of key for use in CopySource.
+func escapeKey(key string) string {
+	segments := strings.Split(strings.TrimPrefix(key, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// mapS3Error translates missing-key and missing-upload errors into the
+// package sentinels.
+func mapS3Error(err error) error {
+	var (
+		noSuchKey    *types.NoSuchKey
+		notFound     *types.NotFound
+		noSuchUpload *types.NoSuchUpload
+	)
+	switch {
+	case errors.As(err, &noSuchKey), errors.As(err, &notFound):
+		return fmt.Errorf("%w: %v", ErrObjectNotFound, err)
+	case errors.As(err, &noSuchUpload):
+		return fmt.Errorf("%w: %v", ErrUploadNotFound, err)
+	}
+	return err
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,6 +14,46 @@ type Client interface {
 	PutObject(ctx context.Context, key string, body io.Reader, metadata map[string]string) (string, error)
 	PresignUpload(ctx context.Context, key string, ttl time.Duration, contentType string) (PresignedUpload, error)
 	GetObject(ctx context.Context, key string) (io.ReadCloser, error)
+	// DeleteObject removes key. Deleting a missing key is not an error.
+	DeleteObject(ctx context.Context, key string) error
+	// HeadObject returns ErrObjectNotFound when key does not exist.
+	HeadObject(ctx context.Context, key string) (ObjectInfo, error)
+	// ListPrefix returns every object whose key starts with prefix.
+	ListPrefix(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	CopyObject(ctx context.Context, srcKey, dstKey string) error
+	// PresignDownload returns a URL that reads key until ttl elapses.
+	PresignDownload(ctx context.Context, key string, ttl time.Duration) (string, error)
+
+	// Multipart uploads let large objects arrive as independently retried
+	// parts, numbered from 1. The object only appears once completed.
+	CreateMultipartUpload(ctx context.Context, key, contentType string) (uploadID string, err error)
+	PresignUploadPart(ctx context.Context, key, uploadID string, partNumber int32, ttl time.Duration) (PresignedUpload, error)
+	ListParts(ctx context.Context, key, uploadID string) ([]UploadedPart, error)
+	// CompleteMultipartUpload assembles parts in order and returns the object URL.
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []UploadedPart) (string, error)
+	// AbortMultipartUpload discards uploaded parts. Aborting an unknown upload
+	// is not an error.
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+}
+
+// UploadedPart identifies one part of a multipart upload.
+type UploadedPart struct {
+	Number int32
+	ETag   string
+	Size   int64
+}
+
+// MaxUploadParts is the most parts a multipart upload may have.
+const MaxUploadParts = 10000
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	ContentType  string
+	LastModified time.Time
+	Metadata     map[string]string
 }
 
 // PresignedUpload holds metadata for generated upload URLs.
@@ -25,6 +66,16 @@ type PresignedUpload struct {
 // ErrNotImplemented signals functionality not yet implemented.
 var ErrNotImplemented = fmt.Errorf("not implemented")
 
+// ErrObjectNotFound is returned when a key does not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrUploadNotFound is returned for unknown or already finished multipart uploads.
+var ErrUploadNotFound = errors.New("multipart upload not found")
+
+// ErrInvalidPart is returned when completion names a part that was not
+// uploaded or whose ETag does not match.
+var ErrInvalidPart = errors.New("invalid multipart upload part")
+
 // ErrIncompleteConfig indicates required configuration is missing.
 var ErrIncompleteConfig = fmt.Errorf("storage configuration incomplete")
 
@@ -46,3 +97,43 @@ func (noopClient) PresignUpload(context.Context, string, time.Duration, string)
 func (noopClient) GetObject(context.Context, string) (io.ReadCloser, error) {
 	return nil, ErrNotImplemented
 }
+
+func (noopClient) DeleteObject(context.Context, string) error {
+	return ErrNotImplemented
+}
+
+func (noopClient) HeadObject(context.Context, string) (ObjectInfo, error) {
+	return ObjectInfo{}, ErrNotImplemented
+}
+
+func (noopClient) ListPrefix(context.Context, string) ([]ObjectInfo, error) {
+	return nil, ErrNotImplemented
+}
+
+func (noopClient) CopyObject(context.Context, string, string) error {
+	return ErrNotImplemented
+}
+
+func (noopClient) PresignDownload(context.Context, string, time.Duration) (string, error) {
+	return "", ErrNotImplemented
+}
+
+func (noopClient) CreateMultipartUpload(context.Context, string, string) (string, error) {
+	return "", ErrNotImplemented
+}
+
+func (noopClient) PresignUploadPart(context.Context, string, string, int32, time.Duration) (PresignedUpload, error) {
+	return PresignedUpload{}, ErrNotImplemented
+}
+
+func (noopClient) ListParts(context.Context, string, string) ([]UploadedPart, error) {
+	return nil, ErrNotImplemented
+}
+
+func (noopClient) CompleteMultipartUpload(context.Context, string, string, []UploadedPart) (string, error) {
+	return "", ErrNotImplemented
+}
+
+func (noopClient) AbortMultipartUpload(context.Context, string, string) error {
+	return ErrNotImplemented
+}
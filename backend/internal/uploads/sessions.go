@@ -0,0 +1,357 @@
+package uploads
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/storage"
+)
+
+const (
+	// MinPartSize is the smallest part object storage accepts, except for
+	// the last part.
+	MinPartSize int64 = 5 << 20
+	// DefaultPartSize balances retry cost on flaky mobile links against the
+	// number of requests.
+	DefaultPartSize int64 = 16 << 20
+	// DefaultSessionTTL is how long a session may stay open before the
+	// reaper aborts it.
+	DefaultSessionTTL = 24 * time.Hour
+	// DefaultPartURLTTL bounds each presigned part URL.
+	DefaultPartURLTTL = time.Hour
+
+	maxPartsPerRequest = 100
+	reapBatchSize      = 100
+)
+
+// Session statuses.
+const (
+	StatusActive    = "active"
+	StatusCompleted = "completed"
+	StatusAborted   = "aborted"
+)
+
+var (
+	// ErrSessionNotFound is returned for unknown sessions and sessions owned
+	// by someone else.
+	ErrSessionNotFound = errors.New("upload session not found")
+	// ErrSessionClosed is returned when a session was completed, aborted or
+	// has expired.
+	ErrSessionClosed = errors.New("upload session is closed")
+	// ErrIncompleteUpload is returned by Complete when uploaded parts do not
+	// add up to the declared size.
+	ErrIncompleteUpload = errors.New("upload is incomplete")
+	// ErrInvalidPartNumber is returned for part numbers outside 1..MaxUploadParts.
+	ErrInvalidPartNumber = errors.New("invalid part number")
+)
+
+// Options tunes upload sessions.
+type Options struct {
+	PartSize   int64
+	SessionTTL time.Duration
+	PartURLTTL time.Duration
+}
+
+// Session is a resumable multipart upload of one object.
+type Session struct {
+	ID          uuid.UUID
+	OwnerID     uuid.UUID
+	StorageKey  string
+	UploadID    string
+	ContentType string
+	// SizeBytes is zero when the client did not declare a size.
+	SizeBytes int64
+	PartSize  int64
+	Status    string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// PartCount is the number of parts the declared size splits into, or zero
+// when the size is unknown.
+func (s Session) PartCount() int {
+	if s.SizeBytes <= 0 || s.PartSize <= 0 {
+		return 0
+	}
+	return int((s.SizeBytes + s.PartSize - 1) / s.PartSize)
+}
+
+// PartURL is a presigned URL for uploading one part.
+type PartURL struct {
+	Number    int32
+	Upload    storage.PresignedUpload
+	ExpiresAt time.Time
+}
+
+// Sessions tracks multipart uploads in the database on top of a
+// storage.Client.
+type Sessions struct {
+	DB      *sql.DB
+	Storage storage.Client
+	opts    Options
+	now     func() time.Time
+}
+
+// NewSessions constructs a session manager.
+func NewSessions(db *sql.DB, store storage.Client, opts Options) *Sessions {
+	if opts.PartSize < MinPartSize {
+		opts.PartSize = DefaultPartSize
+	}
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = DefaultSessionTTL
+	}
+	if opts.PartURLTTL <= 0 {
+		opts.PartURLTTL = DefaultPartURLTTL
+	}
+	return &Sessions{DB: db, Storage: store, opts: opts, now: time.Now}
+}
+
+// Initiate starts a multipart upload for key. sizeBytes may be zero when
+// unknown; when known, the part size grows as needed to stay within
+// storage.MaxUploadParts.
+func (s *Sessions) Initiate(ctx context.Context, ownerID uuid.UUID, key, contentType string, sizeBytes int64) (Session, error) {
+	partSize := s.opts.PartSize
+	if sizeBytes > 0 {
+		if minimum := (sizeBytes + storage.MaxUploadParts - 1) / storage.MaxUploadParts; minimum > partSize {
+			partSize = minimum
+		}
+	}
+
+	uploadID, err := s.Storage.CreateMultipartUpload(ctx, key, contentType)
+	if err != nil {
+		return Session{}, err
+	}
+
+	now := s.now().UTC()
+	session := Session{
+		ID:          uuid.New(),
+		OwnerID:     ownerID,
+		StorageKey:  key,
+		UploadID:    uploadID,
+		ContentType: contentType,
+		SizeBytes:   sizeBytes,
+		PartSize:    partSize,
+		Status:      StatusActive,
+		ExpiresAt:   now.Add(s.opts.SessionTTL),
+		CreatedAt:   now,
+	}
+
+	const stmt = `
+INSERT INTO upload_sessions (id, owner_id, storage_key, upload_id, content_type, size_bytes, part_size, status, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	if _, err := s.DB.ExecContext(ctx, stmt,
+		session.ID, ownerID, key, uploadID, contentType, nullableSize(sizeBytes), partSize, StatusActive, session.ExpiresAt, now,
+	); err != nil {
+		_ = s.Storage.AbortMultipartUpload(ctx, key, uploadID)
+		return Session{}, fmt.Errorf("insert upload session: %w", err)
+	}
+	return session, nil
+}
+
+// Get returns a session owned by ownerID.
+func (s *Sessions) Get(ctx context.Context, id, ownerID uuid.UUID) (Session, error) {
+	const query = `
+SELECT id, owner_id, storage_key, upload_id, content_type, COALESCE(size_bytes, 0), part_size, status, expires_at, created_at
+  FROM upload_sessions
+ WHERE id = $1 AND owner_id = $2`
+
+	var session Session
+	err := s.DB.QueryRowContext(ctx, query, id, ownerID).Scan(
+		&session.ID,
+		&session.OwnerID,
+		&session.StorageKey,
+		&session.UploadID,
+		&session.ContentType,
+		&session.SizeBytes,
+		&session.PartSize,
+		&session.Status,
+		&session.ExpiresAt,
+		&session.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, ErrSessionNotFound
+	}
+	return session, err
+}
+
+// PresignParts returns upload URLs for the requested part numbers. Clients
+// resuming an upload ask only for the parts missing from Parts.
+func (s *Sessions) PresignParts(ctx context.Context, id, ownerID uuid.UUID, numbers []int32) ([]PartURL, error) {
+	if len(numbers) == 0 || len(numbers) > maxPartsPerRequest {
+		return nil, fmt.Errorf("%w: request between 1 and %d parts", ErrInvalidPartNumber, maxPartsPerRequest)
+	}
+	session, err := s.open(ctx, id, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if count := session.PartCount(); count > 0 {
+		for _, number := range numbers {
+			if int(number) > count {
+				return nil, fmt.Errorf("%w: %d exceeds %d parts", ErrInvalidPartNumber, number, count)
+			}
+		}
+	}
+
+	expiresAt := s.now().Add(s.opts.PartURLTTL).UTC()
+	urls := make([]PartURL, 0, len(numbers))
+	for _, number := range numbers {
+		if number < 1 || number > storage.MaxUploadParts {
+			return nil, fmt.Errorf("%w: %d", ErrInvalidPartNumber, number)
+		}
+		upload, err := s.Storage.PresignUploadPart(ctx, session.StorageKey, session.UploadID, number, s.opts.PartURLTTL)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, PartURL{Number: number, Upload: upload, ExpiresAt: expiresAt})
+	}
+	return urls, nil
+}
+
+// Parts lists the parts uploaded so far.
+func (s *Sessions) Parts(ctx context.Context, id, ownerID uuid.UUID) ([]storage.UploadedPart, error) {
+	session, err := s.open(ctx, id, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Storage.ListParts(ctx, session.StorageKey, session.UploadID)
+}
+
+// Complete assembles every uploaded part into the final object. When the
+// client declared a size, the parts must add up to it.
+func (s *Sessions) Complete(ctx context.Context, id, ownerID uuid.UUID) (Session, error) {
+	session, err := s.open(ctx, id, ownerID)
+	if err != nil {
+		return Session{}, err
+	}
+	parts, err := s.Storage.ListParts(ctx, session.StorageKey, session.UploadID)
+	if err != nil {
+		return Session{}, err
+	}
+	if len(parts) == 0 {
+		return Session{}, fmt.Errorf("%w: no parts uploaded", ErrIncompleteUpload)
+	}
+	if session.SizeBytes > 0 {
+		var total int64
+		for _, part := range parts {
+			total += part.Size
+		}
+		if total != session.SizeBytes {
+			return Session{}, fmt.Errorf("%w: %d of %d bytes uploaded", ErrIncompleteUpload, total, session.SizeBytes)
+		}
+	}
+
+	if _, err := s.Storage.CompleteMultipartUpload(ctx, session.StorageKey, session.UploadID, parts); err != nil {
+		if errors.Is(err, storage.ErrUploadNotFound) {
+			return Session{}, ErrSessionClosed
+		}
+		return Session{}, err
+	}
+	if err := s.setStatus(ctx, session.ID, StatusCompleted); err != nil {
+		return Session{}, err
+	}
+	session.Status = StatusCompleted
+	return session, nil
+}
+
+// Abort discards the uploaded parts and closes the session.
+func (s *Sessions) Abort(ctx context.Context, id, ownerID uuid.UUID) error {
+	session, err := s.Get(ctx, id, ownerID)
+	if err != nil {
+		return err
+	}
+	if session.Status != StatusActive {
+		return ErrSessionClosed
+	}
+	if err := s.Storage.AbortMultipartUpload(ctx, session.StorageKey, session.UploadID); err != nil {
+		return err
+	}
+	return s.setStatus(ctx, session.ID, StatusAborted)
+}
+
+// ReapExpired aborts active sessions that expired before now and returns how
+// many were reaped. Uploads already gone from storage count as aborted.
+func (s *Sessions) ReapExpired(ctx context.Context, now time.Time) (int, error) {
+	const query = `
+SELECT id, storage_key, upload_id
+  FROM upload_sessions
+ WHERE status = 'active' AND expires_at < $1
+ ORDER BY expires_at
+ LIMIT $2`
+
+	rows, err := s.DB.QueryContext(ctx, query, now, reapBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	type expired struct {
+		id       uuid.UUID
+		key      string
+		uploadID string
+	}
+	var sessions []expired
+	for rows.Next() {
+		var e expired
+		if err := rows.Scan(&e.id, &e.key, &e.uploadID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		sessions = append(sessions, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// One session that fails to abort must not hold up the rest; failures
+	// are reported together and retried on the next run.
+	var (
+		reaped int
+		errs   []error
+	)
+	for _, e := range sessions {
+		err := s.Storage.AbortMultipartUpload(ctx, e.key, e.uploadID)
+		if err != nil && !errors.Is(err, storage.ErrUploadNotFound) {
+			errs = append(errs, fmt.Errorf("abort upload %s: %w", e.id, err))
+			continue
+		}
+		if err := s.setStatus(ctx, e.id, StatusAborted); err != nil {
+			errs = append(errs, fmt.Errorf("mark upload %s aborted: %w", e.id, err))
+			continue
+		}
+		reaped++
+	}
+	return reaped, errors.Join(errs...)
+}
+
+// open returns a session that can still receive parts.
+func (s *Sessions) open(ctx context.Context, id, ownerID uuid.UUID) (Session, error) {
+	session, err := s.Get(ctx, id, ownerID)
+	if err != nil {
+		return Session{}, err
+	}
+	if session.Status != StatusActive || !s.now().Before(session.ExpiresAt) {
+		return Session{}, ErrSessionClosed
+	}
+	return session, nil
+}
+
+func (s *Sessions) setStatus(ctx context.Context, id uuid.UUID, status string) error {
+	const stmt = `
+UPDATE upload_sessions
+   SET status = $2,
+       completed_at = CASE WHEN $2 = 'completed' THEN now() ELSE completed_at END
+ WHERE id = $1 AND status = 'active'`
+	_, err := s.DB.ExecContext(ctx, stmt, id, status)
+	return err
+}
+
+func nullableSize(size int64) any {
+	if size <= 0 {
+		return nil
+	}
+	return size
+}
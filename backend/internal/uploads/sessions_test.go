@@ -0,0 +1,172 @@
+package uploads
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/storage"
+)
+
+// fakeStorage records multipart calls; other storage methods are unused.
+type fakeStorage struct {
+	storage.Client
+	parts     []storage.UploadedPart
+	completed []storage.UploadedPart
+	aborted   []string
+	// abortErrs fails aborts of the listed upload IDs.
+	abortErrs map[string]error
+}
+
+func (f *fakeStorage) CreateMultipartUpload(context.Context, string, string) (string, error) {
+	return "upload-1", nil
+}
+
+func (f *fakeStorage) ListParts(context.Context, string, string) ([]storage.UploadedPart, error) {
+	return f.parts, nil
+}
+
+func (f *fakeStorage) CompleteMultipartUpload(_ context.Context, _, _ string, parts []storage.UploadedPart) (string, error) {
+	f.completed = parts
+	return "", nil
+}
+
+func (f *fakeStorage) AbortMultipartUpload(_ context.Context, _, uploadID string) error {
+	f.aborted = append(f.aborted, uploadID)
+	return f.abortErrs[uploadID]
+}
+
+var sessionColumns = []string{"id", "owner_id", "storage_key", "upload_id", "content_type", "size_bytes", "part_size", "status", "expires_at", "created_at"}
+
+func TestInitiateGrowsPartSizeForHugeUploads(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	sessions := NewSessions(db, &fakeStorage{}, Options{})
+	size := int64(storage.MaxUploadParts)*DefaultPartSize + 1
+
+	mock.ExpectExec(`INSERT INTO upload_sessions`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	session, err := sessions.Initiate(context.Background(), uuid.New(), "episodes/1/original", "audio/mp4", size)
+	if err != nil {
+		t.Fatalf("initiate: %v", err)
+	}
+	if session.PartSize <= DefaultPartSize || session.PartCount() > storage.MaxUploadParts {
+		t.Fatalf("expected part size to grow to fit %d parts, got %d bytes x %d", storage.MaxUploadParts, session.PartSize, session.PartCount())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestCompleteRequiresDeclaredSize(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := &fakeStorage{parts: []storage.UploadedPart{{Number: 1, ETag: "a", Size: 10}}}
+	sessions := NewSessions(db, store, Options{})
+	id, owner := uuid.New(), uuid.New()
+	now := time.Now()
+	row := func() *sqlmock.Rows {
+		return sqlmock.NewRows(sessionColumns).
+			AddRow(id, owner, "episodes/1/original", "upload-1", "audio/mp4", 15, DefaultPartSize, StatusActive, now.Add(time.Hour), now)
+	}
+
+	mock.ExpectQuery(`SELECT id, owner_id, storage_key`).WithArgs(id, owner).WillReturnRows(row())
+	if _, err := sessions.Complete(context.Background(), id, owner); !errors.Is(err, ErrIncompleteUpload) {
+		t.Fatalf("expected incomplete upload, got %v", err)
+	}
+
+	store.parts = append(store.parts, storage.UploadedPart{Number: 2, ETag: "b", Size: 5})
+	mock.ExpectQuery(`SELECT id, owner_id, storage_key`).WithArgs(id, owner).WillReturnRows(row())
+	mock.ExpectExec(`UPDATE upload_sessions`).WithArgs(id, StatusCompleted).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	session, err := sessions.Complete(context.Background(), id, owner)
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if session.Status != StatusCompleted || len(store.completed) != 2 {
+		t.Fatalf("unexpected completion %+v with parts %v", session, store.completed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestReapExpiredAbortsAbandonedSessions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := &fakeStorage{}
+	sessions := NewSessions(db, store, Options{})
+	now := time.Now()
+	first, second := uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`SELECT id, storage_key, upload_id`).
+		WithArgs(now, reapBatchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "storage_key", "upload_id"}).
+			AddRow(first, "episodes/1/original", "upload-1").
+			AddRow(second, "uploads/u/2.m4a", "upload-2"))
+	mock.ExpectExec(`UPDATE upload_sessions`).WithArgs(first, StatusAborted).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE upload_sessions`).WithArgs(second, StatusAborted).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	reaped, err := sessions.ReapExpired(context.Background(), now)
+	if err != nil {
+		t.Fatalf("reap: %v", err)
+	}
+	if reaped != 2 || len(store.aborted) != 2 || store.aborted[1] != "upload-2" {
+		t.Fatalf("expected both sessions to be aborted, got %d %v", reaped, store.aborted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestReapExpiredContinuesPastFailedAborts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := &fakeStorage{abortErrs: map[string]error{
+		"upload-1": errors.New("storage unavailable"),
+		"upload-2": storage.ErrUploadNotFound,
+	}}
+	sessions := NewSessions(db, store, Options{})
+	now := time.Now()
+	failing, gone, healthy := uuid.New(), uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`SELECT id, storage_key, upload_id`).
+		WithArgs(now, reapBatchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "storage_key", "upload_id"}).
+			AddRow(failing, "uploads/u/1.m4a", "upload-1").
+			AddRow(gone, "uploads/u/2.m4a", "upload-2").
+			AddRow(healthy, "uploads/u/3.m4a", "upload-3"))
+	mock.ExpectExec(`UPDATE upload_sessions`).WithArgs(gone, StatusAborted).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE upload_sessions`).WithArgs(healthy, StatusAborted).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	reaped, err := sessions.ReapExpired(context.Background(), now)
+	if err == nil {
+		t.Fatal("expected the failed abort to be reported")
+	}
+	if reaped != 2 || len(store.aborted) != 3 {
+		t.Fatalf("expected the later sessions to be reaped, got %d %v", reaped, store.aborted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
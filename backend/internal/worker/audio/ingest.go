@@ -0,0 +1,240 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amunx/backend/internal/storage"
+)
+
+// Machine-readable reasons an upload is rejected before processing. They are
+// stored in audio_items.processing_error for the owner to see.
+const (
+	ReasonMissingUpload    = "missing_upload"
+	ReasonEmpty            = "empty_file"
+	ReasonTooLarge         = "too_large"
+	ReasonTooLong          = "too_long"
+	ReasonNotAudio         = "not_audio"
+	ReasonUnsupportedCodec = "unsupported_codec"
+	ReasonCorrupt          = "corrupt_file"
+	ReasonSilent           = "silent_audio"
+	// ReasonProcessingFailed marks items whose processing exhausted its
+	// retries for reasons other than the upload itself.
+	ReasonProcessingFailed = "processing_failed"
+)
+
+// silenceThresholdDB is the peak level below which a file counts as silent.
+const silenceThresholdDB = -60.0
+
+// supportedCodecs lists the audio codecs ffmpeg reliably transcodes to opus.
+var supportedCodecs = map[string]bool{
+	"aac": true, "alac": true, "amr_nb": true, "amr_wb": true, "flac": true,
+	"mp2": true, "mp3": true, "opus": true, "vorbis": true, "wmav2": true,
+}
+
+// IngestLimits bounds what a plan may upload. Zero disables a limit.
+type IngestLimits struct {
+	MaxBytes    int64
+	MaxDuration time.Duration
+}
+
+// IngestError is a permanent rejection of an upload. Retrying cannot fix it,
+// so the job is acked and the reason recorded on the audio item.
+type IngestError struct {
+	Reason string
+	Detail string
+}
+
+func (e *IngestError) Error() string {
+	if e.Detail == "" {
+		return "ingest rejected: " + e.Reason
+	}
+	return fmt.Sprintf("ingest rejected: %s: %s", e.Reason, e.Detail)
+}
+
+func rejectUpload(reason, format string, args ...any) *IngestError {
+	return &IngestError{Reason: reason, Detail: fmt.Sprintf(format, args...)}
+}
+
+// probeResult is the subset of ffprobe's JSON output we validate.
+type probeResult struct {
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Duration  string `json:"duration"`
+	} `json:"streams"`
+}
+
+// checkStoredSize rejects missing, empty and oversized uploads before they
+// are downloaded.
+func (p *Processor) checkStoredSize(ctx context.Context, key string, limits IngestLimits) error {
+	info, err := p.Storage.HeadObject(ctx, key)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return rejectUpload(ReasonMissingUpload, "no object at %s", key)
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size == 0 {
+		return rejectUpload(ReasonEmpty, "uploaded file is empty")
+	}
+	if limits.MaxBytes > 0 && info.Size > limits.MaxBytes {
+		return rejectUpload(ReasonTooLarge, "%d bytes exceeds the %d byte limit", info.Size, limits.MaxBytes)
+	}
+	return nil
+}
+
+// validateUpload probes the downloaded original and scans it for decode
+// errors and silence.
+func (p *Processor) validateUpload(ctx context.Context, path string, limits IngestLimits) error {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=format_name,duration:stream=codec_type,codec_name,duration",
+		"-of", "json",
+		path,
+	)
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && !interrupted(ctx, exitErr) {
+		return rejectUpload(ReasonCorrupt, "ffprobe could not read the file: %s", firstLine(exitErr.Stderr))
+	}
+	if err != nil {
+		return retryable(ctx, err)
+	}
+	probe, err := parseProbe(output)
+	if err != nil {
+		return rejectUpload(ReasonCorrupt, "unreadable probe output")
+	}
+	if err := checkProbe(probe, limits); err != nil {
+		return err
+	}
+
+	return scanDecode(ctx, path)
+}
+
+func parseProbe(output []byte) (probeResult, error) {
+	var probe probeResult
+	err := json.Unmarshal(output, &probe)
+	return probe, err
+}
+
+// checkProbe enforces container, codec and duration rules on probe output.
+func checkProbe(probe probeResult, limits IngestLimits) error {
+	var codec, streamDuration string
+	for _, stream := range probe.Streams {
+		if stream.CodecType == "audio" {
+			codec, streamDuration = stream.CodecName, stream.Duration
+			break
+		}
+	}
+	if codec == "" {
+		return rejectUpload(ReasonNotAudio, "no audio stream in %q", probe.Format.FormatName)
+	}
+	if !supportedCodecs[codec] && !strings.HasPrefix(codec, "pcm_") {
+		return rejectUpload(ReasonUnsupportedCodec, "codec %q is not supported", codec)
+	}
+
+	raw := probe.Format.Duration
+	if raw == "" || raw == "N/A" {
+		raw = streamDuration
+	}
+	seconds, err := strconv.ParseFloat(raw, 64)
+	if err != nil || seconds <= 0 {
+		return rejectUpload(ReasonCorrupt, "duration %q is not readable", raw)
+	}
+	duration := time.Duration(seconds * float64(time.Second))
+	if limits.MaxDuration > 0 && duration > limits.MaxDuration {
+		return rejectUpload(ReasonTooLong, "%s exceeds the %s limit", duration.Round(time.Second), limits.MaxDuration)
+	}
+	return nil
+}
+
+// scanDecode decodes the whole file once, which catches truncated or
+// corrupt payloads that still probe fine, and measures the peak level.
+func scanDecode(ctx context.Context, path string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats",
+		"-i", path,
+		"-map", "0:a:0",
+		"-af", "volumedetect",
+		"-f", "null", "-",
+	)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && !interrupted(ctx, exitErr) {
+		return rejectUpload(ReasonCorrupt, "decoding failed: %s", lastLine(stderr.Bytes()))
+	}
+	if err != nil {
+		return retryable(ctx, err)
+	}
+	return checkDecodeLog(stderr.String())
+}
+
+// interrupted reports whether a tool exited because the job was cancelled
+// or the process was killed, e.g. on shutdown, rather than because of the
+// file it was reading.
+func interrupted(ctx context.Context, exitErr *exec.ExitError) bool {
+	return ctx.Err() != nil || exitErr.ExitCode() == -1
+}
+
+// retryable returns the cause of a tool failure that says nothing about the
+// upload, preferring the context's error when the job was cancelled.
+func retryable(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+var (
+	maxVolumePattern = regexp.MustCompile(`max_volume:\s*(-?[0-9.]+|-inf) dB`)
+	decodeErrPattern = regexp.MustCompile(`(?i)(invalid data found|error while decoding|corrupt)`)
+)
+
+// maxDecodeErrors tolerates a few glitches in otherwise playable recordings.
+const maxDecodeErrors = 10
+
+func checkDecodeLog(log string) error {
+	if errs := decodeErrPattern.FindAllString(log, -1); len(errs) > maxDecodeErrors {
+		return rejectUpload(ReasonCorrupt, "%d decode errors", len(errs))
+	}
+	match := maxVolumePattern.FindStringSubmatch(log)
+	if match == nil {
+		return rejectUpload(ReasonCorrupt, "no decodable audio")
+	}
+	if match[1] == "-inf" {
+		return rejectUpload(ReasonSilent, "recording contains no sound")
+	}
+	peak, err := strconv.ParseFloat(match[1], 64)
+	if err == nil && peak < silenceThresholdDB {
+		return rejectUpload(ReasonSilent, "peak level %.1f dB is below %.0f dB", peak, silenceThresholdDB)
+	}
+	return nil
+}
+
+func firstLine(out []byte) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return line
+}
+
+func lastLine(out []byte) string {
+	text := strings.TrimSpace(string(out))
+	if i := strings.LastIndex(text, "\n"); i >= 0 {
+		return text[i+1:]
+	}
+	return text
+}
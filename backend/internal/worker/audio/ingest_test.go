@@ -0,0 +1,161 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/storage"
+)
+
+type headStorage struct {
+	storage.Client
+	info storage.ObjectInfo
+	err  error
+}
+
+func (h headStorage) HeadObject(context.Context, string) (storage.ObjectInfo, error) {
+	return h.info, h.err
+}
+
+func rejectionReason(err error) string {
+	var rejected *IngestError
+	if errors.As(err, &rejected) {
+		return rejected.Reason
+	}
+	return ""
+}
+
+func TestCheckProbe(t *testing.T) {
+	limits := IngestLimits{MaxDuration: time.Hour}
+	cases := []struct {
+		name   string
+		output string
+		reason string
+	}{
+		{"valid aac", `{"format":{"format_name":"mov,mp4,m4a","duration":"61.5"},"streams":[{"codec_type":"audio","codec_name":"aac"}]}`, ""},
+		{"video with audio", `{"format":{"format_name":"mov,mp4","duration":"30"},"streams":[{"codec_type":"video","codec_name":"h264"},{"codec_type":"audio","codec_name":"aac"}]}`, ""},
+		{"pcm wav", `{"format":{"format_name":"wav","duration":"5"},"streams":[{"codec_type":"audio","codec_name":"pcm_s16le"}]}`, ""},
+		{"stream duration fallback", `{"format":{"format_name":"webm","duration":"N/A"},"streams":[{"codec_type":"audio","codec_name":"opus","duration":"12"}]}`, ""},
+		{"image", `{"format":{"format_name":"png_pipe"},"streams":[{"codec_type":"video","codec_name":"png"}]}`, ReasonNotAudio},
+		{"unsupported codec", `{"format":{"format_name":"matroska","duration":"10"},"streams":[{"codec_type":"audio","codec_name":"dts"}]}`, ReasonUnsupportedCodec},
+		{"too long", `{"format":{"format_name":"mp3","duration":"3601"},"streams":[{"codec_type":"audio","codec_name":"mp3"}]}`, ReasonTooLong},
+		{"no duration", `{"format":{"format_name":"mp3"},"streams":[{"codec_type":"audio","codec_name":"mp3"}]}`, ReasonCorrupt},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			probe, err := parseProbe([]byte(tc.output))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got := rejectionReason(checkProbe(probe, limits)); got != tc.reason {
+				t.Fatalf("expected reason %q, got %q", tc.reason, got)
+			}
+		})
+	}
+}
+
+func TestCheckDecodeLog(t *testing.T) {
+	cases := []struct {
+		name   string
+		log    string
+		reason string
+	}{
+		{"speech", "[Parsed_volumedetect_0] mean_volume: -24.1 dB\n[Parsed_volumedetect_0] max_volume: -3.2 dB", ""},
+		{"digital silence", "[Parsed_volumedetect_0] max_volume: -inf dB", ReasonSilent},
+		{"room noise only", "[Parsed_volumedetect_0] max_volume: -72.5 dB", ReasonSilent},
+		{"nothing decoded", "Output file is empty, nothing was encoded", ReasonCorrupt},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := rejectionReason(checkDecodeLog(tc.log)); got != tc.reason {
+				t.Fatalf("expected reason %q, got %q", tc.reason, got)
+			}
+		})
+	}
+
+	var glitches string
+	for i := 0; i <= maxDecodeErrors; i++ {
+		glitches += "[aac] Error while decoding stream #0:0: Invalid data found when processing input\n"
+	}
+	if got := rejectionReason(checkDecodeLog(glitches + "max_volume: -1.0 dB")); got != ReasonCorrupt {
+		t.Fatalf("expected repeated decode errors to be corrupt, got %q", got)
+	}
+}
+
+func TestCheckStoredSize(t *testing.T) {
+	limits := IngestLimits{MaxBytes: 100}
+	cases := []struct {
+		name   string
+		store  headStorage
+		reason string
+	}{
+		{"within limit", headStorage{info: storage.ObjectInfo{Size: 100}}, ""},
+		{"missing", headStorage{err: storage.ErrObjectNotFound}, ReasonMissingUpload},
+		{"empty", headStorage{info: storage.ObjectInfo{Size: 0}}, ReasonEmpty},
+		{"too large", headStorage{info: storage.ObjectInfo{Size: 101}}, ReasonTooLarge},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Processor{Storage: tc.store}
+			if got := rejectionReason(p.checkStoredSize(context.Background(), "episodes/1/original", limits)); got != tc.reason {
+				t.Fatalf("expected reason %q, got %q", tc.reason, got)
+			}
+		})
+	}
+}
+
+func TestRejectOrRetryRecordsReason(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	p := &Processor{DB: db, Logger: testLogger()}
+	id := uuid.New()
+
+	mock.ExpectExec(regexp.QuoteMeta("processing_error = $2")).
+		WithArgs(id.String(), ReasonTooLong, "2h0m0s exceeds the 1h0m0s limit").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := p.rejectOrRetry(context.Background(), id, rejectUpload(ReasonTooLong, "2h0m0s exceeds the 1h0m0s limit")); err != nil {
+		t.Fatalf("expected rejection to be acked, got %v", err)
+	}
+
+	transient := errors.New("connection reset")
+	if err := p.rejectOrRetry(context.Background(), id, transient); !errors.Is(err, transient) {
+		t.Fatalf("expected transient errors to be retried, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestInterruptedToolRunIsRetryable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := exec.CommandContext(ctx, "sleep", "5").Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected the killed command to exit with an error, got %v", err)
+	}
+	if !interrupted(ctx, exitErr) {
+		t.Fatal("expected a cancelled run to count as interrupted")
+	}
+	if err := retryable(ctx, err); !errors.Is(err, context.DeadlineExceeded) || rejectionReason(err) != "" {
+		t.Fatalf("expected a retryable context error, got %v", err)
+	}
+
+	err = exec.Command("sh", "-c", "exit 1").Run()
+	if !errors.As(err, &exitErr) || interrupted(context.Background(), exitErr) {
+		t.Fatalf("expected a plain non-zero exit not to count as interrupted, got %v", err)
+	}
+}
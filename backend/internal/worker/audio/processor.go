@@ -1,6 +1,7 @@
 package audio
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
@@ -10,7 +11,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -21,195 +21,100 @@ import (
 
 	"github.com/amunx/backend/internal/queue"
 	"github.com/amunx/backend/internal/storage"
+	"github.com/amunx/backend/internal/worker"
 )
 
 const (
-	consumerGroup     = "process_audio"
-	finalizeGroup     = "finalize_live"
-	maxAttempts       = 3
-	maxFinalizeTrials = 3
+	consumerGroup = "process_audio"
+	finalizeGroup = "finalize_live"
 )
 
 type Processor struct {
-	DB                 *sql.DB
-	Storage            storage.Client
-	Queue              queue.Stream
-	Logger             zerolog.Logger
-	CDNBase            string
-	MediaPath          string
-	ModerationKeywords []string
+	DB        *sql.DB
+	Storage   storage.Client
+	Queue     queue.Stream
+	Logger    zerolog.Logger
+	CDNBase   string
+	MediaPath string
+	// STTProOnly limits the transcription pipeline to owners on the pro plan;
+	// other owners keep the basic summary every item gets.
+	STTProOnly bool
+	// WaveformBuckets is the number of peaks stored with each audio item.
+	WaveformBuckets int
+	// WaveformHiResPerSec enables a high-resolution waveform in object
+	// storage with that many buckets per second. Zero disables it.
+	WaveformHiResPerSec int
+	// Limits holds upload limits per plan; plans without an entry use "free".
+	Limits map[string]IngestLimits
 }
 
-var defaultModerationKeywords = []string{
-	"hate",
-	"abuse",
-	"violence",
-	"kill",
-	"weapon",
-	"drugs",
-	"terror",
-	"self-harm",
-}
-
-func (p *Processor) Run(ctx context.Context, pollInterval time.Duration) error {
+// Register adds the processing and live-finalization topics to the worker
+// runtime. Each topic gets its own concurrency so long ffmpeg jobs never hold
+// up live finalization.
+func (p *Processor) Register(s *worker.Supervisor, processConcurrency, finalizeConcurrency int) error {
 	if p.MediaPath == "" {
 		p.MediaPath = os.TempDir()
 	}
 	if err := os.MkdirAll(p.MediaPath, 0o755); err != nil {
 		return err
 	}
-	if len(p.ModerationKeywords) == 0 {
-		p.ModerationKeywords = defaultModerationKeywords
-	}
-
-	consumerName := "proc-" + uuid.NewString()[:8]
-	ticker := time.NewTicker(pollInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
 
-		if err := p.claimAndProcess(ctx, consumerName); err != nil {
-			p.Logger.Error().Err(err).Msg("processor loop error")
-		}
-		if err := p.claimAndFinalize(ctx, consumerName); err != nil {
-			p.Logger.Error().Err(err).Msg("finalize loop error")
-		}
-
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-		}
-	}
+	s.Handle(worker.Topic{
+		Name:         queue.TopicProcessAudio,
+		Group:        consumerGroup,
+		Concurrency:  processConcurrency,
+		Handler:      p.HandleProcessAudio,
+		OnDeadLetter: p.processAudioFailed,
+	})
+	s.Handle(worker.Topic{
+		Name:        queue.TopicFinalizeLive,
+		Group:       finalizeGroup,
+		Concurrency: finalizeConcurrency,
+		Handler:     p.HandleFinalizeLive,
+	})
+	return nil
 }
 
-func (p *Processor) claimAndProcess(ctx context.Context, consumer string) error {
-	messages, err := p.Queue.Claim(ctx, queue.TopicProcessAudio, consumerGroup, consumer, 5)
-	if err != nil {
-		return err
-	}
-	if len(messages) == 0 {
+// HandleProcessAudio processes one jobs:process_audio message.
+func (p *Processor) HandleProcessAudio(ctx context.Context, msg queue.Message) error {
+	episodeID, ok := msg.Values["episode_id"].(string)
+	if !ok || episodeID == "" {
+		p.Logger.Warn().Interface("message", msg).Msg("missing episode_id in job")
 		return nil
 	}
+	return p.handleMessage(ctx, episodeID)
+}
 
-	for _, msg := range messages {
-		episodeID, ok := msg.Values["episode_id"].(string)
-		if !ok || episodeID == "" {
-			p.Logger.Warn().Interface("message", msg).Msg("missing episode_id in job")
-			_ = p.Queue.Ack(ctx, queue.TopicProcessAudio, consumerGroup, msg.ID)
-			continue
-		}
-		attempt := parseAttempt(msg.Values["attempt"])
-
-		if err := p.handleMessage(ctx, episodeID); err != nil {
-			p.Logger.Error().Err(err).Str("episode_id", episodeID).Int("attempt", attempt).Msg("processing failed")
-
-			if attempt+1 >= maxAttempts {
-				if markErr := p.markEpisodeFailed(ctx, episodeID, err); markErr != nil {
-					p.Logger.Error().Err(markErr).Str("episode_id", episodeID).Msg("failed to mark episode failure")
-				}
-			} else {
-				requeueErr := p.Queue.Enqueue(ctx, queue.TopicProcessAudio, map[string]any{
-					"episode_id": episodeID,
-					"attempt":    attempt + 1,
-				})
-				if requeueErr != nil {
-					p.Logger.Error().Err(requeueErr).Str("episode_id", episodeID).Msg("failed to requeue job")
-				}
-			}
-
-			_ = p.Queue.Ack(ctx, queue.TopicProcessAudio, consumerGroup, msg.ID)
-			continue
-		}
-
-		if err := p.Queue.Ack(ctx, queue.TopicProcessAudio, consumerGroup, msg.ID); err != nil {
-			p.Logger.Error().Err(err).Str("episode_id", episodeID).Msg("failed to ack message")
-		}
+// processAudioFailed hides an episode whose processing exhausted its attempts.
+func (p *Processor) processAudioFailed(ctx context.Context, msg queue.Message, cause error) {
+	episodeID, _ := msg.Values["episode_id"].(string)
+	if err := p.markEpisodeFailed(ctx, episodeID, cause); err != nil {
+		p.Logger.Error().Err(err).Str("episode_id", episodeID).Msg("failed to mark episode failure")
 	}
-
-	return nil
 }
 
-func (p *Processor) claimAndFinalize(ctx context.Context, consumer string) error {
-	messages, err := p.Queue.Claim(ctx, queue.TopicFinalizeLive, finalizeGroup, consumer, 5)
-	if err != nil {
-		return err
+// HandleFinalizeLive processes one jobs:finalize_live message.
+func (p *Processor) HandleFinalizeLive(ctx context.Context, msg queue.Message) error {
+	sessionIDStr := stringValue(msg.Values["session_id"])
+	if sessionIDStr == "" {
+		p.Logger.Warn().Interface("message", msg).Msg("missing session_id in finalize job")
+		return nil
 	}
-	if len(messages) == 0 {
+	sessionID, err := uuid.Parse(sessionIDStr)
+	if err != nil {
+		p.Logger.Warn().Str("session_id", sessionIDStr).Msg("invalid session_id in finalize job")
 		return nil
 	}
 
-	for _, msg := range messages {
-		sessionIDStr := stringValue(msg.Values["session_id"])
-		if sessionIDStr == "" {
-			p.Logger.Warn().Interface("message", msg).Msg("missing session_id in finalize job")
-			_ = p.Queue.Ack(ctx, queue.TopicFinalizeLive, finalizeGroup, msg.ID)
-			continue
-		}
-		sessionID, err := uuid.Parse(sessionIDStr)
-		if err != nil {
-			p.Logger.Warn().Str("session_id", sessionIDStr).Msg("invalid session_id in finalize job")
-			_ = p.Queue.Ack(ctx, queue.TopicFinalizeLive, finalizeGroup, msg.ID)
-			continue
-		}
-
-		recordingKey := stringValue(msg.Values["recording_key"])
-		var durationPtr *int
-		if raw, ok := msg.Values["duration_sec"]; ok {
-			if val, err := intValue(raw); err == nil {
-				durationPtr = &val
-			}
-		}
-
-		if err := p.handleFinalizeLive(ctx, sessionID, recordingKey, durationPtr); err != nil {
-			attempt := parseAttempt(msg.Values["attempt"])
-			p.Logger.Error().Err(err).Str("session_id", sessionID.String()).Int("attempt", attempt).Msg("finalize live failed")
-			if attempt+1 >= maxFinalizeTrials {
-				_ = p.Queue.Ack(ctx, queue.TopicFinalizeLive, finalizeGroup, msg.ID)
-				continue
-			}
-			requeue := map[string]any{
-				"session_id": sessionID.String(),
-				"attempt":    attempt + 1,
-			}
-			if recordingKey != "" {
-				requeue["recording_key"] = recordingKey
-			}
-			if durationPtr != nil {
-				requeue["duration_sec"] = *durationPtr
-			}
-			if err := p.Queue.Enqueue(ctx, queue.TopicFinalizeLive, requeue); err != nil {
-				p.Logger.Error().Err(err).Str("session_id", sessionID.String()).Msg("failed to requeue finalize job")
-			}
-			_ = p.Queue.Ack(ctx, queue.TopicFinalizeLive, finalizeGroup, msg.ID)
-			continue
-		}
-
-		if err := p.Queue.Ack(ctx, queue.TopicFinalizeLive, finalizeGroup, msg.ID); err != nil {
-			p.Logger.Error().Err(err).Str("session_id", sessionID.String()).Msg("failed to ack finalize job")
+	recordingKey := stringValue(msg.Values["recording_key"])
+	var durationPtr *int
+	if raw, ok := msg.Values["duration_sec"]; ok {
+		if val, err := intValue(raw); err == nil {
+			durationPtr = &val
 		}
 	}
 
-	return nil
-}
-
-func parseAttempt(value any) int {
-	switch v := value.(type) {
-	case int64:
-		return int(v)
-	case int:
-		return v
-	case string:
-		if n, err := strconv.Atoi(v); err == nil {
-			return n
-		}
-	}
-	return 0
+	return p.handleFinalizeLive(ctx, sessionID, recordingKey, durationPtr)
 }
 
 func stringValue(value any) string {
@@ -247,17 +152,21 @@ func intValue(value any) (int, error) {
 
 func (p *Processor) handleMessage(ctx context.Context, episodeID string) error {
 	const selectEpisode = `
-SELECT id, s3_key
-FROM audio_items
-WHERE id = $1 AND visibility = 'private'
+SELECT ai.id, ai.s3_key, COALESCE(u.plan, 'free'), COALESCE(ls.mask, 'none')
+FROM audio_items ai
+JOIN users u ON u.id = ai.owner_id
+LEFT JOIN live_sessions ls ON ls.id = ai.live_session_id
+WHERE ai.id = $1 AND ai.visibility = 'private'
 `
 
 	var (
-		id         uuid.UUID
-		s3Key      sql.NullString
+		id    uuid.UUID
+		s3Key sql.NullString
+		plan  string
+		mask  string
 	)
 
-	if err := p.DB.QueryRowContext(ctx, selectEpisode, episodeID).Scan(&id, &s3Key); err != nil {
+	if err := p.DB.QueryRowContext(ctx, selectEpisode, episodeID).Scan(&id, &s3Key, &plan, &mask); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil
 		}
@@ -274,39 +183,59 @@ WHERE id = $1 AND visibility = 'private'
 	}
 	defer os.RemoveAll(tempDir)
 
+	limits := p.limitsFor(plan)
+	if err := p.checkStoredSize(ctx, s3Key.String, limits); err != nil {
+		return p.rejectOrRetry(ctx, id, err)
+	}
+
 	originalPath := filepath.Join(tempDir, "original")
 	if err := p.downloadOriginal(ctx, s3Key.String, originalPath); err != nil {
 		return err
 	}
+	if err := p.validateUpload(ctx, originalPath, limits); err != nil {
+		return p.rejectOrRetry(ctx, id, err)
+	}
 
 	processedPath := filepath.Join(tempDir, "processed.opus")
-	// Use default mask 'none' since audio_items don't have mask field
-	if err := p.processWithFFmpeg(ctx, originalPath, processedPath, "none"); err != nil {
+	// Only live replays carry a voice mask, inherited from their session.
+	if err := p.processWithFFmpeg(ctx, originalPath, processedPath, mask); err != nil {
 		return err
 	}
 
-	waveform, duration, sizeBytes, err := p.extractMetadata(ctx, processedPath)
+	wave, hiRes, duration, _, err := p.extractMetadata(ctx, processedPath)
 	if err != nil {
 		return err
 	}
 
 	processedKey := fmt.Sprintf("episodes/%s/processed.opus", id.String())
-	processedURL := processedKey
-	if p.CDNBase != "" {
-		processedURL = fmt.Sprintf("%s/%s", strings.TrimSuffix(p.CDNBase, "/"), processedKey)
-	}
+	processedURL := p.cdnURL(processedKey)
 
 	if err := p.uploadProcessed(ctx, processedKey, processedPath); err != nil {
 		return err
 	}
 
+	if hiRes != nil {
+		hiResKey := fmt.Sprintf("episodes/%s/waveform.json", id.String())
+		if err := p.uploadWaveform(ctx, hiResKey, hiRes); err != nil {
+			p.Logger.Warn().Err(err).Str("episode_id", episodeID).Msg("failed to upload high-resolution waveform")
+		} else {
+			wave.HiResURL = p.cdnURL(hiResKey)
+		}
+	}
+	waveform, err := json.Marshal(wave)
+	if err != nil {
+		return err
+	}
+
 	const updateEpisode = `
 UPDATE audio_items
 SET visibility = 'public',
     audio_url = $2,
     s3_key = $3,
-    waveform = $5,
-    duration_sec = $6,
+    waveform = $4,
+    duration_sec = $5,
+    processing_error = NULL,
+    processing_error_detail = NULL,
     updated_at = now()
 WHERE id = $1
 `
@@ -316,35 +245,53 @@ WHERE id = $1
 		return err
 	}
 
-	summary, keywords, mood := generatePlaceholderSummary("none", duration) // Use default mask
+	// Every item gets a basic summary. The pipeline replaces it once it has
+	// a transcript; without a transcriber, or for non-pro owners under
+	// STTProOnly, it is the summary the item keeps.
+	summary, keywords, mood := generatePlaceholderSummary(mask, duration)
 	if err := p.upsertSummary(ctx, id, summary, keywords, mood); err != nil {
 		p.Logger.Warn().Err(err).Str("episode_id", episodeID).Msg("failed to upsert summary")
 	}
-	if hits := p.scanKeywordHits(summary, keywords); len(hits) > 0 {
-		for _, word := range hits {
-			reason := "keyword_hit:" + word
-			if err := p.insertModerationFlag(ctx, "episodes/"+id.String(), reason); err != nil {
-				p.Logger.Warn().Err(err).Str("episode_id", episodeID).Str("keyword", word).Msg("failed to record moderation flag")
-			}
-		}
+
+	// Transcription, summaries, clips and embeddings run as separate pipeline steps.
+	if p.STTProOnly && plan != "pro" {
+		p.Logger.Debug().Str("episode_id", episodeID).Str("plan", plan).Msg("transcription pipeline is pro-only")
+		return nil
+	}
+	if err := worker.QueueJob(ctx, p.Queue, worker.PipelineJob{AudioID: id, Step: worker.StepTranscribe}); err != nil {
+		p.Logger.Warn().Err(err).Str("episode_id", episodeID).Msg("failed to enqueue ai pipeline")
 	}
 
 	return nil
 }
 
+// handleFinalizeLive turns the recording of an ended live session into a
+// replay audio item and queues it for processing. Each session gets at most
+// one replay, so redelivered jobs are no-ops.
 func (p *Processor) handleFinalizeLive(ctx context.Context, sessionID uuid.UUID, recordingKey string, duration *int) error {
 	session, err := p.loadLiveSession(ctx, sessionID)
 	if err != nil {
 		return err
 	}
-	if session.EpisodeExists {
+	if session.ReplayID != nil {
+		// The replay's processing job is queued after the commit; if that
+		// failed, the redelivered finalize job queues it again.
+		pending, err := p.replayPending(ctx, *session.ReplayID)
+		if err != nil {
+			return err
+		}
+		if pending {
+			return p.enqueueReplay(ctx, *session.ReplayID)
+		}
 		p.Logger.Info().Str("session_id", sessionID.String()).Msg("live session already finalized")
 		return nil
 	}
 
-	key := strings.TrimSpace(recordingKey)
+	// The session's own key is derived server-side; the job's is only a
+	// fallback for sessions recorded before that.
+	key := strings.TrimSpace(session.RecordingKey)
 	if key == "" {
-		key = strings.TrimSpace(session.RecordingKey)
+		key = strings.TrimSpace(recordingKey)
 	}
 	if key == "" {
 		return fmt.Errorf("recording key missing for session %s", sessionID)
@@ -353,76 +300,226 @@ func (p *Processor) handleFinalizeLive(ctx context.Context, sessionID uuid.UUID,
 	if duration == nil && session.DurationSec != nil {
 		duration = session.DurationSec
 	}
-
-	if err := p.ensureLiveMetadata(ctx, sessionID, key, duration); err != nil {
-		return err
-	}
-
-	episodeID := uuid.New()
-	var topic interface{}
-	if session.TopicID != nil {
-		topic = *session.TopicID
-	}
-	var durationValue interface{}
+	durationSec := 0
 	if duration != nil {
-		durationValue = *duration
+		durationSec = *duration
 	}
 	title := session.Title
 	if strings.TrimSpace(title) == "" {
 		title = "Live session"
 	}
 
-	const insertEpisode = `
-INSERT INTO episodes (id, author_id, topic_id, visibility, status, title, duration_sec, storage_key, mask, is_live, live_session_id)
-VALUES ($1, $2, $3, 'public', 'pending_public', $4, $5, $6, $7, true, $8);
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const insertReplay = `
+INSERT INTO audio_items (id, owner_id, visibility, kind, title, duration_sec, s3_key, live_session_id)
+VALUES ($1, $2, 'private', 'podcast_episode', $3, $4, $5, $6)
+ON CONFLICT (live_session_id) WHERE live_session_id IS NOT NULL DO NOTHING
 `
-	_, err = p.DB.ExecContext(ctx, insertEpisode, episodeID, session.HostID, topic, title, durationValue, key, session.Mask, sessionID)
+	replayID := uuid.New()
+	res, err := tx.ExecContext(ctx, insertReplay, replayID, session.HostID, title, durationSec, key, sessionID)
 	if err != nil {
-		if isUniqueViolation(err) {
-			return nil
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return nil
+	}
+
+	if chapters := liveChapters(title, durationSec); len(chapters) > 0 {
+		encoded, err := json.Marshal(chapters)
+		if err != nil {
+			return err
 		}
+		const seedSummary = `
+INSERT INTO summaries (audio_id, preview_sentence, chapters)
+VALUES ($1, $2, $3)
+ON CONFLICT (audio_id) DO NOTHING
+`
+		if _, err := tx.ExecContext(ctx, seedSummary, replayID, title, encoded); err != nil {
+			return err
+		}
+	}
+
+	if err := seedLiveTranscript(ctx, tx, replayID, sessionID); err != nil {
 		return err
 	}
 
-	if err := p.Queue.Enqueue(ctx, queue.TopicProcessAudio, map[string]any{
-		"episode_id": episodeID.String(),
+	// Enqueue only once the replay is committed, so the processing job
+	// always finds it.
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return p.enqueueReplay(ctx, replayID)
+}
+
+// replayPending reports whether a replay has not been processed or rejected
+// yet.
+func (p *Processor) replayPending(ctx context.Context, replayID uuid.UUID) (bool, error) {
+	const query = `
+SELECT visibility = 'private' AND audio_url IS NULL AND processing_error IS NULL
+FROM audio_items
+WHERE id = $1
+`
+	var pending bool
+	err := p.DB.QueryRowContext(ctx, query, replayID).Scan(&pending)
+	return pending, err
+}
+
+func (p *Processor) enqueueReplay(ctx context.Context, replayID uuid.UUID) error {
+	return p.Queue.Enqueue(ctx, queue.TopicProcessAudio, map[string]any{
+		"episode_id": replayID.String(),
 		"attempt":    0,
-	}); err != nil {
+	})
+}
+
+// liveChapters seeds the chapters of a replay from its session. The
+// summarizer replaces them once the replay is transcribed.
+func liveChapters(title string, durationSec int) []worker.Chapter {
+	if durationSec <= 0 {
+		return nil
+	}
+	return []worker.Chapter{{Start: 0, End: durationSec, Title: title}}
+}
+
+// liveCaption is a caption segment captured while the session was live.
+type liveCaption struct {
+	Lang    string
+	StartMs int64
+	EndMs   int64
+	Text    string
+}
+
+// seedLiveTranscript stores the session's captions as the replay's
+// transcript and marks transcription done, so the pipeline goes straight to
+// summarizing. Sessions without captions are transcribed as usual.
+func seedLiveTranscript(ctx context.Context, tx *sql.Tx, replayID, sessionID uuid.UUID) error {
+	const query = `
+SELECT lang, start_ms, end_ms, text
+FROM live_caption_segments
+WHERE session_id = $1
+ORDER BY start_ms, id
+`
+	rows, err := tx.QueryContext(ctx, query, sessionID)
+	if err != nil {
+		return err
+	}
+	var captions []liveCaption
+	for rows.Next() {
+		var c liveCaption
+		if err := rows.Scan(&c.Lang, &c.StartMs, &c.EndMs, &c.Text); err != nil {
+			rows.Close()
+			return err
+		}
+		captions = append(captions, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
 		return err
 	}
 
-	return nil
+	transcript := liveTranscript(captions)
+	if transcript == nil {
+		return nil
+	}
+	words, err := json.Marshal(transcript.Words)
+	if err != nil {
+		return err
+	}
+	const insertTranscript = `
+INSERT INTO transcripts (audio_id, text, lang, words)
+VALUES ($1, $2, NULLIF($3, ''), $4)
+ON CONFLICT (audio_id) DO NOTHING
+`
+	if _, err := tx.ExecContext(ctx, insertTranscript, replayID, transcript.Text, transcript.Lang, words); err != nil {
+		return err
+	}
+	const markTranscribed = `
+INSERT INTO audio_pipeline_steps (audio_id, step, status)
+VALUES ($1, $2, 'succeeded')
+ON CONFLICT (audio_id, step) DO NOTHING
+`
+	_, err = tx.ExecContext(ctx, markTranscribed, replayID, worker.StepTranscribe)
+	return err
+}
+
+// liveTranscript builds a transcript from captions in the session's main
+// language, the one with the most captioned text; captions in other
+// languages are translations. Word timings are spread evenly over their
+// segment.
+func liveTranscript(captions []liveCaption) *worker.TranscriptResult {
+	volume := map[string]int{}
+	lang := ""
+	for _, c := range captions {
+		volume[c.Lang] += len(c.Text)
+		if lang == "" || volume[c.Lang] > volume[lang] {
+			lang = c.Lang
+		}
+	}
+	if lang == "" {
+		return nil
+	}
+
+	result := &worker.TranscriptResult{Lang: lang, Words: []worker.TranscriptWord{}}
+	var text []string
+	for _, c := range captions {
+		if c.Lang != lang {
+			continue
+		}
+		fields := strings.Fields(c.Text)
+		if len(fields) == 0 {
+			continue
+		}
+		text = append(text, strings.Join(fields, " "))
+		start := float64(c.StartMs) / 1000
+		step := float64(c.EndMs-c.StartMs) / 1000 / float64(len(fields))
+		for i, word := range fields {
+			result.Words = append(result.Words, worker.TranscriptWord{
+				Word:  word,
+				Start: start + step*float64(i),
+				End:   start + step*float64(i+1),
+			})
+		}
+	}
+	if len(text) == 0 {
+		return nil
+	}
+	result.Text = strings.Join(text, " ")
+	return result
 }
 
 type liveSessionRecord struct {
-	ID            uuid.UUID
-	HostID        uuid.UUID
-	TopicID       *uuid.UUID
-	RecordingKey  string
-	DurationSec   *int
-	Title         string
-	Mask          string
-	EpisodeExists bool
+	ID           uuid.UUID
+	HostID       uuid.UUID
+	RecordingKey string
+	DurationSec  *int
+	Title        string
+	// ReplayID is set once the session has been finalized.
+	ReplayID *uuid.UUID
 }
 
 func (p *Processor) loadLiveSession(ctx context.Context, id uuid.UUID) (liveSessionRecord, error) {
 	const query = `
-SELECT ls.host_id, ls.topic_id, ls.recording_key, ls.duration_sec, ls.ended_at, ls.title, ls.mask, NULL as episode_id
+SELECT ls.host_id, ls.recording_key, ls.duration_sec, ls.ended_at, ls.title, ai.id
 FROM live_sessions ls
+LEFT JOIN audio_items ai ON ai.live_session_id = ls.id
 WHERE ls.id = $1;
 `
 	var (
 		rec       liveSessionRecord
 		hostID    uuid.UUID
-		topic     sql.NullString
 		recording sql.NullString
 		duration  sql.NullInt64
 		endedAt   sql.NullTime
 		title     sql.NullString
-		mask      string
-		episode   sql.NullString
+		replay    uuid.NullUUID
 	)
-	err := p.DB.QueryRowContext(ctx, query, id).Scan(&hostID, &topic, &recording, &duration, &endedAt, &title, &mask, &episode)
+	err := p.DB.QueryRowContext(ctx, query, id).Scan(&hostID, &recording, &duration, &endedAt, &title, &replay)
 	if err != nil {
 		return rec, err
 	}
@@ -431,12 +528,6 @@ WHERE ls.id = $1;
 	}
 	rec.ID = id
 	rec.HostID = hostID
-	if topic.Valid {
-		if tid, err := uuid.Parse(topic.String); err == nil {
-			topicID := tid
-			rec.TopicID = &topicID
-		}
-	}
 	if recording.Valid {
 		rec.RecordingKey = recording.String
 	}
@@ -447,31 +538,10 @@ WHERE ls.id = $1;
 	if title.Valid {
 		rec.Title = strings.TrimSpace(title.String)
 	}
-	rec.Mask = mask
-	// audio_items don't have live_session_id, so we can't check if episode exists
-	rec.EpisodeExists = false
-	return rec, nil
-}
-
-func (p *Processor) ensureLiveMetadata(ctx context.Context, sessionID uuid.UUID, recordingKey string, duration *int) error {
-	var durationValue interface{}
-	if duration != nil {
-		durationValue = *duration
-	}
-	_, err := p.DB.ExecContext(ctx, `
-UPDATE live_sessions
-SET recording_key = COALESCE(NULLIF($2, ''), recording_key),
-    duration_sec = COALESCE($3, duration_sec)
-WHERE id = $1;
-`, sessionID, recordingKey, durationValue)
-	return err
-}
-
-func isUniqueViolation(err error) bool {
-	if err == nil {
-		return false
+	if replay.Valid {
+		rec.ReplayID = &replay.UUID
 	}
-	return strings.Contains(err.Error(), "duplicate key")
+	return rec, nil
 }
 
 func (p *Processor) downloadOriginal(ctx context.Context, storageKey, destPath string) error {
@@ -505,6 +575,7 @@ func (p *Processor) processWithFFmpeg(ctx context.Context, input, output, mask s
 	cmd := exec.CommandContext(ctx, "ffmpeg",
 		"-y",
 		"-i", input,
+		"-vn",
 		"-af", filter,
 		"-c:a", "libopus",
 		"-b:a", "24k",
@@ -517,7 +588,7 @@ func (p *Processor) processWithFFmpeg(ctx context.Context, input, output, mask s
 	return cmd.Run()
 }
 
-func (p *Processor) extractMetadata(ctx context.Context, processedPath string) ([]byte, time.Duration, int64, error) {
+func (p *Processor) extractMetadata(ctx context.Context, processedPath string) (*Waveform, *Waveform, time.Duration, int64, error) {
 	// duration
 	cmd := exec.CommandContext(ctx, "ffprobe",
 		"-v", "error",
@@ -528,25 +599,24 @@ func (p *Processor) extractMetadata(ctx context.Context, processedPath string) (
 	)
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, 0, 0, err
+		return nil, nil, 0, 0, err
 	}
 	durationSeconds, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
 	if err != nil {
-		return nil, 0, 0, err
+		return nil, nil, 0, 0, err
 	}
 
 	info, err := os.Stat(processedPath)
 	if err != nil {
-		return nil, 0, 0, err
+		return nil, nil, 0, 0, err
 	}
 
-	peaks := make([]int, 64)
-	waveform, err := json.Marshal(peaks)
+	wave, hiRes, err := extractWaveform(ctx, processedPath, durationSeconds, p.WaveformBuckets, p.WaveformHiResPerSec)
 	if err != nil {
-		return nil, 0, 0, err
+		return nil, nil, 0, 0, err
 	}
 
-	return waveform, time.Duration(durationSeconds * float64(time.Second)), info.Size(), nil
+	return wave, hiRes, time.Duration(durationSeconds * float64(time.Second)), info.Size(), nil
 }
 
 func (p *Processor) uploadProcessed(ctx context.Context, key, path string) error {
@@ -560,14 +630,56 @@ func (p *Processor) uploadProcessed(ctx context.Context, key, path string) error
 	return err
 }
 
-func (p *Processor) markEpisodeFailed(ctx context.Context, episodeID string, procErr error) error {
+func (p *Processor) uploadWaveform(ctx context.Context, key string, wave *Waveform) error {
+	body, err := json.Marshal(wave)
+	if err != nil {
+		return err
+	}
+	_, err = p.Storage.PutObject(ctx, key, bytes.NewReader(body), map[string]string{"waveform": "hires"})
+	return err
+}
+
+func (p *Processor) cdnURL(key string) string {
+	if p.CDNBase == "" {
+		return key
+	}
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(p.CDNBase, "/"), key)
+}
+
+// limitsFor returns the ingest limits of plan.
+func (p *Processor) limitsFor(plan string) IngestLimits {
+	if limits, ok := p.Limits[plan]; ok {
+		return limits
+	}
+	return p.Limits["free"]
+}
+
+// rejectOrRetry records an IngestError on the audio item and acks the job;
+// any other error is returned for a retry.
+func (p *Processor) rejectOrRetry(ctx context.Context, id uuid.UUID, err error) error {
+	var rejected *IngestError
+	if !errors.As(err, &rejected) {
+		return err
+	}
+	p.Logger.Info().Str("episode_id", id.String()).Str("reason", rejected.Reason).Str("detail", rejected.Detail).Msg("upload rejected")
+	return p.recordFailure(ctx, id.String(), rejected.Reason, rejected.Detail)
+}
+
+func (p *Processor) recordFailure(ctx context.Context, episodeID, reason, detail string) error {
 	const update = `
 UPDATE audio_items
 SET visibility = 'private',
+    processing_error = $2,
+    processing_error_detail = NULLIF($3, ''),
     updated_at = now()
 WHERE id = $1
 `
-	if _, err := p.DB.ExecContext(ctx, update, episodeID); err != nil {
+	_, err := p.DB.ExecContext(ctx, update, episodeID, reason, detail)
+	return err
+}
+
+func (p *Processor) markEpisodeFailed(ctx context.Context, episodeID string, procErr error) error {
+	if err := p.recordFailure(ctx, episodeID, ReasonProcessingFailed, procErr.Error()); err != nil {
 		return err
 	}
 
@@ -588,6 +700,8 @@ ON CONFLICT DO NOTHING;
 	return err
 }
 
+// upsertSummary stores a basic summary. Chapters, e.g. those seeded from a
+// live session, are left untouched.
 func (p *Processor) upsertSummary(ctx context.Context, episodeID uuid.UUID, summary string, keywords []string, mood map[string]float64) error {
 	moodJSON, err := json.Marshal(mood)
 	if err != nil {
@@ -595,9 +709,10 @@ func (p *Processor) upsertSummary(ctx context.Context, episodeID uuid.UUID, summ
 	}
 
 	const query = `
-INSERT INTO summaries (episode_id, tldr, keywords, mood)
-VALUES ($1, $2, $3, $4)
-ON CONFLICT (episode_id) DO UPDATE SET
+INSERT INTO summaries (audio_id, preview_sentence, tldr, keywords, mood)
+VALUES ($1, $2, $2, $3, $4)
+ON CONFLICT (audio_id) DO UPDATE SET
+	preview_sentence = EXCLUDED.preview_sentence,
 	tldr = EXCLUDED.tldr,
 	keywords = EXCLUDED.keywords,
 	mood = EXCLUDED.mood
@@ -606,42 +721,6 @@ ON CONFLICT (episode_id) DO UPDATE SET
 	return err
 }
 
-func (p *Processor) scanKeywordHits(summary string, keywords []string) []string {
-	if len(p.ModerationKeywords) == 0 {
-		return nil
-	}
-	lowerSummary := strings.ToLower(summary)
-	seen := make(map[string]struct{})
-
-	check := func(candidate string) {
-		candidate = strings.ToLower(candidate)
-		for _, banned := range p.ModerationKeywords {
-			bannedLower := strings.ToLower(strings.TrimSpace(banned))
-			if bannedLower == "" {
-				continue
-			}
-			if strings.Contains(candidate, bannedLower) {
-				seen[bannedLower] = struct{}{}
-			}
-		}
-	}
-
-	check(lowerSummary)
-	for _, kw := range keywords {
-		check(kw)
-	}
-
-	if len(seen) == 0 {
-		return nil
-	}
-	result := make([]string, 0, len(seen))
-	for word := range seen {
-		result = append(result, word)
-	}
-	sort.Strings(result)
-	return result
-}
-
 func generatePlaceholderSummary(mask string, duration time.Duration) (string, []string, map[string]float64) {
 	base := "Voice note"
 	switch mask {
@@ -33,11 +33,39 @@ func (s *stubStream) Ack(_ context.Context, _ string, _ string, _ ...string) err
 	return nil
 }
 
+func (s *stubStream) Extend(_ context.Context, _ string, _ string, _ string, _ ...string) error {
+	return nil
+}
+
+func (s *stubStream) Retry(_ context.Context, _ string, _ string, _ queue.Message, _ error) (bool, error) {
+	return false, nil
+}
+
+func (s *stubStream) DeadLetters(_ context.Context, _ string, _ int64) ([]queue.DeadLetter, error) {
+	return nil, nil
+}
+
+func (s *stubStream) ReplayDeadLetter(_ context.Context, _ string, _ string) error {
+	return nil
+}
+
 func testLogger() zerolog.Logger {
 	return zerolog.New(io.Discard)
 }
 
-func TestHandleFinalizeLiveCreatesEpisodeWithMask(t *testing.T) {
+const selectLiveSession = `
+SELECT ls.host_id, ls.recording_key, ls.duration_sec, ls.ended_at, ls.title, ai.id
+FROM live_sessions ls
+LEFT JOIN audio_items ai ON ai.live_session_id = ls.id
+WHERE ls.id = $1;
+`
+
+var (
+	liveSessionColumns = []string{"host_id", "recording_key", "duration_sec", "ended_at", "title", "id"}
+	liveCaptionColumns = []string{"lang", "start_ms", "end_ms", "text"}
+)
+
+func TestHandleFinalizeLiveCreatesReplay(t *testing.T) {
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
 	if err != nil {
 		t.Fatalf("sqlmock: %v", err)
@@ -45,67 +73,34 @@ func TestHandleFinalizeLiveCreatesEpisodeWithMask(t *testing.T) {
 	defer db.Close()
 
 	queueStub := &stubStream{}
-	logger := testLogger()
-
 	p := &Processor{
-		DB:      db,
-		Queue:   queueStub,
-		Logger:  logger,
-		Storage: nil,
+		DB:     db,
+		Queue:  queueStub,
+		Logger: testLogger(),
 	}
 
 	sessionID := uuid.New()
 	hostID := uuid.New()
+	recordingKey := "live/" + sessionID.String() + "/recording.ogg"
 
-	selectSession := `
-SELECT ls.host_id, ls.topic_id, ls.recording_key, ls.duration_sec, ls.ended_at, ls.title, ls.mask, e.id
-FROM live_sessions ls
-LEFT JOIN episodes e ON e.live_session_id = ls.id
-WHERE ls.id = $1;
-`
-	endedAt := time.Now().UTC()
-	mock.ExpectQuery(regexp.QuoteMeta(selectSession)).
+	mock.ExpectQuery(regexp.QuoteMeta(selectLiveSession)).
 		WithArgs(sessionID).
-		WillReturnRows(sqlmock.NewRows([]string{
-			"host_id",
-			"topic_id",
-			"recording_key",
-			"duration_sec",
-			"ended_at",
-			"title",
-			"mask",
-			"id",
-		}).AddRow(
-			hostID,
-			nil,
-			"original/live/source.opus",
-			int64(240),
-			endedAt,
-			"Afterparty",
-			"basic",
-			nil,
-		))
-
-	updateMeta := `
-UPDATE live_sessions
-SET recording_key = COALESCE(NULLIF($2, ''), recording_key),
-    duration_sec = COALESCE($3, duration_sec)
-WHERE id = $1;
-`
-	mock.ExpectExec(regexp.QuoteMeta(updateMeta)).
-		WithArgs(sessionID, "recordings/live.opus", 240).
+		WillReturnRows(sqlmock.NewRows(liveSessionColumns).
+			AddRow(hostID, recordingKey, int64(240), time.Now().UTC(), "Afterparty", nil))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audio_items (id, owner_id, visibility, kind, title, duration_sec, s3_key, live_session_id)")).
+		WithArgs(sqlmock.AnyArg(), hostID, "Afterparty", 240, recordingKey, sessionID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
-
-	insertEpisode := `
-INSERT INTO episodes (id, author_id, topic_id, visibility, status, title, duration_sec, storage_key, mask, is_live, live_session_id)
-VALUES ($1, $2, $3, 'public', 'pending_public', $4, $5, $6, $7, true, $8);
-`
-	mock.ExpectExec(regexp.QuoteMeta(insertEpisode)).
-		WithArgs(sqlmock.AnyArg(), hostID, nil, "Afterparty", 240, "recordings/live.opus", "basic", sessionID).
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO summaries (audio_id, preview_sentence, chapters)")).
+		WithArgs(sqlmock.AnyArg(), "Afterparty", []byte(`[{"start":0,"end":240,"title":"Afterparty"}]`)).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM live_caption_segments").
+		WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows(liveCaptionColumns))
+	mock.ExpectCommit()
 
-	duration := 240
-	if err := p.handleFinalizeLive(context.Background(), sessionID, "recordings/live.opus", &duration); err != nil {
+	// The job's key is ignored in favour of the server-derived one.
+	if err := p.handleFinalizeLive(context.Background(), sessionID, "somewhere/else.opus", nil); err != nil {
 		t.Fatalf("handleFinalizeLive: %v", err)
 	}
 
@@ -127,6 +122,122 @@ VALUES ($1, $2, $3, 'public', 'pending_public', $4, $5, $6, $7, true, $8);
 	}
 }
 
+func TestHandleFinalizeLiveSeedsTranscriptFromCaptions(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	p := &Processor{
+		DB:     db,
+		Queue:  &stubStream{},
+		Logger: testLogger(),
+	}
+
+	sessionID := uuid.New()
+	hostID := uuid.New()
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectLiveSession)).
+		WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows(liveSessionColumns).
+			AddRow(hostID, "live/x/recording.ogg", nil, time.Now().UTC(), "", nil))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audio_items")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM live_caption_segments").
+		WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows(liveCaptionColumns).
+			AddRow("uk", int64(0), int64(1000), "Привіт усім").
+			AddRow("en", int64(0), int64(1000), "Hi").
+			AddRow("uk", int64(2000), int64(3000), "Почнемо"))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO transcripts (audio_id, text, lang, words)")).
+		WithArgs(sqlmock.AnyArg(), "Привіт усім Почнемо", "uk",
+			[]byte(`[{"word":"Привіт","start":0,"end":0.5},{"word":"усім","start":0.5,"end":1},{"word":"Почнемо","start":2,"end":3}]`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audio_pipeline_steps")).
+		WithArgs(sqlmock.AnyArg(), "transcribe").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := p.handleFinalizeLive(context.Background(), sessionID, "", nil); err != nil {
+		t.Fatalf("handleFinalizeLive: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestHandleFinalizeLiveSkipsFinalizedSession(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	queueStub := &stubStream{}
+	p := &Processor{
+		DB:     db,
+		Queue:  queueStub,
+		Logger: testLogger(),
+	}
+
+	sessionID := uuid.New()
+	replayID := uuid.New()
+	mock.ExpectQuery(regexp.QuoteMeta(selectLiveSession)).
+		WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows(liveSessionColumns).
+			AddRow(uuid.New(), "live/x/recording.ogg", int64(240), time.Now().UTC(), "Afterparty", replayID))
+	mock.ExpectQuery("FROM audio_items").
+		WithArgs(replayID).
+		WillReturnRows(sqlmock.NewRows([]string{"pending"}).AddRow(false))
+
+	if err := p.handleFinalizeLive(context.Background(), sessionID, "", nil); err != nil {
+		t.Fatalf("handleFinalizeLive: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+	if queueStub.lastPayload != nil {
+		t.Fatalf("expected no processing job, got %#v", queueStub.lastPayload)
+	}
+}
+
+func TestHandleFinalizeLiveRequeuesPendingReplay(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	queueStub := &stubStream{}
+	p := &Processor{
+		DB:     db,
+		Queue:  queueStub,
+		Logger: testLogger(),
+	}
+
+	sessionID := uuid.New()
+	replayID := uuid.New()
+	mock.ExpectQuery(regexp.QuoteMeta(selectLiveSession)).
+		WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows(liveSessionColumns).
+			AddRow(uuid.New(), "live/x/recording.ogg", int64(240), time.Now().UTC(), "Afterparty", replayID))
+	mock.ExpectQuery("FROM audio_items").
+		WithArgs(replayID).
+		WillReturnRows(sqlmock.NewRows([]string{"pending"}).AddRow(true))
+
+	if err := p.handleFinalizeLive(context.Background(), sessionID, "", nil); err != nil {
+		t.Fatalf("handleFinalizeLive: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+	if queueStub.lastStream != queue.TopicProcessAudio || queueStub.lastPayload["episode_id"] != replayID.String() {
+		t.Fatalf("expected the pending replay to be queued again, got %q %#v", queueStub.lastStream, queueStub.lastPayload)
+	}
+}
+
 func TestHandleFinalizeLiveRequiresRecordingKey(t *testing.T) {
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
 	if err != nil {
@@ -144,30 +255,26 @@ func TestHandleFinalizeLiveRequiresRecordingKey(t *testing.T) {
 	hostID := uuid.New()
 
 	selectSession := `
-SELECT ls.host_id, ls.topic_id, ls.recording_key, ls.duration_sec, ls.ended_at, ls.title, ls.mask, e.id
+SELECT ls.host_id, ls.recording_key, ls.duration_sec, ls.ended_at, ls.title, ai.id
 FROM live_sessions ls
-LEFT JOIN episodes e ON e.live_session_id = ls.id
+LEFT JOIN audio_items ai ON ai.live_session_id = ls.id
 WHERE ls.id = $1;
 `
 	mock.ExpectQuery(regexp.QuoteMeta(selectSession)).
 		WithArgs(sessionID).
 		WillReturnRows(sqlmock.NewRows([]string{
 			"host_id",
-			"topic_id",
 			"recording_key",
 			"duration_sec",
 			"ended_at",
 			"title",
-			"mask",
 			"id",
 		}).AddRow(
 			hostID,
-			nil,
 			"",
 			nil,
 			time.Now().UTC(),
 			"",
-			"none",
 			nil,
 		))
 
@@ -179,3 +286,13 @@ WHERE ls.id = $1;
 		t.Fatalf("sql expectations: %v", err)
 	}
 }
+
+func TestGeneratePlaceholderSummary(t *testing.T) {
+	summary, keywords, _ := generatePlaceholderSummary("basic", 3*time.Minute)
+	if summary != "Lightly masked voice note (~3 min)" {
+		t.Fatalf("unexpected summary %q", summary)
+	}
+	if len(keywords) != 3 || keywords[2] != "basic" {
+		t.Fatalf("unexpected keywords %v", keywords)
+	}
+}
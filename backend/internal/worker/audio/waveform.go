@@ -0,0 +1,184 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+const (
+	// waveformSampleRate is the rate audio is decoded at for peak extraction;
+	// it is plenty for drawing and keeps the PCM stream small.
+	waveformSampleRate     = 8000
+	defaultWaveformBuckets = 64
+)
+
+// Waveform is the JSON stored in audio_items.waveform. Peaks and RMS hold one
+// value per bucket, normalized to 0..1 against the loudest bucket.
+type Waveform struct {
+	Peaks []float64 `json:"peaks"`
+	RMS   []float64 `json:"rms"`
+	// BucketSec is the length of audio covered by each bucket.
+	BucketSec float64 `json:"bucket_sec"`
+	// HiResURL points at the high-resolution variant used for zoomed
+	// scrubbing, when one was generated.
+	HiResURL string `json:"hires_url,omitempty"`
+}
+
+// extractWaveform decodes the file to mono PCM and computes the overview
+// waveform with the given number of buckets. When hiResPerSec is positive it
+// also returns a variant with that many buckets per second of audio.
+func extractWaveform(ctx context.Context, path string, duration float64, buckets, hiResPerSec int) (*Waveform, *Waveform, error) {
+	if buckets <= 0 {
+		buckets = defaultWaveformBuckets
+	}
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-v", "error",
+		"-i", path,
+		"-f", "s16le",
+		"-ac", "1",
+		"-ar", strconv.Itoa(waveformSampleRate),
+		"-",
+	)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	overview, hiRes, readErr := computeWaveform(stdout, duration, buckets, hiResPerSec)
+	if readErr != nil {
+		// Drain so ffmpeg does not block on a full pipe before exiting.
+		_, _ = io.Copy(io.Discard, stdout)
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, nil, fmt.Errorf("decode pcm: %w", err)
+	}
+	if readErr != nil {
+		return nil, nil, readErr
+	}
+	return overview, hiRes, nil
+}
+
+// computeWaveform reads 16-bit little-endian mono PCM at waveformSampleRate.
+// The expected duration sizes the overview buckets so that the whole file
+// maps onto exactly buckets values.
+func computeWaveform(r io.Reader, duration float64, buckets, hiResPerSec int) (*Waveform, *Waveform, error) {
+	totalSamples := int(math.Ceil(duration * waveformSampleRate))
+	perBucket := (totalSamples + buckets - 1) / buckets
+	if perBucket < 1 {
+		perBucket = 1
+	}
+	overview := &bucketAccumulator{perBucket: perBucket}
+
+	var hiRes *bucketAccumulator
+	if hiResPerSec > 0 {
+		hiRes = &bucketAccumulator{perBucket: max(1, waveformSampleRate/hiResPerSec)}
+	}
+
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var buf [2]byte
+	for {
+		if _, err := io.ReadFull(reader, buf[:]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return nil, nil, err
+		}
+		sample := math.Abs(float64(int16(binary.LittleEndian.Uint16(buf[:])))) / math.MaxInt16
+		overview.add(sample)
+		if hiRes != nil {
+			hiRes.add(sample)
+		}
+	}
+
+	result := overview.waveform(buckets)
+	if hiRes == nil {
+		return result, nil, nil
+	}
+	return result, hiRes.waveform(0), nil
+}
+
+type bucketAccumulator struct {
+	perBucket int
+	peaks     []float64
+	rms       []float64
+
+	peak  float64
+	sumSq float64
+	n     int
+}
+
+func (a *bucketAccumulator) add(sample float64) {
+	if sample > a.peak {
+		a.peak = sample
+	}
+	a.sumSq += sample * sample
+	a.n++
+	if a.n == a.perBucket {
+		a.flush()
+	}
+}
+
+func (a *bucketAccumulator) flush() {
+	if a.n == 0 {
+		return
+	}
+	a.peaks = append(a.peaks, a.peak)
+	a.rms = append(a.rms, math.Sqrt(a.sumSq/float64(a.n)))
+	a.peak, a.sumSq, a.n = 0, 0, 0
+}
+
+// waveform closes the last partial bucket and normalizes the series. A
+// positive size pads or trims the result to exactly that many buckets.
+func (a *bucketAccumulator) waveform(size int) *Waveform {
+	a.flush()
+	peaks, rms := a.peaks, a.rms
+	if size > 0 {
+		peaks = fitBuckets(peaks, size)
+		rms = fitBuckets(rms, size)
+	}
+	return &Waveform{
+		Peaks:     normalizeBuckets(peaks),
+		RMS:       normalizeBuckets(rms),
+		BucketSec: roundBucket(float64(a.perBucket) / waveformSampleRate),
+	}
+}
+
+func fitBuckets(values []float64, size int) []float64 {
+	out := make([]float64, size)
+	copy(out, values)
+	return out
+}
+
+// normalizeBuckets scales values so the largest becomes 1. Silence stays at 0.
+func normalizeBuckets(values []float64) []float64 {
+	maxValue := 0.0
+	for _, v := range values {
+		maxValue = math.Max(maxValue, v)
+	}
+	out := make([]float64, len(values))
+	if maxValue == 0 {
+		return out
+	}
+	for i, v := range values {
+		out[i] = roundBucket(v / maxValue)
+	}
+	return out
+}
+
+// roundBucket keeps three decimals, which is enough for drawing and keeps the
+// stored JSON compact.
+func roundBucket(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
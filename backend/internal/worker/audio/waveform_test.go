@@ -0,0 +1,78 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// pcm encodes one second of samples per amplitude as 16-bit little-endian PCM.
+func pcm(amplitudes ...int16) []byte {
+	var buf bytes.Buffer
+	for _, amp := range amplitudes {
+		for i := 0; i < waveformSampleRate; i++ {
+			sample := amp
+			if i%2 == 1 {
+				sample = -amp
+			}
+			_ = binary.Write(&buf, binary.LittleEndian, sample)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestComputeWaveformNormalizesBuckets(t *testing.T) {
+	data := pcm(0, 8000, 16000, 4000)
+
+	wave, hiRes, err := computeWaveform(bytes.NewReader(data), 4, 4, 0)
+	if err != nil {
+		t.Fatalf("compute: %v", err)
+	}
+	if hiRes != nil {
+		t.Fatal("expected no high-resolution waveform")
+	}
+
+	want := []float64{0, 0.5, 1, 0.25}
+	if len(wave.Peaks) != len(want) || len(wave.RMS) != len(want) {
+		t.Fatalf("expected %d buckets, got peaks=%v rms=%v", len(want), wave.Peaks, wave.RMS)
+	}
+	for i := range want {
+		if wave.Peaks[i] != want[i] || wave.RMS[i] != want[i] {
+			t.Fatalf("bucket %d: expected %v, got peak=%v rms=%v", i, want[i], wave.Peaks[i], wave.RMS[i])
+		}
+	}
+	if wave.BucketSec != 1 {
+		t.Fatalf("expected 1s buckets, got %v", wave.BucketSec)
+	}
+}
+
+func TestComputeWaveformPadsShortAudioAndBuildsHiRes(t *testing.T) {
+	data := pcm(1000, 2000)
+
+	// ffprobe reports more audio than the decoder yields; the tail stays silent.
+	wave, hiRes, err := computeWaveform(bytes.NewReader(data), 3, 8, 10)
+	if err != nil {
+		t.Fatalf("compute: %v", err)
+	}
+	if len(wave.Peaks) != 8 || wave.Peaks[5] != 1 || wave.Peaks[6] != 0 || wave.Peaks[7] != 0 {
+		t.Fatalf("expected 8 overview buckets padded with silence, got %v", wave.Peaks)
+	}
+	if hiRes == nil || len(hiRes.Peaks) != 20 {
+		t.Fatalf("expected 20 high-resolution buckets, got %#v", hiRes)
+	}
+	if hiRes.BucketSec != 0.1 || hiRes.Peaks[0] != 0.5 || hiRes.Peaks[19] != 1 {
+		t.Fatalf("unexpected high-resolution waveform %#v", hiRes)
+	}
+}
+
+func TestComputeWaveformSilence(t *testing.T) {
+	wave, _, err := computeWaveform(bytes.NewReader(pcm(0)), 1, 4, 0)
+	if err != nil {
+		t.Fatalf("compute: %v", err)
+	}
+	for i, peak := range wave.Peaks {
+		if peak != 0 {
+			t.Fatalf("bucket %d: expected silence, got %v", i, peak)
+		}
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/amunx/backend/internal/queue"
+	"github.com/amunx/backend/internal/storage"
 )
 
 // AudiogramJob represents an audiogram generation job
@@ -43,6 +47,49 @@ type StorageClient interface {
 	GetSignedURL(ctx context.Context, s3Key string, expiry time.Duration) (string, error)
 }
 
+// NewObjectStorage adapts a storage.Client to the audiogram StorageClient.
+func NewObjectStorage(client storage.Client) StorageClient {
+	return objectStorage{client: client}
+}
+
+type objectStorage struct {
+	client storage.Client
+}
+
+func (o objectStorage) Download(ctx context.Context, s3Key string, localPath string) error {
+	reader, err := o.client.GetObject(ctx, s3Key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	out, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, reader); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+func (o objectStorage) Upload(ctx context.Context, localPath string, s3Key string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = o.client.PutObject(ctx, s3Key, file, nil)
+	return err
+}
+
+func (o objectStorage) GetSignedURL(ctx context.Context, s3Key string, expiry time.Duration) (string, error) {
+	return o.client.PresignDownload(ctx, s3Key, expiry)
+}
+
 // NewAudiogramWorker creates a new audiogram worker
 func NewAudiogramWorker(storageClient StorageClient, tempDir string) *AudiogramWorker {
 	return &AudiogramWorker{
@@ -52,6 +99,29 @@ func NewAudiogramWorker(storageClient StorageClient, tempDir string) *AudiogramW
 	}
 }
 
+const audiogramGroup = "audiogram"
+
+// Register adds the audiogram topic to the worker runtime.
+func (w *AudiogramWorker) Register(s *Supervisor, concurrency int) {
+	s.Handle(Topic{
+		Name:        queue.TopicAudiogram,
+		Group:       audiogramGroup,
+		Concurrency: concurrency,
+		Handler:     w.HandleMessage,
+	})
+}
+
+// HandleMessage renders the audiogram described by the message's "job" field.
+func (w *AudiogramWorker) HandleMessage(ctx context.Context, msg queue.Message) error {
+	raw, _ := msg.Values["job"].(string)
+	var job AudiogramJob
+	if err := json.Unmarshal([]byte(raw), &job); err != nil || job.JobID == "" {
+		// Malformed jobs can never succeed; drop them instead of retrying.
+		return nil
+	}
+	return w.ProcessJob(ctx, &job)
+}
+
 // ProcessJob processes an audiogram generation job
 func (w *AudiogramWorker) ProcessJob(ctx context.Context, job *AudiogramJob) error {
 	job.Status = "running"
@@ -2,24 +2,60 @@ package worker
 
 import (
 	"context"
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
+
+	"github.com/amunx/backend/internal/queue"
 )
 
 // PipelineJob represents a job in the processing pipeline
 type PipelineJob struct {
-	AudioID   uuid.UUID `json:"audio_id"`
-	Step      string    `json:"step"` // transcribe, summarize, chapters, clips, embeddings
-	RetryCount int      `json:"retry_count"`
+	AudioID    uuid.UUID `json:"audio_id"`
+	Step       string    `json:"step"` // transcribe, summarize (incl. chapters), clips, embeddings
+	RetryCount int       `json:"retry_count"`
 }
 
+// Pipeline step names in execution order.
+const (
+	StepTranscribe = "transcribe"
+	StepSummarize  = "summarize"
+	StepClips      = "clips"
+	StepEmbeddings = "embeddings"
+)
+
+var pipelineSteps = []string{StepTranscribe, StepSummarize, StepClips, StepEmbeddings}
+
+// ModerationKeywords are matched against generated summaries; hits open a
+// moderation flag on the audio item.
+var ModerationKeywords = []string{
+	"hate",
+	"abuse",
+	"violence",
+	"kill",
+	"weapon",
+	"drugs",
+	"terror",
+	"self-harm",
+}
+
+const (
+	pipelineGroup     = "pipeline"
+	pipelineBatchSize = 5
+)
+
 // Pipeline processes audio items through multiple stages
 type Pipeline struct {
-	logger     zerolog.Logger
+	logger      zerolog.Logger
+	db          *sql.DB
+	queue       queue.Stream
 	transcriber Transcriber
 	summarizer  Summarizer
 	clipper     Clipper
@@ -33,7 +69,7 @@ type Transcriber interface {
 
 // Summarizer interface for summarization service
 type Summarizer interface {
-	Summarize(ctx context.Context, transcript string) (*SummaryResult, error)
+	Summarize(ctx context.Context, transcript *TranscriptResult) (*SummaryResult, error)
 }
 
 // Clipper interface for auto-clip generation
@@ -48,8 +84,8 @@ type Embedder interface {
 
 // TranscriptResult represents transcription output
 type TranscriptResult struct {
-	Text  string         `json:"text"`
-	Lang  string         `json:"lang"`
+	Text  string           `json:"text"`
+	Lang  string           `json:"lang"`
 	Words []TranscriptWord `json:"words"`
 }
 
@@ -65,6 +101,9 @@ type SummaryResult struct {
 	TLDR            string    `json:"tldr"`
 	Chapters        []Chapter `json:"chapters"`
 	Keywords        []string  `json:"keywords"`
+	// Terms lists the distinct content words of the transcript; they feed
+	// the document frequencies used for keyword extraction.
+	Terms []string `json:"-"`
 }
 
 type Chapter struct {
@@ -88,16 +127,21 @@ type Embedding struct {
 	TextChunk  string    `json:"text_chunk"`
 }
 
-// NewPipeline creates a new processing pipeline
+// NewPipeline creates a new processing pipeline. Clipper and embedder may be nil,
+// in which case those steps are recorded as skipped.
 func NewPipeline(
 	logger zerolog.Logger,
+	db *sql.DB,
+	stream queue.Stream,
 	transcriber Transcriber,
 	summarizer Summarizer,
 	clipper Clipper,
 	embedder Embedder,
 ) *Pipeline {
 	return &Pipeline{
-		logger:     logger,
+		logger:      logger,
+		db:          db,
+		queue:       stream,
 		transcriber: transcriber,
 		summarizer:  summarizer,
 		clipper:     clipper,
@@ -105,66 +149,261 @@ func NewPipeline(
 	}
 }
 
-// ProcessAudioItem runs the full pipeline for an audio item
-func (p *Pipeline) ProcessAudioItem(ctx context.Context, audioID uuid.UUID, audioURL string) error {
+// Register adds the pipeline topic to the worker runtime.
+func (p *Pipeline) Register(s *Supervisor, concurrency int) {
+	s.Handle(Topic{
+		Name:         queue.TopicPipeline,
+		Group:        pipelineGroup,
+		Concurrency:  concurrency,
+		Handler:      p.HandleMessage,
+		OnDeadLetter: p.stepExhausted,
+	})
+}
+
+// HandleMessage runs the pipeline step carried by one jobs:pipeline message.
+func (p *Pipeline) HandleMessage(ctx context.Context, msg queue.Message) error {
+	job, err := parsePipelineJob(msg.Values)
+	if err != nil {
+		p.logger.Warn().Err(err).Interface("message", msg).Msg("invalid pipeline job")
+		return nil
+	}
+	job.RetryCount = max(job.RetryCount, msg.Attempt)
+
+	if err := p.Handle(ctx, job); err != nil {
+		p.logger.Error().Err(err).
+			Str("audio_id", job.AudioID.String()).
+			Str("step", job.Step).
+			Int("attempt", job.RetryCount).
+			Msg("pipeline step failed")
+		return err
+	}
+	return nil
+}
+
+// Handle runs a single pipeline step and schedules the next one on success.
+func (p *Pipeline) Handle(ctx context.Context, job PipelineJob) error {
+	step := job.Step
+	if step == "" {
+		step = StepTranscribe
+	}
+	if err := p.runStep(ctx, job.AudioID, step); err != nil {
+		return err
+	}
+	return p.advance(ctx, job.AudioID, step)
+}
+
+// stepExhausted moves past an optional step once the queue has given up on it,
+// so the rest of the pipeline still runs.
+func (p *Pipeline) stepExhausted(ctx context.Context, msg queue.Message, _ error) {
+	job, err := parsePipelineJob(msg.Values)
+	if err != nil || !optionalStep(job.Step) {
+		return
+	}
+	if err := p.advance(ctx, job.AudioID, job.Step); err != nil {
+		p.logger.Error().Err(err).Str("audio_id", job.AudioID.String()).Msg("failed to advance pipeline")
+	}
+}
+
+func (p *Pipeline) advance(ctx context.Context, audioID uuid.UUID, step string) error {
+	next := nextStep(step)
+	if next == "" {
+		p.logger.Info().Str("audio_id", audioID.String()).Msg("Pipeline processing complete")
+		return nil
+	}
+	return QueueJob(ctx, p.queue, PipelineJob{AudioID: audioID, Step: next})
+}
+
+// ProcessAudioItem runs the full pipeline inline. Steps that already
+// succeeded are skipped, so a rerun resumes where the last one failed.
+func (p *Pipeline) ProcessAudioItem(ctx context.Context, audioID uuid.UUID) error {
 	p.logger.Info().
 		Str("audio_id", audioID.String()).
 		Msg("Starting pipeline processing")
 
-	// Step 1: Transcribe
-	p.logger.Info().Msg("Step 1: Transcribing...")
-	transcriptResult, err := p.transcriber.Transcribe(ctx, audioURL)
-	if err != nil {
-		return fmt.Errorf("transcription failed: %w", err)
+	for _, step := range pipelineSteps {
+		if err := p.runStep(ctx, audioID, step); err != nil {
+			if optionalStep(step) {
+				p.logger.Warn().Err(err).Str("step", step).Msg("Optional pipeline step failed, continuing...")
+				continue
+			}
+			return err
+		}
 	}
 
-	// TODO: Save transcript to database using sqlc
 	p.logger.Info().
-		Int("text_length", len(transcriptResult.Text)).
-		Str("lang", transcriptResult.Lang).
-		Msg("Transcription complete")
+		Str("audio_id", audioID.String()).
+		Msg("Pipeline processing complete")
+
+	return nil
+}
 
-	// Step 2: Summarize
-	p.logger.Info().Msg("Step 2: Generating summary...")
-	summaryResult, err := p.summarizer.Summarize(ctx, transcriptResult.Text)
+func (p *Pipeline) runStep(ctx context.Context, audioID uuid.UUID, step string) error {
+	status, err := p.stepStatus(ctx, audioID, step)
 	if err != nil {
-		return fmt.Errorf("summarization failed: %w", err)
+		return err
+	}
+	if status == stepSucceeded || status == stepSkipped {
+		p.logger.Debug().Str("audio_id", audioID.String()).Str("step", step).Msg("pipeline step already done")
+		return nil
+	}
+	if !p.stepEnabled(step) {
+		return p.markStep(ctx, audioID, step, stepSkipped, nil)
+	}
+	if step != StepTranscribe {
+		// Every later step reads the transcript; without one there is nothing to do.
+		upstream, err := p.stepStatus(ctx, audioID, StepTranscribe)
+		if err != nil {
+			return err
+		}
+		if upstream == stepSkipped {
+			return p.markStep(ctx, audioID, step, stepSkipped, nil)
+		}
 	}
 
-	// TODO: Save summary to database using sqlc
-	p.logger.Info().
-		Int("chapters", len(summaryResult.Chapters)).
-		Msg("Summary complete")
+	if err := p.markStep(ctx, audioID, step, stepRunning, nil); err != nil {
+		return err
+	}
+	if err := p.execStep(ctx, audioID, step); err != nil {
+		if markErr := p.markStep(ctx, audioID, step, stepFailed, err); markErr != nil {
+			p.logger.Warn().Err(markErr).Str("audio_id", audioID.String()).Str("step", step).Msg("failed to record step failure")
+		}
+		return fmt.Errorf("%s failed: %w", step, err)
+	}
+	return p.markStep(ctx, audioID, step, stepSucceeded, nil)
+}
 
-	// Step 3: Generate clips
-	p.logger.Info().Msg("Step 3: Generating smart clips...")
-	clips, err := p.clipper.GenerateClips(ctx, audioID, transcriptResult.Text, audioURL)
-	if err != nil {
-		p.logger.Warn().Err(err).Msg("Clip generation failed, continuing...")
-	} else {
-		// TODO: Save clips to database using sqlc
+func (p *Pipeline) execStep(ctx context.Context, audioID uuid.UUID, step string) error {
+	switch step {
+	case StepTranscribe:
+		source, err := p.loadAudioSource(ctx, audioID)
+		if err != nil {
+			return err
+		}
+		result, err := p.transcriber.Transcribe(ctx, source)
+		if err != nil {
+			return err
+		}
+		p.logger.Info().
+			Int("text_length", len(result.Text)).
+			Str("lang", result.Lang).
+			Msg("Transcription complete")
+		return p.saveTranscript(ctx, audioID, result)
+
+	case StepSummarize:
+		transcript, err := p.loadTranscript(ctx, audioID)
+		if err != nil {
+			return err
+		}
+		summary, err := p.summarizer.Summarize(ctx, transcript)
+		if err != nil {
+			return err
+		}
+		p.logger.Info().
+			Int("chapters", len(summary.Chapters)).
+			Msg("Summary complete")
+		if err := p.saveSummary(ctx, audioID, summary); err != nil {
+			return err
+		}
+		for _, word := range keywordHits(summary, ModerationKeywords) {
+			if err := p.insertModerationFlag(ctx, "episodes/"+audioID.String(), "keyword_hit:"+word); err != nil {
+				p.logger.Warn().Err(err).Str("audio_id", audioID.String()).Str("keyword", word).Msg("failed to record moderation flag")
+			}
+		}
+		return nil
+
+	case StepClips:
+		transcript, err := p.loadTranscript(ctx, audioID)
+		if err != nil {
+			return err
+		}
+		source, err := p.loadAudioSource(ctx, audioID)
+		if err != nil {
+			return err
+		}
+		clips, err := p.clipper.GenerateClips(ctx, audioID, transcript.Text, source)
+		if err != nil {
+			return err
+		}
 		p.logger.Info().
 			Int("clips", len(clips)).
 			Msg("Clips generated")
-	}
+		return p.saveClips(ctx, audioID, clips)
 
-	// Step 4: Generate embeddings
-	p.logger.Info().Msg("Step 4: Generating embeddings...")
-	embeddings, err := p.embedder.GenerateEmbeddings(ctx, transcriptResult.Text)
-	if err != nil {
-		p.logger.Warn().Err(err).Msg("Embedding generation failed, continuing...")
-	} else {
-		// TODO: Save embeddings to database using sqlc
+	case StepEmbeddings:
+		transcript, err := p.loadTranscript(ctx, audioID)
+		if err != nil {
+			return err
+		}
+		embeddings, err := p.embedder.GenerateEmbeddings(ctx, transcript.Text)
+		if err != nil {
+			return err
+		}
 		p.logger.Info().
 			Int("embeddings", len(embeddings)).
 			Msg("Embeddings generated")
+		return p.saveEmbeddings(ctx, audioID, embeddings)
+
+	default:
+		return fmt.Errorf("unknown pipeline step %q", step)
 	}
+}
 
-	p.logger.Info().
-		Str("audio_id", audioID.String()).
-		Msg("Pipeline processing complete")
+func (p *Pipeline) stepEnabled(step string) bool {
+	switch step {
+	case StepTranscribe:
+		return p.transcriber != nil
+	case StepSummarize:
+		return p.summarizer != nil
+	case StepClips:
+		return p.clipper != nil
+	case StepEmbeddings:
+		return p.embedder != nil
+	default:
+		return false
+	}
+}
 
-	return nil
+// keywordHits returns the banned words found in the summary text or keywords.
+func keywordHits(summary *SummaryResult, banned []string) []string {
+	candidates := append([]string{summary.PreviewSentence, summary.TLDR}, summary.Keywords...)
+	seen := make(map[string]struct{})
+	for _, candidate := range candidates {
+		candidate = strings.ToLower(candidate)
+		for _, word := range banned {
+			word = strings.ToLower(strings.TrimSpace(word))
+			if word != "" && strings.Contains(candidate, word) {
+				seen[word] = struct{}{}
+			}
+		}
+	}
+	hits := make([]string, 0, len(seen))
+	for word := range seen {
+		hits = append(hits, word)
+	}
+	sort.Strings(hits)
+	return hits
+}
+
+func nextStep(step string) string {
+	for i, s := range pipelineSteps {
+		if s == step && i+1 < len(pipelineSteps) {
+			return pipelineSteps[i+1]
+		}
+	}
+	return ""
+}
+
+func validStep(step string) bool {
+	for _, s := range pipelineSteps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+func optionalStep(step string) bool {
+	return step == StepClips || step == StepEmbeddings
 }
 
 // MockTranscriber for testing/development
@@ -187,11 +426,11 @@ func (m *MockTranscriber) Transcribe(ctx context.Context, audioURL string) (*Tra
 // MockSummarizer for testing/development
 type MockSummarizer struct{}
 
-func (m *MockSummarizer) Summarize(ctx context.Context, transcript string) (*SummaryResult, error) {
+func (m *MockSummarizer) Summarize(ctx context.Context, transcript *TranscriptResult) (*SummaryResult, error) {
 	time.Sleep(1 * time.Second) // Simulate API call
-	
+
 	// Generate preview (first 140 chars)
-	preview := transcript
+	preview := transcript.Text
 	if len(preview) > 140 {
 		preview = preview[:137] + "..."
 	}
@@ -213,7 +452,7 @@ type MockClipper struct{}
 
 func (m *MockClipper) GenerateClips(ctx context.Context, audioID uuid.UUID, transcript string, audioURL string) ([]*Clip, error) {
 	time.Sleep(1 * time.Second) // Simulate processing
-	
+
 	return []*Clip{
 		{
 			StartSec: 10,
@@ -235,10 +474,10 @@ type MockEmbedder struct{}
 
 func (m *MockEmbedder) GenerateEmbeddings(ctx context.Context, transcript string) ([]*Embedding, error) {
 	time.Sleep(1 * time.Second) // Simulate API call
-	
+
 	// In production, chunk transcript and generate embeddings for each chunk
 	chunks := chunkText(transcript, 500, 100) // 500 chars with 100 char overlap
-	
+
 	embeddings := make([]*Embedding, len(chunks))
 	for i, chunk := range chunks {
 		// Mock 1536-dim vector (OpenAI ada-002 size)
@@ -246,14 +485,14 @@ func (m *MockEmbedder) GenerateEmbeddings(ctx context.Context, transcript string
 		for j := range vector {
 			vector[j] = 0.1 // Mock value
 		}
-		
+
 		embeddings[i] = &Embedding{
 			ChunkIndex: i,
 			Vector:     vector,
 			TextChunk:  chunk,
 		}
 	}
-	
+
 	return embeddings, nil
 }
 
@@ -262,32 +501,67 @@ func chunkText(text string, chunkSize int, overlap int) []string {
 	if len(text) <= chunkSize {
 		return []string{text}
 	}
-	
+
 	var chunks []string
 	start := 0
-	
+
 	for start < len(text) {
 		end := start + chunkSize
 		if end > len(text) {
 			end = len(text)
 		}
-		
+
 		chunks = append(chunks, text[start:end])
-		
+
 		start += chunkSize - overlap
 		if start >= len(text) {
 			break
 		}
 	}
-	
+
 	return chunks
 }
 
-// QueueJob serializes and queues a pipeline job (using Redis)
-func QueueJob(ctx context.Context, job PipelineJob) error {
-	// TODO: Push to Redis queue
-	jobJSON, _ := json.Marshal(job)
-	fmt.Printf("Queued job: %s\n", string(jobJSON))
-	return nil
+// QueueJob publishes a pipeline job onto the pipeline stream.
+func QueueJob(ctx context.Context, stream queue.Stream, job PipelineJob) error {
+	if stream == nil {
+		return errors.New("pipeline queue not configured")
+	}
+	step := job.Step
+	if step == "" {
+		step = StepTranscribe
+	}
+	return stream.Enqueue(ctx, queue.TopicPipeline, map[string]any{
+		"audio_id":    job.AudioID.String(),
+		"step":        step,
+		"retry_count": job.RetryCount,
+	})
 }
 
+func parsePipelineJob(values map[string]any) (PipelineJob, error) {
+	raw, _ := values["audio_id"].(string)
+	audioID, err := uuid.Parse(raw)
+	if err != nil {
+		return PipelineJob{}, fmt.Errorf("invalid audio_id %q", raw)
+	}
+	step, _ := values["step"].(string)
+	if step == "" {
+		step = StepTranscribe
+	}
+	if !validStep(step) {
+		return PipelineJob{}, fmt.Errorf("unknown step %q", step)
+	}
+
+	job := PipelineJob{AudioID: audioID, Step: step}
+	switch v := values["retry_count"].(type) {
+	case int:
+		job.RetryCount = v
+	case int64:
+		job.RetryCount = int(v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			job.RetryCount = n
+		}
+	}
+	return job, nil
+}
@@ -0,0 +1,231 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	stepRunning   = "running"
+	stepSucceeded = "succeeded"
+	stepFailed    = "failed"
+	stepSkipped   = "skipped"
+)
+
+func (p *Pipeline) stepStatus(ctx context.Context, audioID uuid.UUID, step string) (string, error) {
+	const query = `
+SELECT status
+FROM audio_pipeline_steps
+WHERE audio_id = $1 AND step = $2
+`
+	var status string
+	err := p.db.QueryRowContext(ctx, query, audioID, step).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return status, err
+}
+
+func (p *Pipeline) markStep(ctx context.Context, audioID uuid.UUID, step, status string, stepErr error) error {
+	const query = `
+INSERT INTO audio_pipeline_steps (audio_id, step, status, attempts, last_error, updated_at)
+VALUES ($1, $2, $3, $4, NULLIF($5, ''), now())
+ON CONFLICT (audio_id, step) DO UPDATE SET
+	status = EXCLUDED.status,
+	attempts = audio_pipeline_steps.attempts + EXCLUDED.attempts,
+	last_error = EXCLUDED.last_error,
+	updated_at = EXCLUDED.updated_at
+`
+	attempts := 0
+	if status == stepRunning {
+		attempts = 1
+	}
+	lastErr := ""
+	if stepErr != nil {
+		lastErr = stepErr.Error()
+	}
+	_, err := p.db.ExecContext(ctx, query, audioID, step, status, attempts, lastErr)
+	return err
+}
+
+// loadAudioSource returns the best location of the processed audio: the CDN
+// URL when published, otherwise the storage key.
+func (p *Pipeline) loadAudioSource(ctx context.Context, audioID uuid.UUID) (string, error) {
+	const query = `
+SELECT COALESCE(NULLIF(audio_url, ''), s3_key)
+FROM audio_items
+WHERE id = $1
+`
+	var source string
+	if err := p.db.QueryRowContext(ctx, query, audioID).Scan(&source); err != nil {
+		return "", err
+	}
+	if source == "" {
+		return "", errors.New("audio item has no source")
+	}
+	return source, nil
+}
+
+func (p *Pipeline) loadTranscript(ctx context.Context, audioID uuid.UUID) (*TranscriptResult, error) {
+	const query = `
+SELECT text, COALESCE(lang, ''), words
+FROM transcripts
+WHERE audio_id = $1
+`
+	var (
+		result TranscriptResult
+		words  []byte
+	)
+	err := p.db.QueryRowContext(ctx, query, audioID).Scan(&result.Text, &result.Lang, &words)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("transcript missing for %s", audioID)
+		}
+		return nil, err
+	}
+	if len(words) > 0 {
+		if err := json.Unmarshal(words, &result.Words); err != nil {
+			return nil, fmt.Errorf("decode transcript words: %w", err)
+		}
+	}
+	return &result, nil
+}
+
+func (p *Pipeline) saveTranscript(ctx context.Context, audioID uuid.UUID, result *TranscriptResult) error {
+	words, err := json.Marshal(result.Words)
+	if err != nil {
+		return err
+	}
+	const query = `
+INSERT INTO transcripts (audio_id, text, lang, words)
+VALUES ($1, $2, NULLIF($3, ''), $4)
+ON CONFLICT (audio_id) DO UPDATE SET
+	text = EXCLUDED.text,
+	lang = EXCLUDED.lang,
+	words = EXCLUDED.words
+`
+	_, err = p.db.ExecContext(ctx, query, audioID, result.Text, result.Lang, words)
+	return err
+}
+
+func (p *Pipeline) saveSummary(ctx context.Context, audioID uuid.UUID, summary *SummaryResult) error {
+	// Without detected chapters, keep any seeded ones, e.g. from a live
+	// session.
+	var chapters any
+	if len(summary.Chapters) > 0 {
+		encoded, err := json.Marshal(summary.Chapters)
+		if err != nil {
+			return err
+		}
+		chapters = encoded
+	}
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const query = `
+INSERT INTO summaries (audio_id, preview_sentence, tldr, chapters, keywords)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (audio_id) DO UPDATE SET
+	preview_sentence = EXCLUDED.preview_sentence,
+	tldr = EXCLUDED.tldr,
+	chapters = COALESCE(EXCLUDED.chapters, summaries.chapters),
+	keywords = EXCLUDED.keywords
+`
+	if _, err := tx.ExecContext(ctx, query, audioID, summary.PreviewSentence, summary.TLDR, chapters, pq.Array(summary.Keywords)); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM transcript_terms WHERE audio_id = $1`, audioID); err != nil {
+		return err
+	}
+	if len(summary.Terms) > 0 {
+		const terms = `
+INSERT INTO transcript_terms (audio_id, term)
+SELECT $1, unnest($2::text[])
+ON CONFLICT DO NOTHING
+`
+		if _, err := tx.ExecContext(ctx, terms, audioID, pq.Array(summary.Terms)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *Pipeline) saveClips(ctx context.Context, audioID uuid.UUID, clips []*Clip) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM clips WHERE audio_id = $1`, audioID); err != nil {
+		return err
+	}
+	const insert = `
+INSERT INTO clips (audio_id, start_sec, end_sec, title, quote)
+VALUES ($1, $2, $3, $4, $5)
+`
+	for _, clip := range clips {
+		if _, err := tx.ExecContext(ctx, insert, audioID, clip.StartSec, clip.EndSec, clip.Title, clip.Quote); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *Pipeline) saveEmbeddings(ctx context.Context, audioID uuid.UUID, embeddings []*Embedding) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM embeddings WHERE audio_id = $1`, audioID); err != nil {
+		return err
+	}
+	const insert = `
+INSERT INTO embeddings (audio_id, chunk_index, vector, text_chunk)
+VALUES ($1, $2, $3::vector, $4)
+`
+	for _, emb := range embeddings {
+		if _, err := tx.ExecContext(ctx, insert, audioID, emb.ChunkIndex, formatVector(emb.Vector), emb.TextChunk); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// insertModerationFlag opens a flag unless the same one is already open, so
+// re-running the summarize step does not pile up duplicates.
+func (p *Pipeline) insertModerationFlag(ctx context.Context, objectRef, reason string) error {
+	const query = `
+INSERT INTO moderation_flags (object_ref, severity, reason, status)
+SELECT $1, $2, $3, 'open'
+WHERE NOT EXISTS (
+	SELECT 1 FROM moderation_flags
+	WHERE object_ref = $1 AND reason = $3 AND status = 'open'
+);
+`
+	_, err := p.db.ExecContext(ctx, query, objectRef, 2, reason)
+	return err
+}
+
+// formatVector renders a pgvector text literal such as "[0.1,0.2]".
+func formatVector(vector []float32) string {
+	parts := make([]string, len(vector))
+	for i, v := range vector {
+		parts[i] = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
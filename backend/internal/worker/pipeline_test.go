@@ -0,0 +1,222 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/amunx/backend/internal/queue"
+)
+
+type recordingStream struct {
+	payloads []map[string]any
+}
+
+func (s *recordingStream) Enqueue(_ context.Context, stream string, payload map[string]any) error {
+	if stream != queue.TopicPipeline {
+		return errors.New("unexpected stream " + stream)
+	}
+	s.payloads = append(s.payloads, payload)
+	return nil
+}
+
+func (s *recordingStream) Claim(context.Context, string, string, string, int64) ([]queue.Message, error) {
+	return nil, nil
+}
+
+func (s *recordingStream) Ack(context.Context, string, string, ...string) error {
+	return nil
+}
+
+func (s *recordingStream) Extend(context.Context, string, string, string, ...string) error {
+	return nil
+}
+
+func (s *recordingStream) Retry(context.Context, string, string, queue.Message, error) (bool, error) {
+	return false, nil
+}
+
+func (s *recordingStream) DeadLetters(context.Context, string, int64) ([]queue.DeadLetter, error) {
+	return nil, nil
+}
+
+func (s *recordingStream) ReplayDeadLetter(context.Context, string, string) error {
+	return nil
+}
+
+type stubTranscriber struct {
+	calls  int
+	result *TranscriptResult
+	err    error
+}
+
+func (s *stubTranscriber) Transcribe(context.Context, string) (*TranscriptResult, error) {
+	s.calls++
+	return s.result, s.err
+}
+
+func TestPipelineHandlePersistsTranscriptAndAdvances(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	stream := &recordingStream{}
+	transcriber := &stubTranscriber{result: &TranscriptResult{
+		Text:  "hello world",
+		Lang:  "en",
+		Words: []TranscriptWord{{Word: "hello", Start: 0, End: 0.4}, {Word: "world", Start: 0.5, End: 0.9}},
+	}}
+	p := NewPipeline(zerolog.Nop(), db, stream, transcriber, nil, nil, nil)
+	audioID := uuid.New()
+
+	mock.ExpectQuery(`SELECT status\s+FROM audio_pipeline_steps`).
+		WithArgs(audioID, StepTranscribe).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}))
+	mock.ExpectExec(`INSERT INTO audio_pipeline_steps`).
+		WithArgs(audioID, StepTranscribe, stepRunning, 1, "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COALESCE\(NULLIF\(audio_url, ''\), s3_key\)`).
+		WithArgs(audioID).
+		WillReturnRows(sqlmock.NewRows([]string{"source"}).AddRow("episodes/x/processed.opus"))
+	mock.ExpectExec(`INSERT INTO transcripts`).
+		WithArgs(audioID, "hello world", "en", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO audio_pipeline_steps`).
+		WithArgs(audioID, StepTranscribe, stepSucceeded, 0, "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := p.Handle(context.Background(), PipelineJob{AudioID: audioID, Step: StepTranscribe}); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+	if len(stream.payloads) != 1 {
+		t.Fatalf("expected next step to be queued, got %d payloads", len(stream.payloads))
+	}
+	if step := stream.payloads[0]["step"]; step != StepSummarize {
+		t.Fatalf("expected summarize step, got %v", step)
+	}
+}
+
+func TestPipelineSkipsCompletedStep(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	transcriber := &stubTranscriber{err: errors.New("should not be called")}
+	p := NewPipeline(zerolog.Nop(), db, &recordingStream{}, transcriber, nil, nil, nil)
+	audioID := uuid.New()
+
+	mock.ExpectQuery(`SELECT status\s+FROM audio_pipeline_steps`).
+		WithArgs(audioID, StepTranscribe).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(stepSucceeded))
+
+	if err := p.runStep(context.Background(), audioID, StepTranscribe); err != nil {
+		t.Fatalf("runStep: %v", err)
+	}
+	if transcriber.calls != 0 {
+		t.Fatalf("expected transcription to be skipped, got %d calls", transcriber.calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestPipelineWithoutTranscriberKeepsBasicSummary(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// Without whisper every step is skipped and the basic summary the
+	// processor wrote is left in place: no summaries write is expected.
+	p := NewPipeline(zerolog.Nop(), db, &recordingStream{}, nil, &ExtractiveSummarizer{}, nil, nil)
+	audioID := uuid.New()
+	for _, step := range pipelineSteps {
+		mock.ExpectQuery(`SELECT status\s+FROM audio_pipeline_steps`).
+			WithArgs(audioID, step).
+			WillReturnRows(sqlmock.NewRows([]string{"status"}))
+		if step == StepSummarize {
+			mock.ExpectQuery(`SELECT status\s+FROM audio_pipeline_steps`).
+				WithArgs(audioID, StepTranscribe).
+				WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(stepSkipped))
+		}
+		mock.ExpectExec(`INSERT INTO audio_pipeline_steps`).
+			WithArgs(audioID, step, stepSkipped, 0, "").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	if err := p.ProcessAudioItem(context.Background(), audioID); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestPipelineAdvancesPastExhaustedOptionalStep(t *testing.T) {
+	stream := &recordingStream{}
+	p := NewPipeline(zerolog.Nop(), nil, stream, nil, nil, nil, nil)
+	audioID := uuid.New()
+	cause := errors.New("boom")
+
+	clips := queue.Message{ID: "1-0", Values: map[string]any{"audio_id": audioID.String(), "step": StepClips}}
+	p.stepExhausted(context.Background(), clips, cause)
+	if len(stream.payloads) != 1 || stream.payloads[0]["step"] != StepEmbeddings || stream.payloads[0]["retry_count"] != 0 {
+		t.Fatalf("expected embeddings step after exhausted clips, got %#v", stream.payloads)
+	}
+
+	transcribe := queue.Message{ID: "2-0", Values: map[string]any{"audio_id": audioID.String(), "step": StepTranscribe}}
+	p.stepExhausted(context.Background(), transcribe, cause)
+	if len(stream.payloads) != 1 {
+		t.Fatalf("expected exhausted transcription to stop the pipeline, got %#v", stream.payloads)
+	}
+}
+
+func TestParsePipelineJob(t *testing.T) {
+	audioID := uuid.New()
+	job, err := parsePipelineJob(map[string]any{
+		"audio_id":    audioID.String(),
+		"step":        StepEmbeddings,
+		"retry_count": "2",
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if job.AudioID != audioID || job.Step != StepEmbeddings || job.RetryCount != 2 {
+		t.Fatalf("unexpected job %#v", job)
+	}
+	if _, err := parsePipelineJob(map[string]any{"audio_id": audioID.String(), "step": "chapters"}); err == nil {
+		t.Fatal("expected unknown step to be rejected")
+	}
+}
+
+func TestInsertModerationFlagSkipsOpenDuplicate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	p := NewPipeline(zerolog.Nop(), db, &recordingStream{}, nil, nil, nil, nil)
+	mock.ExpectExec(`WHERE NOT EXISTS \(\s+SELECT 1 FROM moderation_flags\s+WHERE object_ref = \$1 AND reason = \$3 AND status = 'open'`).
+		WithArgs("episodes/x", 2, "keyword_hit:spam").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := p.insertModerationFlag(context.Background(), "episodes/x", "keyword_hit:spam"); err != nil {
+		t.Fatalf("insert flag: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
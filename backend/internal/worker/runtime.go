@@ -0,0 +1,388 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+
+	"github.com/amunx/backend/internal/queue"
+)
+
+const (
+	defaultDrainTimeout      = 30 * time.Second
+	defaultHeartbeatInterval = 10 * time.Second
+	defaultLeaseInterval     = time.Minute
+	heartbeatKeyPrefix       = "worker:heartbeat:"
+)
+
+// Handler processes a single message claimed from a topic. A nil error acks
+// the message; any other error, including a recovered panic, hands it to
+// queue.Stream.Retry.
+type Handler func(ctx context.Context, msg queue.Message) error
+
+// DeadLetterHandler is called after a message has exhausted its attempts and
+// was moved to the dead-letter stream.
+type DeadLetterHandler func(ctx context.Context, msg queue.Message, cause error)
+
+// Topic registers a handler for one queue topic.
+type Topic struct {
+	Name  string
+	Group string
+	// Concurrency bounds how many messages of this topic run at once.
+	Concurrency  int
+	Handler      Handler
+	OnDeadLetter DeadLetterHandler
+}
+
+// SupervisorConfig tunes the shared worker runtime.
+type SupervisorConfig struct {
+	PollInterval time.Duration
+	// DrainTimeout is how long in-flight handlers may keep running after
+	// shutdown starts before their context is cancelled.
+	DrainTimeout      time.Duration
+	HeartbeatInterval time.Duration
+	// LeaseInterval is how often in-flight messages are extended. It must
+	// stay well below the queue's visibility timeout, or long jobs are
+	// reclaimed and run twice.
+	LeaseInterval time.Duration
+}
+
+// Supervisor runs queue topics and periodic tasks in one process. Each topic
+// polls independently, so a slow topic never holds up the others.
+type Supervisor struct {
+	cfg    SupervisorConfig
+	queue  queue.Stream
+	redis  *redis.Client
+	logger zerolog.Logger
+
+	consumer string
+	topics   []Topic
+	tasks    []periodicTask
+	inFlight sync.Map // topic name -> *atomic.Int64
+}
+
+type periodicTask struct {
+	name     string
+	interval time.Duration
+	fn       func(ctx context.Context) error
+}
+
+// NewSupervisor creates a supervisor. The redis client is used for heartbeats
+// and may be nil.
+func NewSupervisor(cfg SupervisorConfig, stream queue.Stream, client *redis.Client, logger zerolog.Logger) *Supervisor {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 2 * time.Second
+	}
+	if cfg.DrainTimeout <= 0 {
+		cfg.DrainTimeout = defaultDrainTimeout
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = defaultHeartbeatInterval
+	}
+	if cfg.LeaseInterval <= 0 {
+		cfg.LeaseInterval = defaultLeaseInterval
+	}
+	host, _ := os.Hostname()
+	return &Supervisor{
+		cfg:      cfg,
+		queue:    stream,
+		redis:    client,
+		logger:   logger,
+		consumer: fmt.Sprintf("%s-%s", host, uuid.NewString()[:8]),
+	}
+}
+
+// Handle registers a topic handler. It must be called before Run.
+func (s *Supervisor) Handle(topic Topic) {
+	if topic.Concurrency <= 0 {
+		topic.Concurrency = 1
+	}
+	s.topics = append(s.topics, topic)
+	s.inFlight.Store(topic.Name, new(atomic.Int64))
+}
+
+// Every registers a task that runs immediately and then once per interval.
+// It must be called before Run.
+func (s *Supervisor) Every(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	s.tasks = append(s.tasks, periodicTask{name: name, interval: interval, fn: fn})
+}
+
+// Run blocks until ctx is cancelled, then stops claiming and waits for
+// in-flight handlers to drain.
+func (s *Supervisor) Run(ctx context.Context) error {
+	// Handlers outlive ctx during the drain; they are only cancelled once
+	// the drain timeout expires.
+	handlerCtx, cancelHandlers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelHandlers()
+
+	var (
+		loops    sync.WaitGroup
+		handlers sync.WaitGroup
+	)
+	for _, topic := range s.topics {
+		topic := topic
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.pollTopic(ctx, handlerCtx, topic, &handlers)
+		}()
+	}
+	for _, task := range s.tasks {
+		task := task
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.runTask(ctx, task)
+		}()
+	}
+	loops.Add(1)
+	go func() {
+		defer loops.Done()
+		s.heartbeat(ctx)
+	}()
+
+	<-ctx.Done()
+	loops.Wait()
+	s.logger.Info().Msg("worker draining in-flight jobs")
+
+	drained := make(chan struct{})
+	go func() {
+		handlers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(s.cfg.DrainTimeout):
+		s.logger.Warn().Dur("timeout", s.cfg.DrainTimeout).Msg("drain timeout reached, cancelling in-flight jobs")
+		cancelHandlers()
+		<-drained
+	}
+	s.clearHeartbeat()
+	return ctx.Err()
+}
+
+func (s *Supervisor) pollTopic(ctx, handlerCtx context.Context, topic Topic, handlers *sync.WaitGroup) {
+	slots := make(chan struct{}, topic.Concurrency)
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if free := topic.Concurrency - len(slots); free > 0 {
+			messages, err := s.queue.Claim(ctx, topic.Name, topic.Group, s.consumer, int64(free))
+			if err != nil && ctx.Err() == nil {
+				s.logger.Error().Err(err).Str("topic", topic.Name).Msg("claim failed")
+			}
+			for _, msg := range messages {
+				slots <- struct{}{}
+				handlers.Add(1)
+				go func(msg queue.Message) {
+					defer handlers.Done()
+					defer func() { <-slots }()
+					s.dispatch(handlerCtx, topic, msg)
+				}(msg)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch runs the handler with panic isolation and settles the message.
+// Failures are only retried or dead-lettered while this consumer still holds
+// the message.
+func (s *Supervisor) dispatch(ctx context.Context, topic Topic, msg queue.Message) {
+	if msg.DeadLetterCause != nil {
+		// The queue already dead-lettered it while reclaiming from a
+		// consumer that kept crashing; only the hook is left to run.
+		s.deadLettered(ctx, topic, msg, msg.DeadLetterCause)
+		return
+	}
+
+	counter := s.counter(topic.Name)
+	counter.Add(1)
+	defer counter.Add(-1)
+
+	stopLease := s.holdLease(ctx, topic, msg)
+	err := s.safeCall(func() error { return topic.Handler(ctx, msg) })
+	leaseLost := stopLease()
+	if err == nil {
+		if ackErr := s.queue.Ack(ctx, topic.Name, topic.Group, msg.ID); ackErr != nil {
+			s.logger.Error().Err(ackErr).Str("topic", topic.Name).Str("message_id", msg.ID).Msg("failed to ack message")
+		}
+		return
+	}
+
+	s.logger.Error().Err(err).
+		Str("topic", topic.Name).
+		Str("message_id", msg.ID).
+		Int("attempt", msg.Attempt).
+		Msg("job failed")
+	if leaseLost {
+		// Another consumer owns the message now; retrying or dead-lettering
+		// here would fail a job that is still running there.
+		return
+	}
+	dead, retryErr := s.queue.Retry(ctx, topic.Name, topic.Group, msg, err)
+	if retryErr != nil {
+		s.logger.Error().Err(retryErr).Str("topic", topic.Name).Str("message_id", msg.ID).Msg("failed to schedule retry")
+		return
+	}
+	if dead {
+		s.deadLettered(ctx, topic, msg, err)
+	}
+}
+
+// deadLettered runs the topic's dead-letter hook with panic isolation.
+func (s *Supervisor) deadLettered(ctx context.Context, topic Topic, msg queue.Message, cause error) {
+	if topic.OnDeadLetter == nil {
+		return
+	}
+	if hookErr := s.safeCall(func() error { topic.OnDeadLetter(ctx, msg, cause); return nil }); hookErr != nil {
+		s.logger.Error().Err(hookErr).Str("topic", topic.Name).Msg("dead letter hook failed")
+	}
+}
+
+// holdLease keeps extending msg until the returned stop function is called,
+// so the queue does not hand a long-running job to another consumer. stop
+// reports whether the lease was lost anyway, in which case the message
+// belongs to whoever reclaimed it.
+func (s *Supervisor) holdLease(ctx context.Context, topic Topic, msg queue.Message) (stop func() (lost bool)) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	var lost atomic.Bool
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(s.cfg.LeaseInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := s.queue.Extend(ctx, topic.Name, topic.Group, s.consumer, msg.ID)
+				switch {
+				case errors.Is(err, queue.ErrLeaseLost):
+					lost.Store(true)
+					s.logger.Warn().Str("topic", topic.Name).Str("message_id", msg.ID).Msg("message lease lost")
+					return
+				case err != nil && ctx.Err() == nil:
+					s.logger.Warn().Err(err).Str("topic", topic.Name).Str("message_id", msg.ID).Msg("failed to extend message lease")
+				}
+			}
+		}
+	}()
+	return func() bool {
+		close(done)
+		<-stopped
+		return lost.Load()
+	}
+}
+
+func (s *Supervisor) runTask(ctx context.Context, task periodicTask) {
+	run := func() {
+		if err := s.safeCall(func() error { return task.fn(ctx) }); err != nil && !errors.Is(err, context.Canceled) {
+			s.logger.Error().Err(err).Str("task", task.name).Msg("periodic task failed")
+		}
+	}
+
+	run()
+	ticker := time.NewTicker(task.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run()
+		}
+	}
+}
+
+type heartbeatPayload struct {
+	Consumer  string           `json:"consumer"`
+	StartedAt time.Time        `json:"started_at"`
+	SeenAt    time.Time        `json:"seen_at"`
+	InFlight  map[string]int64 `json:"in_flight"`
+}
+
+// heartbeat refreshes a Redis key per worker process so operators can see
+// which workers are alive and what they are busy with. The key expires if
+// the process dies.
+func (s *Supervisor) heartbeat(ctx context.Context) {
+	if s.redis == nil {
+		return
+	}
+	started := time.Now().UTC()
+	beat := func() {
+		payload := heartbeatPayload{
+			Consumer:  s.consumer,
+			StartedAt: started,
+			SeenAt:    time.Now().UTC(),
+			InFlight:  make(map[string]int64, len(s.topics)),
+		}
+		for _, topic := range s.topics {
+			payload.InFlight[topic.Name] = s.counter(topic.Name).Load()
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return
+		}
+		if err := s.redis.Set(ctx, heartbeatKeyPrefix+s.consumer, body, 3*s.cfg.HeartbeatInterval).Err(); err != nil && ctx.Err() == nil {
+			s.logger.Warn().Err(err).Msg("failed to write worker heartbeat")
+		}
+	}
+
+	beat()
+	ticker := time.NewTicker(s.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			beat()
+		}
+	}
+}
+
+func (s *Supervisor) clearHeartbeat() {
+	if s.redis == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = s.redis.Del(ctx, heartbeatKeyPrefix+s.consumer).Err()
+}
+
+func (s *Supervisor) counter(topic string) *atomic.Int64 {
+	value, _ := s.inFlight.LoadOrStore(topic, new(atomic.Int64))
+	return value.(*atomic.Int64)
+}
+
+// safeCall turns a panic in fn into an error so one bad job cannot take the
+// whole worker down.
+func (s *Supervisor) safeCall(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error().Str("stack", string(debug.Stack())).Msgf("recovered panic: %v", r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,247 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/amunx/backend/internal/queue"
+)
+
+type settlingStream struct {
+	mu      sync.Mutex
+	pending []queue.Message
+	acked   []string
+	retried []string
+	dead    bool
+	leases  int
+	lost    bool
+}
+
+func (s *settlingStream) Enqueue(context.Context, string, map[string]any) error {
+	return nil
+}
+
+func (s *settlingStream) Claim(_ context.Context, _, _, _ string, batchSize int64) ([]queue.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(int(batchSize), len(s.pending))
+	claimed := s.pending[:n]
+	s.pending = s.pending[n:]
+	return claimed, nil
+}
+
+func (s *settlingStream) Ack(_ context.Context, _, _ string, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acked = append(s.acked, ids...)
+	return nil
+}
+
+func (s *settlingStream) Extend(_ context.Context, _, _, _ string, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leases += len(ids)
+	if s.lost {
+		return queue.ErrLeaseLost
+	}
+	return nil
+}
+
+func (s *settlingStream) Retry(_ context.Context, _, _ string, msg queue.Message, _ error) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retried = append(s.retried, msg.ID)
+	return s.dead, nil
+}
+
+func (s *settlingStream) DeadLetters(context.Context, string, int64) ([]queue.DeadLetter, error) {
+	return nil, nil
+}
+
+func (s *settlingStream) ReplayDeadLetter(context.Context, string, string) error {
+	return nil
+}
+
+func TestSupervisorDispatchSettlesMessages(t *testing.T) {
+	stream := &settlingStream{dead: true}
+	s := NewSupervisor(SupervisorConfig{}, stream, nil, zerolog.Nop())
+
+	var deadLettered []string
+	topic := Topic{
+		Name:  "jobs:test",
+		Group: "test",
+		Handler: func(_ context.Context, msg queue.Message) error {
+			switch msg.ID {
+			case "fail":
+				return errors.New("boom")
+			case "panic":
+				panic("bad job")
+			}
+			return nil
+		},
+		OnDeadLetter: func(_ context.Context, msg queue.Message, _ error) {
+			deadLettered = append(deadLettered, msg.ID)
+		},
+	}
+
+	for _, id := range []string{"ok", "fail", "panic"} {
+		s.dispatch(context.Background(), topic, queue.Message{ID: id})
+	}
+
+	if len(stream.acked) != 1 || stream.acked[0] != "ok" {
+		t.Fatalf("expected only the successful job to be acked, got %v", stream.acked)
+	}
+	if len(stream.retried) != 2 || len(deadLettered) != 2 {
+		t.Fatalf("expected failed and panicking jobs to be retried and dead-lettered, got retried=%v dead=%v", stream.retried, deadLettered)
+	}
+}
+
+func TestSupervisorDrainsInFlightJobsOnShutdown(t *testing.T) {
+	stream := &settlingStream{pending: []queue.Message{{ID: "1"}, {ID: "2"}, {ID: "3"}}}
+	s := NewSupervisor(SupervisorConfig{PollInterval: 5 * time.Millisecond, DrainTimeout: time.Second}, stream, nil, zerolog.Nop())
+
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	var (
+		mu      sync.Mutex
+		running int
+		peak    int
+	)
+	s.Handle(Topic{
+		Name:        "jobs:test",
+		Group:       "test",
+		Concurrency: 2,
+		Handler: func(ctx context.Context, _ queue.Message) error {
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+			started <- struct{}{}
+			<-release
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return ctx.Err()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	<-started
+	<-started
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("supervisor did not stop")
+	}
+
+	if peak != 2 {
+		t.Fatalf("expected concurrency to be capped at 2, got %d", peak)
+	}
+	if len(stream.acked) != 2 {
+		t.Fatalf("expected both in-flight jobs to finish and ack during the drain, got %v", stream.acked)
+	}
+	if len(stream.pending) != 1 {
+		t.Fatalf("expected the third job to stay unclaimed after shutdown, got %v", stream.pending)
+	}
+}
+
+func TestSupervisorExtendsLeaseWhileHandling(t *testing.T) {
+	stream := &settlingStream{}
+	s := NewSupervisor(SupervisorConfig{LeaseInterval: 5 * time.Millisecond}, stream, nil, zerolog.Nop())
+
+	topic := Topic{
+		Name:  "jobs:test",
+		Group: "test",
+		Handler: func(context.Context, queue.Message) error {
+			time.Sleep(40 * time.Millisecond)
+			return nil
+		},
+	}
+	s.dispatch(context.Background(), topic, queue.Message{ID: "slow"})
+
+	stream.mu.Lock()
+	leases := stream.leases
+	stream.mu.Unlock()
+	if leases == 0 {
+		t.Fatal("expected the lease to be extended while the handler ran")
+	}
+	if len(stream.acked) != 1 {
+		t.Fatalf("expected the job to be acked, got %v", stream.acked)
+	}
+}
+
+func TestSupervisorLeavesReclaimedJobToNewOwner(t *testing.T) {
+	stream := &settlingStream{dead: true, lost: true}
+	s := NewSupervisor(SupervisorConfig{LeaseInterval: 5 * time.Millisecond}, stream, nil, zerolog.Nop())
+
+	var deadLettered bool
+	topic := Topic{
+		Name:  "jobs:test",
+		Group: "test",
+		Handler: func(context.Context, queue.Message) error {
+			time.Sleep(20 * time.Millisecond)
+			return errors.New("boom")
+		},
+		OnDeadLetter: func(context.Context, queue.Message, error) {
+			deadLettered = true
+		},
+	}
+	s.dispatch(context.Background(), topic, queue.Message{ID: "reclaimed"})
+
+	if len(stream.retried) != 0 || deadLettered {
+		t.Fatalf("expected a job with a lost lease to be left alone, got retried=%v dead=%v", stream.retried, deadLettered)
+	}
+}
+
+func TestSupervisorRunsHookForMessageDeadLetteredOnReclaim(t *testing.T) {
+	cause := errors.New("consumer did not ack before the visibility timeout")
+	stream := &settlingStream{pending: []queue.Message{{ID: "crashy", Pending: true, Attempt: 3, DeadLetterCause: cause}}}
+	s := NewSupervisor(SupervisorConfig{PollInterval: 5 * time.Millisecond, DrainTimeout: time.Second}, stream, nil, zerolog.Nop())
+
+	hooked := make(chan error, 1)
+	var handled bool
+	s.Handle(Topic{
+		Name:  "jobs:test",
+		Group: "test",
+		Handler: func(context.Context, queue.Message) error {
+			handled = true
+			return nil
+		},
+		OnDeadLetter: func(_ context.Context, msg queue.Message, err error) {
+			if msg.ID == "crashy" {
+				hooked <- err
+			}
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	select {
+	case err := <-hooked:
+		if !errors.Is(err, cause) {
+			t.Fatalf("expected the reclaim cause, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("dead letter hook did not run")
+	}
+	cancel()
+	<-done
+
+	if handled || len(stream.acked) != 0 || len(stream.retried) != 0 {
+		t.Fatalf("expected the dead-lettered message to be left settled, got handled=%v acked=%v retried=%v", handled, stream.acked, stream.retried)
+	}
+}
@@ -12,8 +12,9 @@ import (
 )
 
 const (
-	defaultGeneratorInterval = 5 * time.Minute
-	pruneHorizon             = 24 * time.Hour
+	// DefaultInterval is how often digests are regenerated.
+	DefaultInterval = 5 * time.Minute
+	pruneHorizon    = 24 * time.Hour
 )
 
 // Generator periodically materializes Smart Inbox digests for fast reads.
@@ -33,7 +34,7 @@ func (g *Generator) Run(ctx context.Context) error {
 	}
 	interval := g.Interval
 	if interval <= 0 {
-		interval = defaultGeneratorInterval
+		interval = DefaultInterval
 	}
 
 	// Warm cache immediately before entering the ticker loop.
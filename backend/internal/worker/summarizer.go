@@ -0,0 +1,500 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+const (
+	previewMaxRunes    = 140
+	maxKeywords        = 8
+	maxTLDRSentences   = 3
+	sentencePauseSec   = 1.2
+	maxSentenceWords   = 40
+	chapterPauseSec    = 2.5
+	minChapterSec      = 45
+	topicShiftWindow   = 3
+	topicShiftMaxScore = 0.08
+	chapterTitleTerms  = 3
+)
+
+// TermCorpus reports how many transcripts contain each term.
+type TermCorpus interface {
+	DocumentFrequencies(ctx context.Context, terms []string) (docs int, df map[string]int, err error)
+}
+
+// ExtractiveSummarizer builds previews, TL;DRs, keywords and chapters from the
+// transcript alone. It is deterministic and needs no external service.
+// Without a Corpus, IDF is computed over the sentences of the transcript.
+type ExtractiveSummarizer struct {
+	Corpus TermCorpus
+}
+
+type summarySentence struct {
+	text  string
+	start float64
+	end   float64
+	terms []string
+}
+
+// Summarize implements Summarizer.
+func (s *ExtractiveSummarizer) Summarize(ctx context.Context, transcript *TranscriptResult) (*SummaryResult, error) {
+	result := &SummaryResult{Chapters: []Chapter{}, Keywords: []string{}, Terms: []string{}}
+	if transcript == nil {
+		return result, nil
+	}
+
+	sentences, timed := splitSentences(transcript)
+	if len(sentences) == 0 {
+		return result, nil
+	}
+
+	tf := make(map[string]int)
+	for _, sent := range sentences {
+		for _, term := range sent.terms {
+			tf[term]++
+		}
+	}
+	result.Terms = sortedTerms(tf)
+
+	idf, err := s.idf(ctx, sentences, result.Terms)
+	if err != nil {
+		return nil, err
+	}
+	weights := make(map[string]float64, len(tf))
+	for term, count := range tf {
+		weights[term] = float64(count) * idf[term]
+	}
+
+	result.Keywords = topTerms(weights, maxKeywords)
+
+	scores := make([]float64, len(sentences))
+	for i, sent := range sentences {
+		scores[i] = sentenceScore(sent.terms, weights)
+	}
+	ranked := rankSentences(scores)
+
+	result.PreviewSentence = pickPreview(sentences, ranked)
+	result.TLDR = buildTLDR(sentences, ranked)
+	if timed {
+		result.Chapters = detectChapters(sentences, idf)
+	}
+	return result, nil
+}
+
+func (s *ExtractiveSummarizer) idf(ctx context.Context, sentences []summarySentence, terms []string) (map[string]float64, error) {
+	docs := len(sentences)
+	df := make(map[string]int, len(terms))
+	if s.Corpus != nil {
+		corpusDocs, corpusDF, err := s.Corpus.DocumentFrequencies(ctx, terms)
+		if err != nil {
+			return nil, err
+		}
+		docs, df = corpusDocs, corpusDF
+	} else {
+		for _, sent := range sentences {
+			seen := make(map[string]struct{})
+			for _, term := range sent.terms {
+				if _, ok := seen[term]; !ok {
+					seen[term] = struct{}{}
+					df[term]++
+				}
+			}
+		}
+	}
+
+	idf := make(map[string]float64, len(terms))
+	for _, term := range terms {
+		idf[term] = math.Log(float64(1+docs)/float64(1+df[term])) + 1
+	}
+	return idf, nil
+}
+
+// splitSentences groups words into sentences on terminal punctuation or long
+// pauses. Without word timings it falls back to punctuation in the text.
+func splitSentences(transcript *TranscriptResult) ([]summarySentence, bool) {
+	if len(transcript.Words) == 0 {
+		return splitText(transcript.Text), false
+	}
+
+	var (
+		sentences []summarySentence
+		current   []TranscriptWord
+	)
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		parts := make([]string, len(current))
+		for i, w := range current {
+			parts[i] = strings.TrimSpace(w.Word)
+		}
+		text := strings.Join(parts, " ")
+		sentences = append(sentences, summarySentence{
+			text:  text,
+			start: current[0].Start,
+			end:   current[len(current)-1].End,
+			terms: tokenize(text),
+		})
+		current = nil
+	}
+
+	for i, w := range transcript.Words {
+		if strings.TrimSpace(w.Word) == "" {
+			continue
+		}
+		current = append(current, w)
+		last := i == len(transcript.Words)-1
+		pause := !last && transcript.Words[i+1].Start-w.End >= sentencePauseSec
+		if endsSentence(w.Word) || pause || len(current) >= maxSentenceWords {
+			flush()
+		}
+	}
+	flush()
+	return sentences, true
+}
+
+func splitText(text string) []summarySentence {
+	var (
+		sentences []summarySentence
+		current   []string
+	)
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		joined := strings.Join(current, " ")
+		sentences = append(sentences, summarySentence{text: joined, terms: tokenize(joined)})
+		current = nil
+	}
+	for _, field := range strings.Fields(text) {
+		current = append(current, field)
+		if endsSentence(field) || len(current) >= maxSentenceWords {
+			flush()
+		}
+	}
+	flush()
+	return sentences
+}
+
+func endsSentence(word string) bool {
+	word = strings.TrimRight(strings.TrimSpace(word), `"'»”)`)
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "!") ||
+		strings.HasSuffix(word, "?") || strings.HasSuffix(word, "…")
+}
+
+// tokenize lowercases text and keeps content words of three or more letters.
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’'
+	})
+	terms := make([]string, 0, len(fields))
+	for _, field := range fields {
+		field = strings.Trim(field, "'’")
+		if len([]rune(field)) < 3 || isNumeric(field) {
+			continue
+		}
+		if _, stop := stopWords[field]; stop {
+			continue
+		}
+		terms = append(terms, field)
+	}
+	return terms
+}
+
+func isNumeric(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func sentenceScore(terms []string, weights map[string]float64) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+	seen := make(map[string]struct{}, len(terms))
+	total := 0.0
+	for _, term := range terms {
+		if _, ok := seen[term]; ok {
+			continue
+		}
+		seen[term] = struct{}{}
+		total += weights[term]
+	}
+	return total / math.Sqrt(float64(len(terms)))
+}
+
+// rankSentences returns sentence indexes by descending score; earlier
+// sentences win ties.
+func rankSentences(scores []float64) []int {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+	return order
+}
+
+func pickPreview(sentences []summarySentence, ranked []int) string {
+	for _, idx := range ranked {
+		if len([]rune(sentences[idx].text)) <= previewMaxRunes {
+			return sentences[idx].text
+		}
+	}
+	return truncateRunes(sentences[ranked[0]].text, previewMaxRunes)
+}
+
+func buildTLDR(sentences []summarySentence, ranked []int) string {
+	count := 1 + len(sentences)/10
+	if count > maxTLDRSentences {
+		count = maxTLDRSentences
+	}
+	picked := append([]int(nil), ranked[:count]...)
+	sort.Ints(picked)
+
+	parts := make([]string, len(picked))
+	for i, idx := range picked {
+		parts[i] = sentences[idx].text
+	}
+	return strings.Join(parts, " ")
+}
+
+// truncateRunes cuts text at a word boundary so the result, including the
+// ellipsis, fits in limit runes.
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	cut := string(runes[:limit-1])
+	if idx := strings.LastIndex(cut, " "); idx > 0 {
+		cut = cut[:idx]
+	}
+	return strings.TrimRight(cut, " ,;:-") + "…"
+}
+
+// detectChapters opens a new chapter at long pauses or where the vocabulary of
+// the surrounding sentences stops overlapping, as long as the current chapter
+// has run for at least minChapterSec.
+func detectChapters(sentences []summarySentence, idf map[string]float64) []Chapter {
+	var (
+		chapters  []Chapter
+		firstIdx  = 0
+		lastStart = sentences[0].start
+	)
+	closeChapter := func(from, to int, end float64) {
+		chapters = append(chapters, Chapter{
+			Start: int(math.Floor(sentences[from].start)),
+			End:   int(math.Ceil(end)),
+			Title: chapterTitle(sentences[from:to], idf, len(chapters)+1),
+		})
+	}
+
+	for i := 1; i < len(sentences); i++ {
+		if sentences[i].start-lastStart < minChapterSec {
+			continue
+		}
+		gap := sentences[i].start - sentences[i-1].end
+		if gap >= chapterPauseSec || topicShift(sentences, i) {
+			closeChapter(firstIdx, i, sentences[i].start)
+			firstIdx = i
+			lastStart = sentences[i].start
+		}
+	}
+	closeChapter(firstIdx, len(sentences), sentences[len(sentences)-1].end)
+
+	for i := 1; i < len(chapters); i++ {
+		chapters[i].Start = chapters[i-1].End
+	}
+	return chapters
+}
+
+func topicShift(sentences []summarySentence, at int) bool {
+	before := termCounts(sentences[max(0, at-topicShiftWindow):at])
+	after := termCounts(sentences[at:min(len(sentences), at+topicShiftWindow)])
+	if len(before) == 0 || len(after) == 0 {
+		return false
+	}
+	return cosine(before, after) < topicShiftMaxScore
+}
+
+func termCounts(sentences []summarySentence) map[string]float64 {
+	counts := make(map[string]float64)
+	for _, sent := range sentences {
+		for _, term := range sent.terms {
+			counts[term]++
+		}
+	}
+	return counts
+}
+
+func cosine(a, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for term, v := range a {
+		dot += v * b[term]
+		normA += v * v
+	}
+	for _, v := range b {
+		normB += v * v
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func chapterTitle(sentences []summarySentence, idf map[string]float64, n int) string {
+	weights := make(map[string]float64)
+	for term, count := range termCounts(sentences) {
+		weights[term] = count * idf[term]
+	}
+	terms := topTerms(weights, chapterTitleTerms)
+	if len(terms) == 0 {
+		return "Part " + strconv.Itoa(n)
+	}
+	title := strings.Join(terms, ", ")
+	runes := []rune(title)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// topTerms returns up to limit terms by descending weight, alphabetically on ties.
+func topTerms(weights map[string]float64, limit int) []string {
+	terms := make([]string, 0, len(weights))
+	for term, w := range weights {
+		if w > 0 {
+			terms = append(terms, term)
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if weights[terms[i]] != weights[terms[j]] {
+			return weights[terms[i]] > weights[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if len(terms) > limit {
+		terms = terms[:limit]
+	}
+	return terms
+}
+
+func sortedTerms(tf map[string]int) []string {
+	terms := make([]string, 0, len(tf))
+	for term := range tf {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// DefaultCorpusSizeTTL is how long SQLTermCorpus reuses its document count.
+const DefaultCorpusSizeTTL = 10 * time.Minute
+
+// SQLTermCorpus reads document frequencies from transcript_terms. Counting
+// the documents scans the whole table, so the count is cached for SizeTTL
+// (DefaultCorpusSizeTTL when zero); one summary barely moves the weights.
+type SQLTermCorpus struct {
+	DB      *sql.DB
+	SizeTTL time.Duration
+
+	mu        sync.Mutex
+	docs      int
+	countedAt time.Time
+}
+
+// DocumentFrequencies implements TermCorpus.
+func (c *SQLTermCorpus) DocumentFrequencies(ctx context.Context, terms []string) (int, map[string]int, error) {
+	docs, err := c.documents(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	const query = `
+SELECT term, COUNT(*)
+FROM transcript_terms
+WHERE term = ANY($1)
+GROUP BY term
+`
+	rows, err := c.DB.QueryContext(ctx, query, pq.Array(terms))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	df := make(map[string]int, len(terms))
+	for rows.Next() {
+		var (
+			term  string
+			count int
+		)
+		if err := rows.Scan(&term, &count); err != nil {
+			return 0, nil, err
+		}
+		df[term] = count
+	}
+	return docs, df, rows.Err()
+}
+
+func (c *SQLTermCorpus) documents(ctx context.Context) (int, error) {
+	ttl := c.SizeTTL
+	if ttl <= 0 {
+		ttl = DefaultCorpusSizeTTL
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.countedAt.IsZero() && time.Since(c.countedAt) < ttl {
+		return c.docs, nil
+	}
+	var docs int
+	if err := c.DB.QueryRowContext(ctx, `SELECT COUNT(DISTINCT audio_id) FROM transcript_terms`).Scan(&docs); err != nil {
+		return 0, err
+	}
+	c.docs, c.countedAt = docs, time.Now()
+	return docs, nil
+}
+
+var stopWords = toSet(
+	// English
+	"the", "and", "for", "are", "but", "not", "you", "all", "any", "can", "had", "her", "was", "one",
+	"our", "out", "has", "have", "him", "his", "how", "its", "may", "new", "now", "old", "see", "two",
+	"who", "did", "get", "got", "let", "say", "she", "too", "use", "that", "this", "with", "they",
+	"from", "what", "when", "where", "which", "there", "their", "them", "then", "than", "these",
+	"those", "will", "would", "could", "should", "about", "into", "just", "like", "also", "some",
+	"been", "were", "your", "yours", "more", "very", "much", "because", "really", "yeah", "okay",
+	"well", "know", "think", "going", "gonna", "want", "thing", "things", "here", "only", "over",
+	"such", "being", "does", "doing", "each", "other", "after", "before", "while", "again", "don't",
+	"it's", "i'm", "that's", "we're", "you're", "they're", "there's", "can't", "didn't", "i've",
+	// Ukrainian
+	"але", "або", "так", "для", "від", "без", "під", "над", "при", "про", "через", "щоб", "що",
+	"який", "яка", "яке", "які", "цей", "ця", "це", "ці", "той", "та", "те", "ті", "вже", "ще",
+	"тут", "там", "коли", "якщо", "тому", "тоді", "теж", "також", "дуже", "його", "її", "їх",
+	"він", "вона", "воно", "вони", "ми", "ви", "нас", "вас", "мене", "тебе", "мені", "тобі",
+	"бути", "був", "була", "було", "були", "буде", "будуть", "є", "ну", "просто", "може", "можна",
+	"треба", "нам", "вам", "їм", "свій", "своє", "свої", "своя", "цього", "цьому", "того", "тих",
+	// Russian
+	"как", "это", "что", "все", "они", "она", "оно", "его", "если", "уже", "еще", "ещё", "или",
+	"когда", "даже", "тоже", "только", "было", "была", "были", "будет", "есть", "так", "вот",
+	"чтобы", "этот", "эта", "эти", "того", "тут", "там", "нет", "для", "при", "без", "над",
+)
+
+func toSet(words ...string) map[string]struct{} {
+	set := make(map[string]struct{}, len(words))
+	for _, w := range words {
+		set[w] = struct{}{}
+	}
+	return set
+}
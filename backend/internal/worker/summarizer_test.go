@@ -0,0 +1,150 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// timedWords lays out the sentences every step seconds from start, with the
+// words of each sentence 0.3s apart.
+func timedWords(start, step float64, sentences ...string) []TranscriptWord {
+	var words []TranscriptWord
+	for i, sentence := range sentences {
+		at := start + float64(i)*step
+		for _, w := range strings.Fields(sentence) {
+			words = append(words, TranscriptWord{Word: w, Start: at, End: at + 0.25})
+			at += 0.3
+		}
+	}
+	return words
+}
+
+func repeatSentences(n int, sentences ...string) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = sentences[i%len(sentences)]
+	}
+	return out
+}
+
+func TestExtractiveSummarizerChaptersAtPausesAndTopicShifts(t *testing.T) {
+	coffee := repeatSentences(18,
+		"Roasting coffee beans needs heat and patience.",
+		"Light roasting keeps coffee acidity bright.",
+		"Dark roasting brings chocolate notes to coffee.",
+	)
+	marathon := repeatSentences(15,
+		"Marathon training starts with slow running.",
+		"Running long distances builds marathon endurance.",
+		"Marathon runners plan running shoes carefully.",
+	)
+	var words []TranscriptWord
+	words = append(words, timedWords(0, 3, coffee...)...)
+	words = append(words, timedWords(54, 3, marathon...)...)
+	// A long pause splits the marathon topic even though the vocabulary stays.
+	words = append(words, timedWords(110, 3, marathon[:3]...)...)
+	transcript := &TranscriptResult{Words: words}
+
+	summary, err := (&ExtractiveSummarizer{}).Summarize(context.Background(), transcript)
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+
+	if len(summary.Chapters) != 3 {
+		t.Fatalf("expected 3 chapters, got %#v", summary.Chapters)
+	}
+	if summary.Chapters[0].Start != 0 || !strings.Contains(summary.Chapters[0].Title, "offee") {
+		t.Fatalf("expected coffee chapter at 0s, got %#v", summary.Chapters[0])
+	}
+	if summary.Chapters[1].Start != 54 || !strings.Contains(summary.Chapters[1].Title, "arathon") {
+		t.Fatalf("expected marathon chapter at 54s, got %#v", summary.Chapters[1])
+	}
+	if summary.Chapters[2].Start != 110 {
+		t.Fatalf("expected pause chapter at 110s, got %#v", summary.Chapters[2])
+	}
+	if summary.Chapters[0].End != summary.Chapters[1].Start || summary.Chapters[1].End != summary.Chapters[2].Start {
+		t.Fatalf("expected contiguous chapters, got %#v", summary.Chapters)
+	}
+	keywords := strings.Join(summary.Keywords, ",")
+	if !strings.Contains(keywords, "coffee") || !strings.Contains(keywords, "marathon") {
+		t.Fatalf("expected coffee and marathon keywords, got %v", summary.Keywords)
+	}
+	if summary.PreviewSentence == "" || utf8.RuneCountInString(summary.PreviewSentence) > previewMaxRunes {
+		t.Fatalf("unexpected preview %q", summary.PreviewSentence)
+	}
+	if summary.TLDR == "" {
+		t.Fatal("expected a TL;DR")
+	}
+}
+
+func TestExtractiveSummarizerUsesCorpusFrequencies(t *testing.T) {
+	transcript := &TranscriptResult{Text: "Podcast podcast podcast about Kyiv. Kyiv trams are green."}
+	corpus := fakeCorpus{docs: 100, df: map[string]int{"podcast": 95, "kyiv": 2}}
+
+	summary, err := (&ExtractiveSummarizer{Corpus: corpus}).Summarize(context.Background(), transcript)
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	if summary.Keywords[0] != "kyiv" {
+		t.Fatalf("expected rare term to rank first, got %v", summary.Keywords)
+	}
+	if len(summary.Chapters) != 0 {
+		t.Fatalf("expected no chapters without timings, got %#v", summary.Chapters)
+	}
+	if strings.Join(summary.Terms, ",") != "green,kyiv,podcast,trams" {
+		t.Fatalf("unexpected terms %v", summary.Terms)
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	long := strings.Repeat("слово ", 40)
+	got := truncateRunes(long, previewMaxRunes)
+	if utf8.RuneCountInString(got) > previewMaxRunes || !strings.HasSuffix(got, "слово…") {
+		t.Fatalf("unexpected truncation %q", got)
+	}
+	if truncateRunes("short", previewMaxRunes) != "short" {
+		t.Fatal("short text should be unchanged")
+	}
+}
+
+type fakeCorpus struct {
+	docs int
+	df   map[string]int
+}
+
+func (c fakeCorpus) DocumentFrequencies(context.Context, []string) (int, map[string]int, error) {
+	return c.docs, c.df, nil
+}
+
+func TestSQLTermCorpusCachesDocumentCount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	corpus := &SQLTermCorpus{DB: db}
+	mock.ExpectQuery(`SELECT COUNT\(DISTINCT audio_id\) FROM transcript_terms`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(40))
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(`SELECT term, COUNT\(\*\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"term", "count"}).AddRow("river", 3))
+	}
+
+	for i := 0; i < 2; i++ {
+		docs, df, err := corpus.DocumentFrequencies(context.Background(), []string{"river"})
+		if err != nil {
+			t.Fatalf("frequencies: %v", err)
+		}
+		if docs != 40 || df["river"] != 3 {
+			t.Fatalf("unexpected frequencies %d %v", docs, df)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
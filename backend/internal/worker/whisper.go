@@ -0,0 +1,383 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/amunx/backend/internal/storage"
+)
+
+// ErrWhisperNotConfigured is returned when the whisper binary or model is missing.
+var ErrWhisperNotConfigured = errors.New("whisper binary and model must be configured")
+
+const (
+	whisperSampleRate   = 16000
+	wavBytesPerSecond   = whisperSampleRate * 2 // 16-bit mono PCM
+	defaultChunkSeconds = 600
+	silenceNoiseFloor   = "-35dB"
+	silenceMinDuration  = 0.4
+)
+
+// WhisperConfig configures the local whisper.cpp transcriber.
+type WhisperConfig struct {
+	BinaryPath   string
+	ModelPath    string
+	Language     string // "auto" lets whisper detect the spoken language
+	Threads      int
+	ChunkSeconds int // upper bound for a single whisper invocation
+	TempDir      string
+	FFmpegPath   string
+}
+
+// WhisperTranscriber shells out to a whisper.cpp compatible CLI. Long
+// recordings are split on silence so each invocation stays bounded.
+type WhisperTranscriber struct {
+	cfg     WhisperConfig
+	storage storage.Client
+	http    *http.Client
+	logger  zerolog.Logger
+}
+
+// NewWhisperTranscriber validates the config and returns a transcriber.
+// Sources that are neither URLs nor local files are fetched from store.
+func NewWhisperTranscriber(cfg WhisperConfig, store storage.Client, logger zerolog.Logger) (*WhisperTranscriber, error) {
+	if cfg.BinaryPath == "" || cfg.ModelPath == "" {
+		return nil, ErrWhisperNotConfigured
+	}
+	if _, err := os.Stat(cfg.ModelPath); err != nil {
+		return nil, fmt.Errorf("whisper model: %w", err)
+	}
+	if cfg.Language == "" {
+		cfg.Language = "auto"
+	}
+	if cfg.Threads <= 0 {
+		cfg.Threads = 4
+	}
+	if cfg.ChunkSeconds <= 0 {
+		cfg.ChunkSeconds = defaultChunkSeconds
+	}
+	if cfg.TempDir == "" {
+		cfg.TempDir = os.TempDir()
+	}
+	if cfg.FFmpegPath == "" {
+		cfg.FFmpegPath = "ffmpeg"
+	}
+	return &WhisperTranscriber{
+		cfg:     cfg,
+		storage: store,
+		http:    &http.Client{Timeout: 5 * time.Minute},
+		logger:  logger,
+	}, nil
+}
+
+// Transcribe converts the source to 16 kHz mono PCM, chunks it on silence and
+// merges the per-chunk word timings into a single transcript.
+func (t *WhisperTranscriber) Transcribe(ctx context.Context, audioURL string) (*TranscriptResult, error) {
+	if err := os.MkdirAll(t.cfg.TempDir, 0o755); err != nil {
+		return nil, err
+	}
+	workDir, err := os.MkdirTemp(t.cfg.TempDir, "stt-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	input, err := t.localize(ctx, audioURL, workDir)
+	if err != nil {
+		return nil, fmt.Errorf("fetch audio: %w", err)
+	}
+
+	wavPath := filepath.Join(workDir, "audio.wav")
+	if err := t.ffmpeg(ctx, "-y", "-i", input, "-ar", strconv.Itoa(whisperSampleRate), "-ac", "1", "-c:a", "pcm_s16le", wavPath); err != nil {
+		return nil, fmt.Errorf("decode audio: %w", err)
+	}
+	info, err := os.Stat(wavPath)
+	if err != nil {
+		return nil, err
+	}
+	duration := float64(info.Size()-44) / wavBytesPerSecond
+
+	silences, err := t.detectSilence(ctx, wavPath)
+	if err != nil {
+		return nil, fmt.Errorf("detect silence: %w", err)
+	}
+	spans := planChunks(duration, silences, float64(t.cfg.ChunkSeconds))
+
+	parts := make([]chunkTranscript, 0, len(spans))
+	for i, span := range spans {
+		chunkPath := wavPath
+		if len(spans) > 1 {
+			chunkPath = filepath.Join(workDir, fmt.Sprintf("chunk-%03d.wav", i))
+			if err := t.ffmpeg(ctx, "-y", "-i", wavPath,
+				"-ss", formatSeconds(span.Start),
+				"-to", formatSeconds(span.End),
+				"-c", "copy", chunkPath); err != nil {
+				return nil, fmt.Errorf("cut chunk %d: %w", i, err)
+			}
+		}
+		out, err := t.runWhisper(ctx, chunkPath, filepath.Join(workDir, fmt.Sprintf("chunk-%03d", i)))
+		if err != nil {
+			return nil, fmt.Errorf("whisper chunk %d: %w", i, err)
+		}
+		parts = append(parts, chunkTranscript{Offset: span.Start, Output: out})
+	}
+
+	t.logger.Debug().
+		Float64("duration_sec", duration).
+		Int("chunks", len(spans)).
+		Msg("whisper transcription finished")
+
+	return mergeChunks(parts), nil
+}
+
+// localize makes the source available as a local file inside workDir.
+func (t *WhisperTranscriber) localize(ctx context.Context, source, workDir string) (string, error) {
+	if _, err := os.Stat(source); err == nil {
+		return source, nil
+	}
+
+	var body io.ReadCloser
+	switch {
+	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := t.http.Do(req)
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode >= 400 {
+			resp.Body.Close()
+			return "", fmt.Errorf("download %s: status %d", source, resp.StatusCode)
+		}
+		body = resp.Body
+	case t.storage != nil:
+		reader, err := t.storage.GetObject(ctx, source)
+		if err != nil {
+			return "", err
+		}
+		body = reader
+	default:
+		return "", fmt.Errorf("cannot resolve audio source %q", source)
+	}
+	defer body.Close()
+
+	dest := filepath.Join(workDir, "source")
+	out, err := os.Create(dest)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, body); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+func (t *WhisperTranscriber) ffmpeg(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, t.cfg.FFmpegPath, append([]string{"-hide_banner", "-loglevel", "error"}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (t *WhisperTranscriber) detectSilence(ctx context.Context, wavPath string) ([]silenceSpan, error) {
+	filter := fmt.Sprintf("silencedetect=noise=%s:d=%s", silenceNoiseFloor, formatSeconds(silenceMinDuration))
+	cmd := exec.CommandContext(ctx, t.cfg.FFmpegPath, "-hide_banner", "-nostats", "-i", wavPath, "-af", filter, "-f", "null", "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseSilences(stderr.String()), nil
+}
+
+func (t *WhisperTranscriber) runWhisper(ctx context.Context, wavPath, outBase string) (whisperOutput, error) {
+	args := []string{
+		"-m", t.cfg.ModelPath,
+		"-f", wavPath,
+		"-l", t.cfg.Language,
+		"-t", strconv.Itoa(t.cfg.Threads),
+		"-ml", "1", // one segment per word
+		"-sow",
+		"-oj",
+		"-of", outBase,
+		"-np",
+	}
+	cmd := exec.CommandContext(ctx, t.cfg.BinaryPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return whisperOutput{}, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	data, err := os.ReadFile(outBase + ".json")
+	if err != nil {
+		return whisperOutput{}, err
+	}
+	var out whisperOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return whisperOutput{}, fmt.Errorf("decode whisper output: %w", err)
+	}
+	return out, nil
+}
+
+// whisperOutput mirrors the JSON written by whisper.cpp with -oj.
+type whisperOutput struct {
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []struct {
+		Offsets struct {
+			From int64 `json:"from"`
+			To   int64 `json:"to"`
+		} `json:"offsets"`
+		Text string `json:"text"`
+	} `json:"transcription"`
+}
+
+type chunkTranscript struct {
+	Offset float64
+	Output whisperOutput
+}
+
+type silenceSpan struct {
+	Start float64
+	End   float64
+}
+
+type chunkSpan struct {
+	Start float64
+	End   float64
+}
+
+var silenceLine = regexp.MustCompile(`silence_(start|end): (-?[0-9.]+)`)
+
+// parseSilences extracts silence spans from ffmpeg silencedetect output.
+func parseSilences(output string) []silenceSpan {
+	var (
+		spans   []silenceSpan
+		start   float64
+		pending bool
+	)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		match := silenceLine.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		value, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			continue
+		}
+		switch match[1] {
+		case "start":
+			start = value
+			if start < 0 {
+				start = 0
+			}
+			pending = true
+		case "end":
+			if pending {
+				spans = append(spans, silenceSpan{Start: start, End: value})
+				pending = false
+			}
+		}
+	}
+	return spans
+}
+
+// planChunks splits [0, duration) into spans no longer than maxLen, cutting at
+// the latest silence midpoint in the second half of each window. Windows
+// without silence are cut hard at maxLen.
+func planChunks(duration float64, silences []silenceSpan, maxLen float64) []chunkSpan {
+	if duration <= 0 {
+		return []chunkSpan{{Start: 0, End: 0}}
+	}
+	var chunks []chunkSpan
+	start := 0.0
+	for duration-start > maxLen {
+		limit := start + maxLen
+		cut := limit
+		for _, s := range silences {
+			mid := (s.Start + s.End) / 2
+			if mid > start+maxLen/2 && mid <= limit {
+				cut = mid
+			}
+		}
+		chunks = append(chunks, chunkSpan{Start: start, End: cut})
+		start = cut
+	}
+	return append(chunks, chunkSpan{Start: start, End: duration})
+}
+
+// mergeChunks shifts chunk-local word timings by each chunk offset and picks
+// the language detected for the largest share of words.
+func mergeChunks(parts []chunkTranscript) *TranscriptResult {
+	result := &TranscriptResult{Words: []TranscriptWord{}}
+	langWords := make(map[string]int)
+	texts := make([]string, 0)
+
+	for _, part := range parts {
+		count := 0
+		for _, seg := range part.Output.Transcription {
+			word := strings.TrimSpace(seg.Text)
+			if word == "" || isWhisperAnnotation(word) {
+				continue
+			}
+			result.Words = append(result.Words, TranscriptWord{
+				Word:  word,
+				Start: roundMillis(part.Offset + float64(seg.Offsets.From)/1000),
+				End:   roundMillis(part.Offset + float64(seg.Offsets.To)/1000),
+			})
+			texts = append(texts, word)
+			count++
+		}
+		if lang := strings.TrimSpace(part.Output.Result.Language); lang != "" {
+			langWords[lang] += count
+		}
+	}
+
+	best := -1
+	for lang, count := range langWords {
+		if count > best || (count == best && lang < result.Lang) {
+			result.Lang = lang
+			best = count
+		}
+	}
+	result.Text = strings.Join(texts, " ")
+	return result
+}
+
+// isWhisperAnnotation reports non-speech markers such as [BLANK_AUDIO] or (music).
+func isWhisperAnnotation(word string) bool {
+	return (strings.HasPrefix(word, "[") && strings.HasSuffix(word, "]")) ||
+		(strings.HasPrefix(word, "(") && strings.HasSuffix(word, ")"))
+}
+
+func roundMillis(v float64) float64 {
+	return float64(int64(v*1000+0.5)) / 1000
+}
+
+func formatSeconds(v float64) string {
+	return strconv.FormatFloat(v, 'f', 3, 64)
+}
@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseSilences(t *testing.T) {
+	output := `[silencedetect @ 0x1] silence_start: -0.01
+[silencedetect @ 0x1] silence_end: 1.2 | silence_duration: 1.21
+size=N/A time=00:00:10.00 bitrate=N/A
+[silencedetect @ 0x1] silence_start: 5.5
+[silencedetect @ 0x1] silence_end: 6.25 | silence_duration: 0.75
+[silencedetect @ 0x1] silence_start: 9.8`
+
+	got := parseSilences(output)
+	want := []silenceSpan{{Start: 0, End: 1.2}, {Start: 5.5, End: 6.25}}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d spans, got %#v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("span %d: expected %#v, got %#v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestPlanChunksCutsOnSilence(t *testing.T) {
+	silences := []silenceSpan{
+		{Start: 2, End: 3},     // too early in the first window
+		{Start: 7, End: 8},     // latest in the first window, cut at 7.5
+		{Start: 14, End: 14.4}, // in the second window, cut at 14.2
+	}
+	got := planChunks(25, silences, 10)
+	want := []chunkSpan{{Start: 0, End: 7.5}, {Start: 7.5, End: 14.2}, {Start: 14.2, End: 24.2}, {Start: 24.2, End: 25}}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d chunks, got %#v", len(want), got)
+	}
+	for i := range want {
+		if roundMillis(got[i].Start) != want[i].Start || roundMillis(got[i].End) != want[i].End {
+			t.Fatalf("chunk %d: expected %#v, got %#v", i, want[i], got[i])
+		}
+	}
+
+	if short := planChunks(4, nil, 10); len(short) != 1 || short[0].End != 4 {
+		t.Fatalf("expected a single chunk for short audio, got %#v", short)
+	}
+}
+
+func TestMergeChunksOffsetsWordsAndPicksLanguage(t *testing.T) {
+	decode := func(raw string) whisperOutput {
+		var out whisperOutput
+		if err := json.Unmarshal([]byte(raw), &out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return out
+	}
+	first := decode(`{"result":{"language":"uk"},"transcription":[
+		{"offsets":{"from":0,"to":400},"text":" привіт"},
+		{"offsets":{"from":400,"to":900},"text":" світ"},
+		{"offsets":{"from":900,"to":1000},"text":" [BLANK_AUDIO]"}]}`)
+	second := decode(`{"result":{"language":"en"},"transcription":[
+		{"offsets":{"from":100,"to":500},"text":" hello"},
+		{"offsets":{"from":500,"to":520},"text":" "}]}`)
+
+	result := mergeChunks([]chunkTranscript{{Offset: 0, Output: first}, {Offset: 30, Output: second}})
+
+	if result.Text != "привіт світ hello" {
+		t.Fatalf("unexpected text %q", result.Text)
+	}
+	if result.Lang != "uk" {
+		t.Fatalf("expected majority language uk, got %q", result.Lang)
+	}
+	if len(result.Words) != 3 {
+		t.Fatalf("expected 3 words, got %#v", result.Words)
+	}
+	if w := result.Words[2]; w.Start != 30.1 || w.End != 30.5 {
+		t.Fatalf("expected offset timing for second chunk, got %#v", w)
+	}
+}
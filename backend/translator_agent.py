@@ -7,6 +7,8 @@ Real-time ASR → MT → TTS pipeline with <2-3s e2e latency
 import os
 import asyncio
 import json
+import time
+import uuid
 from typing import Dict, List
 import httpx
 
@@ -23,7 +25,8 @@ API_BASE_URL = os.getenv("API_BASE_URL", "http://localhost:8080")
 SOURCE_LANG = os.getenv("SOURCE_LANG", "uk-UA")
 TARGET_LANGS = os.getenv("TARGET_LANGS", "en,pl").split(",")
 TRANSLATE_MIN_LISTENERS = int(os.getenv("TRANSLATE_MIN_LISTENERS", "1"))
-BUDGET_MAX_COST_PER_HOUR_USD = float(os.getenv("BUDGET_MAX_COST_PER_HOUR_USD", "5.0"))
+# Shared secret for reporting usage; the API enforces BUDGET_MAX_COST_PER_HOUR_USD.
+LIVE_AGENT_TOKEN = os.getenv("LIVE_AGENT_TOKEN", "")
 
 # Voice mapping for TTS
 VOICE_MAP = {
@@ -42,7 +45,13 @@ class TranslatorAgent:
         self.session_id: str = None
         self.translation_enabled = False
         self.target_langs: List[str] = []
-        self.cost_usd = 0.0
+        # Spend not yet reported to the API.
+        self.pending_cost_usd = 0.0
+        self.pending_audio_seconds = 0.0
+        # Final caption segments not yet stored by the API.
+        self.pending_captions: List[dict] = []
+        self.host_identity: str = ""
+        self.caption_lock = asyncio.Lock()
 
     async def setup_providers(self):
         """Initialize ASR, MT, TTS providers"""
@@ -88,13 +97,97 @@ class TranslatorAgent:
                     timeout=5.0,
                 )
                 if resp.status_code == 200:
-                    data = resp.json()
-                    self.translation_enabled = data.get("enabled", False)
-                    self.target_langs = data.get("target_langs", TARGET_LANGS)
-                    print(f"Translation config: enabled={self.translation_enabled}, langs={self.target_langs}")
+                    self.apply_config(resp.json())
         except Exception as e:
             print(f"Failed to fetch translation config: {e}")
 
+    def apply_config(self, data: dict):
+        enabled = data.get("enabled", False)
+        if self.translation_enabled and not enabled and data.get("disabled_reason") == "budget":
+            print("⚠️ Translation budget exceeded")
+            asyncio.create_task(self.room.local_participant.publish_data(
+                json.dumps({"type": "translate.guard", "reason": "budget"}).encode("utf-8"),
+                reliable=True,
+            ))
+        self.translation_enabled = enabled
+        self.target_langs = data.get("target_langs") or []
+        print(f"Translation config: enabled={self.translation_enabled}, langs={self.target_langs}")
+
+    async def report_usage(self):
+        """Report spend since the last report; the API answers with the
+        config, turning translation off once the hourly budget is spent."""
+        if not self.session_id or not LIVE_AGENT_TOKEN:
+            return
+        if self.pending_cost_usd == 0 and self.pending_audio_seconds == 0:
+            return
+
+        cost, seconds = self.pending_cost_usd, self.pending_audio_seconds
+        report = {
+            "report_id": str(uuid.uuid4()),
+            "cost_usd": round(cost, 6),
+            "audio_seconds": int(seconds),
+        }
+        for _ in range(3):
+            try:
+                async with httpx.AsyncClient() as client:
+                    resp = await client.post(
+                        f"{API_BASE_URL}/v1/live/agent/sessions/{self.session_id}/translate/usage",
+                        headers={"Authorization": f"Bearer {LIVE_AGENT_TOKEN}"},
+                        json=report,
+                        timeout=5.0,
+                    )
+                if resp.status_code == 200:
+                    self.pending_cost_usd -= cost
+                    self.pending_audio_seconds -= int(seconds)
+                    self.apply_config(resp.json())
+                    return
+                print(f"Usage report rejected: {resp.status_code}")
+                return
+            except Exception as e:
+                # Retrying with the same report_id is safe.
+                print(f"Failed to report usage: {e}")
+
+    def add_caption(self, text: str, started_at: float, ended_at: float):
+        """Queue a final source-language segment for the replay transcript."""
+        def iso(ts: float) -> str:
+            return time.strftime("%Y-%m-%dT%H:%M:%S", time.gmtime(ts)) + f".{int(ts % 1 * 1000):03d}Z"
+
+        self.pending_captions.append({
+            "segment_id": str(uuid.uuid4()),
+            "speaker": self.host_identity,
+            "lang": SOURCE_LANG[:2],
+            "started_at": iso(started_at),
+            "ended_at": iso(max(ended_at, started_at)),
+            "text": text,
+        })
+
+    async def flush_captions(self):
+        """Send queued segments; the API fans them out to listeners and
+        keeps them for the replay. Failed batches are resent as is."""
+        if not self.session_id or not LIVE_AGENT_TOKEN:
+            return
+
+        async with self.caption_lock:
+            batch = self.pending_captions[:50]
+            if not batch:
+                return
+            try:
+                async with httpx.AsyncClient() as client:
+                    resp = await client.post(
+                        f"{API_BASE_URL}/v1/live/agent/sessions/{self.session_id}/captions",
+                        headers={"Authorization": f"Bearer {LIVE_AGENT_TOKEN}"},
+                        json={"segments": batch},
+                        timeout=5.0,
+                    )
+                if resp.status_code in (200, 400, 404):
+                    # Drop bad batches and those of ended sessions rather
+                    # than resending them forever.
+                    del self.pending_captions[:len(batch)]
+                else:
+                    print(f"Caption upload rejected: {resp.status_code}")
+            except Exception as e:
+                print(f"Failed to upload captions: {e}")
+
     async def translate_text(self, text: str, target_lang: str) -> str:
         """Translate text using configured MT provider"""
         if MT_PROVIDER == "azure":
@@ -193,8 +286,10 @@ class TranslatorAgent:
         stream = self.stt.stream()
         audio_stream = rtc.AudioStream(track)
 
+        segment_started = None
         async for event in stream:
             if event.type == SpeechEventType.INTERIM_TRANSCRIPT:
+                segment_started = segment_started or time.time()
                 text = event.alternatives[0].text
                 # Send partial captions (original language)
                 await self.send_captions(text, SOURCE_LANG[:2], is_final=False)
@@ -208,6 +303,9 @@ class TranslatorAgent:
             elif event.type == SpeechEventType.FINAL_TRANSCRIPT:
                 text = event.alternatives[0].text
                 print(f"Final transcript: {text}")
+                self.add_caption(text, segment_started or time.time(), time.time())
+                segment_started = None
+                asyncio.create_task(self.flush_captions())
 
                 # Send final captions (original)
                 await self.send_captions(text, SOURCE_LANG[:2], is_final=True)
@@ -224,25 +322,18 @@ class TranslatorAgent:
                             if tts:
                                 async for audio_chunk in tts.synthesize(translated):
                                     await self.publish_dub_audio(audio_chunk.data, lang)
-                                    self.cost_usd += 0.002  # Approx $2/1M chars
+                                    self.pending_cost_usd += 0.002  # Approx $2/1M chars
+                                    self.pending_audio_seconds += len(audio_chunk.data) / 2 / 24000
                         except Exception as e:
                             print(f"TTS error for {lang}: {e}")
 
-                # Budget guard
-                if self.cost_usd > BUDGET_MAX_COST_PER_HOUR_USD:
-                    print(f"⚠️ Budget exceeded: ${self.cost_usd:.2f}")
-                    self.translation_enabled = False
-                    await self.room.local_participant.publish_data(
-                        json.dumps({"type": "translate.guard", "reason": "budget"}).encode("utf-8"),
-                        reliable=True,
-                    )
-
 async def entrypoint(ctx: JobContext):
     """Main agent entrypoint"""
     print(f"Agent starting for room: {ctx.room.name}")
 
     agent = TranslatorAgent(ctx)
-    agent.session_id = ctx.room.name.replace("moweton_", "")
+    # Rooms are named live-<session id>.
+    agent.session_id = ctx.room.name.removeprefix("live-")
 
     # Connect to room
     agent.room = await ctx.connect(auto_subscribe=AutoSubscribe.SUBSCRIBE_ALL)
@@ -254,10 +345,12 @@ async def entrypoint(ctx: JobContext):
     # Check translation config from API
     await agent.check_translation_config()
 
-    # Poll config every 10s
+    # Report usage and poll config every 10s
     async def poll_config():
         while True:
             await asyncio.sleep(10)
+            await agent.report_usage()
+            await agent.flush_captions()
             await agent.check_translation_config()
 
     asyncio.create_task(poll_config())
@@ -266,7 +359,8 @@ async def entrypoint(ctx: JobContext):
     @agent.room.on("track_subscribed")
     def on_track_subscribed(track: rtc.Track, publication: rtc.TrackPublication, participant: rtc.RemoteParticipant):
         if isinstance(track, rtc.RemoteAudioTrack) and publication.source == rtc.TrackSource.SOURCE_MICROPHONE:
-            if participant.identity.startswith("host_"):
+            if participant.attributes.get("role") == "host":
+                agent.host_identity = participant.identity
                 print(f"Host track found: {track.sid}")
                 asyncio.create_task(agent.process_host_track(track))
 